@@ -32,6 +32,10 @@ type AutoscalingListenerSpec struct {
 	// Required
 	RunnerScaleSetId int `json:"runnerScaleSetId,omitempty"`
 
+	// RunnerScaleSetName is the name of the runner scale set on GitHub.
+	// +optional
+	RunnerScaleSetName string `json:"runnerScaleSetName,omitempty"`
+
 	// Required
 	AutoscalingRunnerSetNamespace string `json:"autoscalingRunnerSetNamespace,omitempty"`
 
@@ -57,10 +61,33 @@ type AutoscalingListenerSpec struct {
 
 	// +optional
 	Proxy *ProxyConfig `json:"proxy,omitempty"`
+
+	// +optional
+	SessionBackoffMax *metav1.Duration `json:"sessionBackoffMax,omitempty"`
+
+	// +optional
+	StaleSessionTimeout *metav1.Duration `json:"staleSessionTimeout,omitempty"`
+
+	// +optional
+	Cordoned bool `json:"cordoned,omitempty"`
+
+	// +optional
+	PreferredLabels []string `json:"preferredLabels,omitempty"`
 }
 
 // AutoscalingListenerStatus defines the observed state of AutoscalingListener
-type AutoscalingListenerStatus struct{}
+type AutoscalingListenerStatus struct {
+	// Conditions represent the latest available observations of the AutoscalingListener's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// AutoscalingListenerConditionScaleSetRegistered is True once the controller confirmed with GitHub that the runner scale set
+// of the AutoscalingListener is registered. The listener pod is not created before, unless the registration can't be confirmed
+// within the configured number of retries. The condition is only set when the controller checks the registration.
+const AutoscalingListenerConditionScaleSetRegistered = "ScaleSetRegistered"
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
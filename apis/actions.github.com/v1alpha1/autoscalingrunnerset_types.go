@@ -17,7 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -69,6 +71,20 @@ type AutoscalingRunnerSetSpec struct {
 	// Required
 	Template corev1.PodTemplateSpec `json:"template,omitempty"`
 
+	// PriorityClassName is the priority class of the runner pods whose template doesn't set one.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// ImagePullSecrets are merged into the image pull secrets of the template of the runner pods,
+	// skipping the secrets the template already references by name.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// RunnerImageOverride replaces the image of the "runner" container of the template of the runner pods,
+	// e.g. to canary a new runner image on a single runner set.
+	// +optional
+	RunnerImageOverride string `json:"runnerImageOverride,omitempty"`
+
 	// +optional
 	// +kubebuilder:validation:Minimum:=0
 	MaxRunners *int `json:"maxRunners,omitempty"`
@@ -76,6 +92,63 @@ type AutoscalingRunnerSetSpec struct {
 	// +optional
 	// +kubebuilder:validation:Minimum:=0
 	MinRunners *int `json:"minRunners,omitempty"`
+
+	// DrainOnDelete keeps EphemeralRunner resources assigned to a job alive when the AutoscalingRunnerSet
+	// is deleted, until their jobs finish or the DrainTimeout elapses. No new jobs are acquired while draining.
+	// +optional
+	DrainOnDelete bool `json:"drainOnDelete,omitempty"`
+
+	// DrainTimeout is the maximum duration to wait for running jobs when DrainOnDelete is set,
+	// after which the remaining EphemeralRunner resources are deleted. Defaults to 1h.
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
+
+	// ListenerSessionBackoffMax caps the exponential backoff the listener uses to re-create its
+	// message session after it was lost. Defaults to 5m.
+	// +optional
+	ListenerSessionBackoffMax *metav1.Duration `json:"listenerSessionBackoffMax,omitempty"`
+
+	// ListenerStaleSessionTimeout is how long the listener waits for a message or a keepalive before
+	// it tears down its message session and creates a new one. Stale sessions are not detected when unset.
+	// +optional
+	ListenerStaleSessionTimeout *metav1.Duration `json:"listenerStaleSessionTimeout,omitempty"`
+
+	// Paused stops the AutoscalingRunnerSet from acquiring new jobs and scales its EphemeralRunnerSet
+	// down to zero, without deleting it. Runners already assigned to a job finish it.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Cordoned stops the AutoscalingRunnerSet from acquiring new jobs, without scaling its EphemeralRunnerSet down to zero.
+	// Runners stay registered so the jobs they were assigned finish, which allows draining the nodes one by one.
+	// +optional
+	Cordoned bool `json:"cordoned,omitempty"`
+
+	// ListenerPreferredLabels makes the listener acquire the available jobs requesting the most of these labels first,
+	// e.g. while migrating the runners of the scale set to new labels. GitHub still routes the jobs to the scale set
+	// by its labels and assigns them to its runners, which all share the labels of the scale set, so the listener only
+	// orders the acquisition and never skips a job. Jobs are acquired in the order they are received when not set.
+	// +optional
+	ListenerPreferredLabels []string `json:"listenerPreferredLabels,omitempty"`
+
+	// ResourceLabels are merged onto the labels of the EphemeralRunnerSet, EphemeralRunner and runner pod resources
+	// of the AutoscalingRunnerSet. Labels set by the controller can't be overridden.
+	// +optional
+	ResourceLabels map[string]string `json:"resourceLabels,omitempty"`
+
+	// ResourceAnnotations are merged onto the annotations of the EphemeralRunnerSet, EphemeralRunner and runner pod resources
+	// of the AutoscalingRunnerSet. Annotations set by the controller can't be overridden.
+	// +optional
+	ResourceAnnotations map[string]string `json:"resourceAnnotations,omitempty"`
+
+	// CreateServiceAccount creates a ServiceAccount owned by the AutoscalingRunnerSet and runs the runner pods under it.
+	// Nothing is created when the template sets a serviceAccountName.
+	// +optional
+	CreateServiceAccount bool `json:"createServiceAccount,omitempty"`
+
+	// ServiceAccountAnnotations are the annotations of the ServiceAccount created by CreateServiceAccount,
+	// e.g. to bind it to a cloud provider identity.
+	// +optional
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
 }
 
 type GitHubServerTLSConfig struct {
@@ -92,9 +165,46 @@ type ProxyConfig struct {
 
 	// +optional
 	NoProxy []string `json:"noProxy,omitempty"`
+
+	// CACertificateSecretRef is the name of a secret holding a PEM encoded
+	// CA bundle under the "ca.crt" key, used to verify TLS connections made
+	// through the proxy. The system pool is used when unset.
+	// +optional
+	CACertificateSecretRef string `json:"caCertificateSecretRef,omitempty"`
+
+	// InjectEnv adds the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, and their lower case
+	// variants, to every container of the runner pods, for sidecars and tools that don't use the runner's proxy settings.
+	// Containers defining one of the variables keep their value. The values are read from the proxy secret,
+	// so proxy credentials are not written to the pod spec.
+	// +optional
+	InjectEnv bool `json:"injectEnv,omitempty"`
+
+	// InheritFromController uses the proxy settings of the controller, read from its HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY environment variables or their lower case variants, when HTTP, HTTPS and NoProxy are all unset.
+	// Explicit settings always take precedence: once any of HTTP, HTTPS or NoProxy is set, the environment
+	// of the controller is ignored entirely. CACertificateSecretRef and InjectEnv apply in both cases.
+	// The inherited settings, including credentials in the proxy URLs, are only written to the proxy secrets.
+	// +optional
+	InheritFromController bool `json:"inheritFromController,omitempty"`
+}
+
+// InheritsFromController reports whether the proxy settings are read from the environment of the controller.
+func (c *ProxyConfig) InheritsFromController() bool {
+	return c.InheritFromController && c.HTTP == nil && c.HTTPS == nil && len(c.NoProxy) == 0
 }
 
+// controllerProxyEnvironment returns the proxy settings of the controller process.
+var controllerProxyEnvironment = httpproxy.FromEnvironment
+
+// ProxyCACertificateKey is the secret key holding the proxy CA bundle, both in
+// the secret referenced by CACertificateSecretRef and in the proxy secret.
+const ProxyCACertificateKey = "ca.crt"
+
 func (c *ProxyConfig) toHTTPProxyConfig(secretFetcher func(string) (*corev1.Secret, error)) (*httpproxy.Config, error) {
+	if c.InheritsFromController() {
+		return controllerProxyEnvironment(), nil
+	}
+
 	config := &httpproxy.Config{
 		NoProxy: strings.Join(c.NoProxy, ","),
 	}
@@ -163,22 +273,147 @@ func (c *ProxyConfig) ToSecretData(secretFetcher func(string) (*corev1.Secret, e
 	data["https_proxy"] = []byte(config.HTTPSProxy)
 	data["no_proxy"] = []byte(config.NoProxy)
 
+	caCertificate, err := c.CACertificate(secretFetcher)
+	if err != nil {
+		return nil, err
+	}
+	if caCertificate != nil {
+		data[ProxyCACertificateKey] = caCertificate
+	}
+
 	return data, nil
 }
 
+// Validate checks that the proxy URLs are valid and that the referenced secrets exist
+// and contain the keys they are expected to.
+func (c *ProxyConfig) Validate(secretFetcher func(string) (*corev1.Secret, error)) error {
+	servers := []struct {
+		scheme string
+		server *ProxyServerConfig
+	}{
+		{"http", c.HTTP},
+		{"https", c.HTTPS},
+	}
+
+	for _, s := range servers {
+		if s.server == nil {
+			continue
+		}
+
+		u, err := url.Parse(s.server.Url)
+		if err != nil {
+			return fmt.Errorf("failed to parse proxy %s url %q: %w", s.scheme, s.server.Url, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("proxy %s url %q must contain a scheme and a host", s.scheme, s.server.Url)
+		}
+
+		if s.server.CredentialSecretRef == "" {
+			continue
+		}
+
+		secret, err := secretFetcher(s.server.CredentialSecretRef)
+		if err != nil {
+			return fmt.Errorf("failed to get secret %s for %s proxy: %w", s.server.CredentialSecretRef, s.scheme, err)
+		}
+
+		for _, key := range []string{"username", "password"} {
+			if len(secret.Data[key]) == 0 {
+				return fmt.Errorf("secret %s for %s proxy does not contain key %q", s.server.CredentialSecretRef, s.scheme, key)
+			}
+		}
+	}
+
+	if _, err := c.CACertificate(secretFetcher); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CACertificate returns the PEM encoded CA bundle referenced by
+// CACertificateSecretRef, or nil when no bundle is configured.
+func (c *ProxyConfig) CACertificate(secretFetcher func(string) (*corev1.Secret, error)) ([]byte, error) {
+	if c.CACertificateSecretRef == "" {
+		return nil, nil
+	}
+
+	secret, err := secretFetcher(c.CACertificateSecretRef)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to get secret %s for proxy ca certificate: %w",
+			c.CACertificateSecretRef,
+			err,
+		)
+	}
+
+	caCertificate, ok := secret.Data[ProxyCACertificateKey]
+	if !ok || len(caCertificate) == 0 {
+		return nil, fmt.Errorf("secret %s does not contain key %q", c.CACertificateSecretRef, ProxyCACertificateKey)
+	}
+
+	return caCertificate, nil
+}
+
 func (c *ProxyConfig) ProxyFunc(secretFetcher func(string) (*corev1.Secret, error)) (func(*http.Request) (*url.URL, error), error) {
 	config, err := c.toHTTPProxyConfig(secretFetcher)
 	if err != nil {
 		return nil, err
 	}
 
+	noProxyCIDRs := noProxyCIDRs(config.NoProxy)
+
 	proxyFunc := func(req *http.Request) (*url.URL, error) {
+		if resolvesToCIDR(req.Context(), req.URL.Hostname(), noProxyCIDRs) {
+			return nil, nil
+		}
+
 		return config.ProxyFunc()(req.URL)
 	}
 
 	return proxyFunc, nil
 }
 
+// noProxyCIDRs returns the entries of the comma separated no proxy list that are CIDR ranges.
+// httpproxy already matches them against IP literals, but not against
+// addresses that a hostname resolves to.
+func noProxyCIDRs(noProxy string) []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, entry := range strings.Split(noProxy, ",") {
+		_, cidr, err := net.ParseCIDR(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	return cidrs
+}
+
+// resolvesToCIDR reports whether the hostname resolves to an address within
+// one of the given CIDR ranges. Lookup failures are treated as no match so the
+// request falls back to the regular proxy selection.
+func resolvesToCIDR(ctx context.Context, host string, cidrs []*net.IPNet) bool {
+	if len(cidrs) == 0 || host == "" || net.ParseIP(host) != nil {
+		return false
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return false
+	}
+
+	for _, addr := range addrs {
+		for _, cidr := range cidrs {
+			if cidr.Contains(addr.IP) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 type ProxyServerConfig struct {
 	// Required
 	Url string `json:"url,omitempty"`
@@ -194,11 +429,95 @@ type AutoscalingRunnerSetStatus struct {
 
 	// +optional
 	State string `json:"state,omitempty"`
+
+	// Conditions represent the latest available observations of the AutoscalingRunnerSet's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// AutoscalingRunnerSetConditionPaused is True when the AutoscalingRunnerSet is paused
+// and no longer acquires new jobs.
+const AutoscalingRunnerSetConditionPaused = "Paused"
+
+// AutoscalingRunnerSetConditionCordoned is True when the AutoscalingRunnerSet is cordoned
+// and its listener no longer acquires new jobs.
+const AutoscalingRunnerSetConditionCordoned = "Cordoned"
+
+// AutoscalingRunnerSetConditionPriorityClassNotFound is True when the PriorityClassName of the AutoscalingRunnerSet
+// references a PriorityClass that doesn't exist. Runner pods using it can't be created until it is fixed.
+const AutoscalingRunnerSetConditionPriorityClassNotFound = "PriorityClassNotFound"
+
+// AutoscalingRunnerSetConditionScaleSetMissing is True when the runner scale set of the AutoscalingRunnerSet
+// no longer exists in GitHub, e.g. because it was deleted there. The AutoscalingRunnerSet is not reconciled
+// until the runner scale set is recreated, by removing the runner-scale-set-id annotation, or the runner set is deleted.
+const AutoscalingRunnerSetConditionScaleSetMissing = "ScaleSetMissing"
+
+// AutoscalingRunnerSetConditionImagePullSecretNotFound is True when an image pull secret of the runner pods
+// doesn't exist in the namespace of the AutoscalingRunnerSet. Images requiring it can't be pulled until it is created.
+const AutoscalingRunnerSetConditionImagePullSecretNotFound = "ImagePullSecretNotFound"
+
+// AutoscalingRunnerSetConditionInvalidConfiguration is True when the GitHubConfigSecret of the AutoscalingRunnerSet
+// doesn't exist or doesn't hold valid credentials. The AutoscalingRunnerSet is not reconciled until it is fixed.
+const AutoscalingRunnerSetConditionInvalidConfiguration = "InvalidConfiguration"
+
+// runnerContainerName is the name of the container running the runner image in the pod template.
+const runnerContainerName = "runner"
+
+// RunnerTemplate returns the pod template of the runner pods, with the priority class of the
+// AutoscalingRunnerSet applied if the template doesn't set one, its image pull secrets merged in,
+// and its runner image override applied.
+func (ars *AutoscalingRunnerSet) RunnerTemplate() corev1.PodTemplateSpec {
+	template := *ars.Spec.Template.DeepCopy()
+	if template.Spec.PriorityClassName == "" {
+		template.Spec.PriorityClassName = ars.Spec.PriorityClassName
+	}
+	for _, secret := range ars.Spec.ImagePullSecrets {
+		if !hasImagePullSecret(template.Spec.ImagePullSecrets, secret.Name) {
+			template.Spec.ImagePullSecrets = append(template.Spec.ImagePullSecrets, secret)
+		}
+	}
+	if ars.CreatesServiceAccount() {
+		template.Spec.ServiceAccountName = ars.RunnerServiceAccountName()
+	}
+	if ars.Spec.RunnerImageOverride != "" {
+		for i := range template.Spec.Containers {
+			if template.Spec.Containers[i].Name == runnerContainerName {
+				template.Spec.Containers[i].Image = ars.Spec.RunnerImageOverride
+			}
+		}
+	}
+	return template
+}
+
+func hasImagePullSecret(secrets []corev1.LocalObjectReference, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatesServiceAccount reports whether the controller creates the ServiceAccount of the runner pods.
+func (ars *AutoscalingRunnerSet) CreatesServiceAccount() bool {
+	return ars.Spec.CreateServiceAccount && ars.Spec.Template.Spec.ServiceAccountName == ""
+}
+
+// RunnerServiceAccountName is the name of the ServiceAccount created for the runner pods by CreateServiceAccount.
+func (ars *AutoscalingRunnerSet) RunnerServiceAccountName() string {
+	return ars.Name + "-runner"
 }
 
 func (ars *AutoscalingRunnerSet) ListenerSpecHash() string {
 	type listenerSpec = AutoscalingRunnerSetSpec
 	arsSpec := ars.Spec.DeepCopy()
+	// Resource labels and annotations, and the annotations of the runner service account,
+	// are propagated in place and don't affect the listener.
+	arsSpec.ResourceLabels = nil
+	arsSpec.ResourceAnnotations = nil
+	arsSpec.ServiceAccountAnnotations = nil
 	spec := arsSpec
 	return hash.ComputeTemplateHash(&spec)
 }
@@ -220,7 +539,7 @@ func (ars *AutoscalingRunnerSet) RunnerSetSpecHash() string {
 		RunnerScaleSetName: ars.Spec.RunnerScaleSetName,
 		Proxy:              ars.Spec.Proxy,
 		GitHubServerTLS:    ars.Spec.GitHubServerTLS,
-		Template:           ars.Spec.Template,
+		Template:           ars.RunnerTemplate(),
 	}
 	return hash.ComputeTemplateHash(&spec)
 }
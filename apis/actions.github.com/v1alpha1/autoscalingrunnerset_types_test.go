@@ -0,0 +1,80 @@
+package v1alpha1_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoscalingRunnerSet_RunnerTemplate(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "runner"}},
+				},
+			},
+		},
+	}
+	hash := ars.RunnerSetSpecHash()
+
+	ars.Spec.PriorityClassName = "runners"
+	assert.Equal(t, "runners", ars.RunnerTemplate().Spec.PriorityClassName)
+	assert.Empty(t, ars.Spec.Template.Spec.PriorityClassName, "the template should not be modified")
+	assert.NotEqual(t, hash, ars.RunnerSetSpecHash(), "changing the priority class should roll the runners")
+
+	ars.Spec.Template.Spec.PriorityClassName = "custom"
+	assert.Equal(t, "custom", ars.RunnerTemplate().Spec.PriorityClassName, "the priority class of the template takes precedence")
+}
+
+func TestAutoscalingRunnerSet_RunnerTemplateImagePullSecrets(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{}
+	ars.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "template"}, {Name: "shared"}}
+	hash := ars.RunnerSetSpecHash()
+
+	ars.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "shared"}, {Name: "registry"}}
+	assert.Equal(t,
+		[]corev1.LocalObjectReference{{Name: "template"}, {Name: "shared"}, {Name: "registry"}},
+		ars.RunnerTemplate().Spec.ImagePullSecrets,
+		"the secrets should be merged without duplicates",
+	)
+	assert.Len(t, ars.Spec.Template.Spec.ImagePullSecrets, 2, "the template should not be modified")
+	assert.NotEqual(t, hash, ars.RunnerSetSpecHash(), "changing the image pull secrets should roll the runners")
+}
+
+func TestAutoscalingRunnerSet_RunnerTemplateImageOverride(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{}
+	ars.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: "runner", Image: "runner:stable"},
+		{Name: "sidecar", Image: "sidecar:stable"},
+	}
+	hash := ars.RunnerSetSpecHash()
+
+	ars.Spec.RunnerImageOverride = "runner:canary"
+	containers := ars.RunnerTemplate().Spec.Containers
+	assert.Equal(t, "runner:canary", containers[0].Image)
+	assert.Equal(t, "sidecar:stable", containers[1].Image, "only the runner container should be overridden")
+	assert.Equal(t, "runner:stable", ars.Spec.Template.Spec.Containers[0].Image, "the template should not be modified")
+	assert.NotEqual(t, hash, ars.RunnerSetSpecHash(), "overriding the image should roll the runners")
+}
+
+func TestAutoscalingRunnerSet_RunnerServiceAccount(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{}
+	ars.Name = "runner-set"
+	hash := ars.RunnerSetSpecHash()
+
+	assert.False(t, ars.CreatesServiceAccount())
+	assert.Empty(t, ars.RunnerTemplate().Spec.ServiceAccountName)
+
+	ars.Spec.CreateServiceAccount = true
+	assert.True(t, ars.CreatesServiceAccount())
+	assert.Equal(t, "runner-set-runner", ars.RunnerTemplate().Spec.ServiceAccountName)
+	assert.NotEqual(t, hash, ars.RunnerSetSpecHash(), "creating the service account should roll the runners")
+
+	ars.Spec.Template.Spec.ServiceAccountName = "custom"
+	assert.False(t, ars.CreatesServiceAccount(), "no service account should be created when the template sets one")
+	assert.Equal(t, "custom", ars.RunnerTemplate().Spec.ServiceAccountName)
+}
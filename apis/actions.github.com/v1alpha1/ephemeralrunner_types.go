@@ -65,10 +65,141 @@ type EphemeralRunnerSpec struct {
 	// +optional
 	GitHubServerTLS *GitHubServerTLSConfig `json:"githubServerTLS,omitempty"`
 
+	// MaxLifetime is the maximum duration an idle runner pod is kept after it has started.
+	// Once exceeded, the EphemeralRunner is deleted so the EphemeralRunnerSet can re-create it.
+	// +optional
+	MaxLifetime *metav1.Duration `json:"maxLifetime,omitempty"`
+
+	// RegistrationTimeout is how long the runner of a new pod has to come online in the service, counted from
+	// the creation of the pod. It covers the pod startup as well as runners whose container runs but can't reach GitHub.
+	// Runners are confirmed online through the service once their container runs, or once they are assigned a job.
+	// Once exceeded, the pod is deleted as a RegistrationTimeout failure and re-created after the pod creation backoff.
+	// Registration is not timed out when unset.
+	// +optional
+	RegistrationTimeout *metav1.Duration `json:"registrationTimeout,omitempty"`
+
+	// FailureLimit is the number of consecutive pod failures tolerated before the EphemeralRunner is marked as Failed.
+	// +optional
+	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum:=1
+	FailureLimit int `json:"failureLimit,omitempty"`
+
+	// PreDeleteCommand is executed in the runner container before the runner pod of a gracefully
+	// removed EphemeralRunner is deleted. Failures are reported as events and do not block the deletion.
+	// The command is executed without a shell, e.g. use ["sh", "-c", "..."], or ["pwsh", "-Command", "..."] on Windows.
+	// +optional
+	PreDeleteCommand []string `json:"preDeleteCommand,omitempty"`
+
+	// RunnerContainerName is the name of the container running the self-hosted runner image
+	// in the pod template. Defaults to "runner".
+	// +optional
+	RunnerContainerName string `json:"runnerContainerName,omitempty"`
+
+	// KeepFailedPod keeps the pod of the EphemeralRunner for inspection when it fails, instead of deleting it
+	// and starting a new one. The EphemeralRunner is marked as Failed and labeled as a retained failure,
+	// and the EphemeralRunnerSet creates a replacement.
+	// +optional
+	KeepFailedPod bool `json:"keepFailedPod,omitempty"`
+
+	// TerminationGracePeriodSeconds is the termination grace period of the runner pod, overriding the one of the pod template.
+	// It is used when the runner pod is deleted, including during the clean up of a deleted EphemeralRunner.
+	// Runners assigned to a job are not scaled down, so this is mainly a safety margin for the processes of the runner pod
+	// to shut down. Defaults to the termination grace period of the pod template, or the Kubernetes default if unset.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// PreflightCheck adds an init container to the runner pod checking that the GitHub config URL can be reached,
+	// through the proxy if configured, before the runner starts. The ephemeral runner fails if the check fails.
+	// +optional
+	PreflightCheck bool `json:"preflightCheck,omitempty"`
+
+	// SidecarDependency delays the start of the runner until a sidecar container of the runner pod,
+	// e.g. docker in docker or a cache, is up. The runner container is not considered started, ready,
+	// or registered until then. Linux runners only.
+	// +optional
+	SidecarDependency *SidecarDependency `json:"sidecarDependency,omitempty"`
+
+	// DinDSharedWorkVolume mounts the work volume of the runner container into the docker in docker container
+	// of the runner pod, named "dind", at the same path, so the bind mounts of the jobs resolve to the same files.
+	// An emptyDir "work" volume mounted at the runner work directory is added when the pod template has none.
+	// Runners whose pod template has no "dind" container are marked as Failed with the InvalidDinDSharedWorkVolume
+	// reason instead of having a pod created. Linux runners only.
+	// +optional
+	DinDSharedWorkVolume bool `json:"dindSharedWorkVolume,omitempty"`
+
+	// HealthCheck periodically checks an HTTP endpoint of the idle runner pod, independently of the probes
+	// of its containers. Runners failing the check too many consecutive times are deleted, so the EphemeralRunnerSet
+	// re-creates them. Runners running a job are never checked.
+	// +optional
+	HealthCheck *RunnerHealthCheck `json:"healthCheck,omitempty"`
+
+	// OS is the operating system of the runner pod. It adjusts the defaults injected by the controllers:
+	// the kubernetes.io/os node selector, the work directory of the default work volume and the preflight check.
+	// No node selector is added when unset, and the other defaults are the Linux ones.
+	// +optional
+	OS EphemeralRunnerOS `json:"os,omitempty"`
+
 	// +required
 	corev1.PodTemplateSpec `json:",inline"`
 }
 
+// SidecarDependency is an endpoint of a sidecar container the runner waits for before starting.
+// The command of the runner container is wrapped in a bash script polling the endpoint on localhost,
+// so the runner container must set its command, and the runner image must provide curl to check an HTTP path.
+// The startup probe of the runner container is replaced by one passing once the sidecar is up.
+type SidecarDependency struct {
+	// Port is the port of the runner pod the sidecar listens on.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	Port int32 `json:"port"`
+
+	// Path is the HTTP path checked on the port, which must respond with a successful status code.
+	// When unset, the runner only waits for the port to accept TCP connections.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Timeout is how long the runner waits for the sidecar. Once exceeded, the runner container fails,
+	// which counts as a pod failure of the EphemeralRunner. Defaults to 2m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// RunnerHealthCheck is an HTTP endpoint of the runner pod the controller checks while the runner is idle.
+// The check passes when the endpoint responds with a successful status code.
+type RunnerHealthCheck struct {
+	// Port is the port of the runner pod the endpoint listens on.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	Port int32 `json:"port"`
+
+	// Path is the HTTP path of the endpoint. Defaults to "/".
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Interval is how often the endpoint is checked. Defaults to 30s.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed checks after which the runner is deleted. Defaults to 3.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+}
+
+// EphemeralRunnerOS is the operating system of the runner pod.
+// +kubebuilder:validation:Enum=linux;windows
+type EphemeralRunnerOS string
+
+const (
+	EphemeralRunnerOSLinux   EphemeralRunnerOS = "linux"
+	EphemeralRunnerOSWindows EphemeralRunnerOS = "windows"
+)
+
+// AnnotationKeyJobAssignedAt is set on each EphemeralRunner by the listener with the time the job was assigned to it,
+// in RFC 3339 format.
+const AnnotationKeyJobAssignedAt = "actions.github.com/job-assigned-at"
+
 // EphemeralRunnerStatus defines the observed state of EphemeralRunner
 type EphemeralRunnerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -103,6 +234,33 @@ type EphemeralRunnerStatus struct {
 	// +optional
 	Failures map[string]bool `json:"failures,omitempty"`
 
+	// FailureCount is the number of consecutive pod failures since the runner pod was last running.
+	// +optional
+	FailureCount int `json:"failureCount,omitempty"`
+
+	// LastFailureMessage contains the last lines of the runner container logs captured when the runner pod last failed.
+	// +optional
+	LastFailureMessage string `json:"lastFailureMessage,omitempty"`
+
+	// HealthCheckFailures is the number of consecutive failed health checks of the idle runner.
+	// It is reset once a health check passes.
+	// +optional
+	HealthCheckFailures int `json:"healthCheckFailures,omitempty"`
+
+	// PodCreationBackoffLevel is the number of successive runner pod failures the pod creation backoff is based on.
+	// It is reset once the runner pod is running.
+	// +optional
+	PodCreationBackoffLevel int `json:"podCreationBackoffLevel,omitempty"`
+
+	// NextPodCreationTime is the earliest time a new runner pod is created after the previous one failed.
+	// +optional
+	NextPodCreationTime *metav1.Time `json:"nextPodCreationTime,omitempty"`
+
+	// Unschedulable is true when the runner pod has been pending for longer than the unschedulable threshold
+	// of the controller because the scheduler can't place it on a node.
+	// +optional
+	Unschedulable bool `json:"unschedulable,omitempty"`
+
 	// +optional
 	JobRequestId int64 `json:"jobRequestId,omitempty"`
 
@@ -17,7 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"github.com/actions/actions-runner-controller/hash"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EphemeralRunnerSetSpec defines the desired state of EphemeralRunnerSet
@@ -25,15 +28,274 @@ type EphemeralRunnerSetSpec struct {
 	// Replicas is the number of desired EphemeralRunner resources in the k8s namespace.
 	Replicas int `json:"replicas,omitempty"`
 
+	// ScaleDownPolicy defines the order in which idle EphemeralRunner resources are deleted when scaling down.
+	// +optional
+	// +kubebuilder:default:=OldestFirst
+	ScaleDownPolicy ScaleDownPolicy `json:"scaleDownPolicy,omitempty"`
+
+	// MinIdleReplicas is the minimum number of EphemeralRunner resources kept in the k8s namespace,
+	// regardless of the number of desired replicas, so idle runners are ready to pick up new jobs.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MinIdleReplicas int `json:"minIdleReplicas,omitempty"`
+
+	// OverProvision is the number of EphemeralRunner resources added on top of the replicas while there are any,
+	// to hide the start up time of new runners when jobs ramp up. Either a count, or a percentage of the replicas
+	// rounded up. The extra runners are scaled down along with the replicas, once ScaleDownStabilizationWindow elapses.
+	// MinIdleReplicas is not over-provisioned, it only applies when it exceeds the over-provisioned replicas.
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Pattern=`^[0-9]+%?$`
+	OverProvision *intstr.IntOrString `json:"overProvision,omitempty"`
+
+	// MaxOverProvisionedReplicas bounds the replicas inflated by OverProvision. Replicas above it are not reduced.
+	// Unbounded when not set.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxOverProvisionedReplicas int `json:"maxOverProvisionedReplicas,omitempty"`
+
+	// UpdateStrategy defines how idle EphemeralRunner resources are replaced when the ephemeral runner spec changes.
+	// +optional
+	// +kubebuilder:default:=OnDelete
+	UpdateStrategy UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// MaxUnavailable is the maximum number of idle EphemeralRunner resources replaced at the same time
+	// when using the RollingUpdate strategy.
+	// +optional
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=1
+	MaxUnavailable int `json:"maxUnavailable,omitempty"`
+
+	// NamingStrategy defines how the names of new EphemeralRunner resources are generated.
+	// +optional
+	// +kubebuilder:default:=Random
+	NamingStrategy EphemeralRunnerNamingStrategy `json:"namingStrategy,omitempty"`
+
+	// ScaleDownStabilizationWindow is the duration idle EphemeralRunner resources are kept after the
+	// desired replicas last increased, before scaling down below the recently observed peak.
+	// +optional
+	ScaleDownStabilizationWindow *metav1.Duration `json:"scaleDownStabilizationWindow,omitempty"`
+
+	// RunnerRegistrationThreshold is how long an EphemeralRunner can be pending or running without
+	// registering with GitHub before the WaitingForRunnerRegistration condition is set. Defaults to 5m.
+	// +optional
+	RunnerRegistrationThreshold *metav1.Duration `json:"runnerRegistrationThreshold,omitempty"`
+
+	// SpreadAcrossNodes spreads the runner pods of the runner scale set across nodes on a best-effort basis.
+	// A topology spread constraint on the hostname is added unless the pod template already defines one.
+	// +optional
+	SpreadAcrossNodes bool `json:"spreadAcrossNodes,omitempty"`
+
+	// NodeSelector is merged into the node selector of the runner pods, e.g. to schedule them on a dedicated node pool.
+	// The node selector of the pod template takes precedence on conflicting keys.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are added to the tolerations of the runner pods, e.g. to schedule them on tainted dedicated nodes.
+	// Tolerations already in the pod template are not duplicated.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// DNSPolicy is the DNS policy of the runner pods whose pod template doesn't set one.
+	// +optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig is merged into the DNS config of the runner pods, e.g. to add the nameservers and search domains
+	// of internal services. The nameservers and searches of the pod template replace these, and its options
+	// take precedence on conflicting names.
+	// +optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// ProtectBusyRunners creates a PodDisruptionBudget owned by the EphemeralRunnerSet that doesn't allow evicting
+	// the runner pods assigned a job, so draining a node waits for the jobs in progress. Idle runner pods are still evicted.
+	// +optional
+	ProtectBusyRunners bool `json:"protectBusyRunners,omitempty"`
+
+	// PostJobGracePeriod is how long a finished EphemeralRunner and its pod are kept before being deleted,
+	// e.g. to give sidecar containers time to flush logs. Finished EphemeralRunner resources
+	// do not count towards the desired replicas during the grace period.
+	// +optional
+	PostJobGracePeriod *metav1.Duration `json:"postJobGracePeriod,omitempty"`
+
+	// MaxConcurrentCreations is the maximum number of EphemeralRunner resources created in a single reconcile.
+	// The remaining EphemeralRunner resources are created in subsequent reconciles. Unlimited when not set.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxConcurrentCreations int `json:"maxConcurrentCreations,omitempty"`
+
+	// MaxConcurrentDeletions is the maximum number of idle EphemeralRunner resources deleted in a single reconcile
+	// when scaling down. The remaining EphemeralRunner resources are deleted in subsequent reconciles. Unlimited when not set.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxConcurrentDeletions int `json:"maxConcurrentDeletions,omitempty"`
+
+	// MaxRetainedFailedPods is the maximum number of failed EphemeralRunner resources retained with KeepFailedPod.
+	// The oldest retained failures are deleted first. Unlimited when not set.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxRetainedFailedPods int `json:"maxRetainedFailedPods,omitempty"`
+
+	// EmptyTTL is how long the EphemeralRunnerSet can stay without EphemeralRunner resources and without desired replicas
+	// before it is deleted, e.g. to clean up runner sets left behind by version transitions or rollbacks.
+	// The active EphemeralRunnerSet of an AutoscalingRunnerSet, the most recently created one, is never deleted.
+	// Empty runner sets are kept when not set.
+	// +optional
+	EmptyTTL *metav1.Duration `json:"emptyTTL,omitempty"`
+
+	// ResourceLabels are merged onto the labels of the EphemeralRunner and runner pod resources
+	// of the EphemeralRunnerSet. Labels set by the controller can't be overridden.
+	// +optional
+	ResourceLabels map[string]string `json:"resourceLabels,omitempty"`
+
+	// ResourceAnnotations are merged onto the annotations of the EphemeralRunner and runner pod resources
+	// of the EphemeralRunnerSet. Annotations set by the controller can't be overridden.
+	// +optional
+	ResourceAnnotations map[string]string `json:"resourceAnnotations,omitempty"`
+
 	EphemeralRunnerSpec EphemeralRunnerSpec `json:"ephemeralRunnerSpec,omitempty"`
 }
 
+// ScaleDownPolicy defines the order in which idle EphemeralRunner resources are selected for deletion.
+// +kubebuilder:validation:Enum=OldestFirst;NewestFirst
+type ScaleDownPolicy string
+
+const (
+	// ScaleDownPolicyOldestFirst deletes the idle EphemeralRunner resources with the oldest creation timestamp first.
+	ScaleDownPolicyOldestFirst ScaleDownPolicy = "OldestFirst"
+
+	// ScaleDownPolicyNewestFirst deletes the idle EphemeralRunner resources with the newest creation timestamp first.
+	ScaleDownPolicyNewestFirst ScaleDownPolicy = "NewestFirst"
+)
+
+// EphemeralRunnerNamingStrategy defines how the names of new EphemeralRunner resources are generated.
+// +kubebuilder:validation:Enum=Random;Ordinal
+type EphemeralRunnerNamingStrategy string
+
+const (
+	// EphemeralRunnerNamingStrategyRandom appends a random suffix to the name of the EphemeralRunnerSet.
+	EphemeralRunnerNamingStrategyRandom EphemeralRunnerNamingStrategy = "Random"
+
+	// EphemeralRunnerNamingStrategyOrdinal appends the lowest ordinal not used by an existing EphemeralRunner
+	// to the name of the EphemeralRunnerSet, so ordinals are reused as runners are replaced.
+	EphemeralRunnerNamingStrategyOrdinal EphemeralRunnerNamingStrategy = "Ordinal"
+)
+
+// UpdateStrategy defines how EphemeralRunner resources are replaced when the ephemeral runner spec changes.
+// +kubebuilder:validation:Enum=OnDelete;RollingUpdate
+type UpdateStrategy string
+
+const (
+	// UpdateStrategyOnDelete keeps existing EphemeralRunner resources until they are deleted after finishing a job.
+	UpdateStrategyOnDelete UpdateStrategy = "OnDelete"
+
+	// UpdateStrategyRollingUpdate replaces idle EphemeralRunner resources created from an outdated spec.
+	UpdateStrategyRollingUpdate UpdateStrategy = "RollingUpdate"
+)
+
 // EphemeralRunnerSetStatus defines the observed state of EphemeralRunnerSet
 type EphemeralRunnerSetStatus struct {
 	// CurrentReplicas is the number of currently running EphemeralRunner resources being managed by this EphemeralRunnerSet.
 	CurrentReplicas int `json:"currentReplicas,omitempty"`
+
+	// IdleReplicas is the number of running EphemeralRunner resources that are not assigned to a job.
+	// +optional
+	IdleReplicas int `json:"idleReplicas,omitempty"`
+
+	// BusyReplicas is the number of running EphemeralRunner resources that are assigned to a job.
+	// +optional
+	BusyReplicas int `json:"busyReplicas,omitempty"`
+
+	// DesiredReplicas is the number of desired EphemeralRunner resources observed during the last reconciliation.
+	// +optional
+	DesiredReplicas int `json:"desiredReplicas,omitempty"`
+
+	// QueuedCreations is the number of EphemeralRunner resources left to be created in subsequent reconciles
+	// because of MaxConcurrentCreations.
+	// +optional
+	QueuedCreations int `json:"queuedCreations,omitempty"`
+
+	// RunnerImage is the image of the runner container in the pod template of the EphemeralRunner resources.
+	// It is empty if the runner container can't be found in the pod template.
+	// +optional
+	RunnerImage string `json:"runnerImage,omitempty"`
+
+	// LastScaleUpTime is the last time the number of desired EphemeralRunner resources increased.
+	// +optional
+	LastScaleUpTime *metav1.Time `json:"lastScaleUpTime,omitempty"`
+
+	// LastReconcileTime is the last time the EphemeralRunnerSet was successfully reconciled, to detect stale runner sets.
+	// To avoid a status write on every reconcile, it is only refreshed along with other status changes,
+	// or once it is older than 5 minutes.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// EmptySince is when the EphemeralRunnerSet last became empty, without EphemeralRunner resources and without
+	// desired replicas. It is unset while the EphemeralRunnerSet is not empty.
+	// +optional
+	EmptySince *metav1.Time `json:"emptySince,omitempty"`
+
+	// RunnerGroup is the GitHub runner group of the runner scale set, as resolved by the AutoscalingRunnerSet.
+	// It is empty if the runner group can't be resolved.
+	// +optional
+	RunnerGroup *RunnerGroupStatus `json:"runnerGroup,omitempty"`
+
+	// OldestRunningJobAssignedAt is when the oldest job currently running on an EphemeralRunner was assigned.
+	// The age of the job is the time elapsed since then, so the field only changes when the oldest job completes
+	// and stays accurate between reconciles. It is empty when no job is running.
+	// +optional
+	OldestRunningJobAssignedAt *metav1.Time `json:"oldestRunningJobAssignedAt,omitempty"`
+
+	// Conditions represent the latest available observations of the EphemeralRunnerSet's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// RunnerGroupStatus identifies the GitHub runner group of a runner scale set.
+type RunnerGroupStatus struct {
+	// Name is the name of the runner group.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Id is the id of the runner group.
+	// +optional
+	Id int `json:"id,omitempty"`
 }
 
+// EphemeralRunnerSetConditionWaitingForRunnerRegistration is True when EphemeralRunner resources have been
+// pending or running without a RunnerId for longer than the RunnerRegistrationThreshold.
+const EphemeralRunnerSetConditionWaitingForRunnerRegistration = "WaitingForRunnerRegistration"
+
+// EphemeralRunnerSetConditionInvalidProxyConfig is True when the proxy configuration of the EphemeralRunnerSet
+// can't be used, e.g. because a referenced secret is missing. No EphemeralRunner resources are created until it is fixed.
+const EphemeralRunnerSetConditionInvalidProxyConfig = "InvalidProxyConfig"
+
+// EphemeralRunnerSetConditionInvalidNodeSelector is True when the node selector of the EphemeralRunnerSet
+// has keys or values that are not valid labels. No EphemeralRunner resources are created until it is fixed.
+const EphemeralRunnerSetConditionInvalidNodeSelector = "InvalidNodeSelector"
+
+// EphemeralRunnerSetConditionInvalidTolerations is True when the tolerations of the EphemeralRunnerSet
+// are not valid, e.g. an Exists toleration with a value. No EphemeralRunner resources are created until it is fixed.
+const EphemeralRunnerSetConditionInvalidTolerations = "InvalidTolerations"
+
+// EphemeralRunnerSetConditionInvalidDNSConfig is True when the DNS policy or DNS config of the EphemeralRunnerSet
+// are not valid, e.g. a nameserver that is not an IP address. No EphemeralRunner resources are created until it is fixed.
+const EphemeralRunnerSetConditionInvalidDNSConfig = "InvalidDNSConfig"
+
+// EphemeralRunnerSetConditionRunnerContainerNotFound is True when the pod template of the EphemeralRunnerSet
+// has no container with the runner container name, so the runner image can't be resolved.
+const EphemeralRunnerSetConditionRunnerContainerNotFound = "RunnerContainerNotFound"
+
+// EphemeralRunnerSetConditionRunnersUnschedulable is True when runner pods of EphemeralRunner resources have been
+// pending for longer than the unschedulable threshold of the controller because they can't be scheduled,
+// e.g. because of insufficient resources, so the EphemeralRunnerSet can't reach its desired replicas.
+const EphemeralRunnerSetConditionRunnersUnschedulable = "RunnersUnschedulable"
+
+// EphemeralRunnerSetConditionNamespaceRunnerLimitReached is True when the EphemeralRunnerSet creates fewer
+// EphemeralRunner resources than desired because its namespace reached the limit of runners per namespace of the controller.
+const EphemeralRunnerSetConditionNamespaceRunnerLimitReached = "NamespaceRunnerLimitReached"
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:JSONPath=".spec.replicas",name="DesiredReplicas",type="integer"
@@ -47,6 +309,41 @@ type EphemeralRunnerSet struct {
 	Status EphemeralRunnerSetStatus `json:"status,omitempty"`
 }
 
+// EphemeralRunnerSpecHash returns the hash of the spec used to create new EphemeralRunner resources.
+func (ers *EphemeralRunnerSet) EphemeralRunnerSpecHash() string {
+	return hash.ComputeTemplateHash(&ers.Spec.EphemeralRunnerSpec)
+}
+
+// DesiredReplicas returns the number of EphemeralRunner resources the EphemeralRunnerSet should have,
+// taking OverProvision and MinIdleReplicas into account.
+func (ers *EphemeralRunnerSet) DesiredReplicas() int {
+	replicas := ers.Spec.Replicas + ers.OverProvisionedReplicas()
+	if ers.Spec.MinIdleReplicas > replicas {
+		return ers.Spec.MinIdleReplicas
+	}
+	return replicas
+}
+
+// OverProvisionedReplicas returns the number of EphemeralRunner resources added on top of the replicas by OverProvision,
+// bounded by MaxOverProvisionedReplicas. It returns zero without replicas, or when OverProvision is not valid.
+func (ers *EphemeralRunnerSet) OverProvisionedReplicas() int {
+	if ers.Spec.OverProvision == nil || ers.Spec.Replicas <= 0 {
+		return 0
+	}
+
+	extra, err := intstr.GetScaledValueFromIntOrPercent(ers.Spec.OverProvision, ers.Spec.Replicas, true)
+	if err != nil || extra < 0 {
+		return 0
+	}
+	if limit := ers.Spec.MaxOverProvisionedReplicas; limit > 0 && ers.Spec.Replicas+extra > limit {
+		extra = limit - ers.Spec.Replicas
+		if extra < 0 {
+			return 0
+		}
+	}
+	return extra
+}
+
 //+kubebuilder:object:root=true
 
 // EphemeralRunnerSetList contains a list of EphemeralRunnerSet
@@ -0,0 +1,64 @@
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEphemeralRunnerSet_DesiredReplicas(t *testing.T) {
+	count := intstr.FromInt(2)
+	percent := intstr.FromString("25%")
+
+	tests := map[string]struct {
+		spec v1alpha1.EphemeralRunnerSetSpec
+		want int
+	}{
+		"replicas": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 3},
+			want: 3,
+		},
+		"min idle replicas above replicas": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 3, MinIdleReplicas: 5},
+			want: 5,
+		},
+		"over-provision count": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 3, OverProvision: &count},
+			want: 5,
+		},
+		"over-provision percentage rounded up": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 5, OverProvision: &percent},
+			want: 7,
+		},
+		"no over-provision without replicas": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{MinIdleReplicas: 1, OverProvision: &count},
+			want: 1,
+		},
+		"min idle replicas are not over-provisioned": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 1, MinIdleReplicas: 4, OverProvision: &count},
+			want: 4,
+		},
+		"over-provisioned replicas above min idle replicas": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 3, MinIdleReplicas: 4, OverProvision: &count},
+			want: 5,
+		},
+		"bounded over-provision": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 3, OverProvision: &count, MaxOverProvisionedReplicas: 4},
+			want: 4,
+		},
+		"replicas above the bound are not reduced": {
+			spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 6, OverProvision: &count, MaxOverProvisionedReplicas: 4},
+			want: 6,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ers := &v1alpha1.EphemeralRunnerSet{Spec: tt.spec}
+			assert.Equal(t, tt.want, ers.DesiredReplicas())
+		})
+	}
+}
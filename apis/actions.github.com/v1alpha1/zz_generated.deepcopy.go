@@ -23,7 +23,9 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -32,7 +34,7 @@ func (in *AutoscalingListener) DeepCopyInto(out *AutoscalingListener) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingListener.
@@ -98,6 +100,21 @@ func (in *AutoscalingListenerSpec) DeepCopyInto(out *AutoscalingListenerSpec) {
 		*out = new(ProxyConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SessionBackoffMax != nil {
+		in, out := &in.SessionBackoffMax, &out.SessionBackoffMax
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.StaleSessionTimeout != nil {
+		in, out := &in.StaleSessionTimeout, &out.StaleSessionTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PreferredLabels != nil {
+		in, out := &in.PreferredLabels, &out.PreferredLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingListenerSpec.
@@ -113,6 +130,13 @@ func (in *AutoscalingListenerSpec) DeepCopy() *AutoscalingListenerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoscalingListenerStatus) DeepCopyInto(out *AutoscalingListenerStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingListenerStatus.
@@ -131,7 +155,7 @@ func (in *AutoscalingRunnerSet) DeepCopyInto(out *AutoscalingRunnerSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingRunnerSet.
@@ -198,6 +222,11 @@ func (in *AutoscalingRunnerSetSpec) DeepCopyInto(out *AutoscalingRunnerSetSpec)
 		**out = **in
 	}
 	in.Template.DeepCopyInto(&out.Template)
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
 	if in.MaxRunners != nil {
 		in, out := &in.MaxRunners, &out.MaxRunners
 		*out = new(int)
@@ -208,6 +237,47 @@ func (in *AutoscalingRunnerSetSpec) DeepCopyInto(out *AutoscalingRunnerSetSpec)
 		*out = new(int)
 		**out = **in
 	}
+	if in.DrainTimeout != nil {
+		in, out := &in.DrainTimeout, &out.DrainTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ListenerSessionBackoffMax != nil {
+		in, out := &in.ListenerSessionBackoffMax, &out.ListenerSessionBackoffMax
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ListenerStaleSessionTimeout != nil {
+		in, out := &in.ListenerStaleSessionTimeout, &out.ListenerStaleSessionTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ListenerPreferredLabels != nil {
+		in, out := &in.ListenerPreferredLabels, &out.ListenerPreferredLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceLabels != nil {
+		in, out := &in.ResourceLabels, &out.ResourceLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ResourceAnnotations != nil {
+		in, out := &in.ResourceAnnotations, &out.ResourceAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingRunnerSetSpec.
@@ -223,6 +293,13 @@ func (in *AutoscalingRunnerSetSpec) DeepCopy() *AutoscalingRunnerSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoscalingRunnerSetStatus) DeepCopyInto(out *AutoscalingRunnerSetStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingRunnerSetStatus.
@@ -300,7 +377,7 @@ func (in *EphemeralRunnerSet) DeepCopyInto(out *EphemeralRunnerSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSet.
@@ -356,6 +433,64 @@ func (in *EphemeralRunnerSetList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EphemeralRunnerSetSpec) DeepCopyInto(out *EphemeralRunnerSetSpec) {
 	*out = *in
+	if in.OverProvision != nil {
+		in, out := &in.OverProvision, &out.OverProvision
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.ScaleDownStabilizationWindow != nil {
+		in, out := &in.ScaleDownStabilizationWindow, &out.ScaleDownStabilizationWindow
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RunnerRegistrationThreshold != nil {
+		in, out := &in.RunnerRegistrationThreshold, &out.RunnerRegistrationThreshold
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostJobGracePeriod != nil {
+		in, out := &in.PostJobGracePeriod, &out.PostJobGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.EmptyTTL != nil {
+		in, out := &in.EmptyTTL, &out.EmptyTTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ResourceLabels != nil {
+		in, out := &in.ResourceLabels, &out.ResourceLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ResourceAnnotations != nil {
+		in, out := &in.ResourceAnnotations, &out.ResourceAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	in.EphemeralRunnerSpec.DeepCopyInto(&out.EphemeralRunnerSpec)
 }
 
@@ -372,6 +507,34 @@ func (in *EphemeralRunnerSetSpec) DeepCopy() *EphemeralRunnerSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EphemeralRunnerSetStatus) DeepCopyInto(out *EphemeralRunnerSetStatus) {
 	*out = *in
+	if in.LastScaleUpTime != nil {
+		in, out := &in.LastScaleUpTime, &out.LastScaleUpTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.EmptySince != nil {
+		in, out := &in.EmptySince, &out.EmptySince
+		*out = (*in).DeepCopy()
+	}
+	if in.RunnerGroup != nil {
+		in, out := &in.RunnerGroup, &out.RunnerGroup
+		*out = new(RunnerGroupStatus)
+		**out = **in
+	}
+	if in.OldestRunningJobAssignedAt != nil {
+		in, out := &in.OldestRunningJobAssignedAt, &out.OldestRunningJobAssignedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetStatus.
@@ -397,6 +560,36 @@ func (in *EphemeralRunnerSpec) DeepCopyInto(out *EphemeralRunnerSpec) {
 		*out = new(GitHubServerTLSConfig)
 		**out = **in
 	}
+	if in.MaxLifetime != nil {
+		in, out := &in.MaxLifetime, &out.MaxLifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RegistrationTimeout != nil {
+		in, out := &in.RegistrationTimeout, &out.RegistrationTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PreDeleteCommand != nil {
+		in, out := &in.PreDeleteCommand, &out.PreDeleteCommand
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SidecarDependency != nil {
+		in, out := &in.SidecarDependency, &out.SidecarDependency
+		*out = new(SidecarDependency)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(RunnerHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
 	in.PodTemplateSpec.DeepCopyInto(&out.PodTemplateSpec)
 }
 
@@ -420,6 +613,10 @@ func (in *EphemeralRunnerStatus) DeepCopyInto(out *EphemeralRunnerStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.NextPodCreationTime != nil {
+		in, out := &in.NextPodCreationTime, &out.NextPodCreationTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerStatus.
@@ -491,3 +688,58 @@ func (in *ProxyServerConfig) DeepCopy() *ProxyServerConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerGroupStatus) DeepCopyInto(out *RunnerGroupStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerGroupStatus.
+func (in *RunnerGroupStatus) DeepCopy() *RunnerGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerHealthCheck) DeepCopyInto(out *RunnerHealthCheck) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerHealthCheck.
+func (in *RunnerHealthCheck) DeepCopy() *RunnerHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarDependency) DeepCopyInto(out *SidecarDependency) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarDependency.
+func (in *SidecarDependency) DeepCopy() *SidecarDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarDependency)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -121,7 +121,7 @@ func TestTemplateRenderedGitHubSecretErrorWithMissingAppInput(t *testing.T) {
 	_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/githubsecret.yaml"})
 	require.Error(t, err)
 
-	assert.ErrorContains(t, err, "provide .Values.githubConfigSecret.github_app_installation_id and .Values.githubConfigSecret.github_app_private_key")
+	assert.ErrorContains(t, err, "provide .Values.githubConfigSecret.github_app_private_key")
 }
 
 func TestTemplateNotRenderedGitHubSecretWithPredefinedSecret(t *testing.T) {
@@ -4,15 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
+// Keys of the labels and annotations set on an EphemeralRunner with the job it was assigned.
+// Labels hold sanitized values for selection, annotations hold the original values.
+const (
+	jobWorkflowKey   = "actions.github.com/workflow"
+	jobRepositoryKey = "actions.github.com/repository"
+)
+
 type AutoScalerKubernetesManager struct {
 	*kubernetes.Clientset
 
@@ -125,5 +138,144 @@ func (k *AutoScalerKubernetesManager) UpdateEphemeralRunnerWithJobInfo(ctx conte
 		return fmt.Errorf("could not patch ephemeral runner status, patch JSON: %s, error: %w", string(mergePatch), err)
 	}
 
+	return k.labelEphemeralRunnerWithJobInfo(ctx, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef)
+}
+
+// RecordEphemeralRunnerSetWarning creates a Warning Event on the EphemeralRunnerSet scaled by the listener.
+func (k *AutoScalerKubernetesManager) RecordEphemeralRunnerSetWarning(ctx context.Context, namespace, resourceName, reason, message string) error {
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{}
+	err := k.RESTClient().
+		Get().
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunnerSets").
+		Name(resourceName).
+		Do(ctx).
+		Into(ephemeralRunnerSet)
+	if err != nil {
+		return fmt.Errorf("could not get ephemeral runner set, error: %w", err)
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: resourceName + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      v1alpha1.GroupVersion.String(),
+			Kind:            "EphemeralRunnerSet",
+			Namespace:       namespace,
+			Name:            resourceName,
+			UID:             ephemeralRunnerSet.UID,
+			ResourceVersion: ephemeralRunnerSet.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: "autoscaler-listener"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	if _, err := k.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("could not create event, error: %w", err)
+	}
+
 	return nil
 }
+
+func (k *AutoScalerKubernetesManager) labelEphemeralRunnerWithJobInfo(ctx context.Context, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef string) error {
+	labels, annotations := jobInfoLabelsAndAnnotations(ownerName, repositoryName, jobWorkflowRef)
+	annotations[v1alpha1.AnnotationKeyJobAssignedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	original := &v1alpha1.EphemeralRunner{}
+	originalJson, err := json.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("could not marshal empty ephemeral runner, error: %w", err)
+	}
+
+	patch := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+	patchedJson, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("could not marshal patched ephemeral runner, error: %w", err)
+	}
+
+	mergePatch, err := jsonpatch.CreateMergePatch(originalJson, patchedJson)
+	if err != nil {
+		k.logger.Error(err, "could not create merge patch json for ephemeral runner")
+	}
+
+	k.logger.Info("Created merge patch json for EphemeralRunner job labels", "json", string(mergePatch))
+
+	patched := &v1alpha1.EphemeralRunner{}
+	err = k.RESTClient().
+		Patch(types.MergePatchType).
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunners").
+		Name(resourceName).
+		Body(mergePatch).
+		Do(ctx).
+		Into(patched)
+	if err != nil {
+		return fmt.Errorf("could not patch ephemeral runner labels, patch JSON: %s, error: %w", string(mergePatch), err)
+	}
+
+	return nil
+}
+
+// jobInfoLabelsAndAnnotations returns the labels and annotations describing the workflow and repository of a job.
+// Missing fields are skipped. The workflow is the file name of the workflow, without the ref.
+func jobInfoLabelsAndAnnotations(ownerName, repositoryName, jobWorkflowRef string) (labels, annotations map[string]string) {
+	labels = map[string]string{}
+	annotations = map[string]string{}
+
+	if workflowPath, _, _ := strings.Cut(jobWorkflowRef, "@"); workflowPath != "" {
+		workflow := path.Base(workflowPath)
+		annotations[jobWorkflowKey] = workflow
+		if value := sanitizeLabelValue(workflow); value != "" {
+			labels[jobWorkflowKey] = value
+		}
+	}
+
+	if repositoryName != "" {
+		repository := repositoryName
+		if ownerName != "" {
+			repository = ownerName + "/" + repositoryName
+		}
+		annotations[jobRepositoryKey] = repository
+		if value := sanitizeLabelValue(strings.ReplaceAll(repository, "/", ".")); value != "" {
+			labels[jobRepositoryKey] = value
+		}
+	}
+
+	return labels, annotations
+}
+
+// sanitizeLabelValue replaces the characters that are not allowed in label values with "_",
+// truncates the value to the maximum label length and trims characters that cannot start or end it.
+func sanitizeLabelValue(value string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+
+	if len(sanitized) > validation.LabelValueMaxLength {
+		sanitized = sanitized[:validation.LabelValueMaxLength]
+	}
+
+	return strings.TrimFunc(sanitized, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+}
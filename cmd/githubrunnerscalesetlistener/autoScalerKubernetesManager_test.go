@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestJobInfoLabelsAndAnnotations(t *testing.T) {
+	t.Run("sets sanitized labels and original annotations", func(t *testing.T) {
+		labels, annotations := jobInfoLabelsAndAnnotations("owner1", "repo1", "owner1/repo1/.github/workflows/ci.yaml@refs/heads/main")
+		assert.Equal(t, map[string]string{
+			jobWorkflowKey:   "ci.yaml",
+			jobRepositoryKey: "owner1.repo1",
+		}, labels)
+		assert.Equal(t, map[string]string{
+			jobWorkflowKey:   "ci.yaml",
+			jobRepositoryKey: "owner1/repo1",
+		}, annotations)
+	})
+
+	t.Run("skips missing fields", func(t *testing.T) {
+		labels, annotations := jobInfoLabelsAndAnnotations("", "", "")
+		assert.Empty(t, labels)
+		assert.Empty(t, annotations)
+
+		labels, annotations = jobInfoLabelsAndAnnotations("", "repo1", "")
+		assert.Equal(t, map[string]string{jobRepositoryKey: "repo1"}, labels)
+		assert.Equal(t, map[string]string{jobRepositoryKey: "repo1"}, annotations)
+	})
+
+	t.Run("sanitizes non label characters", func(t *testing.T) {
+		labels, annotations := jobInfoLabelsAndAnnotations("", "", ".github/workflows/日本.yml")
+		assert.Equal(t, map[string]string{jobWorkflowKey: "yml"}, labels)
+		assert.Equal(t, map[string]string{jobWorkflowKey: "日本.yml"}, annotations)
+	})
+
+	t.Run("skips labels that are empty after sanitizing", func(t *testing.T) {
+		labels, annotations := jobInfoLabelsAndAnnotations("", "", ".github/workflows/日本")
+		assert.Empty(t, labels)
+		assert.Equal(t, map[string]string{jobWorkflowKey: "日本"}, annotations)
+	})
+}
+
+func TestSanitizeLabelValue(t *testing.T) {
+	tests := map[string]string{
+		"ci.yaml":            "ci.yaml",
+		"my workflow (prod)": "my_workflow__prod",
+		"-leading":           "leading",
+		"trailing.":          "trailing",
+		"":                   "",
+	}
+	for value, want := range tests {
+		got := sanitizeLabelValue(value)
+		assert.Equal(t, want, got, value)
+		assert.Empty(t, validation.IsValidLabelValue(got), value)
+	}
+
+	long := sanitizeLabelValue(strings.Repeat("a", 100))
+	assert.Len(t, long, validation.LabelValueMaxLength)
+}
@@ -17,8 +17,14 @@ import (
 
 const (
 	sessionCreationMaxRetryCount = 10
+
+	sessionBackoffInitial    = 5 * time.Second
+	defaultSessionBackoffMax = 5 * time.Minute
 )
 
+// errStaleSession is returned when no message or keepalive is received within the stale session timeout.
+var errStaleSession = errors.New("no message received within the stale session timeout")
+
 type devContextKey bool
 
 var testIgnoreSleep devContextKey = true
@@ -27,8 +33,51 @@ type AutoScalerClient struct {
 	client actions.SessionService
 	logger logr.Logger
 
+	actionsClient    actions.ActionsService
+	runnerScaleSetId int
+	// runnerScaleSetName labels the metrics of the client.
+	runnerScaleSetName string
+
 	lastMessageId  int64
 	initialMessage *actions.RunnerScaleSetMessage
+
+	sessionBackoff sessionBackoff
+
+	// staleSessionTimeout is how long to wait for a message or a keepalive before the message session
+	// is considered stale and re-created. Zero disables the detection.
+	staleSessionTimeout time.Duration
+
+	// onSessionBackoffCapped is called once the session re-creation backoff reaches its cap,
+	// with the error that caused the last attempt to fail.
+	onSessionBackoffCapped func(err error)
+}
+
+// sessionBackoff is an exponential backoff between attempts to re-create the message session.
+type sessionBackoff struct {
+	initial time.Duration
+	max     time.Duration
+
+	current time.Duration
+}
+
+// next returns the delay before the next attempt and whether it reached the cap.
+func (b *sessionBackoff) next() (time.Duration, bool) {
+	if b.current == 0 {
+		b.current = b.initial
+	} else {
+		b.current *= 2
+	}
+
+	if b.current >= b.max {
+		b.current = b.max
+		return b.current, true
+	}
+
+	return b.current, false
+}
+
+func (b *sessionBackoff) reset() {
+	b.current = 0
 }
 
 func NewAutoScalerClient(
@@ -39,7 +88,13 @@ func NewAutoScalerClient(
 	options ...func(*AutoScalerClient),
 ) (*AutoScalerClient, error) {
 	listener := AutoScalerClient{
-		logger: logger.WithName("auto_scaler"),
+		logger:           logger.WithName("auto_scaler"),
+		actionsClient:    client,
+		runnerScaleSetId: runnerScaleSetId,
+		sessionBackoff: sessionBackoff{
+			initial: sessionBackoffInitial,
+			max:     defaultSessionBackoffMax,
+		},
 	}
 
 	session, initialMessage, err := createSession(ctx, &listener.logger, client, runnerScaleSetId)
@@ -123,20 +178,33 @@ func (m *AutoScalerClient) Close() error {
 }
 
 func (m *AutoScalerClient) GetRunnerScaleSetMessage(ctx context.Context, handler func(msg *actions.RunnerScaleSetMessage) error) error {
-	if m.initialMessage != nil {
-		err := handler(m.initialMessage)
-		if err != nil {
-			return fmt.Errorf("fail to process initial message. %w", err)
+	for {
+		if m.initialMessage != nil {
+			err := handler(m.initialMessage)
+			if err != nil {
+				return fmt.Errorf("fail to process initial message. %w", err)
+			}
+
+			m.initialMessage = nil
+			return nil
 		}
 
-		m.initialMessage = nil
-		return nil
-	}
-
-	for {
-		message, err := m.client.GetMessage(ctx, m.lastMessageId)
+		message, err := m.getMessage(ctx)
 		if err != nil {
-			return fmt.Errorf("get message failed from refreshing client. %w", err)
+			if ctx.Err() != nil {
+				return fmt.Errorf("get message failed from refreshing client. %w", err)
+			}
+
+			if errors.Is(err, errStaleSession) {
+				incStaleSessions(m.runnerScaleSetId, m.runnerScaleSetName)
+				m.logger.Info("no message received within the stale session timeout, re-creating the message session.", "staleSessionTimeout", m.staleSessionTimeout.String())
+			} else {
+				m.logger.Info("lost message session, re-creating it.", "error", err.Error())
+			}
+			if err := m.recreateSession(ctx); err != nil {
+				return fmt.Errorf("get message failed from refreshing client. %w", err)
+			}
+			continue
 		}
 
 		if message == nil {
@@ -154,6 +222,71 @@ func (m *AutoScalerClient) GetRunnerScaleSetMessage(ctx context.Context, handler
 	}
 }
 
+// getMessage polls the next message of the session. Any answer, including an empty one
+// from a keepalive, is recorded as the last time a message was received.
+// With a stale session timeout, the poll fails with errStaleSession when nothing is received in time.
+func (m *AutoScalerClient) getMessage(ctx context.Context) (*actions.RunnerScaleSetMessage, error) {
+	pollCtx := ctx
+	if m.staleSessionTimeout > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, m.staleSessionTimeout)
+		defer cancel()
+	}
+
+	message, err := m.client.GetMessage(pollCtx, m.lastMessageId)
+	if err != nil {
+		if pollCtx.Err() != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("%w. %v", errStaleSession, err)
+		}
+		return nil, err
+	}
+
+	setLastMessageReceived(m.runnerScaleSetId, m.runnerScaleSetName, time.Now())
+	return message, nil
+}
+
+// recreateSession replaces the message session with a new one, backing off exponentially
+// between attempts until one succeeds or ctx is done. A new session resets the backoff.
+func (m *AutoScalerClient) recreateSession(ctx context.Context) error {
+	notified := false
+	for {
+		delay, capped := m.sessionBackoff.next()
+		if ok := ctx.Value(testIgnoreSleep); ok == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		incSessionReconnects(m.runnerScaleSetId, m.runnerScaleSetName)
+
+		if err := m.client.Close(); err != nil {
+			m.logger.Info("unable to delete the lost message session.", "error", err.Error())
+		}
+
+		session, initialMessage, err := createSession(ctx, &m.logger, m.actionsClient, m.runnerScaleSetId)
+		if err == nil {
+			m.client = newSessionClient(m.actionsClient, &m.logger, session)
+			m.initialMessage = initialMessage
+			m.lastMessageId = 0
+			m.sessionBackoff.reset()
+			m.logger.Info("re-created message session.")
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		m.logger.Info("unable to re-create message session.", "error", err.Error())
+		if capped && !notified && m.onSessionBackoffCapped != nil {
+			m.onSessionBackoffCapped(err)
+			notified = true
+		}
+	}
+}
+
 func (m *AutoScalerClient) deleteMessage(ctx context.Context, messageId int64) error {
 	err := m.client.DeleteMessage(ctx, messageId)
 	if err != nil {
@@ -165,7 +298,7 @@ func (m *AutoScalerClient) deleteMessage(ctx context.Context, messageId int64) e
 }
 
 func (m *AutoScalerClient) AcquireJobsForRunnerScaleSet(ctx context.Context, requestIds []int64) error {
-	m.logger.Info("acquiring jobs.", "request count", len(requestIds), "requestIds", fmt.Sprint(requestIds))
+	m.logger.Info("acquiring jobs.", "request count", len(requestIds), "requestIds", requestIds)
 	if len(requestIds) == 0 {
 		return nil
 	}
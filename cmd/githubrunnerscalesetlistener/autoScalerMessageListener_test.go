@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/logging"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -530,7 +532,7 @@ func TestGetRunnerScaleSetMessage_ErrorOnGetMessage(t *testing.T) {
 	logger = logger.WithName(t.Name())
 	require.NoError(t, err, "Error creating logger")
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
 	sessionId := uuid.New()
 	session := &actions.RunnerScaleSetSession{
 		SessionId:               &sessionId,
@@ -550,6 +552,7 @@ func TestGetRunnerScaleSetMessage_ErrorOnGetMessage(t *testing.T) {
 	})
 	require.NoError(t, err, "Error creating autoscaler client")
 
+	cancel()
 	err = asClient.GetRunnerScaleSetMessage(ctx, func(msg *actions.RunnerScaleSetMessage) error {
 		return fmt.Errorf("Should not be called")
 	})
@@ -560,6 +563,149 @@ func TestGetRunnerScaleSetMessage_ErrorOnGetMessage(t *testing.T) {
 	assert.True(t, mockSessionClient.AssertExpectations(t), "All expectations should be met")
 }
 
+func TestGetRunnerScaleSetMessage_RecreateSessionOnGetMessageError(t *testing.T) {
+	mockActionsClient := &actions.MockActionsService{}
+	mockSessionClient := &actions.MockSessionService{}
+	logger, err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, err, "Error creating logger")
+
+	ctx := context.WithValue(context.Background(), testIgnoreSleep, true)
+	sessionId := uuid.New()
+	session := &actions.RunnerScaleSetSession{
+		SessionId:               &sessionId,
+		OwnerName:               "owner",
+		MessageQueueUrl:         "https://github.com",
+		MessageQueueAccessToken: "token",
+		RunnerScaleSet: &actions.RunnerScaleSet{
+			Id: 1,
+		},
+		Statistics: &actions.RunnerScaleSetStatistic{},
+	}
+	mockActionsClient.On("CreateMessageSession", ctx, 1, mock.Anything).Return(session, nil).Once()
+	mockActionsClient.On("CreateMessageSession", ctx, 1, mock.Anything).Return(nil, &actions.HttpClientSideError{
+		Code: 403,
+	}).Times(3)
+	mockActionsClient.On("CreateMessageSession", ctx, 1, mock.Anything).Return(session, nil).Once()
+	mockActionsClient.On("GetMessage", ctx, "https://github.com", "token", int64(0)).Return(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "test",
+		Body:        "test",
+	}, nil)
+	mockActionsClient.On("DeleteMessage", ctx, "https://github.com", "token", int64(1)).Return(nil)
+	mockSessionClient.On("GetMessage", ctx, int64(0)).Return(nil, fmt.Errorf("error")).Once()
+	mockSessionClient.On("Close").Return(nil)
+
+	var cappedErrors []error
+	asClient, err := NewAutoScalerClient(ctx, mockActionsClient, &logger, 1, func(asc *AutoScalerClient) {
+		asc.client = mockSessionClient
+		asc.runnerScaleSetName = "scale-set"
+		asc.sessionBackoff.max = 2 * sessionBackoffInitial
+		asc.onSessionBackoffCapped = func(err error) {
+			cappedErrors = append(cappedErrors, err)
+		}
+	})
+	require.NoError(t, err, "Error creating autoscaler client")
+
+	reconnects := testutil.ToFloat64(sessionReconnectsTotal.WithLabelValues("1", "scale-set"))
+
+	err = asClient.GetRunnerScaleSetMessage(ctx, func(msg *actions.RunnerScaleSetMessage) error {
+		logger.Info("Message received", "messageId", msg.MessageId, "messageType", msg.MessageType, "body", msg.Body)
+		return nil
+	})
+
+	assert.NoError(t, err, "Error getting message")
+	assert.Equal(t, int64(1), asClient.lastMessageId, "Last message id should be updated")
+	assert.Equal(t, reconnects+4, testutil.ToFloat64(sessionReconnectsTotal.WithLabelValues("1", "scale-set")), "Every attempt to re-create the session should be counted")
+	assert.Len(t, cappedErrors, 1, "Reaching the backoff cap should be reported once")
+	assert.Equal(t, time.Duration(0), asClient.sessionBackoff.current, "A new session should reset the backoff")
+	assert.True(t, mockActionsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockSessionClient.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestGetRunnerScaleSetMessage_RecreateStaleSession(t *testing.T) {
+	mockActionsClient := &actions.MockActionsService{}
+	mockSessionClient := &actions.MockSessionService{}
+	logger, err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, err, "Error creating logger")
+
+	ctx := context.WithValue(context.Background(), testIgnoreSleep, true)
+	sessionId := uuid.New()
+	session := &actions.RunnerScaleSetSession{
+		SessionId:               &sessionId,
+		OwnerName:               "owner",
+		MessageQueueUrl:         "https://github.com",
+		MessageQueueAccessToken: "token",
+		RunnerScaleSet: &actions.RunnerScaleSet{
+			Id: 1,
+		},
+		Statistics: &actions.RunnerScaleSetStatistic{},
+	}
+	mockActionsClient.On("CreateMessageSession", ctx, 1, mock.Anything).Return(session, nil).Twice()
+	mockActionsClient.On("GetMessage", mock.Anything, "https://github.com", "token", int64(0)).Return(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "test",
+		Body:        "test",
+	}, nil)
+	mockActionsClient.On("DeleteMessage", ctx, "https://github.com", "token", int64(1)).Return(nil)
+	mockSessionClient.On("GetMessage", mock.Anything, int64(0)).Return(
+		func(ctx context.Context, lastMessageId int64) *actions.RunnerScaleSetMessage {
+			<-ctx.Done()
+			return nil
+		},
+		func(ctx context.Context, lastMessageId int64) error {
+			return ctx.Err()
+		},
+	).Once()
+	mockSessionClient.On("Close").Return(nil)
+
+	asClient, err := NewAutoScalerClient(ctx, mockActionsClient, &logger, 1, func(asc *AutoScalerClient) {
+		asc.client = mockSessionClient
+		asc.runnerScaleSetName = "scale-set"
+		asc.staleSessionTimeout = 10 * time.Millisecond
+	})
+	require.NoError(t, err, "Error creating autoscaler client")
+
+	staleSessions := testutil.ToFloat64(staleSessionsTotal.WithLabelValues("1", "scale-set"))
+	start := time.Now()
+
+	err = asClient.GetRunnerScaleSetMessage(ctx, func(msg *actions.RunnerScaleSetMessage) error {
+		logger.Info("Message received", "messageId", msg.MessageId, "messageType", msg.MessageType, "body", msg.Body)
+		return nil
+	})
+
+	assert.NoError(t, err, "Error getting message")
+	assert.Equal(t, int64(1), asClient.lastMessageId, "Last message id should be updated")
+	assert.Equal(t, staleSessions+1, testutil.ToFloat64(staleSessionsTotal.WithLabelValues("1", "scale-set")), "The stale session should be counted")
+	assert.GreaterOrEqual(t, testutil.ToFloat64(lastMessageTimestampSeconds.WithLabelValues("1", "scale-set")), float64(start.Unix()), "The time of the last message should be recorded")
+	assert.True(t, mockActionsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockSessionClient.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestSessionBackoff(t *testing.T) {
+	backoff := sessionBackoff{
+		initial: 5 * time.Second,
+		max:     30 * time.Second,
+	}
+
+	var delays []time.Duration
+	var capped []bool
+	for i := 0; i < 5; i++ {
+		delay, ok := backoff.next()
+		delays = append(delays, delay)
+		capped = append(capped, ok)
+	}
+
+	assert.Equal(t, []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}, delays)
+	assert.Equal(t, []bool{false, false, false, true, true}, capped)
+
+	backoff.reset()
+	delay, ok := backoff.next()
+	assert.Equal(t, 5*time.Second, delay, "Backoff should start over after a reset")
+	assert.False(t, ok)
+}
+
 func TestDeleteRunnerScaleSetMessage_Error(t *testing.T) {
 	mockActionsClient := &actions.MockActionsService{}
 	mockSessionClient := &actions.MockSessionService{}
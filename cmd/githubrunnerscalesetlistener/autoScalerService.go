@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
@@ -16,6 +18,18 @@ type ScaleSettings struct {
 	ResourceName string
 	MinRunners   int
 	MaxRunners   int
+
+	// Cordoned stops the service from acquiring the available jobs. The jobs already assigned
+	// to the runner scale set are still tracked, so its runners are scaled down as they finish.
+	Cordoned bool
+
+	// PreferredLabels makes the service acquire the available jobs requesting the most of these labels first.
+	// GitHub routes the jobs and assigns them to the runners, so the order is the only thing the service controls.
+	PreferredLabels []string
+
+	// RunnerScaleSetId and RunnerScaleSetName label the metrics of the service.
+	RunnerScaleSetId   int
+	RunnerScaleSetName string
 }
 
 type Service struct {
@@ -51,6 +65,13 @@ func NewService(
 }
 
 func (s *Service) Start() error {
+	defer deleteScaleSetMetrics(s.settings.RunnerScaleSetId, s.settings.RunnerScaleSetName)
+
+	setCordoned(s.settings.RunnerScaleSetId, s.settings.RunnerScaleSetName, s.settings.Cordoned)
+	if s.settings.Cordoned {
+		s.logger.Info("runner scale set is cordoned, available jobs are not acquired.")
+	}
+
 	if s.settings.MinRunners > 0 {
 		s.logger.Info("scale to match minimal runners.")
 		err := s.scaleForAssignedJobCount(0)
@@ -75,6 +96,12 @@ func (s *Service) Start() error {
 }
 
 func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
+	// The message loop calls the handler as soon as a message is received.
+	receivedAt := time.Now()
+	defer func() {
+		setMessageProcessingLag(s.settings.RunnerScaleSetId, s.settings.RunnerScaleSetName, time.Since(receivedAt))
+	}()
+
 	s.logger.Info("process message.", "messageId", message.MessageId, "messageType", message.MessageType)
 	if message.Statistics == nil {
 		return fmt.Errorf("can't process message with empty statistics")
@@ -88,6 +115,7 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 		"registered runners", message.Statistics.TotalRegisteredRunners,
 		"busy runners", message.Statistics.TotalBusyRunners,
 		"idle runners", message.Statistics.TotalIdleRunners)
+	setScaleSetStatistics(s.settings.RunnerScaleSetId, s.settings.RunnerScaleSetName, message.Statistics)
 
 	if message.MessageType != "RunnerScaleSetJobMessages" {
 		s.logger.Info("skip message with unknown message type.", "messageType", message.MessageType)
@@ -102,6 +130,8 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 	s.logger.Info("process batched runner scale set job messages.", "messageId", message.MessageId, "batchSize", len(batchedMessages))
 
 	var availableJobs []int64
+	queueTimes := make(map[int64]time.Time)
+	requestLabels := make(map[int64][]string)
 	for _, message := range batchedMessages {
 		var messageType actions.JobMessageType
 		if err := json.Unmarshal(message, &messageType); err != nil {
@@ -116,6 +146,8 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 			}
 			s.logger.Info("job available message received.", "RequestId", jobAvailable.RunnerRequestId)
 			availableJobs = append(availableJobs, jobAvailable.RunnerRequestId)
+			queueTimes[jobAvailable.RunnerRequestId] = jobAvailable.QueueTime
+			requestLabels[jobAvailable.RunnerRequestId] = jobAvailable.RequestLabels
 		case "JobAssigned":
 			var jobAssigned actions.JobAssigned
 			if err := json.Unmarshal(message, &jobAssigned); err != nil {
@@ -140,9 +172,24 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 		}
 	}
 
-	err := s.rsClient.AcquireJobsForRunnerScaleSet(s.ctx, availableJobs)
-	if err != nil {
-		return fmt.Errorf("could not acquire jobs. %w", err)
+	if s.settings.Cordoned {
+		if len(availableJobs) > 0 {
+			s.logger.Info("skip acquiring available jobs of cordoned runner scale set.", "count", len(availableJobs))
+		}
+	} else {
+		if len(s.settings.PreferredLabels) > 0 {
+			sortByPreferredLabels(availableJobs, requestLabels, s.settings.PreferredLabels)
+		}
+
+		err := s.rsClient.AcquireJobsForRunnerScaleSet(s.ctx, availableJobs)
+		if err != nil {
+			return fmt.Errorf("could not acquire jobs. %w", err)
+		}
+
+		acquireTime := time.Now()
+		for _, requestId := range availableJobs {
+			observeJobQueueDuration(s.settings.RunnerScaleSetId, s.settings.RunnerScaleSetName, queueTimes[requestId], acquireTime)
+		}
 	}
 
 	return s.scaleForAssignedJobCount(message.Statistics.TotalAssignedJobs)
@@ -164,10 +211,34 @@ func (s *Service) scaleForAssignedJobCount(count int) error {
 
 		s.currentRunnerCount = targetRunnerCount
 	}
+	setDesiredRunners(s.settings.RunnerScaleSetId, s.settings.RunnerScaleSetName, s.currentRunnerCount)
 
 	return nil
 }
 
+// sortByPreferredLabels sorts the request IDs of the available jobs by the number of preferred labels they request,
+// most first. Labels are compared case-insensitively, like GitHub does, and jobs with as many preferred labels
+// keep the order they were received in.
+func sortByPreferredLabels(requestIds []int64, requestLabels map[int64][]string, preferredLabels []string) {
+	preferred := make(map[string]bool, len(preferredLabels))
+	for _, label := range preferredLabels {
+		preferred[strings.ToLower(strings.TrimSpace(label))] = true
+	}
+
+	matches := make(map[int64]int, len(requestIds))
+	for _, requestId := range requestIds {
+		for _, label := range requestLabels[requestId] {
+			if preferred[strings.ToLower(label)] {
+				matches[requestId]++
+			}
+		}
+	}
+
+	sort.SliceStable(requestIds, func(i, j int) bool {
+		return matches[requestIds[i]] > matches[requestIds[j]]
+	})
+}
+
 // updateJobInfoForRunner updates the ephemeral runner with the job info and this is best effort since the info is only for better telemetry
 func (s *Service) updateJobInfoForRunner(jobInfo actions.JobStarted) {
 	s.logger.Info("update job info for runner",
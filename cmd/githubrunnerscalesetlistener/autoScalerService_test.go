@@ -428,6 +428,103 @@ func TestProcessMessage_AcquireJobsFailed(t *testing.T) {
 	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
 }
 
+func TestProcessMessage_Cordoned(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   1,
+			MaxRunners:   5,
+			Cordoned:     true,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2).Return(nil).Once()
+
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics: &actions.RunnerScaleSetStatistic{
+			TotalAssignedJobs:  2,
+			TotalAvailableJobs: 1,
+		},
+		Body: "[{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 3}]",
+	})
+
+	assert.NoError(t, err, "Unexpected error")
+	mockRsClient.AssertNotCalled(t, "AcquireJobsForRunnerScaleSet", mock.Anything, mock.Anything)
+	assert.True(t, mockKubeManager.AssertExpectations(t), "Runners of assigned jobs should be kept")
+}
+
+func TestProcessMessage_PreferredLabels(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:       "namespace",
+			ResourceName:    "resource",
+			MinRunners:      0,
+			MaxRunners:      5,
+			PreferredLabels: []string{"linux", "gpu"},
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{3, 2, 1}).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 3).Return(nil).Once()
+
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics: &actions.RunnerScaleSetStatistic{
+			TotalAssignedJobs:  3,
+			TotalAvailableJobs: 3,
+		},
+		Body: "[{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 1, \"requestLabels\": [\"self-hosted\"]}," +
+			"{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 2, \"requestLabels\": [\"self-hosted\", \"linux\"]}," +
+			"{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 3, \"requestLabels\": [\"Linux\", \"GPU\"]}]",
+	})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Jobs requesting the most preferred labels should be acquired first")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All MockKubernetesManager expectations should be met")
+}
+
+func TestSortByPreferredLabels(t *testing.T) {
+	requestLabels := map[int64][]string{
+		1: {"self-hosted"},
+		2: {"linux"},
+		3: {"gpu"},
+	}
+
+	requestIds := []int64{1, 2, 3}
+	sortByPreferredLabels(requestIds, requestLabels, []string{"linux", "gpu"})
+	assert.Equal(t, []int64{2, 3, 1}, requestIds, "jobs with as many preferred labels keep their order")
+}
+
 func TestScaleForAssignedJobCount_DeDupScale(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/actions/actions-runner-controller/build"
 	"github.com/actions/actions-runner-controller/github/actions"
@@ -34,30 +35,43 @@ import (
 )
 
 type RunnerScaleSetListenerConfig struct {
-	ConfigureUrl                string `split_words:"true"`
-	AppID                       int64  `split_words:"true"`
-	AppInstallationID           int64  `split_words:"true"`
-	AppPrivateKey               string `split_words:"true"`
-	Token                       string `split_words:"true"`
-	EphemeralRunnerSetNamespace string `split_words:"true"`
-	EphemeralRunnerSetName      string `split_words:"true"`
-	MaxRunners                  int    `split_words:"true"`
-	MinRunners                  int    `split_words:"true"`
-	RunnerScaleSetId            int    `split_words:"true"`
+	ConfigureUrl                string        `split_words:"true"`
+	AppID                       int64         `split_words:"true"`
+	AppInstallationID           int64         `split_words:"true"`
+	AppPrivateKey               string        `split_words:"true"`
+	Token                       string        `split_words:"true"`
+	EphemeralRunnerSetNamespace string        `split_words:"true"`
+	EphemeralRunnerSetName      string        `split_words:"true"`
+	MaxRunners                  int           `split_words:"true"`
+	MinRunners                  int           `split_words:"true"`
+	RunnerScaleSetId            int           `split_words:"true"`
+	RunnerScaleSetName          string        `split_words:"true"`
+	MetricsAddr                 string        `split_words:"true" default:":8080"`
+	SessionBackoffMax           time.Duration `split_words:"true" default:"5m"`
+	StaleSessionTimeout         time.Duration `split_words:"true"`
+	LogFormat                   string        `split_words:"true" default:"text"`
+	Cordoned                    bool          `split_words:"true"`
+	PreferredLabels             []string      `split_words:"true"`
+	PathPrefix                  string        `split_words:"true"`
 }
 
 func main() {
-	logger, err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: creating logger: %v\n", err)
+	var rc RunnerScaleSetListenerConfig
+	if err := envconfig.Process("github", &rc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: processing environment variables for RunnerScaleSetListenerConfig: %v\n", err)
 		os.Exit(1)
 	}
 
-	var rc RunnerScaleSetListenerConfig
-	if err := envconfig.Process("github", &rc); err != nil {
-		logger.Error(err, "Error: processing environment variables for RunnerScaleSetListenerConfig")
+	logger, err := logging.NewLogger(logging.LogLevelDebug, rc.LogFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: creating logger: %v\n", err)
 		os.Exit(1)
 	}
+	logger = logger.WithValues(
+		"namespace", rc.EphemeralRunnerSetNamespace,
+		"name", rc.EphemeralRunnerSetName,
+		"runnerScaleSetId", rc.RunnerScaleSetId,
+	)
 
 	// Validate all inputs
 	if err := validateConfig(&rc); err != nil {
@@ -97,24 +111,45 @@ func run(rc RunnerScaleSetListenerConfig, logger logr.Logger) error {
 		return fmt.Errorf("failed to create an Actions Service client: %w", err)
 	}
 
+	// Create kube manager and scale controller
+	kubeManager, err := NewKubernetesManager(&logger)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes manager: %w", err)
+	}
+
 	// Create message listener
-	autoScalerClient, err := NewAutoScalerClient(ctx, actionsServiceClient, &logger, rc.RunnerScaleSetId)
+	autoScalerClient, err := NewAutoScalerClient(ctx, actionsServiceClient, &logger, rc.RunnerScaleSetId, func(c *AutoScalerClient) {
+		c.runnerScaleSetName = rc.RunnerScaleSetName
+		if rc.SessionBackoffMax > 0 {
+			c.sessionBackoff.max = rc.SessionBackoffMax
+		}
+		c.staleSessionTimeout = rc.StaleSessionTimeout
+		c.onSessionBackoffCapped = func(sessionErr error) {
+			message := fmt.Sprintf("Unable to re-create the message session, retrying every %s: %v", c.sessionBackoff.max, sessionErr)
+			if err := kubeManager.RecordEphemeralRunnerSetWarning(ctx, rc.EphemeralRunnerSetNamespace, rc.EphemeralRunnerSetName, "SessionBackoffCapped", message); err != nil {
+				logger.Error(err, "Unable to record session backoff event")
+			}
+		}
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create a message listener: %w", err)
 	}
 	defer autoScalerClient.Close()
 
-	// Create kube manager and scale controller
-	kubeManager, err := NewKubernetesManager(&logger)
-	if err != nil {
-		return fmt.Errorf("failed to create kubernetes manager: %w", err)
+	scaleSettings := &ScaleSettings{
+		Namespace:       rc.EphemeralRunnerSetNamespace,
+		ResourceName:    rc.EphemeralRunnerSetName,
+		MaxRunners:      rc.MaxRunners,
+		MinRunners:      rc.MinRunners,
+		Cordoned:        rc.Cordoned,
+		PreferredLabels: rc.PreferredLabels,
+
+		RunnerScaleSetId:   rc.RunnerScaleSetId,
+		RunnerScaleSetName: rc.RunnerScaleSetName,
 	}
 
-	scaleSettings := &ScaleSettings{
-		Namespace:    rc.EphemeralRunnerSetNamespace,
-		ResourceName: rc.EphemeralRunnerSetName,
-		MaxRunners:   rc.MaxRunners,
-		MinRunners:   rc.MinRunners,
+	if rc.MetricsAddr != "" {
+		go serveMetrics(ctx, rc.MetricsAddr, logger.WithName("metrics"))
 	}
 
 	service := NewService(ctx, autoScalerClient, kubeManager, scaleSettings, func(s *Service) {
@@ -145,6 +180,14 @@ func validateConfig(config *RunnerScaleSetListenerConfig) error {
 		return fmt.Errorf("MinRunners '%d' cannot be greater than MaxRunners '%d'", config.MinRunners, config.MaxRunners)
 	}
 
+	if config.SessionBackoffMax < 0 {
+		return fmt.Errorf("SessionBackoffMax '%s' cannot be negative", config.SessionBackoffMax)
+	}
+
+	if config.StaleSessionTimeout < 0 {
+		return fmt.Errorf("StaleSessionTimeout '%s' cannot be negative", config.StaleSessionTimeout)
+	}
+
 	hasToken := len(config.Token) > 0
 	hasPrivateKeyConfig := config.AppID > 0 && config.AppPrivateKey != ""
 
@@ -165,5 +208,9 @@ func newActionsClientFromConfig(config RunnerScaleSetListenerConfig, creds *acti
 		return proxyFunc(req.URL)
 	}))
 
+	if config.PathPrefix != "" {
+		options = append(options, actions.WithGitHubPathPrefix(config.PathPrefix))
+	}
+
 	return actions.NewClient(config.ConfigureUrl, creds, options...)
 }
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -97,6 +98,21 @@ func TestConfigValidationConfigUrl(t *testing.T) {
 	assert.ErrorContains(t, err, "GitHubConfigUrl is not provided", "Expected error about missing ConfigureUrl")
 }
 
+func TestConfigValidationStaleSessionTimeout(t *testing.T) {
+	config := &RunnerScaleSetListenerConfig{
+		ConfigureUrl:                "https://github.com/actions",
+		EphemeralRunnerSetNamespace: "namespace",
+		EphemeralRunnerSetName:      "deployment",
+		RunnerScaleSetId:            1,
+		Token:                       "asdf",
+		StaleSessionTimeout:         -time.Minute,
+	}
+
+	err := validateConfig(config)
+
+	assert.ErrorContains(t, err, "StaleSessionTimeout '-1m0s' cannot be negative", "Expected error about negative StaleSessionTimeout")
+}
+
 func TestProxySettings(t *testing.T) {
 	t.Run("http", func(t *testing.T) {
 		wentThroughProxy := false
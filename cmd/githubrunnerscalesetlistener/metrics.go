@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsRegistry = prometheus.NewRegistry()
+
+func init() {
+	metricsRegistry.MustRegister(
+		jobQueueSeconds,
+		sessionReconnectsTotal,
+		staleSessionsTotal,
+		lastMessageTimestampSeconds,
+		desiredRunners,
+		assignedJobs,
+		runningJobs,
+		cordoned,
+		messageProcessingLagSeconds,
+	)
+}
+
+const (
+	labelKeyRunnerScaleSetID   = "runner_scale_set_id"
+	labelKeyRunnerScaleSetName = "runner_scale_set_name"
+)
+
+var jobQueueSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "arc_job_queue_seconds",
+		Help:    "Time between a job being queued on GitHub and the listener acquiring it.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var sessionReconnectsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "arc_listener_session_reconnects_total",
+		Help: "Number of attempts to re-create the message session after it was lost.",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var staleSessionsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "arc_listener_stale_sessions_total",
+		Help: "Number of message sessions re-created because no message or keepalive was received within the stale session timeout.",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var lastMessageTimestampSeconds = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_listener_last_message_timestamp_seconds",
+		Help: "Unix time at which the listener last received a message or a keepalive from its message session.",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var desiredRunners = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_desired_runners",
+		Help: "Number of runners the listener last requested from its EphemeralRunnerSet.",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var assignedJobs = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_assigned_jobs",
+		Help: "Number of jobs assigned to the runner scale set, as reported in the last message statistics.",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var runningJobs = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_running_jobs",
+		Help: "Number of jobs running on runners of the runner scale set, as reported in the last message statistics.",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var cordoned = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_cordoned",
+		Help: "Whether the runner scale set is cordoned, in which case the listener does not acquire new jobs (1) or not (0).",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+var messageProcessingLagSeconds = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_listener_message_processing_lag_seconds",
+		Help: "Time between the listener receiving its last message and finishing processing it. Messages are processed one at a time, so the next message waits for it.",
+	},
+	[]string{labelKeyRunnerScaleSetID, labelKeyRunnerScaleSetName},
+)
+
+func scaleSetLabels(runnerScaleSetId int, runnerScaleSetName string) prometheus.Labels {
+	return prometheus.Labels{
+		labelKeyRunnerScaleSetID:   strconv.Itoa(runnerScaleSetId),
+		labelKeyRunnerScaleSetName: runnerScaleSetName,
+	}
+}
+
+// setDesiredRunners sets the number of runners requested for the runner scale set.
+func setDesiredRunners(runnerScaleSetId int, runnerScaleSetName string, count int) {
+	desiredRunners.With(scaleSetLabels(runnerScaleSetId, runnerScaleSetName)).Set(float64(count))
+}
+
+// setCordoned sets whether the runner scale set is cordoned.
+func setCordoned(runnerScaleSetId int, runnerScaleSetName string, isCordoned bool) {
+	value := 0.0
+	if isCordoned {
+		value = 1
+	}
+	cordoned.With(scaleSetLabels(runnerScaleSetId, runnerScaleSetName)).Set(value)
+}
+
+// setScaleSetStatistics sets the job gauges of the runner scale set from the message statistics.
+func setScaleSetStatistics(runnerScaleSetId int, runnerScaleSetName string, statistics *actions.RunnerScaleSetStatistic) {
+	if statistics == nil {
+		return
+	}
+
+	labels := scaleSetLabels(runnerScaleSetId, runnerScaleSetName)
+	assignedJobs.With(labels).Set(float64(statistics.TotalAssignedJobs))
+	runningJobs.With(labels).Set(float64(statistics.TotalRunningJobs))
+}
+
+// deleteScaleSetMetrics removes the series of the runner scale set, so they are not reported after the listener stopped.
+func deleteScaleSetMetrics(runnerScaleSetId int, runnerScaleSetName string) {
+	labels := scaleSetLabels(runnerScaleSetId, runnerScaleSetName)
+	desiredRunners.Delete(labels)
+	assignedJobs.Delete(labels)
+	runningJobs.Delete(labels)
+	cordoned.Delete(labels)
+	messageProcessingLagSeconds.Delete(labels)
+	jobQueueSeconds.Delete(labels)
+	sessionReconnectsTotal.Delete(labels)
+	staleSessionsTotal.Delete(labels)
+	lastMessageTimestampSeconds.Delete(labels)
+}
+
+// setMessageProcessingLag sets how long the last message of the runner scale set took to be processed after it was received.
+func setMessageProcessingLag(runnerScaleSetId int, runnerScaleSetName string, lag time.Duration) {
+	messageProcessingLagSeconds.With(scaleSetLabels(runnerScaleSetId, runnerScaleSetName)).Set(lag.Seconds())
+}
+
+// setLastMessageReceived records when a message or a keepalive was last received for the runner scale set.
+func setLastMessageReceived(runnerScaleSetId int, runnerScaleSetName string, t time.Time) {
+	lastMessageTimestampSeconds.With(scaleSetLabels(runnerScaleSetId, runnerScaleSetName)).Set(float64(t.Unix()))
+}
+
+// incSessionReconnects counts an attempt to re-create the message session of the runner scale set.
+func incSessionReconnects(runnerScaleSetId int, runnerScaleSetName string) {
+	sessionReconnectsTotal.With(scaleSetLabels(runnerScaleSetId, runnerScaleSetName)).Inc()
+}
+
+// incStaleSessions counts a message session of the runner scale set re-created because it went stale.
+func incStaleSessions(runnerScaleSetId int, runnerScaleSetName string) {
+	staleSessionsTotal.With(scaleSetLabels(runnerScaleSetId, runnerScaleSetName)).Inc()
+}
+
+// observeJobQueueDuration records how long a job of the runner scale set waited in the queue before it was acquired.
+// Jobs without a queue time are skipped.
+func observeJobQueueDuration(runnerScaleSetId int, runnerScaleSetName string, queueTime, acquireTime time.Time) {
+	if queueTime.IsZero() {
+		return
+	}
+
+	jobQueueSeconds.With(scaleSetLabels(runnerScaleSetId, runnerScaleSetName)).Observe(acquireTime.Sub(queueTime).Seconds())
+}
+
+// serveMetrics serves the listener metrics on addr until ctx is done.
+func serveMetrics(ctx context.Context, addr string, logger logr.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	}))
+
+	srv := http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		srv.Shutdown(context.Background())
+	}()
+
+	if err := srv.ListenAndServe(); err != nil {
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(err, "problem running metrics server")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jobQueueSecondsSamples(t *testing.T) (uint64, float64) {
+	var m dto.Metric
+	require.NoError(t, jobQueueSeconds.WithLabelValues("1", "scale-set").(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func TestObserveJobQueueDuration(t *testing.T) {
+	acquireTime := time.Now()
+
+	count, sum := jobQueueSecondsSamples(t)
+
+	observeJobQueueDuration(1, "scale-set", acquireTime.Add(-90*time.Second), acquireTime)
+
+	newCount, newSum := jobQueueSecondsSamples(t)
+	assert.Equal(t, count+1, newCount)
+	assert.InDelta(t, sum+90, newSum, 0.001)
+
+	observeJobQueueDuration(1, "scale-set", time.Time{}, acquireTime)
+
+	newCount, _ = jobQueueSecondsSamples(t)
+	assert.Equal(t, count+1, newCount, "jobs without a queue time should not be recorded")
+}
+
+func TestScaleSetMetrics(t *testing.T) {
+	count := testutil.CollectAndCount(desiredRunners) + testutil.CollectAndCount(assignedJobs) + testutil.CollectAndCount(runningJobs) + testutil.CollectAndCount(cordoned) + testutil.CollectAndCount(messageProcessingLagSeconds) + testutil.CollectAndCount(jobQueueSeconds) + testutil.CollectAndCount(sessionReconnectsTotal) + testutil.CollectAndCount(staleSessionsTotal) + testutil.CollectAndCount(lastMessageTimestampSeconds)
+
+	setDesiredRunners(5, "scale-set", 3)
+	setMessageProcessingLag(5, "scale-set", 1500*time.Millisecond)
+	setCordoned(5, "scale-set", true)
+	setLastMessageReceived(5, "scale-set", time.Unix(1700000000, 0))
+	observeJobQueueDuration(5, "scale-set", time.Unix(1700000000, 0), time.Unix(1700000030, 0))
+	incSessionReconnects(5, "scale-set")
+	incStaleSessions(5, "scale-set")
+	setScaleSetStatistics(5, "scale-set", &actions.RunnerScaleSetStatistic{
+		TotalAssignedJobs: 4,
+		TotalRunningJobs:  2,
+	})
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(desiredRunners.WithLabelValues("5", "scale-set")))
+	assert.Equal(t, float64(4), testutil.ToFloat64(assignedJobs.WithLabelValues("5", "scale-set")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(runningJobs.WithLabelValues("5", "scale-set")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(cordoned.WithLabelValues("5", "scale-set")))
+	assert.Equal(t, 1.5, testutil.ToFloat64(messageProcessingLagSeconds.WithLabelValues("5", "scale-set")))
+	assert.Equal(t, float64(1700000000), testutil.ToFloat64(lastMessageTimestampSeconds.WithLabelValues("5", "scale-set")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sessionReconnectsTotal.WithLabelValues("5", "scale-set")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(staleSessionsTotal.WithLabelValues("5", "scale-set")))
+
+	setCordoned(5, "scale-set", false)
+	assert.Equal(t, float64(0), testutil.ToFloat64(cordoned.WithLabelValues("5", "scale-set")))
+
+	deleteScaleSetMetrics(5, "scale-set")
+
+	newCount := testutil.CollectAndCount(desiredRunners) + testutil.CollectAndCount(assignedJobs) + testutil.CollectAndCount(runningJobs) + testutil.CollectAndCount(cordoned) + testutil.CollectAndCount(messageProcessingLagSeconds) + testutil.CollectAndCount(jobQueueSeconds) + testutil.CollectAndCount(sessionReconnectsTotal) + testutil.CollectAndCount(staleSessionsTotal) + testutil.CollectAndCount(lastMessageTimestampSeconds)
+	assert.Equal(t, count, newCount, "series should be removed once the listener stops")
+}
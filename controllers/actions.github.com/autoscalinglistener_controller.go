@@ -18,10 +18,15 @@ package actionsgithubcom
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -33,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	v1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
 	hash "github.com/actions/actions-runner-controller/hash"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -43,6 +49,10 @@ const (
 	autoscalingListenerContainerName = "autoscaler"
 	autoscalingListenerOwnerKey      = ".metadata.controller"
 	autoscalingListenerFinalizerName = "autoscalinglistener.actions.github.com/finalizer"
+
+	// scaleSetRegistrationCheckInterval is the time between the checks of the runner scale set registration
+	// while the listener pod waits for it.
+	scaleSetRegistrationCheckInterval = 10 * time.Second
 )
 
 // AutoscalingListenerReconciler reconciles a AutoscalingListener object
@@ -51,9 +61,33 @@ type AutoscalingListenerReconciler struct {
 	Log    logr.Logger
 	Scheme *runtime.Scheme
 
+	// ListenerLogFormat is the log format of the listeners, e.g. json. The listeners log text when empty.
+	ListenerLogFormat string
+
+	// GitHubPathPrefix is the path GitHub Enterprise Server is served under, passed to the listeners
+	// to build the API URLs. Empty when GitHub Enterprise Server is served at the root of its host.
+	GitHubPathPrefix string
+
+	// ActionsClient is used to confirm the runner scale set is registered before the listener pod is created.
+	ActionsClient actions.MultiClient
+
+	// ScaleSetRegistrationRetries is how many times the registration of the runner scale set is checked with GitHub
+	// before the listener pod is created, so the listener doesn't start polling a runner scale set that is not registered yet.
+	// The listener pod is created anyway once the retries are exhausted. The registration is not checked when it is zero.
+	ScaleSetRegistrationRetries int
+
+	registrationChecksMu sync.Mutex
+	registrationChecks   map[types.UID]registrationCheck
+
 	resourceBuilder resourceBuilder
 }
 
+// registrationCheck tracks the checks of the runner scale set registration of an AutoscalingListener.
+type registrationCheck struct {
+	attempts int
+	next     time.Time
+}
+
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update
@@ -66,12 +100,13 @@ type AutoscalingListenerReconciler struct {
 
 // Reconcile a AutoscalingListener resource to meet its desired spec.
 func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("autoscalinglistener", req.NamespacedName)
+	log := r.Log.WithValues("namespace", req.Namespace, "name", req.Name)
 
 	autoscalingListener := new(v1alpha1.AutoscalingListener)
 	if err := r.Get(ctx, req.NamespacedName, autoscalingListener); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	log = log.WithValues("runnerScaleSetId", autoscalingListener.Spec.RunnerScaleSetId)
 
 	if !autoscalingListener.ObjectMeta.DeletionTimestamp.IsZero() {
 		if !controllerutil.ContainsFinalizer(autoscalingListener, autoscalingListenerFinalizerName) {
@@ -89,6 +124,8 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 			return ctrl.Result{}, nil
 		}
 
+		r.forgetRegistrationCheck(autoscalingListener.UID)
+
 		log.Info("Removing finalizer")
 		err = patch(ctx, r.Client, autoscalingListener, func(obj *v1alpha1.AutoscalingListener) {
 			controllerutil.RemoveFinalizer(obj, autoscalingListenerFinalizerName)
@@ -228,6 +265,15 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 			return ctrl.Result{}, err
 		}
 
+		requeueAfter, err := r.scaleSetRegistrationPending(ctx, &autoscalingRunnerSet, autoscalingListener, secret, time.Now(), log)
+		if err != nil {
+			log.Error(err, "Failed to update the scale set registered condition")
+			return ctrl.Result{}, err
+		}
+		if requeueAfter > 0 {
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+
 		// Create a listener pod in the controller namespace
 		log.Info("Creating a listener pod")
 		return r.createListenerPod(ctx, &autoscalingRunnerSet, autoscalingListener, serviceAccount, mirrorSecret, log)
@@ -306,6 +352,134 @@ func (r *AutoscalingListenerReconciler) SetupWithManager(mgr ctrl.Manager) error
 		Complete(r)
 }
 
+// scaleSetRegistrationPending checks with GitHub that the runner scale set of the listener is registered, up to ScaleSetRegistrationRetries times.
+// It returns how long to wait before checking again, or zero when the listener pod can be created, because the registration
+// is confirmed, the retries are exhausted or the check is disabled.
+func (r *AutoscalingListenerReconciler) scaleSetRegistrationPending(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, autoscalingListener *v1alpha1.AutoscalingListener, secret *corev1.Secret, now time.Time, log logr.Logger) (time.Duration, error) {
+	if r.ScaleSetRegistrationRetries <= 0 || meta.IsStatusConditionTrue(autoscalingListener.Status.Conditions, v1alpha1.AutoscalingListenerConditionScaleSetRegistered) {
+		return 0, nil
+	}
+
+	r.registrationChecksMu.Lock()
+	check := r.registrationChecks[autoscalingListener.UID]
+	r.registrationChecksMu.Unlock()
+	if check.attempts >= r.ScaleSetRegistrationRetries {
+		return 0, nil
+	}
+	if remaining := check.next.Sub(now); remaining > 0 {
+		return remaining, nil
+	}
+
+	registered, err := r.scaleSetRegistered(ctx, autoscalingRunnerSet, autoscalingListener, secret)
+	if err != nil {
+		log.Error(err, "Failed to check the registration of the runner scale set")
+	}
+	if registered {
+		log.Info("Confirmed the registration of the runner scale set")
+		r.forgetRegistrationCheck(autoscalingListener.UID)
+		return 0, r.updateScaleSetRegisteredCondition(ctx, autoscalingListener, metav1.ConditionTrue, "Registered", "The runner scale set is registered")
+	}
+
+	check.attempts++
+	check.next = now.Add(scaleSetRegistrationCheckInterval)
+	r.registrationChecksMu.Lock()
+	if r.registrationChecks == nil {
+		r.registrationChecks = make(map[types.UID]registrationCheck)
+	}
+	r.registrationChecks[autoscalingListener.UID] = check
+	r.registrationChecksMu.Unlock()
+
+	if check.attempts >= r.ScaleSetRegistrationRetries {
+		log.Info("Could not confirm the registration of the runner scale set. Creating the listener pod anyway", "attempts", check.attempts)
+		return 0, r.updateScaleSetRegisteredCondition(ctx, autoscalingListener, metav1.ConditionFalse, "RegistrationUnconfirmed", fmt.Sprintf("The registration of the runner scale set could not be confirmed after %d attempts", check.attempts))
+	}
+
+	log.Info("Waiting for the runner scale set to be registered before creating the listener pod", "attempts", check.attempts, "retries", r.ScaleSetRegistrationRetries)
+	if err := r.updateScaleSetRegisteredCondition(ctx, autoscalingListener, metav1.ConditionFalse, "WaitingForRegistration", "Waiting for the runner scale set to be registered"); err != nil {
+		return 0, err
+	}
+	return scaleSetRegistrationCheckInterval, nil
+}
+
+// scaleSetRegistered reports whether the runner scale set of the listener exists in GitHub.
+func (r *AutoscalingListenerReconciler) scaleSetRegistered(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, autoscalingListener *v1alpha1.AutoscalingListener, secret *corev1.Secret) (bool, error) {
+	var opts []actions.ClientOption
+	if autoscalingListener.Spec.Proxy != nil {
+		secretFetcher := func(s string) (*corev1.Secret, error) {
+			var proxySecret corev1.Secret
+			err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: s}, &proxySecret)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get proxy secret %s: %w", s, err)
+			}
+
+			return &proxySecret, nil
+		}
+
+		proxyFunc, err := autoscalingListener.Spec.Proxy.ProxyFunc(secretFetcher)
+		if err != nil {
+			return false, fmt.Errorf("failed to get proxy func: %w", err)
+		}
+
+		opts = append(opts, actions.WithProxy(proxyFunc))
+
+		caCertificate, err := autoscalingListener.Spec.Proxy.CACertificate(secretFetcher)
+		if err != nil {
+			return false, fmt.Errorf("failed to get proxy ca certificate: %w", err)
+		}
+
+		if caCertificate != nil {
+			opts = append(opts, actions.WithProxyCACertificate(caCertificate))
+		}
+	}
+
+	actionsClient, err := r.ActionsClient.GetClientFromSecret(
+		ctx,
+		autoscalingListener.Spec.GitHubConfigUrl,
+		autoscalingRunnerSet.Namespace,
+		actions.KubernetesSecret{
+			Name:            secret.Name,
+			ResourceVersion: secret.ResourceVersion,
+			Data:            secret.Data,
+		},
+		opts...,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	runnerScaleSet, err := actionsClient.GetRunnerScaleSetById(ctx, autoscalingListener.Spec.RunnerScaleSetId)
+	if err != nil {
+		actionsError := &actions.ActionsError{}
+		if errors.As(err, &actionsError) && actionsError.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get runner scale set %d: %w", autoscalingListener.Spec.RunnerScaleSetId, err)
+	}
+	return runnerScaleSet != nil, nil
+}
+
+func (r *AutoscalingListenerReconciler) updateScaleSetRegisteredCondition(ctx context.Context, autoscalingListener *v1alpha1.AutoscalingListener, status metav1.ConditionStatus, reason, message string) error {
+	condition := metav1.Condition{
+		Type:               v1alpha1.AutoscalingListenerConditionScaleSetRegistered,
+		Status:             status,
+		ObservedGeneration: autoscalingListener.Generation,
+		Reason:             reason,
+		Message:            message,
+	}
+	if !conditionChanged(autoscalingListener.Status.Conditions, condition) {
+		return nil
+	}
+	return patchSubResource(ctx, r.Status(), autoscalingListener, func(obj *v1alpha1.AutoscalingListener) {
+		meta.SetStatusCondition(&obj.Status.Conditions, condition)
+	})
+}
+
+func (r *AutoscalingListenerReconciler) forgetRegistrationCheck(uid types.UID) {
+	r.registrationChecksMu.Lock()
+	defer r.registrationChecksMu.Unlock()
+	delete(r.registrationChecks, uid)
+}
+
 func (r *AutoscalingListenerReconciler) cleanupResources(ctx context.Context, autoscalingListener *v1alpha1.AutoscalingListener, logger logr.Logger) (done bool, err error) {
 	logger.Info("Cleaning up the listener pod")
 	listenerPod := new(corev1.Pod)
@@ -383,6 +557,8 @@ func (r *AutoscalingListenerReconciler) createServiceAccountForListener(ctx cont
 func (r *AutoscalingListenerReconciler) createListenerPod(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, autoscalingListener *v1alpha1.AutoscalingListener, serviceAccount *corev1.ServiceAccount, secret *corev1.Secret, logger logr.Logger) (ctrl.Result, error) {
 	var envs []corev1.EnvVar
 	if autoscalingListener.Spec.Proxy != nil {
+		inherited := autoscalingListener.Spec.Proxy.InheritsFromController()
+
 		httpURL := corev1.EnvVar{
 			Name: "http_proxy",
 			ValueFrom: &corev1.EnvVarSource{
@@ -392,7 +568,7 @@ func (r *AutoscalingListenerReconciler) createListenerPod(ctx context.Context, a
 				},
 			},
 		}
-		if autoscalingListener.Spec.Proxy.HTTP != nil {
+		if inherited || autoscalingListener.Spec.Proxy.HTTP != nil {
 			envs = append(envs, httpURL)
 		}
 
@@ -405,7 +581,7 @@ func (r *AutoscalingListenerReconciler) createListenerPod(ctx context.Context, a
 				},
 			},
 		}
-		if autoscalingListener.Spec.Proxy.HTTPS != nil {
+		if inherited || autoscalingListener.Spec.Proxy.HTTPS != nil {
 			envs = append(envs, httpsURL)
 		}
 
@@ -418,11 +594,25 @@ func (r *AutoscalingListenerReconciler) createListenerPod(ctx context.Context, a
 				},
 			},
 		}
-		if len(autoscalingListener.Spec.Proxy.NoProxy) > 0 {
+		if inherited || len(autoscalingListener.Spec.Proxy.NoProxy) > 0 {
 			envs = append(envs, noProxy)
 		}
 	}
 
+	if r.ListenerLogFormat != "" {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "GITHUB_LOG_FORMAT",
+			Value: r.ListenerLogFormat,
+		})
+	}
+
+	if r.GitHubPathPrefix != "" {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "GITHUB_PATH_PREFIX",
+			Value: r.GitHubPathPrefix,
+		})
+	}
+
 	newPod := r.resourceBuilder.newScaleSetListenerPod(autoscalingListener, serviceAccount, secret, envs...)
 
 	if err := ctrl.SetControllerReference(autoscalingListener, newPod, r.Scheme); err != nil {
@@ -3,6 +3,8 @@ package actionsgithubcom
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"testing"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -11,13 +13,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	actionsv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/actions/actions-runner-controller/github/actions/fake"
 )
 
 const (
@@ -554,3 +562,88 @@ var _ = Describe("Test AutoScalingListener controller with proxy", func() {
 			autoscalingListenerTestInterval).Should(Succeed(), "failed to delete secret with proxy details")
 	})
 })
+
+func TestScaleSetRegistrationPending(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	configSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(autoscalingListenerTestGitHubToken)},
+	}
+	autoscalingRunnerSet := &actionsv1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default"},
+	}
+	newListener := func() *actionsv1alpha1.AutoscalingListener {
+		return &actionsv1alpha1.AutoscalingListener{
+			ObjectMeta: metav1.ObjectMeta{Name: "listener", Namespace: "arc-systems", UID: "uid"},
+			Spec: actionsv1alpha1.AutoscalingListenerSpec{
+				GitHubConfigUrl:    "https://github.com/owner/repo",
+				GitHubConfigSecret: configSecret.Name,
+				RunnerScaleSetId:   1,
+			},
+		}
+	}
+	newReconciler := func(listener *actionsv1alpha1.AutoscalingListener, scaleSet *actions.RunnerScaleSet, err error) *AutoscalingListenerReconciler {
+		return &AutoscalingListenerReconciler{
+			Client:                      newFakeClient(scheme, configSecret, listener),
+			ActionsClient:               fake.NewMultiClient(fake.WithDefaultClient(fake.NewFakeClient(fake.WithGetRunnerScaleSetById(scaleSet, err)), nil)),
+			ScaleSetRegistrationRetries: 2,
+		}
+	}
+	registeredCondition := func(r *AutoscalingListenerReconciler, listener *actionsv1alpha1.AutoscalingListener) *metav1.Condition {
+		updated := new(actionsv1alpha1.AutoscalingListener)
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(listener), updated))
+		listener.Status = updated.Status
+		return meta.FindStatusCondition(updated.Status.Conditions, actionsv1alpha1.AutoscalingListenerConditionScaleSetRegistered)
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("registered", func(t *testing.T) {
+		listener := newListener()
+		r := newReconciler(listener, &actions.RunnerScaleSet{Id: 1}, nil)
+		requeueAfter, err := r.scaleSetRegistrationPending(ctx, autoscalingRunnerSet, listener, configSecret, now, logr.Discard())
+		require.NoError(t, err)
+		assert.Zero(t, requeueAfter)
+
+		condition := registeredCondition(r, listener)
+		require.NotNil(t, condition)
+		assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		listener := newListener()
+		r := newReconciler(listener, nil, &actions.ActionsError{StatusCode: http.StatusNotFound})
+		requeueAfter, err := r.scaleSetRegistrationPending(ctx, autoscalingRunnerSet, listener, configSecret, now, logr.Discard())
+		require.NoError(t, err)
+		assert.Equal(t, scaleSetRegistrationCheckInterval, requeueAfter)
+
+		condition := registeredCondition(r, listener)
+		require.NotNil(t, condition)
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, "WaitingForRegistration", condition.Reason)
+
+		requeueAfter, err = r.scaleSetRegistrationPending(ctx, autoscalingRunnerSet, listener, configSecret, now.Add(time.Second), logr.Discard())
+		require.NoError(t, err)
+		assert.Equal(t, scaleSetRegistrationCheckInterval-time.Second, requeueAfter, "the registration is not checked before the interval")
+
+		requeueAfter, err = r.scaleSetRegistrationPending(ctx, autoscalingRunnerSet, listener, configSecret, now.Add(scaleSetRegistrationCheckInterval), logr.Discard())
+		require.NoError(t, err)
+		assert.Zero(t, requeueAfter, "the listener pod is created once the retries are exhausted")
+
+		condition = registeredCondition(r, listener)
+		require.NotNil(t, condition)
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, "RegistrationUnconfirmed", condition.Reason)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		listener := newListener()
+		r := newReconciler(listener, nil, &actions.ActionsError{StatusCode: http.StatusNotFound})
+		r.ScaleSetRegistrationRetries = 0
+		requeueAfter, err := r.scaleSetRegistrationPending(ctx, autoscalingRunnerSet, listener, configSecret, now, logr.Discard())
+		require.NoError(t, err)
+		assert.Zero(t, requeueAfter)
+		assert.Nil(t, registeredCondition(r, listener))
+	})
+}
@@ -18,21 +18,29 @@ package actionsgithubcom
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -48,6 +56,14 @@ const (
 	runnerScaleSetIdKey               = "runner-scale-set-id"
 	runnerScaleSetNameKey             = "runner-scale-set-name"
 	runnerScaleSetRunnerGroupNameKey  = "runner-scale-set-runner-group-name"
+	runnerScaleSetRunnerGroupIdKey    = "runner-scale-set-runner-group-id"
+
+	// defaultDrainTimeout is used when the AutoscalingRunnerSet does not set DrainTimeout.
+	defaultDrainTimeout = 1 * time.Hour
+	// drainRequeueInterval is how often busy ephemeral runners are checked while draining.
+	drainRequeueInterval = 30 * time.Second
+	// invalidConfigurationRequeueInterval is how often an invalid GitHub config secret is checked again.
+	invalidConfigurationRequeueInterval = 1 * time.Minute
 )
 
 // AutoscalingRunnerSetReconciler reconciles a AutoscalingRunnerSet object
@@ -59,8 +75,29 @@ type AutoscalingRunnerSetReconciler struct {
 	DefaultRunnerScaleSetListenerImage            string
 	DefaultRunnerScaleSetListenerImagePullSecrets []string
 	ActionsClient                                 actions.MultiClient
+	// MaxConcurrentReconciles is the number of AutoscalingRunnerSets reconciled in parallel. Defaults to 1.
+	// An AutoscalingRunnerSet is never reconciled by two workers at once. The runner scale sets are
+	// created and updated through the ActionsClient, which is safe for concurrent use.
+	MaxConcurrentReconciles int
+	// ScaleSetCheckInterval is how often the runner scale set of an AutoscalingRunnerSet is checked to still exist
+	// in GitHub. A runner scale set deleted in GitHub is reported by the ScaleSetMissing condition, and the
+	// AutoscalingRunnerSet is not reconciled further until it is recreated. Set to 0 to disable the check.
+	ScaleSetCheckInterval time.Duration
+	// SkipDeregistration keeps the runner scale sets of deleted AutoscalingRunnerSets in GitHub, e.g. in throwaway
+	// clusters torn down wholesale. Only the resources in the cluster are cleaned up.
+	SkipDeregistration bool
 
 	resourceBuilder resourceBuilder
+
+	scaleSetChecksMu sync.Mutex
+	scaleSetChecks   map[types.UID]scaleSetCheck
+}
+
+// scaleSetCheck is the result of the last check of the runner scale set of an AutoscalingRunnerSet.
+type scaleSetCheck struct {
+	runnerScaleSetId int
+	checkedAt        time.Time
+	exists           bool
 }
 
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets,verbs=get;list;watch;create;update;patch;delete
@@ -68,17 +105,23 @@ type AutoscalingRunnerSetReconciler struct {
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets/finalizers,verbs=update
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners,verbs=get;list;watch
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;patch
 
 // Reconcile a AutoscalingRunnerSet resource to meet its desired spec.
 func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("autoscalingrunnerset", req.NamespacedName)
+	log := r.Log.WithValues("namespace", req.Namespace, "name", req.Name)
 
 	autoscalingRunnerSet := new(v1alpha1.AutoscalingRunnerSet)
 	if err := r.Get(ctx, req.NamespacedName, autoscalingRunnerSet); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if id, ok := autoscalingRunnerSet.Annotations[runnerScaleSetIdKey]; ok {
+		log = log.WithValues("runnerScaleSetId", id)
+	}
 
 	if !autoscalingRunnerSet.ObjectMeta.DeletionTimestamp.IsZero() {
 		if !controllerutil.ContainsFinalizer(autoscalingRunnerSet, autoscalingRunnerSetFinalizerName) {
@@ -98,6 +141,20 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 			return ctrl.Result{}, nil
 		}
 
+		// The listener is gone at this point, so no new jobs are acquired and
+		// the ephemeral runner sets are no longer scaled up while draining.
+		if autoscalingRunnerSet.Spec.DrainOnDelete {
+			requeueAfter, err := r.drainEphemeralRunnerSets(ctx, autoscalingRunnerSet, log)
+			if err != nil {
+				log.Error(err, "Failed to drain ephemeral runner sets")
+				return ctrl.Result{}, err
+			}
+			if requeueAfter > 0 {
+				log.Info("Waiting for busy ephemeral runners to finish their jobs", "requeueAfter", requeueAfter)
+				return ctrl.Result{RequeueAfter: requeueAfter}, nil
+			}
+		}
+
 		done, err = r.cleanupEphemeralRunnerSets(ctx, autoscalingRunnerSet, log)
 		if err != nil {
 			log.Error(err, "Failed to clean up ephemeral runner sets")
@@ -108,11 +165,16 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 			return ctrl.Result{}, nil
 		}
 
-		err = r.deleteRunnerScaleSet(ctx, autoscalingRunnerSet, log)
-		if err != nil {
-			log.Error(err, "Failed to delete runner scale set")
-			return ctrl.Result{}, err
+		if r.SkipDeregistration {
+			log.Info("WARNING: Deregistration is skipped, the runner scale set remains in GitHub", "runnerScaleSetId", autoscalingRunnerSet.Annotations[runnerScaleSetIdKey])
+		} else {
+			err = r.deleteRunnerScaleSet(ctx, autoscalingRunnerSet, log)
+			if err != nil {
+				log.Error(err, "Failed to delete runner scale set")
+				return ctrl.Result{}, err
+			}
 		}
+		r.forgetScaleSetCheck(autoscalingRunnerSet.UID)
 
 		log.Info("Removing finalizer")
 		err = patch(ctx, r.Client, autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
@@ -140,6 +202,25 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, nil
 	}
 
+	problem, err := r.configSecretProblem(ctx, autoscalingRunnerSet)
+	if err != nil {
+		log.Error(err, "Failed to validate the GitHub config secret")
+		return ctrl.Result{}, err
+	}
+	if problem != "" {
+		log.Info("Invalid configuration. Waiting for it to be fixed before calling GitHub", "problem", problem)
+		condition := invalidConfigurationCondition(autoscalingRunnerSet.Generation, problem)
+		if conditionChanged(autoscalingRunnerSet.Status.Conditions, condition) {
+			if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+				meta.SetStatusCondition(&obj.Status.Conditions, condition)
+			}); err != nil {
+				log.Error(err, "Failed to update autoscaling runner set status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: invalidConfigurationRequeueInterval}, nil
+	}
+
 	scaleSetIdRaw, ok := autoscalingRunnerSet.Annotations[runnerScaleSetIdKey]
 	if !ok {
 		// Need to create a new runner scale set on Actions service
@@ -147,12 +228,32 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		return r.createRunnerScaleSet(ctx, autoscalingRunnerSet, log)
 	}
 
-	if id, err := strconv.Atoi(scaleSetIdRaw); err != nil || id <= 0 {
+	scaleSetId, err := strconv.Atoi(scaleSetIdRaw)
+	if err != nil || scaleSetId <= 0 {
 		log.Info("Runner scale set id annotation is not an id, or is <= 0. Creating a new runner scale set.")
 		// something modified the scaleSetId. Try to create one
 		return r.createRunnerScaleSet(ctx, autoscalingRunnerSet, log)
 	}
 
+	exists, err := r.runnerScaleSetExists(ctx, autoscalingRunnerSet, scaleSetId, time.Now())
+	if err != nil {
+		log.Error(err, "Failed to check that the runner scale set exists")
+		return ctrl.Result{}, err
+	}
+	if !exists {
+		log.Info("Runner scale set no longer exists in GitHub. Waiting for it to be recreated or for the autoscaling runner set to be deleted.")
+		condition := scaleSetMissingCondition(autoscalingRunnerSet.Generation, scaleSetId, true)
+		if conditionChanged(autoscalingRunnerSet.Status.Conditions, condition) {
+			if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+				meta.SetStatusCondition(&obj.Status.Conditions, condition)
+			}); err != nil {
+				log.Error(err, "Failed to update autoscaling runner set status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: r.ScaleSetCheckInterval}, nil
+	}
+
 	// Make sure the runner group of the scale set is up to date
 	currentRunnerGroupName, ok := autoscalingRunnerSet.Annotations[runnerScaleSetRunnerGroupNameKey]
 	if !ok || (len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 && !strings.EqualFold(currentRunnerGroupName, autoscalingRunnerSet.Spec.RunnerGroup)) {
@@ -175,6 +276,13 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, err
 	}
 
+	if autoscalingRunnerSet.CreatesServiceAccount() {
+		if err := r.ensureRunnerServiceAccount(ctx, autoscalingRunnerSet, log); err != nil {
+			log.Error(err, "Failed to create runner service account")
+			return ctrl.Result{}, err
+		}
+	}
+
 	existingRunnerSets, err := r.listEphemeralRunnerSets(ctx, autoscalingRunnerSet)
 	if err != nil {
 		log.Error(err, "Failed to list existing ephemeral runner sets")
@@ -207,6 +315,15 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		}
 	}
 
+	if err := r.updateResourceMetadata(ctx, autoscalingRunnerSet, latestRunnerSet, log); err != nil {
+		log.Error(err, "Failed to update resource labels and annotations of the ephemeral runner set")
+		return ctrl.Result{}, err
+	}
+
+	if autoscalingRunnerSet.Spec.Paused {
+		return r.pause(ctx, autoscalingRunnerSet, latestRunnerSet, log)
+	}
+
 	// Make sure the AutoscalingListener is up and running in the controller namespace
 	listener := new(v1alpha1.AutoscalingListener)
 	if err := r.Get(ctx, client.ObjectKey{Namespace: r.ControllerNamespace, Name: scaleSetListenerName(autoscalingRunnerSet)}, listener); err != nil {
@@ -235,18 +352,366 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 	}
 
 	// Update the status of autoscaling runner set.
-	if latestRunnerSet.Status.CurrentReplicas != autoscalingRunnerSet.Status.CurrentRunners {
-		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
-			obj.Status.CurrentRunners = latestRunnerSet.Status.CurrentReplicas
+	if err := r.updateStatus(ctx, autoscalingRunnerSet, latestRunnerSet); err != nil {
+		log.Error(err, "Failed to update autoscaling runner set status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// pause removes the listener, so no new jobs are acquired, and scales the latest ephemeral runner set
+// down to zero. Runners assigned to a job are left to finish it. Unpausing recreates the listener,
+// which resumes autoscaling.
+func (r *AutoscalingRunnerSetReconciler) pause(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, latestRunnerSet *v1alpha1.EphemeralRunnerSet, logger logr.Logger) (ctrl.Result, error) {
+	done, err := r.cleanupListener(ctx, autoscalingRunnerSet, logger)
+	if err != nil {
+		logger.Error(err, "Failed to clean up listener")
+		return ctrl.Result{}, err
+	}
+	if !done {
+		logger.Info("Waiting for listener to be deleted before scaling down the paused runner set")
+		return ctrl.Result{}, nil
+	}
+
+	if latestRunnerSet.Spec.Replicas != 0 || latestRunnerSet.Spec.MinIdleReplicas != 0 {
+		logger.Info("Scaling down ephemeral runner set of paused runner set", "name", latestRunnerSet.Name)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.Replicas = 0
+			obj.Spec.MinIdleReplicas = 0
 		}); err != nil {
-			log.Error(err, "Failed to update autoscaling runner set status with current runner count")
+			logger.Error(err, "Failed to scale down ephemeral runner set")
 			return ctrl.Result{}, err
 		}
 	}
 
+	if err := r.updateStatus(ctx, autoscalingRunnerSet, latestRunnerSet); err != nil {
+		logger.Error(err, "Failed to update autoscaling runner set status")
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// ensureRunnerServiceAccount creates the ServiceAccount of the runner pods, owned by the AutoscalingRunnerSet
+// so it is garbage collected with it, and keeps its annotations up to date.
+// A ServiceAccount with the same name that is not owned by the AutoscalingRunnerSet is left untouched.
+func (r *AutoscalingRunnerSetReconciler) ensureRunnerServiceAccount(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) error {
+	desired := r.resourceBuilder.newRunnerServiceAccount(autoscalingRunnerSet)
+
+	serviceAccount := new(corev1.ServiceAccount)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), serviceAccount); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get runner service account: %v", err)
+		}
+
+		if err := ctrl.SetControllerReference(autoscalingRunnerSet, desired, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set controller reference on runner service account: %v", err)
+		}
+
+		logger.Info("Creating runner service account", "name", desired.Name)
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create runner service account: %v", err)
+		}
+		return nil
+	}
+
+	if !metav1.IsControlledBy(serviceAccount, autoscalingRunnerSet) {
+		logger.Info("Runner service account is not owned by the autoscaling runner set, leaving it untouched", "name", serviceAccount.Name)
+		return nil
+	}
+
+	if len(serviceAccount.Annotations) == 0 && len(desired.Annotations) == 0 ||
+		reflect.DeepEqual(serviceAccount.Annotations, desired.Annotations) {
+		return nil
+	}
+
+	logger.Info("Updating annotations of the runner service account", "name", serviceAccount.Name)
+	if err := patch(ctx, r.Client, serviceAccount, func(obj *corev1.ServiceAccount) {
+		obj.Annotations = desired.Annotations
+	}); err != nil {
+		return fmt.Errorf("failed to update runner service account: %v", err)
+	}
+	return nil
+}
+
+func (r *AutoscalingRunnerSetReconciler) updateStatus(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, latestRunnerSet *v1alpha1.EphemeralRunnerSet) error {
+	paused := pausedCondition(autoscalingRunnerSet.Generation, autoscalingRunnerSet.Spec.Paused)
+	cordoned := cordonedCondition(autoscalingRunnerSet.Generation, autoscalingRunnerSet.Spec.Cordoned)
+	priorityClass, err := r.priorityClassCondition(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return err
+	}
+	imagePullSecret, err := r.imagePullSecretCondition(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return err
+	}
+	// The configuration is valid, or the autoscaling runner set would not be reconciled this far.
+	configuration := invalidConfigurationCondition(autoscalingRunnerSet.Generation, "")
+	conditions := []metav1.Condition{paused, cordoned, priorityClass, imagePullSecret, configuration}
+	if r.ScaleSetCheckInterval > 0 {
+		// The runner scale set was found, or the autoscaling runner set would not be reconciled this far.
+		scaleSetId, _ := strconv.Atoi(autoscalingRunnerSet.Annotations[runnerScaleSetIdKey])
+		conditions = append(conditions, scaleSetMissingCondition(autoscalingRunnerSet.Generation, scaleSetId, false))
+	}
+
+	changed := latestRunnerSet.Status.CurrentReplicas != autoscalingRunnerSet.Status.CurrentRunners
+	for _, condition := range conditions {
+		changed = changed || conditionChanged(autoscalingRunnerSet.Status.Conditions, condition)
+	}
+	if !changed {
+		return nil
+	}
+
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		obj.Status.CurrentRunners = latestRunnerSet.Status.CurrentReplicas
+		for _, condition := range conditions {
+			meta.SetStatusCondition(&obj.Status.Conditions, condition)
+		}
+	})
+}
+
+// runnerScaleSetExists checks that the runner scale set still exists in GitHub. The result is cached for
+// the ScaleSetCheckInterval, so the Actions service is called at most once per interval for each AutoscalingRunnerSet.
+// It always reports true when the check is disabled.
+func (r *AutoscalingRunnerSetReconciler) runnerScaleSetExists(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, runnerScaleSetId int, now time.Time) (bool, error) {
+	if r.ScaleSetCheckInterval <= 0 {
+		return true, nil
+	}
+
+	r.scaleSetChecksMu.Lock()
+	check, ok := r.scaleSetChecks[autoscalingRunnerSet.UID]
+	r.scaleSetChecksMu.Unlock()
+	if ok && check.runnerScaleSetId == runnerScaleSetId && now.Sub(check.checkedAt) < r.ScaleSetCheckInterval {
+		return check.exists, nil
+	}
+
+	actionsClient, err := r.actionsClientFor(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return false, err
+	}
+
+	runnerScaleSet, err := actionsClient.GetRunnerScaleSetById(ctx, runnerScaleSetId)
+	if err != nil {
+		actionsError := &actions.ActionsError{}
+		if !errors.As(err, &actionsError) || actionsError.StatusCode != http.StatusNotFound {
+			return false, fmt.Errorf("failed to get runner scale set %d: %w", runnerScaleSetId, err)
+		}
+	}
+
+	exists := err == nil && runnerScaleSet != nil
+	r.scaleSetChecksMu.Lock()
+	if r.scaleSetChecks == nil {
+		r.scaleSetChecks = make(map[types.UID]scaleSetCheck)
+	}
+	r.scaleSetChecks[autoscalingRunnerSet.UID] = scaleSetCheck{
+		runnerScaleSetId: runnerScaleSetId,
+		checkedAt:        now,
+		exists:           exists,
+	}
+	r.scaleSetChecksMu.Unlock()
+	return exists, nil
+}
+
+func (r *AutoscalingRunnerSetReconciler) forgetScaleSetCheck(uid types.UID) {
+	r.scaleSetChecksMu.Lock()
+	defer r.scaleSetChecksMu.Unlock()
+	delete(r.scaleSetChecks, uid)
+}
+
+func scaleSetMissingCondition(generation int64, runnerScaleSetId int, missing bool) metav1.Condition {
+	if !missing {
+		return metav1.Condition{
+			Type:               v1alpha1.AutoscalingRunnerSetConditionScaleSetMissing,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "ScaleSetFound",
+			Message:            fmt.Sprintf("The runner scale set %d exists in GitHub", runnerScaleSetId),
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.AutoscalingRunnerSetConditionScaleSetMissing,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "ScaleSetMissing",
+		Message: fmt.Sprintf("The runner scale set %d no longer exists in GitHub. Remove the %s annotation to recreate it, or delete the runner set",
+			runnerScaleSetId, runnerScaleSetIdKey),
+	}
+}
+
+// priorityClassCondition checks that the priority class of the runner pods exists.
+func (r *AutoscalingRunnerSetReconciler) priorityClassCondition(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (metav1.Condition, error) {
+	name := autoscalingRunnerSet.RunnerTemplate().Spec.PriorityClassName
+	if name == "" {
+		return metav1.Condition{
+			Type:               v1alpha1.AutoscalingRunnerSetConditionPriorityClassNotFound,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: autoscalingRunnerSet.Generation,
+			Reason:             "PriorityClassNotSet",
+			Message:            "The runner pods don't set a priority class",
+		}, nil
+	}
+
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, new(schedulingv1.PriorityClass)); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return metav1.Condition{}, fmt.Errorf("failed to get priority class %q: %v", name, err)
+		}
+
+		return metav1.Condition{
+			Type:               v1alpha1.AutoscalingRunnerSetConditionPriorityClassNotFound,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: autoscalingRunnerSet.Generation,
+			Reason:             "PriorityClassNotFound",
+			Message:            fmt.Sprintf("The priority class %q of the runner pods does not exist", name),
+		}, nil
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.AutoscalingRunnerSetConditionPriorityClassNotFound,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: autoscalingRunnerSet.Generation,
+		Reason:             "PriorityClassFound",
+		Message:            fmt.Sprintf("The priority class %q of the runner pods exists", name),
+	}, nil
+}
+
+// imagePullSecretCondition checks that the image pull secrets of the runner pods exist.
+func (r *AutoscalingRunnerSetReconciler) imagePullSecretCondition(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (metav1.Condition, error) {
+	var missing []string
+	for _, secret := range autoscalingRunnerSet.RunnerTemplate().Spec.ImagePullSecrets {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: secret.Name}, new(corev1.Secret)); err != nil {
+			if !kerrors.IsNotFound(err) {
+				return metav1.Condition{}, fmt.Errorf("failed to get image pull secret %q: %v", secret.Name, err)
+			}
+			missing = append(missing, secret.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return metav1.Condition{
+			Type:               v1alpha1.AutoscalingRunnerSetConditionImagePullSecretNotFound,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: autoscalingRunnerSet.Generation,
+			Reason:             "ImagePullSecretNotFound",
+			Message:            fmt.Sprintf("The image pull secrets %s of the runner pods do not exist", strings.Join(missing, ", ")),
+		}, nil
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.AutoscalingRunnerSetConditionImagePullSecretNotFound,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: autoscalingRunnerSet.Generation,
+		Reason:             "ImagePullSecretsFound",
+		Message:            "The image pull secrets of the runner pods exist",
+	}, nil
+}
+
+// configSecretProblem describes what is wrong with the GitHub config secret of the autoscaling runner set:
+// it must exist and hold either a github_token, or the github_app_id and github_app_private_key of a GitHub App.
+// It returns an empty string when the secret is valid.
+func (r *AutoscalingRunnerSetReconciler) configSecretProblem(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (string, error) {
+	name := autoscalingRunnerSet.Spec.GitHubConfigSecret
+	if name == "" {
+		return "The GitHub config secret is not set", nil
+	}
+
+	secret := new(corev1.Secret)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: name}, secret); err != nil {
+		if kerrors.IsNotFound(err) {
+			return fmt.Sprintf("The GitHub config secret %q does not exist", name), nil
+		}
+		return "", fmt.Errorf("failed to get GitHub config secret %q: %v", name, err)
+	}
+
+	hasToken := len(secret.Data["github_token"]) > 0
+	appID := string(secret.Data["github_app_id"])
+	hasAppID := len(appID) > 0
+	hasPrivateKey := len(secret.Data["github_app_private_key"]) > 0
+	switch {
+	case hasToken && (hasAppID || hasPrivateKey):
+		return fmt.Sprintf("The GitHub config secret %q must hold either github_token or GitHub App credentials, not both", name), nil
+	case hasToken:
+		return "", nil
+	case !hasAppID && !hasPrivateKey:
+		return fmt.Sprintf("The GitHub config secret %q holds neither github_token nor github_app_id and github_app_private_key", name), nil
+	case !hasPrivateKey:
+		return fmt.Sprintf("The GitHub config secret %q is missing github_app_private_key", name), nil
+	case !hasAppID:
+		return fmt.Sprintf("The GitHub config secret %q is missing github_app_id", name), nil
+	}
+
+	if _, err := strconv.ParseInt(appID, 10, 64); err != nil {
+		return fmt.Sprintf("The github_app_id of the GitHub config secret %q is not a number", name), nil
+	}
+	if installationID := string(secret.Data["github_app_installation_id"]); installationID != "" {
+		if _, err := strconv.ParseInt(installationID, 10, 64); err != nil {
+			return fmt.Sprintf("The github_app_installation_id of the GitHub config secret %q is not a number", name), nil
+		}
+	}
+	return "", nil
+}
+
+func invalidConfigurationCondition(generation int64, problem string) metav1.Condition {
+	if problem == "" {
+		return metav1.Condition{
+			Type:               v1alpha1.AutoscalingRunnerSetConditionInvalidConfiguration,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "ValidConfiguration",
+			Message:            "The GitHub config secret holds valid credentials",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.AutoscalingRunnerSetConditionInvalidConfiguration,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "InvalidConfiguration",
+		Message:            problem,
+	}
+}
+
+func pausedCondition(generation int64, paused bool) metav1.Condition {
+	if !paused {
+		return metav1.Condition{
+			Type:               v1alpha1.AutoscalingRunnerSetConditionPaused,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "Active",
+			Message:            "The runner set acquires new jobs",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.AutoscalingRunnerSetConditionPaused,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "Paused",
+		Message:            "The runner set is paused and does not acquire new jobs",
+	}
+}
+
+func cordonedCondition(generation int64, cordoned bool) metav1.Condition {
+	if !cordoned {
+		return metav1.Condition{
+			Type:               v1alpha1.AutoscalingRunnerSetConditionCordoned,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "Uncordoned",
+			Message:            "The listener of the runner set acquires new jobs",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.AutoscalingRunnerSetConditionCordoned,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "Cordoned",
+		Message:            "The runner set is cordoned, its runners finish their jobs but no new jobs are acquired",
+	}
+}
+
 func (r *AutoscalingRunnerSetReconciler) cleanupListener(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) (done bool, err error) {
 	logger.Info("Cleaning up the listener")
 	var listener v1alpha1.AutoscalingListener
@@ -286,6 +751,88 @@ func (r *AutoscalingRunnerSetReconciler) cleanupEphemeralRunnerSets(ctx context.
 	return false, nil
 }
 
+// drainEphemeralRunnerSets scales the ephemeral runner sets down to zero, so idle runners are removed,
+// and returns how long to wait before checking again while runners are still assigned to a job.
+// A zero duration means draining is done, either because no runner is busy or because the drain timeout elapsed.
+func (r *AutoscalingRunnerSetReconciler) drainEphemeralRunnerSets(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) (time.Duration, error) {
+	timeout := defaultDrainTimeout
+	if autoscalingRunnerSet.Spec.DrainTimeout != nil {
+		timeout = autoscalingRunnerSet.Spec.DrainTimeout.Duration
+	}
+
+	remaining := drainTimeoutRemaining(timeout, autoscalingRunnerSet.DeletionTimestamp, time.Now())
+	if remaining <= 0 {
+		logger.Info("Drain timeout elapsed, deleting remaining ephemeral runners", "timeout", timeout)
+		return 0, nil
+	}
+
+	runnerSets, err := r.listEphemeralRunnerSets(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list ephemeral runner sets: %v", err)
+	}
+
+	busy := 0
+	items := runnerSets.all()
+	for i := range items {
+		rs := &items[i]
+		if rs.Spec.Replicas != 0 || rs.Spec.MinIdleReplicas != 0 {
+			logger.Info("Scaling down ephemeral runner set to drain it", "name", rs.Name)
+			if err := patch(ctx, r.Client, rs, func(obj *v1alpha1.EphemeralRunnerSet) {
+				obj.Spec.Replicas = 0
+				obj.Spec.MinIdleReplicas = 0
+			}); err != nil {
+				return 0, fmt.Errorf("failed to scale down ephemeral runner set %s: %v", rs.Name, err)
+			}
+		}
+
+		n, err := r.countBusyEphemeralRunners(ctx, rs)
+		if err != nil {
+			return 0, err
+		}
+		busy += n
+	}
+
+	if busy == 0 {
+		logger.Info("All jobs finished, ephemeral runner sets are drained")
+		return 0, nil
+	}
+
+	logger.Info("Ephemeral runners are still running jobs", "busy", busy, "remaining", remaining)
+	if remaining < drainRequeueInterval {
+		return remaining, nil
+	}
+	return drainRequeueInterval, nil
+}
+
+// countBusyEphemeralRunners returns the number of ephemeral runners of the runner set that are assigned to a job
+// and have not finished yet.
+func (r *AutoscalingRunnerSetReconciler) countBusyEphemeralRunners(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet) (int, error) {
+	var runners v1alpha1.EphemeralRunnerList
+	if err := r.List(ctx, &runners, client.InNamespace(runnerSet.Namespace)); err != nil {
+		return 0, fmt.Errorf("failed to list ephemeral runners: %v", err)
+	}
+
+	busy := 0
+	for i := range runners.Items {
+		runner := &runners.Items[i]
+		if !metav1.IsControlledBy(runner, runnerSet) {
+			continue
+		}
+		if runner.Status.JobRequestId > 0 && (runner.Status.Phase == corev1.PodPending || runner.Status.Phase == corev1.PodRunning) {
+			busy++
+		}
+	}
+	return busy, nil
+}
+
+// drainTimeoutRemaining returns how long draining can still go on after the deletion was requested.
+func drainTimeoutRemaining(timeout time.Duration, deletionTimestamp *metav1.Time, now time.Time) time.Duration {
+	if deletionTimestamp == nil {
+		return timeout
+	}
+	return deletionTimestamp.Add(timeout).Sub(now)
+}
+
 func (r *AutoscalingRunnerSetReconciler) deleteEphemeralRunnerSets(ctx context.Context, oldRunnerSets []v1alpha1.EphemeralRunnerSet, logger logr.Logger) error {
 	for i := range oldRunnerSets {
 		rs := &oldRunnerSets[i]
@@ -363,6 +910,7 @@ func (r *AutoscalingRunnerSetReconciler) createRunnerScaleSet(ctx context.Contex
 		obj.Annotations[runnerScaleSetNameKey] = runnerScaleSet.Name
 		obj.Annotations[runnerScaleSetIdKey] = strconv.Itoa(runnerScaleSet.Id)
 		obj.Annotations[runnerScaleSetRunnerGroupNameKey] = runnerScaleSet.RunnerGroupName
+		obj.Annotations[runnerScaleSetRunnerGroupIdKey] = strconv.Itoa(runnerScaleSet.RunnerGroupId)
 	}); err != nil {
 		logger.Error(err, "Failed to add runner scale set ID, name and runner group name as an annotation")
 		return ctrl.Result{}, err
@@ -408,6 +956,7 @@ func (r *AutoscalingRunnerSetReconciler) updateRunnerScaleSetRunnerGroup(ctx con
 	logger.Info("Updating runner scale set runner group name as an annotation")
 	if err := patch(ctx, r.Client, autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
 		obj.Annotations[runnerScaleSetRunnerGroupNameKey] = updatedRunnerScaleSet.RunnerGroupName
+		obj.Annotations[runnerScaleSetRunnerGroupIdKey] = strconv.Itoa(updatedRunnerScaleSet.RunnerGroupId)
 	}); err != nil {
 		logger.Error(err, "Failed to update runner group name annotation")
 		return ctrl.Result{}, err
@@ -543,7 +1092,7 @@ func (r *AutoscalingRunnerSetReconciler) actionsClientFor(ctx context.Context, a
 
 	var opts []actions.ClientOption
 	if autoscalingRunnerSet.Spec.Proxy != nil {
-		proxyFunc, err := autoscalingRunnerSet.Spec.Proxy.ProxyFunc(func(s string) (*corev1.Secret, error) {
+		secretFetcher := func(s string) (*corev1.Secret, error) {
 			var secret corev1.Secret
 			err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: s}, &secret)
 			if err != nil {
@@ -551,23 +1100,57 @@ func (r *AutoscalingRunnerSetReconciler) actionsClientFor(ctx context.Context, a
 			}
 
 			return &secret, nil
-		})
+		}
+
+		proxyFunc, err := autoscalingRunnerSet.Spec.Proxy.ProxyFunc(secretFetcher)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get proxy func: %w", err)
 		}
 
 		opts = append(opts, actions.WithProxy(proxyFunc))
+
+		caCertificate, err := autoscalingRunnerSet.Spec.Proxy.CACertificate(secretFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get proxy ca certificate: %w", err)
+		}
+
+		if caCertificate != nil {
+			opts = append(opts, actions.WithProxyCACertificate(caCertificate))
+		}
 	}
 
 	return r.ActionsClient.GetClientFromSecret(
 		ctx,
 		autoscalingRunnerSet.Spec.GitHubConfigUrl,
 		autoscalingRunnerSet.Namespace,
-		configSecret.Data,
+		actions.KubernetesSecret{
+			Name:            configSecret.Name,
+			ResourceVersion: configSecret.ResourceVersion,
+			Data:            configSecret.Data,
+		},
 		opts...,
 	)
 }
 
+// updateResourceMetadata propagates the resource labels and annotations of the AutoscalingRunnerSet to the EphemeralRunnerSet,
+// which propagates them to its EphemeralRunner resources in turn.
+func (r *AutoscalingRunnerSetReconciler) updateResourceMetadata(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
+	labels := autoscalingRunnerSet.Spec.ResourceLabels
+	annotations := autoscalingRunnerSet.Spec.ResourceAnnotations
+	if !mergeResourceMetadata(ephemeralRunnerSet.DeepCopy(), labels, annotations) &&
+		reflect.DeepEqual(ephemeralRunnerSet.Spec.ResourceLabels, labels) &&
+		reflect.DeepEqual(ephemeralRunnerSet.Spec.ResourceAnnotations, annotations) {
+		return nil
+	}
+
+	log.Info("Updating resource labels and annotations of the ephemeral runner set", "name", ephemeralRunnerSet.Name)
+	return patch(ctx, r.Client, ephemeralRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+		mergeResourceMetadata(obj, labels, annotations)
+		obj.Spec.ResourceLabels = labels
+		obj.Spec.ResourceAnnotations = annotations
+	})
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *AutoscalingRunnerSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	groupVersionIndexer := func(rawObj client.Object) []string {
@@ -593,6 +1176,7 @@ func (r *AutoscalingRunnerSetReconciler) SetupWithManager(mgr ctrl.Manager) erro
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.AutoscalingRunnerSet{}).
 		Owns(&v1alpha1.EphemeralRunnerSet{}).
+		Owns(&corev1.ServiceAccount{}).
 		Watches(&source.Kind{Type: &v1alpha1.AutoscalingListener{}}, handler.EnqueueRequestsFromMapFunc(
 			func(o client.Object) []reconcile.Request {
 				autoscalingListener := o.(*v1alpha1.AutoscalingListener)
@@ -607,7 +1191,8 @@ func (r *AutoscalingRunnerSetReconciler) SetupWithManager(mgr ctrl.Manager) erro
 			},
 		)).
 		WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(instrumentReconciler("autoscalingrunnerset", r))
 }
 
 // NOTE: if this is logic should be used for other resources,
@@ -7,9 +7,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"testing"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -18,7 +20,10 @@ import (
 	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
@@ -788,3 +793,338 @@ var _ = Describe("Test Client optional configuration", func() {
 		})
 	})
 })
+
+func TestDrainTimeoutRemaining(t *testing.T) {
+	now := time.Now()
+	deletionTimestamp := metav1.NewTime(now.Add(-10 * time.Minute))
+
+	assert.Equal(t, time.Hour, drainTimeoutRemaining(time.Hour, nil, now))
+	assert.Equal(t, 50*time.Minute, drainTimeoutRemaining(time.Hour, &deletionTimestamp, now))
+	assert.Equal(t, -5*time.Minute, drainTimeoutRemaining(5*time.Minute, &deletionTimestamp, now))
+}
+
+func TestCountBusyEphemeralRunners(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default", UID: "runner-set-uid"},
+	}
+	otherRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-runner-set", Namespace: "default", UID: "other-runner-set-uid"},
+	}
+
+	newRunner := func(name string, owner *v1alpha1.EphemeralRunnerSet, jobRequestId int64, phase corev1.PodPhase) *v1alpha1.EphemeralRunner {
+		runner := &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		}
+		require.NoError(t, controllerutil.SetControllerReference(owner, runner, scheme))
+		runner.Status.JobRequestId = jobRequestId
+		runner.Status.Phase = phase
+		return runner
+	}
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client: newFakeClient(
+			scheme,
+			newRunner("busy", runnerSet, 1, corev1.PodRunning),
+			newRunner("idle", runnerSet, 0, corev1.PodRunning),
+			newRunner("finished", runnerSet, 2, corev1.PodSucceeded),
+			newRunner("other-busy", otherRunnerSet, 3, corev1.PodRunning),
+		),
+	}
+
+	busy, err := r.countBusyEphemeralRunners(context.Background(), runnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, 1, busy)
+}
+
+func TestPauseAutoscalingRunnerSet(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default", Generation: 2},
+		Spec:       v1alpha1.AutoscalingRunnerSetSpec{Paused: true},
+	}
+	runnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set-abcde", Namespace: "default"},
+		Spec:       v1alpha1.EphemeralRunnerSetSpec{Replicas: 3, MinIdleReplicas: 1},
+		Status:     v1alpha1.EphemeralRunnerSetStatus{CurrentReplicas: 3},
+	}
+	listener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{Name: scaleSetListenerName(autoscalingRunnerSet), Namespace: "arc-systems"},
+	}
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client:              newFakeClient(scheme, autoscalingRunnerSet, runnerSet, listener),
+		ControllerNamespace: "arc-systems",
+	}
+	ctx := context.Background()
+	logger := logr.Discard()
+
+	_, err := r.pause(ctx, autoscalingRunnerSet, runnerSet, logger)
+	require.NoError(t, err)
+
+	err = r.Get(ctx, client.ObjectKeyFromObject(listener), new(v1alpha1.AutoscalingListener))
+	assert.True(t, errors.IsNotFound(err), "listener should be deleted")
+
+	updated := new(v1alpha1.EphemeralRunnerSet)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runnerSet), updated))
+	assert.Equal(t, 3, updated.Spec.Replicas, "runner set should not be scaled before the listener is gone")
+
+	_, err = r.pause(ctx, autoscalingRunnerSet, runnerSet, logger)
+	require.NoError(t, err)
+
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runnerSet), updated))
+	assert.Equal(t, 0, updated.Spec.Replicas)
+	assert.Equal(t, 0, updated.Spec.MinIdleReplicas)
+
+	updatedAutoscalingRunnerSet := new(v1alpha1.AutoscalingRunnerSet)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), updatedAutoscalingRunnerSet))
+	condition := meta.FindStatusCondition(updatedAutoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionPaused)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, int64(2), condition.ObservedGeneration)
+}
+
+func TestPausedCondition(t *testing.T) {
+	paused := pausedCondition(1, true)
+	assert.Equal(t, v1alpha1.AutoscalingRunnerSetConditionPaused, paused.Type)
+	assert.Equal(t, metav1.ConditionTrue, paused.Status)
+	assert.Equal(t, "Paused", paused.Reason)
+
+	active := pausedCondition(1, false)
+	assert.Equal(t, metav1.ConditionFalse, active.Status)
+	assert.Equal(t, "Active", active.Reason)
+}
+
+func TestCordonedCondition(t *testing.T) {
+	cordoned := cordonedCondition(1, true)
+	assert.Equal(t, v1alpha1.AutoscalingRunnerSetConditionCordoned, cordoned.Type)
+	assert.Equal(t, metav1.ConditionTrue, cordoned.Status)
+	assert.Equal(t, "Cordoned", cordoned.Reason)
+
+	uncordoned := cordonedCondition(1, false)
+	assert.Equal(t, metav1.ConditionFalse, uncordoned.Status)
+	assert.Equal(t, "Uncordoned", uncordoned.Reason)
+}
+
+func TestPriorityClassCondition(t *testing.T) {
+	scheme := newTestScheme(t, schedulingv1.AddToScheme)
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client: newFakeClient(scheme, &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "runners"}}),
+	}
+	ctx := context.Background()
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default", Generation: 3},
+	}
+	condition, err := r.priorityClassCondition(ctx, autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "PriorityClassNotSet", condition.Reason)
+
+	autoscalingRunnerSet.Spec.PriorityClassName = "runners"
+	condition, err = r.priorityClassCondition(ctx, autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "PriorityClassFound", condition.Reason)
+	assert.Equal(t, int64(3), condition.ObservedGeneration)
+
+	autoscalingRunnerSet.Spec.Template.Spec.PriorityClassName = "missing"
+	condition, err = r.priorityClassCondition(ctx, autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status, "the priority class of the template takes precedence")
+	assert.Equal(t, "PriorityClassNotFound", condition.Reason)
+}
+
+func TestImagePullSecretCondition(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client: newFakeClient(scheme, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "default"}}),
+	}
+	ctx := context.Background()
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default", Generation: 3},
+	}
+	condition, err := r.imagePullSecretCondition(ctx, autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+
+	autoscalingRunnerSet.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	condition, err = r.imagePullSecretCondition(ctx, autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "ImagePullSecretsFound", condition.Reason)
+	assert.Equal(t, int64(3), condition.ObservedGeneration)
+
+	autoscalingRunnerSet.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "missing"}}
+	condition, err = r.imagePullSecretCondition(ctx, autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status, "the secrets of the template are checked too")
+	assert.Equal(t, "ImagePullSecretNotFound", condition.Reason)
+	assert.Equal(t, "The image pull secrets missing of the runner pods do not exist", condition.Message)
+}
+
+func TestConfigSecretProblem(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	tests := map[string]struct {
+		data    map[string][]byte
+		problem string
+	}{
+		"token": {
+			data: map[string][]byte{"github_token": []byte("token")},
+		},
+		"app": {
+			data: map[string][]byte{"github_app_id": []byte("1"), "github_app_installation_id": []byte("2"), "github_app_private_key": []byte("key")},
+		},
+		"app without installation id": {
+			data: map[string][]byte{"github_app_id": []byte("1"), "github_app_private_key": []byte("key")},
+		},
+		"empty": {
+			data:    map[string][]byte{},
+			problem: `The GitHub config secret "config" holds neither github_token nor github_app_id and github_app_private_key`,
+		},
+		"token and app": {
+			data:    map[string][]byte{"github_token": []byte("token"), "github_app_id": []byte("1")},
+			problem: `The GitHub config secret "config" must hold either github_token or GitHub App credentials, not both`,
+		},
+		"app without private key": {
+			data:    map[string][]byte{"github_app_id": []byte("1")},
+			problem: `The GitHub config secret "config" is missing github_app_private_key`,
+		},
+		"non-numeric installation id": {
+			data:    map[string][]byte{"github_app_id": []byte("1"), "github_app_installation_id": []byte("abc"), "github_app_private_key": []byte("key")},
+			problem: `The github_app_installation_id of the GitHub config secret "config" is not a number`,
+		},
+	}
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default"},
+		Spec:       v1alpha1.AutoscalingRunnerSetSpec{GitHubConfigSecret: "config"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &AutoscalingRunnerSetReconciler{
+				Client: newFakeClient(scheme, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"}, Data: tt.data}),
+			}
+			problem, err := r.configSecretProblem(ctx, autoscalingRunnerSet)
+			require.NoError(t, err)
+			assert.Equal(t, tt.problem, problem)
+		})
+	}
+
+	r := &AutoscalingRunnerSetReconciler{Client: newFakeClient(scheme)}
+	problem, err := r.configSecretProblem(ctx, autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, `The GitHub config secret "config" does not exist`, problem)
+
+	condition := invalidConfigurationCondition(2, problem)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "InvalidConfiguration", condition.Reason)
+	assert.Equal(t, int64(2), condition.ObservedGeneration)
+	assert.Equal(t, metav1.ConditionFalse, invalidConfigurationCondition(2, "").Status)
+}
+
+func TestRunnerScaleSetExists(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	configSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(autoscalingRunnerSetTestGitHubToken)},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default", UID: "uid", Generation: 2},
+		Spec:       v1alpha1.AutoscalingRunnerSetSpec{GitHubConfigSecret: configSecret.Name},
+	}
+	newReconciler := func(scaleSet *actions.RunnerScaleSet, err error) *AutoscalingRunnerSetReconciler {
+		return &AutoscalingRunnerSetReconciler{
+			Client:                newFakeClient(scheme, configSecret),
+			ActionsClient:         fake.NewMultiClient(fake.WithDefaultClient(fake.NewFakeClient(fake.WithGetRunnerScaleSetById(scaleSet, err)), nil)),
+			ScaleSetCheckInterval: time.Minute,
+		}
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	r := newReconciler(&actions.RunnerScaleSet{Id: 1}, nil)
+	exists, err := r.runnerScaleSetExists(ctx, autoscalingRunnerSet, 1, now)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	r = newReconciler(nil, &actions.ActionsError{StatusCode: http.StatusNotFound})
+	exists, err = r.runnerScaleSetExists(ctx, autoscalingRunnerSet, 1, now)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	r.ActionsClient = fake.NewMultiClient(fake.WithDefaultClient(fake.NewFakeClient(fake.WithGetRunnerScaleSetById(&actions.RunnerScaleSet{Id: 1}, nil)), nil))
+	exists, err = r.runnerScaleSetExists(ctx, autoscalingRunnerSet, 1, now.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.False(t, exists, "the result is cached for the check interval")
+
+	exists, err = r.runnerScaleSetExists(ctx, autoscalingRunnerSet, 1, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, exists, "the runner scale set is checked again after the check interval")
+
+	r = newReconciler(nil, &actions.ActionsError{StatusCode: http.StatusInternalServerError})
+	_, err = r.runnerScaleSetExists(ctx, autoscalingRunnerSet, 1, now)
+	assert.Error(t, err, "errors other than not found don't report the runner scale set as missing")
+
+	r.ScaleSetCheckInterval = 0
+	exists, err = r.runnerScaleSetExists(ctx, autoscalingRunnerSet, 1, now)
+	require.NoError(t, err)
+	assert.True(t, exists, "the check is disabled")
+}
+
+func TestScaleSetMissingCondition(t *testing.T) {
+	missing := scaleSetMissingCondition(2, 5, true)
+	assert.Equal(t, v1alpha1.AutoscalingRunnerSetConditionScaleSetMissing, missing.Type)
+	assert.Equal(t, metav1.ConditionTrue, missing.Status)
+	assert.Equal(t, "ScaleSetMissing", missing.Reason)
+	assert.Contains(t, missing.Message, runnerScaleSetIdKey)
+	assert.Equal(t, int64(2), missing.ObservedGeneration)
+
+	found := scaleSetMissingCondition(2, 5, false)
+	assert.Equal(t, metav1.ConditionFalse, found.Status)
+	assert.Equal(t, "ScaleSetFound", found.Reason)
+}
+
+func TestEnsureRunnerServiceAccount(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-set", Namespace: "default", UID: "ars-uid"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			CreateServiceAccount:      true,
+			ServiceAccountAnnotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/runners"},
+		},
+	}
+	unowned := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "other-runner", Namespace: "default"}}
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client: newFakeClient(scheme, autoscalingRunnerSet, unowned),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+
+	require.NoError(t, r.ensureRunnerServiceAccount(ctx, autoscalingRunnerSet, logr.Discard()))
+	serviceAccount := new(corev1.ServiceAccount)
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: "runner-set-runner"}, serviceAccount))
+	assert.True(t, metav1.IsControlledBy(serviceAccount, autoscalingRunnerSet), "service account should be garbage collected with the runner set")
+	assert.Equal(t, autoscalingRunnerSet.Spec.ServiceAccountAnnotations, serviceAccount.Annotations)
+
+	autoscalingRunnerSet.Spec.ServiceAccountAnnotations = map[string]string{"iam.gke.io/gcp-service-account": "runners@project.iam.gserviceaccount.com"}
+	require.NoError(t, r.ensureRunnerServiceAccount(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: "runner-set-runner"}, serviceAccount))
+	assert.Equal(t, autoscalingRunnerSet.Spec.ServiceAccountAnnotations, serviceAccount.Annotations)
+
+	other := autoscalingRunnerSet.DeepCopy()
+	other.Name = "other"
+	require.NoError(t, r.ensureRunnerServiceAccount(ctx, other, logr.Discard()))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(unowned), serviceAccount))
+	assert.Empty(t, serviceAccount.Annotations, "a service account not owned by the runner set should be left untouched")
+}
@@ -0,0 +1,149 @@
+package actionsgithubcom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// completionNotifierRetries is how many times the delivery of a completion record is retried before it is dropped.
+	completionNotifierRetries = 5
+	// completionNotifierBackoff is the delay before the first retry, doubled on each retry up to completionNotifierBackoffMax.
+	completionNotifierBackoff    = time.Second
+	completionNotifierBackoffMax = 30 * time.Second
+	completionNotifierTimeout    = 10 * time.Second
+)
+
+// CompletionRecord is the JSON record posted to the completion webhook when an ephemeral runner completes a job.
+type CompletionRecord struct {
+	Namespace          string          `json:"namespace"`
+	EphemeralRunnerSet string          `json:"ephemeralRunnerSet"`
+	EphemeralRunner    string          `json:"ephemeralRunner"`
+	RunnerScaleSetId   int             `json:"runnerScaleSetId"`
+	JobRequestId       int64           `json:"jobRequestId"`
+	RepositoryName     string          `json:"repositoryName,omitempty"`
+	Phase              corev1.PodPhase `json:"phase"`
+	Succeeded          bool            `json:"succeeded"`
+	// DurationSeconds is the time between the assignment of the job and its completion.
+	// It is zero when the assignment time of the job is unknown.
+	DurationSeconds float64   `json:"durationSeconds"`
+	CompletedAt     time.Time `json:"completedAt"`
+}
+
+// CompletionNotifier posts a CompletionRecord to a webhook for each job completed by an ephemeral runner.
+// Records are queued and delivered in the background with retries, so the reconciliation is never blocked:
+// records are dropped when the queue is full or their delivery keeps failing.
+// A nil CompletionNotifier doesn't notify anything.
+type CompletionNotifier struct {
+	url    string
+	client *http.Client
+	log    logr.Logger
+	queue  chan CompletionRecord
+
+	retries    int
+	backoff    time.Duration
+	backoffMax time.Duration
+}
+
+// NewCompletionNotifier returns a CompletionNotifier posting to url, queueing up to queueSize records.
+// It returns nil, which doesn't notify anything, when url is empty.
+func NewCompletionNotifier(url string, queueSize int, log logr.Logger) *CompletionNotifier {
+	if url == "" {
+		return nil
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &CompletionNotifier{
+		url:        url,
+		client:     &http.Client{Timeout: completionNotifierTimeout},
+		log:        log,
+		queue:      make(chan CompletionRecord, queueSize),
+		retries:    completionNotifierRetries,
+		backoff:    completionNotifierBackoff,
+		backoffMax: completionNotifierBackoffMax,
+	}
+}
+
+// Notify queues the record for delivery. It never blocks: the record is dropped when the queue is full.
+func (n *CompletionNotifier) Notify(record CompletionRecord) {
+	if n == nil {
+		return
+	}
+
+	select {
+	case n.queue <- record:
+	default:
+		n.log.Info("Completion record queue is full. Dropping the record", "ephemeralRunner", record.EphemeralRunner, "jobRequestId", record.JobRequestId)
+	}
+}
+
+// Start delivers the queued records until the context is done. It implements manager.Runnable.
+func (n *CompletionNotifier) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case record := <-n.queue:
+			n.deliver(ctx, record)
+		}
+	}
+}
+
+// deliver posts the record, retrying with an exponential backoff until it is accepted, the retries are exhausted
+// or the context is done.
+func (n *CompletionNotifier) deliver(ctx context.Context, record CompletionRecord) bool {
+	body, err := json.Marshal(record)
+	if err != nil {
+		n.log.Error(err, "Failed to marshal the completion record")
+		return false
+	}
+
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		err := n.post(ctx, body)
+		if err == nil {
+			return true
+		}
+		if attempt >= n.retries {
+			n.log.Error(err, "Failed to deliver the completion record. Dropping the record", "ephemeralRunner", record.EphemeralRunner, "jobRequestId", record.JobRequestId, "attempts", attempt+1)
+			return false
+		}
+
+		n.log.Info("Failed to deliver the completion record. Retrying", "error", err.Error(), "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > n.backoffMax {
+			backoff = n.backoffMax
+		}
+	}
+}
+
+func (n *CompletionNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+)
+
+func TestCompletionNotifier(t *testing.T) {
+	var disabled *CompletionNotifier
+	disabled.Notify(CompletionRecord{})
+	assert.Nil(t, NewCompletionNotifier("", 10, logr.Discard()))
+
+	var mu sync.Mutex
+	var records []CompletionRecord
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var record CompletionRecord
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&record))
+		records = append(records, record)
+	}))
+	defer server.Close()
+	received := func() []CompletionRecord {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]CompletionRecord(nil), records...)
+	}
+
+	notifier := NewCompletionNotifier(server.URL, 1, logr.Discard())
+	notifier.backoff = time.Millisecond
+	notifier.retries = 1
+
+	notifier.Notify(CompletionRecord{EphemeralRunner: "runner-1", JobRequestId: 1, Phase: corev1.PodSucceeded, Succeeded: true})
+	notifier.Notify(CompletionRecord{EphemeralRunner: "runner-2", JobRequestId: 2})
+	require.Len(t, notifier.queue, 1, "records are dropped when the queue is full")
+
+	ctx := context.Background()
+	assert.True(t, notifier.deliver(ctx, <-notifier.queue), "the delivery is retried")
+	delivered := received()
+	require.Len(t, delivered, 1)
+	assert.Equal(t, "runner-1", delivered[0].EphemeralRunner)
+	assert.Equal(t, int64(1), delivered[0].JobRequestId)
+	assert.True(t, delivered[0].Succeeded)
+
+	mu.Lock()
+	failures = 2
+	mu.Unlock()
+	assert.False(t, notifier.deliver(ctx, CompletionRecord{JobRequestId: 3}), "the record is dropped once the retries are exhausted")
+	assert.Len(t, received(), 1)
+}
+
+func TestNotifyCompletion(t *testing.T) {
+	controller := true
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.UID = "runner-uid"
+	runner.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: v1alpha1.GroupVersion.String(),
+		Kind:       "EphemeralRunnerSet",
+		Name:       "runner-set",
+		Controller: &controller,
+	}}
+	runner.Spec.RunnerScaleSetId = 3
+	runner.Status.JobRequestId = 10
+	runner.Status.JobRepositoryName = "owner/repo"
+	now := time.Now()
+	runner.Annotations = map[string]string{v1alpha1.AnnotationKeyJobAssignedAt: now.Add(-time.Minute).UTC().Format(time.RFC3339)}
+
+	r := &EphemeralRunnerReconciler{CompletionNotifier: NewCompletionNotifier("http://example.com", 10, logr.Discard())}
+	r.notifyCompletion(runner, corev1.PodFailed, now)
+	r.notifyCompletion(runner, corev1.PodFailed, now)
+	require.Len(t, r.CompletionNotifier.queue, 1, "each job request is notified once")
+
+	record := <-r.CompletionNotifier.queue
+	assert.Equal(t, "default", record.Namespace)
+	assert.Equal(t, "runner-set", record.EphemeralRunnerSet)
+	assert.Equal(t, "test-runner", record.EphemeralRunner)
+	assert.Equal(t, 3, record.RunnerScaleSetId)
+	assert.Equal(t, int64(10), record.JobRequestId)
+	assert.Equal(t, "owner/repo", record.RepositoryName)
+	assert.Equal(t, corev1.PodFailed, record.Phase)
+	assert.False(t, record.Succeeded)
+	assert.InDelta(t, time.Minute.Seconds(), record.DurationSeconds, 1)
+
+	runner.Status.JobRequestId = 11
+	r.notifyCompletion(runner, corev1.PodSucceeded, now)
+	require.Len(t, r.CompletionNotifier.queue, 1, "the next job request of the runner is notified")
+
+	runner.Status.JobRequestId = 0
+	r.notifyCompletion(runner, corev1.PodSucceeded, now)
+	assert.Len(t, r.CompletionNotifier.queue, 1, "runners without a job are not notified")
+}
@@ -5,6 +5,51 @@ const (
 	LabelKeyPodTemplateHash    = "pod-template-hash"
 )
 
+// AnnotationKeyRunnerSpecHash is set on each EphemeralRunner with the hash of the
+// EphemeralRunnerSet spec it was created from.
+const AnnotationKeyRunnerSpecHash = "actions.github.com/runner-spec-hash"
+
+// AnnotationKeyFinishedAt is set on each finished EphemeralRunner kept during the post job grace period
+// with the time its EphemeralRunnerSet first observed it as finished.
+const AnnotationKeyFinishedAt = "actions.github.com/finished-at"
+
+// AnnotationKeyUploadInProgress can be set on an EphemeralRunner, e.g. by a job hook, while the job is
+// still uploading logs or artifacts. Its EphemeralRunnerSet doesn't delete the finished EphemeralRunner
+// until the annotation is removed.
+const AnnotationKeyUploadInProgress = "actions.github.com/upload-in-progress"
+
+// AnnotationKeyJobCompletedAt is set on each EphemeralRunner with the time it was observed to complete its job.
+// Together with v1alpha1.AnnotationKeyJobAssignedAt it gives how long the runner was busy.
+const AnnotationKeyJobCompletedAt = "actions.github.com/job-completed-at"
+
+// AnnotationKeyNode is set on each EphemeralRunner with the name of the node its pod was scheduled to,
+// when the EphemeralRunner controller annotates runners with their node.
+const AnnotationKeyNode = "actions.github.com/node"
+
+// AnnotationKeyNoScaleDown can be set to "true" on an idle EphemeralRunner, e.g. while debugging it,
+// to keep its EphemeralRunnerSet from deleting it on scale down. The runner still counts towards the desired replicas.
+const AnnotationKeyNoScaleDown = "actions.github.com/no-scale-down"
+
+// LabelKeyRetainedFailure is set on failed EphemeralRunner resources and their pods kept for inspection
+// because of KeepFailedPod.
+const LabelKeyRetainedFailure = "actions.github.com/retained-failure"
+
+// LabelKeyBusy is set on each runner pod to "true" while its EphemeralRunner is assigned a job, and to "false" otherwise.
+// The busy runners PodDisruptionBudget of EphemeralRunnerSets with ProtectBusyRunners selects the busy runner pods with it.
+const LabelKeyBusy = "actions.github.com/busy"
+
+// LabelKeyEphemeralRunnerSetName is set on each runner pod with the name of the EphemeralRunnerSet of its EphemeralRunner,
+// unless the name is too long for a label value.
+const LabelKeyEphemeralRunnerSetName = "actions.github.com/ephemeral-runner-set-name"
+
+// LabelKeyManagedProxySecret is set on the proxy secrets created for EphemeralRunnerSet resources.
+// Only secrets with this label are removed by the orphaned proxy secret sweep.
+const LabelKeyManagedProxySecret = "actions.github.com/managed-proxy-secret"
+
+// AnnotationKeyEphemeralRunnerSetName is set on the proxy secrets created for EphemeralRunnerSet resources
+// with the name of the EphemeralRunnerSet using them.
+const AnnotationKeyEphemeralRunnerSetName = "actions.github.com/ephemeral-runner-set-name"
+
 const (
 	EnvVarRunnerJITConfig      = "ACTIONS_RUNNER_INPUT_JITCONFIG"
 	EnvVarRunnerExtraUserAgent = "GITHUB_ACTIONS_RUNNER_EXTRA_USER_AGENT"
@@ -0,0 +1,59 @@
+package actionsgithubcom
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DeregistrationLimiter paces the calls removing runners from the service, with a token bucket per runner scale set,
+// so mass scale-downs don't trip the rate limits of GitHub.
+// A nil DeregistrationLimiter doesn't limit anything.
+type DeregistrationLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[int]*rate.Limiter
+}
+
+// NewDeregistrationLimiter returns a DeregistrationLimiter allowing callsPerSecond runner removals per runner scale set,
+// with bursts of up to burst removals. It returns nil, which doesn't limit anything, when callsPerSecond is not positive.
+func NewDeregistrationLimiter(callsPerSecond float64, burst int) *DeregistrationLimiter {
+	if callsPerSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &DeregistrationLimiter{
+		limit:    rate.Limit(callsPerSecond),
+		burst:    burst,
+		limiters: make(map[int]*rate.Limiter),
+	}
+}
+
+// Reserve takes a token of the runner scale set to remove one of its runners from the service.
+// When no token is available, no token is taken and it returns how long to wait before trying again.
+// It returns zero when the runner can be removed now.
+func (l *DeregistrationLimiter) Reserve(runnerScaleSetId int, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	limiter, ok := l.limiters[runnerScaleSetId]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[runnerScaleSetId] = limiter
+	}
+	l.mu.Unlock()
+
+	reservation := limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+	}
+	return delay
+}
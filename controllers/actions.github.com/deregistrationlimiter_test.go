@@ -0,0 +1,28 @@
+package actionsgithubcom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeregistrationLimiter(t *testing.T) {
+	var disabled *DeregistrationLimiter
+	assert.Zero(t, disabled.Reserve(1, time.Now()))
+	assert.Nil(t, NewDeregistrationLimiter(0, 10))
+
+	now := time.Now()
+	limiter := NewDeregistrationLimiter(1, 2)
+	assert.Zero(t, limiter.Reserve(1, now))
+	assert.Zero(t, limiter.Reserve(1, now))
+
+	delay := limiter.Reserve(1, now)
+	assert.Equal(t, time.Second, delay, "the burst is exhausted")
+	assert.Equal(t, time.Second, limiter.Reserve(1, now), "deferred calls don't take a token")
+
+	assert.Zero(t, limiter.Reserve(2, now), "each runner scale set has its own bucket")
+
+	assert.Zero(t, limiter.Reserve(1, now.Add(delay)))
+	assert.NotZero(t, limiter.Reserve(1, now.Add(delay)))
+}
@@ -20,48 +20,207 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/controllers/actions.github.com/metrics"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
 	"go.uber.org/multierr"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 const (
-	// EphemeralRunnerContainerName is the name of the runner container.
-	// It represents the name of the container running the self-hosted runner image.
+	// EphemeralRunnerContainerName is the default name of the runner container.
+	// It represents the name of the container running the self-hosted runner image,
+	// unless the EphemeralRunner sets RunnerContainerName.
 	EphemeralRunnerContainerName = "runner"
 
 	ephemeralRunnerFinalizerName        = "ephemeralrunner.actions.github.com/finalizer"
 	ephemeralRunnerActionsFinalizerName = "ephemeralrunner.actions.github.com/runner-registration-finalizer"
+
+	// defaultEphemeralRunnerFailureLimit is used when the EphemeralRunner does not specify a failure limit.
+	defaultEphemeralRunnerFailureLimit = 5
+
+	// maxLastFailureMessageLength is the maximum number of bytes of runner container logs stored in the status.
+	maxLastFailureMessageLength = 4096
+
+	// preDeleteCommandTimeout bounds the time the pre-delete command can delay the runner pod deletion.
+	preDeleteCommandTimeout = 1 * time.Minute
+
+	// RunnerRegisteredPodConditionType is the readiness gate of runner pods that becomes True
+	// once the runner container runs with a runner registered with GitHub.
+	RunnerRegisteredPodConditionType corev1.PodConditionType = "actions.github.com/runner-registered"
+
+	// podCreationBackoffInitial is the delay before creating a new runner pod after the first pod failure.
+	// It doubles with each successive failure.
+	podCreationBackoffInitial = 5 * time.Second
+	// podCreationBackoffJitter is the maximum fraction of the pod creation backoff randomly added to it.
+	podCreationBackoffJitter = 0.2
+	// DefaultPodCreationBackoffMax is used when the reconciler does not set PodCreationBackoffMax.
+	DefaultPodCreationBackoffMax = 5 * time.Minute
+	// DefaultUnschedulableThreshold is the default threshold after which pending runner pods that can't be
+	// scheduled are reported as unschedulable.
+	DefaultUnschedulableThreshold = 10 * time.Minute
+)
+
+const (
+	// PreflightCheckContainerName is the name of the init container added to runner pods with PreflightCheck.
+	PreflightCheckContainerName = "preflight-check"
+
+	// DefaultPreflightCheckImage is used when the reconciler does not set PreflightCheckImage.
+	DefaultPreflightCheckImage = "curlimages/curl:8.4.0"
+	// DefaultWindowsPreflightCheckImage is used for Windows runners when the reconciler does not set WindowsPreflightCheckImage.
+	DefaultWindowsPreflightCheckImage = "mcr.microsoft.com/powershell:lts-nanoserver-ltsc2022"
+
+	// EnvVarGitHubConfigUrl holds the GitHub config URL of the runner in the preflight check container.
+	EnvVarGitHubConfigUrl = "GITHUB_CONFIG_URL"
+
+	// defaultSidecarDependencyTimeout is used when the sidecar dependency of the runner does not set a timeout.
+	defaultSidecarDependencyTimeout = 2 * time.Minute
+	// sidecarDependencyReadyFile is created in the runner container once the sidecar dependency is up,
+	// which passes the startup probe of the runner container.
+	sidecarDependencyReadyFile = "/tmp/.arc-sidecar-dependency-ready"
+
+	// DinDContainerName is the name of the docker in docker container of runner pods with DinDSharedWorkVolume.
+	DinDContainerName = "dind"
+
+	// defaultHealthCheckInterval is used when the health check of the runner does not set an interval.
+	defaultHealthCheckInterval = 30 * time.Second
+	// defaultHealthCheckFailureThreshold is used when the health check of the runner does not set a failure threshold.
+	defaultHealthCheckFailureThreshold = 3
+	// healthCheckTimeout bounds each request to the health endpoint of a runner pod.
+	healthCheckTimeout = 5 * time.Second
+	// runnerRegistrationCheckInterval is how often the service is asked whether the runner of a pod with a registration
+	// timeout is online, until it is.
+	runnerRegistrationCheckInterval = 15 * time.Second
 )
 
+// DefaultPreflightCheckCommand is used when the reconciler does not set PreflightCheckCommand.
+// Any HTTP response means the GitHub config URL can be reached.
+var DefaultPreflightCheckCommand = []string{
+	"sh", "-c",
+	`curl --silent --show-error --output /dev/null --max-time 30 "$` + EnvVarGitHubConfigUrl + `"`,
+}
+
+// DefaultWindowsPreflightCheckCommand is used for Windows runners when the reconciler does not set WindowsPreflightCheckCommand.
+// Any HTTP response means the GitHub config URL can be reached.
+var DefaultWindowsPreflightCheckCommand = []string{
+	"pwsh", "-Command",
+	`try { Invoke-WebRequest -UseBasicParsing -TimeoutSec 30 -Uri $env:` + EnvVarGitHubConfigUrl + ` | Out-Null } ` +
+		`catch { if ($null -eq $_.Exception.Response) { Write-Error $_; exit 1 } }`,
+}
+
 // EphemeralRunnerReconciler reconciles a EphemeralRunner object
 type EphemeralRunnerReconciler struct {
 	client.Client
 	Log             logr.Logger
 	Scheme          *runtime.Scheme
 	ActionsClient   actions.MultiClient
-	resourceBuilder resourceBuilder
+	Recorder        record.EventRecorder
+	KubeClient      kubernetes.Interface
+	FailureLogLines int64
+	PodExecutor     PodCommandExecutor
+	// RegistrationReadinessGate adds the RunnerRegisteredPodConditionType readiness gate to runner pods,
+	// so they only become Ready once the runner is registered with GitHub.
+	RegistrationReadinessGate bool
+	// PodCreationBackoffMax caps the backoff between the creation of successive runner pods
+	// after failures. Defaults to DefaultPodCreationBackoffMax.
+	PodCreationBackoffMax time.Duration
+	// PreflightCheckImage is the image of the preflight check init container. Defaults to DefaultPreflightCheckImage.
+	PreflightCheckImage string
+	// PreflightCheckCommand is the command of the preflight check init container. Defaults to DefaultPreflightCheckCommand.
+	// The GitHub config URL is available in the GITHUB_CONFIG_URL environment variable.
+	PreflightCheckCommand []string
+	// WindowsPreflightCheckImage and WindowsPreflightCheckCommand replace PreflightCheckImage and PreflightCheckCommand
+	// for Windows runners. They default to DefaultWindowsPreflightCheckImage and DefaultWindowsPreflightCheckCommand.
+	WindowsPreflightCheckImage   string
+	WindowsPreflightCheckCommand []string
+	// RemovedRunnerCheckInterval is how often an idle registered runner is checked to still exist in the service,
+	// once it has been running for that long. Runners removed from the service, e.g. from the GitHub UI,
+	// are deleted so their EphemeralRunnerSet re-creates them. Zero disables the check.
+	RemovedRunnerCheckInterval time.Duration
+	// UnschedulableThreshold is how long a runner pod can be pending because the scheduler can't place it
+	// before the EphemeralRunner is reported as unschedulable, with an event and the RunnersUnschedulable condition
+	// of its EphemeralRunnerSet. Zero disables the detection.
+	UnschedulableThreshold time.Duration
+	// UnschedulableRetry deletes the runner pods that are unschedulable for longer than UnschedulableThreshold,
+	// so they are re-created after the pod creation backoff. Each retry counts as a pod failure of the EphemeralRunner.
+	UnschedulableRetry bool
+	// ScheduleMetricsNodeLabel is the node label whose value labels the arc_runner_schedule_seconds metric as node pool,
+	// e.g. karpenter.sh/nodepool. Node names are not used, to keep the cardinality of the metric bounded.
+	// The node pool is left empty when unset.
+	ScheduleMetricsNodeLabel string
+	// MaxConcurrentReconciles is the number of EphemeralRunners reconciled in parallel. Defaults to 1.
+	// An EphemeralRunner is never reconciled by two workers at once, and the pod and secrets the reconciler writes
+	// belong to a single EphemeralRunner, so no locking is needed.
+	MaxConcurrentReconciles int
+	// DeregistrationLimiter paces the removal of deleted runners from the service. The registration finalizer
+	// of runners without a token is kept until one is available. Nil disables the limit.
+	DeregistrationLimiter *DeregistrationLimiter
+	// JobEvents records an event on the EphemeralRunner when a job is assigned to it and when it completes the job,
+	// as an audit trail of the jobs run by each runner. Each event is recorded once per runner and job request.
+	JobEvents bool
+	// SkipDeregistration removes the registration finalizer of deleted EphemeralRunners without removing the runners
+	// from the service, e.g. in throwaway clusters torn down wholesale. The runners remain registered with GitHub.
+	SkipDeregistration bool
+	// NodeAnnotation annotates each EphemeralRunner with the node its pod was scheduled to, updated when
+	// the pod is re-created on another node, to correlate runner failures with nodes.
+	NodeAnnotation bool
+	// CompletionNotifier posts a record to a webhook when an EphemeralRunner completes a job or its pod fails
+	// while running one. Nil disables the notifications.
+	CompletionNotifier *CompletionNotifier
+	resourceBuilder    resourceBuilder
+
+	// removedRunnerChecks holds the time each ephemeral runner was last checked to exist in the service.
+	removedRunnerChecksMu sync.Mutex
+	removedRunnerChecks   map[types.UID]time.Time
+
+	// registrationChecks holds whether the runner of the current pod of each ephemeral runner with a registration
+	// timeout was seen online in the service, and when it was last checked.
+	registrationChecksMu sync.Mutex
+	registrationChecks   map[types.UID]runnerRegistrationCheck
+
+	// healthChecks holds the time the health endpoint of each idle ephemeral runner was last checked.
+	healthChecksMu sync.Mutex
+	healthChecks   map[types.UID]time.Time
+
+	// jobEvents holds the job request ID whose assignment was last recorded as an event of each ephemeral runner.
+	jobEventsMu sync.Mutex
+	jobEvents   map[types.UID]int64
+
+	// completionNotifications holds the job request ID whose completion was last notified for each ephemeral runner.
+	completionNotificationsMu sync.Mutex
+	completionNotifications   map[types.UID]int64
 }
 
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners/finalizers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
+// +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get;patch
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups=core,resources=pods/exec,verbs=get;create
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=create;get;list;watch;delete
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,12 +228,13 @@ type EphemeralRunnerReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.6.4/pkg/reconcile
 func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("ephemeralrunner", req.NamespacedName)
+	log := r.Log.WithValues("namespace", req.Namespace, "name", req.Name)
 
 	ephemeralRunner := new(v1alpha1.EphemeralRunner)
 	if err := r.Get(ctx, req.NamespacedName, ephemeralRunner); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	log = log.WithValues("runnerScaleSetId", ephemeralRunner.Spec.RunnerScaleSetId)
 
 	if !ephemeralRunner.ObjectMeta.DeletionTimestamp.IsZero() {
 		if !controllerutil.ContainsFinalizer(ephemeralRunner, ephemeralRunnerFinalizerName) {
@@ -95,6 +255,20 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 				log.Info("Successfully removed runner registration finalizer")
 				return ctrl.Result{}, nil
 			default:
+				if r.SkipDeregistration {
+					log.Info("WARNING: Deregistration is skipped, removing the runner registration finalizer without removing the runner from the service", "runnerId", ephemeralRunner.Status.RunnerId)
+					if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+						controllerutil.RemoveFinalizer(obj, ephemeralRunnerActionsFinalizerName)
+					}); err != nil {
+						log.Error(err, "Failed to update ephemeral runner without runner registration finalizer")
+						return ctrl.Result{}, err
+					}
+					return ctrl.Result{}, nil
+				}
+				if delay := r.DeregistrationLimiter.Reserve(ephemeralRunner.Spec.RunnerScaleSetId, time.Now()); delay > 0 {
+					log.Info("Deferring the removal of the runner from the service because of the deregistration rate limit", "delay", delay)
+					return ctrl.Result{RequeueAfter: delay}, nil
+				}
 				return r.cleanupRunnerFromService(ctx, ephemeralRunner, log)
 			}
 		}
@@ -129,6 +303,11 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			return ctrl.Result{}, err
 		}
 
+		r.forgetRemovedRunnerCheck(ephemeralRunner.UID)
+		r.forgetRegistrationCheck(ephemeralRunner.UID)
+		r.forgetHealthCheck(ephemeralRunner.UID)
+		r.forgetJobEvent(ephemeralRunner.UID)
+		r.forgetCompletionNotification(ephemeralRunner.UID)
 		log.Info("Successfully removed finalizer after cleanup")
 		return ctrl.Result{}, nil
 	}
@@ -182,15 +361,28 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return r.createSecret(ctx, ephemeralRunner, log)
 	}
 
+	r.recordJobAssigned(ephemeralRunner)
+
 	pod := new(corev1.Pod)
 	if err := r.Get(ctx, req.NamespacedName, pod); err != nil {
+		if kerrors.IsNotFound(err) && ephemeralRunner.Status.JobRequestId > 0 {
+			reclaimed, err := r.reclaimStaleJob(ctx, ephemeralRunner, log)
+			if err != nil {
+				log.Error(err, "Failed to check if the runner of the stale job exists in the service")
+				return ctrl.Result{}, err
+			}
+			if reclaimed {
+				return ctrl.Result{}, nil
+			}
+		}
+
 		switch {
 		case !kerrors.IsNotFound(err):
 			log.Error(err, "Failed to fetch the pod")
 			return ctrl.Result{}, err
 
-		case len(ephemeralRunner.Status.Failures) > 5:
-			log.Info("EphemeralRunner has failed more than 5 times. Marking it as failed")
+		case len(ephemeralRunner.Status.Failures) > failureLimit(ephemeralRunner):
+			log.Info("EphemeralRunner has reached its pod failure limit. Marking it as failed", "failures", len(ephemeralRunner.Status.Failures), "failureLimit", failureLimit(ephemeralRunner))
 			if err := r.markAsFailed(ctx, ephemeralRunner, log); err != nil {
 				log.Error(err, "Failed to set ephemeral runner to phase Failed")
 				return ctrl.Result{}, err
@@ -198,18 +390,95 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			return ctrl.Result{}, nil
 
 		default:
+			if remaining := podCreationBackoffRemaining(ephemeralRunner, time.Now()); remaining > 0 {
+				log.Info("Waiting for the pod creation backoff to elapse before creating a new pod", "remaining", remaining, "backoffLevel", ephemeralRunner.Status.PodCreationBackoffLevel)
+				return ctrl.Result{RequeueAfter: remaining}, nil
+			}
+
 			// Pod was not found. Create if the pod has never been created
 			log.Info("Creating new EphemeralRunner pod.")
 			return r.createPod(ctx, ephemeralRunner, secret, log)
 		}
 	}
 
-	cs := runnerContainerStatus(pod)
+	if err := r.updatePodResourceMetadata(ctx, ephemeralRunner, pod, log); err != nil {
+		log.Error(err, "Failed to update resource labels and annotations of the pod")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.annotateNode(ctx, ephemeralRunner, pod, log); err != nil {
+		log.Error(err, "Failed to annotate the ephemeral runner with the node of its pod")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.updatePodDisruptionLabels(ctx, ephemeralRunner, pod, log); err != nil {
+		log.Error(err, "Failed to update the busy label of the pod")
+		return ctrl.Result{}, err
+	}
+
+	if message, failed := preflightCheckFailure(ephemeralRunner, pod); failed {
+		log.Info("Preflight check of the ephemeral runner pod failed", "message", message)
+		if err := r.markAsPreflightCheckFailed(ctx, ephemeralRunner, pod, message, log); err != nil {
+			log.Error(err, "Failed to set ephemeral runner to phase Failed after the preflight check failure")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if since, ok := podUnschedulableSince(pod); ok && r.UnschedulableThreshold > 0 {
+		if remaining := since.Add(r.UnschedulableThreshold).Sub(time.Now()); remaining > 0 {
+			log.Info("Ephemeral runner pod can't be scheduled", "remaining", remaining)
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+
+		if r.UnschedulableRetry {
+			log.Info("Ephemeral runner pod is unschedulable. Deleting it to retry after the pod creation backoff", "threshold", r.UnschedulableThreshold)
+			if err := r.deletePodAsFailed(ctx, ephemeralRunner, pod, log); err != nil {
+				log.Error(err, "Failed to delete unschedulable pod")
+				return ctrl.Result{}, err
+			}
+		}
+
+		if err := r.markAsUnschedulable(ctx, ephemeralRunner, pod, log); err != nil {
+			log.Error(err, "Failed to mark ephemeral runner as unschedulable")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if ephemeralRunner.Status.Unschedulable {
+		log.Info("Ephemeral runner pod is no longer unschedulable")
+		if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+			obj.Status.Unschedulable = false
+		}); err != nil {
+			log.Error(err, "Failed to clear unschedulable status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	cs := runnerContainerStatus(pod, runnerContainerName(ephemeralRunner))
+
+	registrationTimedOut, registrationCheck := r.checkRegistration(ctx, ephemeralRunner, pod, cs, time.Now(), log)
+	if registrationTimedOut {
+		log.Info("Ephemeral runner did not come online within its registration timeout. Deleting the pod to retry after the pod creation backoff", "registrationTimeout", ephemeralRunner.Spec.RegistrationTimeout.Duration)
+		message := fmt.Sprintf("Runner did not come online within %s", ephemeralRunner.Spec.RegistrationTimeout.Duration)
+		r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "RegistrationTimeout", message)
+		// The failure is recorded with its own reason rather than the one of the pod.
+		timedOut := pod.DeepCopy()
+		timedOut.Status.Reason = "RegistrationTimeout"
+		timedOut.Status.Message = message
+		if err := r.deletePodAsFailed(ctx, ephemeralRunner, timedOut, log); err != nil {
+			log.Error(err, "Failed to delete pod after the registration timeout")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
 	switch {
 	case cs == nil:
 		// starting, no container state yet
 		log.Info("Waiting for runner container status to be available")
-		return ctrl.Result{}, nil
+		return ctrl.Result{RequeueAfter: registrationCheck}, nil
 	case cs.State.Terminated == nil: // still running or evicted
 		if pod.Status.Phase == corev1.PodFailed && pod.Status.Reason == "Evicted" {
 			log.Info("Pod set the termination phase, but container state is not terminated. Deleting pod",
@@ -218,6 +487,14 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 				"PodMessage", pod.Status.Message,
 			)
 
+			if ephemeralRunner.Spec.KeepFailedPod {
+				if err := r.retainFailedPod(ctx, ephemeralRunner, pod, log); err != nil {
+					log.Error(err, "Failed to retain failed pod")
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{}, nil
+			}
+
 			if err := r.deletePodAsFailed(ctx, ephemeralRunner, pod, log); err != nil {
 				log.Error(err, "failed to delete pod as failed on pod.Status.Phase: Failed")
 				return ctrl.Result{}, err
@@ -225,15 +502,85 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			return ctrl.Result{}, nil
 		}
 
+		if waitsForSidecarDependency(ephemeralRunner) && (cs.Started == nil || !*cs.Started) {
+			log.Info("Waiting for the sidecar dependency of the runner container", "port", ephemeralRunner.Spec.SidecarDependency.Port)
+			return ctrl.Result{}, nil
+		}
+
 		log.Info("Ephemeral runner container is still running")
 		if err := r.updateRunStatusFromPod(ctx, ephemeralRunner, pod, log); err != nil {
 			log.Info("Failed to update ephemeral runner status. Requeue to not miss this event")
 			return ctrl.Result{}, err
 		}
+
+		if err := r.updateRunnerRegisteredCondition(ctx, ephemeralRunner, pod, cs, log); err != nil {
+			log.Error(err, "Failed to update runner registered pod condition")
+			return ctrl.Result{}, err
+		}
+
+		removed, nextCheck := r.checkRemovedFromService(ctx, ephemeralRunner, pod, time.Now(), log)
+		if removed {
+			log.Info("Ephemeral runner no longer exists in the service. Deleting it to be re-created by the EphemeralRunnerSet", "runnerId", ephemeralRunner.Status.RunnerId)
+			r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "RemovedFromService", fmt.Sprintf("Runner %d was removed from the service", ephemeralRunner.Status.RunnerId))
+			if err := r.recycle(ctx, ephemeralRunner, "RemovedFromService", log); err != nil {
+				log.Error(err, "Failed to recycle ephemeral runner removed from the service")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+
+		unhealthy, nextHealthCheck, err := r.checkHealth(ctx, ephemeralRunner, pod, time.Now(), log)
+		if err != nil {
+			log.Error(err, "Failed to update the health check failures of the ephemeral runner")
+			return ctrl.Result{}, err
+		}
+		if unhealthy {
+			log.Info("Ephemeral runner failed too many consecutive health checks. Deleting it to be re-created by the EphemeralRunnerSet", "failures", ephemeralRunner.Status.HealthCheckFailures)
+			r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "Unhealthy", fmt.Sprintf("Runner failed %d consecutive health checks", ephemeralRunner.Status.HealthCheckFailures))
+			if err := r.recycle(ctx, ephemeralRunner, "HealthCheckFailed", log); err != nil {
+				log.Error(err, "Failed to recycle unhealthy ephemeral runner")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		if nextHealthCheck > 0 && (nextCheck == 0 || nextHealthCheck < nextCheck) {
+			nextCheck = nextHealthCheck
+		}
+		if registrationCheck > 0 && (nextCheck == 0 || registrationCheck < nextCheck) {
+			nextCheck = registrationCheck
+		}
+
+		remaining, ok := maxLifetimeRemaining(ephemeralRunner, pod, time.Now())
+		switch {
+		case !ok:
+			return ctrl.Result{RequeueAfter: nextCheck}, nil
+		case remaining > 0:
+			if nextCheck > 0 && nextCheck < remaining {
+				remaining = nextCheck
+			}
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		case ephemeralRunner.Status.JobRequestId > 0:
+			log.Info("Ephemeral runner exceeded its max lifetime, but it is running a job", "jobRequestId", ephemeralRunner.Status.JobRequestId)
+			return ctrl.Result{}, nil
+		}
+
+		log.Info("Ephemeral runner exceeded its max lifetime. Deleting it to be re-created by the EphemeralRunnerSet", "maxLifetime", ephemeralRunner.Spec.MaxLifetime.Duration)
+		if err := r.recycle(ctx, ephemeralRunner, "MaxLifetimeExceeded", log); err != nil {
+			log.Error(err, "Failed to recycle ephemeral runner after exceeding its max lifetime")
+			return ctrl.Result{}, err
+		}
 		return ctrl.Result{}, nil
 
 	case cs.State.Terminated.ExitCode != 0: // failed
 		log.Info("Ephemeral runner container failed", "exitCode", cs.State.Terminated.ExitCode)
+		if ephemeralRunner.Spec.KeepFailedPod {
+			if err := r.retainFailedPod(ctx, ephemeralRunner, pod, log); err != nil {
+				log.Error(err, "Failed to retain failed pod")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+
 		if err := r.deletePodAsFailed(ctx, ephemeralRunner, pod, log); err != nil {
 			log.Error(err, "Failed to delete runner pod on failure")
 			return ctrl.Result{}, err
@@ -271,14 +618,19 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 func (r *EphemeralRunnerReconciler) cleanupRunnerFromService(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (ctrl.Result, error) {
 	actionsError := &actions.ActionsError{}
 	err := r.deleteRunnerFromService(ctx, ephemeralRunner, log)
-	if err != nil {
-		if errors.As(err, &actionsError) &&
-			actionsError.StatusCode == http.StatusBadRequest &&
-			strings.Contains(actionsError.ExceptionName, "JobStillRunningException") {
-			log.Info("Runner is still running the job. Re-queue in 30 seconds")
-			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
-		}
-
+	switch {
+	case err == nil:
+	case errors.As(err, &actionsError) &&
+		actionsError.StatusCode == http.StatusBadRequest &&
+		strings.Contains(actionsError.ExceptionName, "JobStillRunningException"):
+		log.Info("Runner is still running the job. Re-queue in 30 seconds")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	case errors.As(err, &actionsError) &&
+		actionsError.StatusCode == http.StatusNotFound &&
+		strings.Contains(actionsError.ExceptionName, "AgentNotFoundException"):
+		// The runner was already removed from the service, e.g. from the GitHub UI.
+		log.Info("Runner does not exist in the service anymore", "runnerId", ephemeralRunner.Status.RunnerId)
+	default:
 		log.Error(err, "Failed clean up runner from the service")
 		return ctrl.Result{}, err
 	}
@@ -302,8 +654,12 @@ func (r *EphemeralRunnerReconciler) cleanupResources(ctx context.Context, epheme
 	switch {
 	case err == nil:
 		if pod.ObjectMeta.DeletionTimestamp.IsZero() {
+			if ephemeralRunner.Status.Phase != corev1.PodFailed {
+				r.runPreDeleteCommand(ctx, ephemeralRunner, pod, log)
+			}
+
 			log.Info("Deleting the runner pod")
-			if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
+			if err := r.Delete(ctx, pod, podDeleteOptions(ephemeralRunner)...); err != nil && !kerrors.IsNotFound(err) {
 				return false, fmt.Errorf("failed to delete pod: %v", err)
 			}
 		}
@@ -425,13 +781,15 @@ func (r *EphemeralRunnerReconciler) cleanupRunnerLinkedSecrets(ctx context.Conte
 
 func (r *EphemeralRunnerReconciler) markAsFailed(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
 	log.Info("Updating ephemeral runner status to Failed")
+	message := fmt.Sprintf("Pod has failed to start more than %d times", failureLimit(ephemeralRunner))
 	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
 		obj.Status.Phase = corev1.PodFailed
 		obj.Status.Reason = "TooManyPodFailures"
-		obj.Status.Message = "Pod has failed to start more than 5 times"
+		obj.Status.Message = message
 	}); err != nil {
 		return fmt.Errorf("failed to update ephemeral runner status Phase/Message: %v", err)
 	}
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "TooManyPodFailures", message)
 
 	log.Info("Removing the runner from the service")
 	if err := r.deleteRunnerFromService(ctx, ephemeralRunner, log); err != nil {
@@ -442,171 +800,649 @@ func (r *EphemeralRunnerReconciler) markAsFailed(ctx context.Context, ephemeralR
 	return nil
 }
 
-func (r *EphemeralRunnerReconciler) markAsFinished(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
-	log.Info("Updating ephemeral runner status to Finished")
-	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
-		obj.Status.Phase = corev1.PodSucceeded
-	}); err != nil {
-		return fmt.Errorf("failed to update ephemeral runner with status finished: %v", err)
-	}
-
-	log.Info("EphemeralRunner status is marked as Finished")
-	return nil
-}
-
-// deletePodAsFailed is responsible for deleting the pod and updating the .Status.Failures for tracking failure count.
-// It should not be responsible for setting the status to Failed.
-func (r *EphemeralRunnerReconciler) deletePodAsFailed(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
-	if pod.ObjectMeta.DeletionTimestamp.IsZero() {
+// markAsPreflightCheckFailed deletes the pod whose preflight check failed, unless KeepFailedPod is set,
+// and marks the ephemeral runner as failed with the output of the check.
+func (r *EphemeralRunnerReconciler) markAsPreflightCheckFailed(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, message string, log logr.Logger) error {
+	if !ephemeralRunner.Spec.KeepFailedPod && pod.ObjectMeta.DeletionTimestamp.IsZero() {
 		log.Info("Deleting the ephemeral runner pod", "podId", pod.UID)
 		if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
-			return fmt.Errorf("failed to delete pod with status failed: %v", err)
+			return fmt.Errorf("failed to delete pod with failed preflight check: %v", err)
 		}
 	}
 
-	log.Info("Updating ephemeral runner status to track the failure count")
+	log.Info("Updating ephemeral runner status to Failed")
 	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
-		if obj.Status.Failures == nil {
-			obj.Status.Failures = make(map[string]bool)
-		}
-		obj.Status.Failures[string(pod.UID)] = true
 		obj.Status.Ready = false
-		obj.Status.Reason = pod.Status.Reason
-		obj.Status.Message = pod.Status.Message
+		obj.Status.Phase = corev1.PodFailed
+		obj.Status.Reason = "PreflightCheckFailed"
+		obj.Status.Message = message
 	}); err != nil {
-		return fmt.Errorf("failed to update ephemeral runner status: failed attempts: %v", err)
+		return fmt.Errorf("failed to update ephemeral runner status Phase/Message: %v", err)
 	}
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "PreflightCheckFailed", message)
 
-	log.Info("EphemeralRunner pod is deleted and status is updated with failure count")
+	log.Info("Removing the runner from the service")
+	if err := r.deleteRunnerFromService(ctx, ephemeralRunner, log); err != nil {
+		return fmt.Errorf("failed to remove the runner from service: %v", err)
+	}
+
+	log.Info("EphemeralRunner is marked as Failed after its preflight check failed")
 	return nil
 }
 
-// updateStatusWithRunnerConfig fetches runtime configuration needed by the runner
-// This method should always set .status.runnerId and .status.runnerJITConfig
-func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (ctrl.Result, error) {
-	// Runner is not registered with the service. We need to register it first
-	log.Info("Creating ephemeral runner JIT config")
-	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunner)
-	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to get actions client for generating JIT config: %v", err)
+// markAsInvalidPodTemplate marks the ephemeral runner as failed without creating its pod, since its pod template
+// can't be used as configured, and removes the runner from the service.
+func (r *EphemeralRunnerReconciler) markAsInvalidPodTemplate(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, reason, message string, log logr.Logger) error {
+	log.Info("Updating ephemeral runner status to Failed", "reason", reason)
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.Ready = false
+		obj.Status.Phase = corev1.PodFailed
+		obj.Status.Reason = reason
+		obj.Status.Message = message
+	}); err != nil {
+		return fmt.Errorf("failed to update ephemeral runner status Phase/Message: %v", err)
 	}
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, reason, message)
 
-	jitSettings := &actions.RunnerScaleSetJitRunnerSetting{
-		Name: ephemeralRunner.Name,
+	log.Info("Removing the runner from the service")
+	if err := r.deleteRunnerFromService(ctx, ephemeralRunner, log); err != nil {
+		return fmt.Errorf("failed to remove the runner from service: %v", err)
 	}
-	jitConfig, err := actionsClient.GenerateJitRunnerConfig(ctx, jitSettings, ephemeralRunner.Spec.RunnerScaleSetId)
-	if err != nil {
-		actionsError := &actions.ActionsError{}
-		if !errors.As(err, &actionsError) {
-			return ctrl.Result{}, fmt.Errorf("failed to generate JIT config with generic error: %v", err)
-		}
 
-		if actionsError.StatusCode != http.StatusConflict ||
-			!strings.Contains(actionsError.ExceptionName, "AgentExistsException") {
-			return ctrl.Result{}, fmt.Errorf("failed to generate JIT config with Actions service error: %v", err)
-		}
+	log.Info("EphemeralRunner is marked as Failed because of its pod template")
+	return nil
+}
 
-		// If the runner with the name we want already exists it means:
-		// - We might have a name collision.
-		// - Our previous reconciliation loop failed to update the
-		//   status with the runnerId and runnerJITConfig after the `GenerateJitRunnerConfig`
-		//   created the runner registration on the service.
-		// We will try to get the runner and see if it's belong to this AutoScalingRunnerSet,
-		// if so, we can simply delete the runner registration and create a new one.
-		log.Info("Getting runner jit config failed with conflict error, trying to get the runner by name", "runnerName", ephemeralRunner.Name)
-		existingRunner, err := actionsClient.GetRunnerByName(ctx, ephemeralRunner.Name)
-		if err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to get runner by name: %v", err)
-		}
+// markAsUnschedulable records that the runner pod can't be scheduled, with an event and the Unschedulable status
+// of the ephemeral runner, so the EphemeralRunnerSet reports it. The status is only patched when its message changes.
+func (r *EphemeralRunnerReconciler) markAsUnschedulable(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	message := podScheduledMessage(pod)
+	r.Recorder.Eventf(ephemeralRunner, corev1.EventTypeWarning, "Unschedulable", "Runner pod has been unschedulable for longer than %s: %s", r.UnschedulableThreshold, message)
 
-		if existingRunner == nil {
-			log.Info("Runner with the same name does not exist, re-queuing the reconciliation")
-			return ctrl.Result{Requeue: true}, nil
-		}
+	if ephemeralRunner.Status.Unschedulable && ephemeralRunner.Status.Message == message {
+		return nil
+	}
 
-		log.Info("Found the runner with the same name", "runnerId", existingRunner.Id, "runnerScaleSetId", existingRunner.RunnerScaleSetId)
-		if existingRunner.RunnerScaleSetId == ephemeralRunner.Spec.RunnerScaleSetId {
-			log.Info("Removing the runner with the same name")
-			err := actionsClient.RemoveRunner(ctx, int64(existingRunner.Id))
-			if err != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to remove runner from the service: %v", err)
-			}
+	log.Info("Updating ephemeral runner status to unschedulable", "message", message)
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.Unschedulable = true
+		obj.Status.Reason = corev1.PodReasonUnschedulable
+		obj.Status.Message = message
+	}); err != nil {
+		return fmt.Errorf("failed to update ephemeral runner status to unschedulable: %v", err)
+	}
+	return nil
+}
 
-			log.Info("Removed the runner with the same name, re-queuing the reconciliation")
-			return ctrl.Result{Requeue: true}, nil
+// podUnschedulableSince returns when the pending pod was reported as unschedulable by the scheduler.
+func podUnschedulableSince(pod *corev1.Pod) (time.Time, bool) {
+	if pod.Status.Phase != corev1.PodPending {
+		return time.Time{}, false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+			return condition.LastTransitionTime.Time, true
 		}
+	}
+	return time.Time{}, false
+}
 
-		// TODO: Do we want to mark the ephemeral runner as failed, and let EphemeralRunnerSet to clean it up, so we can recover from this situation?
-		// The situation is that the EphemeralRunner's name is already used by something else to register a runner, and we can't take the control back.
-		return ctrl.Result{}, fmt.Errorf("runner with the same name but doesn't belong to this RunnerScaleSet: %v", err)
+// podScheduledMessage returns the message of the PodScheduled condition of the pod, explaining why it can't be scheduled.
+func podScheduledMessage(pod *corev1.Pod) string {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled {
+			return condition.Message
+		}
 	}
-	log.Info("Created ephemeral runner JIT config", "runnerId", jitConfig.Runner.Id)
+	return ""
+}
 
-	log.Info("Updating ephemeral runner status with runnerId and runnerJITConfig")
-	err = patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
-		obj.Status.RunnerId = jitConfig.Runner.Id
-		obj.Status.RunnerName = jitConfig.Runner.Name
-		obj.Status.RunnerJITConfig = jitConfig.EncodedJITConfig
-	})
-	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to update runner status for RunnerId/RunnerName/RunnerJITConfig: %v", err)
+// annotateNode sets the AnnotationKeyNode annotation of the ephemeral runner to the node its pod was scheduled to,
+// if NodeAnnotation is enabled. Pods not scheduled yet are skipped.
+func (r *EphemeralRunnerReconciler) annotateNode(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	if !r.NodeAnnotation || pod.Spec.NodeName == "" || ephemeralRunner.Annotations[AnnotationKeyNode] == pod.Spec.NodeName {
+		return nil
 	}
 
-	log.Info("Updated ephemeral runner status with runnerId and runnerJITConfig")
-	return ctrl.Result{}, nil
+	log.Info("Annotating the ephemeral runner with the node of its pod", "node", pod.Spec.NodeName)
+	return patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		if obj.Annotations == nil {
+			obj.Annotations = make(map[string]string)
+		}
+		obj.Annotations[AnnotationKeyNode] = pod.Spec.NodeName
+	})
 }
 
-func (r *EphemeralRunnerReconciler) createPod(ctx context.Context, runner *v1alpha1.EphemeralRunner, secret *corev1.Secret, log logr.Logger) (ctrl.Result, error) {
-	var envs []corev1.EnvVar
-	if runner.Spec.ProxySecretRef != "" {
-		http := corev1.EnvVar{
-			Name: "http_proxy",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: runner.Spec.ProxySecretRef,
-					},
-					Key: "http_proxy",
-				},
-			},
-		}
-		if runner.Spec.Proxy.HTTP != nil {
-			envs = append(envs, http)
+// updatePodDisruptionLabels keeps the LabelKeyBusy label of the pod in line with the job assignment of the ephemeral runner,
+// so the busy runners PodDisruptionBudget of its EphemeralRunnerSet only protects the pods running a job.
+func (r *EphemeralRunnerReconciler) updatePodDisruptionLabels(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	labels := runnerPodDisruptionLabels(ephemeralRunner)
+	changed := false
+	for k, v := range labels {
+		if pod.Labels[k] != v {
+			changed = true
 		}
+	}
+	if !changed {
+		return nil
+	}
 
-		https := corev1.EnvVar{
-			Name: "https_proxy",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: runner.Spec.ProxySecretRef,
-					},
-					Key: "https_proxy",
-				},
-			},
+	log.Info("Updating the busy label of the ephemeral runner pod", "busy", labels[LabelKeyBusy])
+	if err := patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		if obj.Labels == nil {
+			obj.Labels = make(map[string]string, len(labels))
 		}
-		if runner.Spec.Proxy.HTTPS != nil {
-			envs = append(envs, https)
+		for k, v := range labels {
+			obj.Labels[k] = v
 		}
+	}); err != nil {
+		return fmt.Errorf("failed to patch pod labels: %v", err)
+	}
+	return nil
+}
 
-		noProxy := corev1.EnvVar{
-			Name: "no_proxy",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: runner.Spec.ProxySecretRef,
-					},
-					Key: "no_proxy",
-				},
-			},
-		}
-		if len(runner.Spec.Proxy.NoProxy) > 0 {
-			envs = append(envs, noProxy)
-		}
+// updatePodResourceMetadata merges the labels and annotations of the ephemeral runner onto its pod,
+// so the resource labels and annotations of the runner set are propagated to existing pods.
+// Labels and annotations managed by the controllers are left untouched.
+func (r *EphemeralRunnerReconciler) updatePodResourceMetadata(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	if !mergeResourceMetadata(pod.DeepCopy(), ephemeralRunner.Labels, ephemeralRunner.Annotations) {
+		return nil
 	}
 
+	log.Info("Updating resource labels and annotations of the ephemeral runner pod", "podId", pod.UID)
+	if err := patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		mergeResourceMetadata(obj, ephemeralRunner.Labels, ephemeralRunner.Annotations)
+	}); err != nil {
+		return fmt.Errorf("failed to patch pod metadata: %v", err)
+	}
+	return nil
+}
+
+func (r *EphemeralRunnerReconciler) markAsFinished(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
+	log.Info("Updating ephemeral runner status to Finished")
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.Phase = corev1.PodSucceeded
+	}); err != nil {
+		return fmt.Errorf("failed to update ephemeral runner with status finished: %v", err)
+	}
+
+	if r.JobEvents && ephemeralRunner.Status.JobRequestId > 0 {
+		r.recordJobAssigned(ephemeralRunner)
+		r.Recorder.Event(ephemeralRunner, corev1.EventTypeNormal, "JobCompleted", fmt.Sprintf("Completed job request %d", ephemeralRunner.Status.JobRequestId))
+	}
+	if ephemeralRunner.Status.JobRequestId > 0 {
+		r.notifyCompletion(ephemeralRunner, corev1.PodSucceeded, time.Now())
+		r.observeBusy(ctx, ephemeralRunner, time.Now(), log)
+	}
+
+	log.Info("EphemeralRunner status is marked as Finished")
+	return nil
+}
+
+// recordJobAssigned records an event on the ephemeral runner the first time it is seen assigned to a job request,
+// when JobEvents is enabled. Reconciles of the runner while it runs the job don't record it again.
+func (r *EphemeralRunnerReconciler) recordJobAssigned(ephemeralRunner *v1alpha1.EphemeralRunner) {
+	if !r.JobEvents || ephemeralRunner.Status.JobRequestId == 0 {
+		return
+	}
+
+	r.jobEventsMu.Lock()
+	if r.jobEvents[ephemeralRunner.UID] == ephemeralRunner.Status.JobRequestId {
+		r.jobEventsMu.Unlock()
+		return
+	}
+	if r.jobEvents == nil {
+		r.jobEvents = make(map[types.UID]int64)
+	}
+	r.jobEvents[ephemeralRunner.UID] = ephemeralRunner.Status.JobRequestId
+	r.jobEventsMu.Unlock()
+
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeNormal, "JobAssigned", fmt.Sprintf(
+		"Assigned job request %d of workflow run %d of %s (%s)",
+		ephemeralRunner.Status.JobRequestId,
+		ephemeralRunner.Status.WorkflowRunId,
+		ephemeralRunner.Status.JobRepositoryName,
+		ephemeralRunner.Status.JobWorkflowRef,
+	))
+}
+
+// forgetJobEvent removes the job request whose assignment was last recorded for the ephemeral runner.
+func (r *EphemeralRunnerReconciler) forgetJobEvent(uid types.UID) {
+	r.jobEventsMu.Lock()
+	defer r.jobEventsMu.Unlock()
+	delete(r.jobEvents, uid)
+}
+
+// notifyCompletion queues the completion record of the job of the ephemeral runner to the CompletionNotifier,
+// with the phase of the runner pod once the job is done. Each job request is notified once per runner,
+// even when the failure of its pod is handled more than once.
+func (r *EphemeralRunnerReconciler) notifyCompletion(ephemeralRunner *v1alpha1.EphemeralRunner, phase corev1.PodPhase, now time.Time) {
+	if r.CompletionNotifier == nil || ephemeralRunner.Status.JobRequestId == 0 {
+		return
+	}
+
+	r.completionNotificationsMu.Lock()
+	if r.completionNotifications[ephemeralRunner.UID] == ephemeralRunner.Status.JobRequestId {
+		r.completionNotificationsMu.Unlock()
+		return
+	}
+	if r.completionNotifications == nil {
+		r.completionNotifications = make(map[types.UID]int64)
+	}
+	r.completionNotifications[ephemeralRunner.UID] = ephemeralRunner.Status.JobRequestId
+	r.completionNotificationsMu.Unlock()
+
+	record := CompletionRecord{
+		Namespace:          ephemeralRunner.Namespace,
+		EphemeralRunnerSet: ephemeralRunnerSetName(ephemeralRunner),
+		EphemeralRunner:    ephemeralRunner.Name,
+		RunnerScaleSetId:   ephemeralRunner.Spec.RunnerScaleSetId,
+		JobRequestId:       ephemeralRunner.Status.JobRequestId,
+		RepositoryName:     ephemeralRunner.Status.JobRepositoryName,
+		Phase:              phase,
+		Succeeded:          phase == corev1.PodSucceeded,
+		CompletedAt:        now.UTC(),
+	}
+	if assignedAt, err := time.Parse(time.RFC3339, ephemeralRunner.Annotations[v1alpha1.AnnotationKeyJobAssignedAt]); err == nil && now.After(assignedAt) {
+		record.DurationSeconds = now.Sub(assignedAt).Seconds()
+	}
+	r.CompletionNotifier.Notify(record)
+}
+
+// forgetCompletionNotification removes the job request whose completion was last notified for the ephemeral runner.
+func (r *EphemeralRunnerReconciler) forgetCompletionNotification(uid types.UID) {
+	r.completionNotificationsMu.Lock()
+	defer r.completionNotificationsMu.Unlock()
+	delete(r.completionNotifications, uid)
+}
+
+// reclaimStaleJob handles an ephemeral runner assigned to a job whose pod no longer exists, e.g. after an eviction.
+// Ephemeral runners are removed from the service once their job is done or cancelled, so a runner that no longer
+// exists in the service is no longer assigned the job: its job information is cleared and it is marked as finished,
+// so the EphemeralRunnerSet stops protecting it from scale down and reclaims its slot.
+// A runner that still exists in the service is left as is, since it may still run the job once its pod is re-created.
+func (r *EphemeralRunnerReconciler) reclaimStaleJob(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (reclaimed bool, err error) {
+	log.Info("Ephemeral runner pod is gone while assigned to a job", "jobRequestId", ephemeralRunner.Status.JobRequestId)
+	existsInService, err := r.runnerRegisteredWithService(ctx, ephemeralRunner.DeepCopy(), log)
+	if err != nil {
+		return false, err
+	}
+	if existsInService {
+		return false, nil
+	}
+
+	jobRequestId := ephemeralRunner.Status.JobRequestId
+	log.Info("Ephemeral runner of the job no longer exists in the service. Clearing the stale job", "jobRequestId", jobRequestId)
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.Phase = corev1.PodSucceeded
+		obj.Status.JobRequestId = 0
+		obj.Status.JobRepositoryName = ""
+		obj.Status.JobWorkflowRef = ""
+		obj.Status.WorkflowRunId = 0
+		obj.Status.JobDisplayName = ""
+	}); err != nil {
+		return false, fmt.Errorf("failed to clear the stale job of the ephemeral runner: %v", err)
+	}
+
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "StaleJobCleared", fmt.Sprintf("Cleared job request %d: the runner pod is gone and runner %d no longer exists in the service", jobRequestId, ephemeralRunner.Status.RunnerId))
+	return true, nil
+}
+
+// retainFailedPod keeps the failed pod for inspection instead of deleting it, and marks the ephemeral runner as failed
+// so the EphemeralRunnerSet creates a replacement. Both the pod and the ephemeral runner are labeled as a retained failure.
+func (r *EphemeralRunnerReconciler) retainFailedPod(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	r.notifyCompletion(ephemeralRunner, corev1.PodFailed, time.Now())
+	lastFailureMessage := r.runnerContainerLogs(ctx, pod, runnerContainerName(ephemeralRunner), log)
+
+	log.Info("Keeping the failed ephemeral runner pod for inspection", "podId", pod.UID)
+	if err := patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		if obj.Labels == nil {
+			obj.Labels = make(map[string]string)
+		}
+		obj.Labels[LabelKeyRetainedFailure] = "true"
+	}); err != nil {
+		return fmt.Errorf("failed to label failed pod: %v", err)
+	}
+
+	if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		if obj.Labels == nil {
+			obj.Labels = make(map[string]string)
+		}
+		obj.Labels[LabelKeyRetainedFailure] = "true"
+	}); err != nil {
+		return fmt.Errorf("failed to label failed ephemeral runner: %v", err)
+	}
+
+	message := fmt.Sprintf("Pod %s has failed and is kept for inspection", pod.Name)
+	log.Info("Updating ephemeral runner status to Failed")
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		if obj.Status.Failures == nil {
+			obj.Status.Failures = make(map[string]bool)
+		}
+		obj.Status.Failures[string(pod.UID)] = true
+		obj.Status.FailureCount = len(obj.Status.Failures)
+		obj.Status.Ready = false
+		obj.Status.Phase = corev1.PodFailed
+		obj.Status.Reason = "FailedPodRetained"
+		obj.Status.Message = message
+		if lastFailureMessage != "" {
+			obj.Status.LastFailureMessage = lastFailureMessage
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to update ephemeral runner status Phase/Message: %v", err)
+	}
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "FailedPodRetained", message)
+
+	log.Info("Removing the runner from the service")
+	if err := r.deleteRunnerFromService(ctx, ephemeralRunner, log); err != nil {
+		return fmt.Errorf("failed to remove the runner from service: %v", err)
+	}
+
+	log.Info("EphemeralRunner is marked as Failed and its pod is retained")
+	return nil
+}
+
+// podCreationBackoff returns the jittered delay before creating a new runner pod after `level` successive pod failures.
+// The delay doubles with each failure, up to max.
+func podCreationBackoff(level int, max time.Duration) time.Duration {
+	if max <= 0 {
+		max = DefaultPodCreationBackoffMax
+	}
+
+	backoff := podCreationBackoffInitial
+	for i := 1; i < level && backoff < max; i++ {
+		backoff *= 2
+	}
+
+	backoff = wait.Jitter(backoff, podCreationBackoffJitter)
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
+
+// proxyEnvVars returns the proxy environment variables of the runner pod. Their values are read from the proxy secret
+// of the runner, so proxy credentials are not written to the pod spec. The names are upper case if upperCase is set.
+func proxyEnvVars(runner *v1alpha1.EphemeralRunner, upperCase bool) []corev1.EnvVar {
+	if runner.Spec.ProxySecretRef == "" || runner.Spec.Proxy == nil {
+		return nil
+	}
+
+	// The inherited settings are only known to the controller, so all of them are read from the secret.
+	inherited := runner.Spec.Proxy.InheritsFromController()
+
+	var envs []corev1.EnvVar
+	for _, v := range []struct {
+		key     string
+		enabled bool
+	}{
+		{EnvVarHTTPProxy, inherited || runner.Spec.Proxy.HTTP != nil},
+		{EnvVarHTTPSProxy, inherited || runner.Spec.Proxy.HTTPS != nil},
+		{EnvVarNoProxy, inherited || len(runner.Spec.Proxy.NoProxy) > 0},
+	} {
+		if !v.enabled {
+			continue
+		}
+
+		name := v.key
+		if upperCase {
+			name = strings.ToUpper(name)
+		}
+		envs = append(envs, corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: runner.Spec.ProxySecretRef,
+					},
+					Key: v.key,
+				},
+			},
+		})
+	}
+	return envs
+}
+
+// withProxyEnv adds the proxy environment variables to each container that does not define them already.
+func withProxyEnv(containers []corev1.Container, envs []corev1.EnvVar) []corev1.Container {
+	for i := range containers {
+		defined := make(map[string]bool, len(containers[i].Env))
+		for _, env := range containers[i].Env {
+			defined[env.Name] = true
+		}
+		for _, env := range envs {
+			if !defined[env.Name] {
+				containers[i].Env = append(containers[i].Env, env)
+			}
+		}
+	}
+	return containers
+}
+
+// podDeleteOptions returns the options to delete the runner pod of the ephemeral runner with,
+// so a termination grace period set on the ephemeral runner also applies to pods created before it was set.
+func podDeleteOptions(ephemeralRunner *v1alpha1.EphemeralRunner) []client.DeleteOption {
+	if ephemeralRunner.Spec.TerminationGracePeriodSeconds == nil {
+		return nil
+	}
+	return []client.DeleteOption{client.GracePeriodSeconds(*ephemeralRunner.Spec.TerminationGracePeriodSeconds)}
+}
+
+// podCreationBackoffRemaining returns how long to wait before creating a new runner pod.
+func podCreationBackoffRemaining(ephemeralRunner *v1alpha1.EphemeralRunner, now time.Time) time.Duration {
+	if ephemeralRunner.Status.NextPodCreationTime == nil {
+		return 0
+	}
+	return ephemeralRunner.Status.NextPodCreationTime.Sub(now)
+}
+
+// deletePodAsFailed is responsible for deleting the pod and updating the .Status.Failures for tracking failure count.
+// It should not be responsible for setting the status to Failed.
+func (r *EphemeralRunnerReconciler) deletePodAsFailed(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	r.notifyCompletion(ephemeralRunner, corev1.PodFailed, time.Now())
+	lastFailureMessage := r.runnerContainerLogs(ctx, pod, runnerContainerName(ephemeralRunner), log)
+
+	if pod.ObjectMeta.DeletionTimestamp.IsZero() {
+		log.Info("Deleting the ephemeral runner pod", "podId", pod.UID)
+		if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete pod with status failed: %v", err)
+		}
+	}
+
+	log.Info("Updating ephemeral runner status to track the failure count")
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		if obj.Status.Failures == nil {
+			obj.Status.Failures = make(map[string]bool)
+		}
+		obj.Status.Failures[string(pod.UID)] = true
+		obj.Status.FailureCount = len(obj.Status.Failures)
+		obj.Status.PodCreationBackoffLevel++
+		nextPodCreationTime := metav1.NewTime(time.Now().Add(podCreationBackoff(obj.Status.PodCreationBackoffLevel, r.PodCreationBackoffMax)))
+		obj.Status.NextPodCreationTime = &nextPodCreationTime
+		obj.Status.Ready = false
+		obj.Status.Reason = pod.Status.Reason
+		obj.Status.Message = pod.Status.Message
+		if lastFailureMessage != "" {
+			obj.Status.LastFailureMessage = lastFailureMessage
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to update ephemeral runner status: failed attempts: %v", err)
+	}
+
+	log.Info("EphemeralRunner pod is deleted and status is updated with failure count")
+	return nil
+}
+
+// recycle deletes the idle ephemeral runner so the EphemeralRunnerSet can replace it with a new one.
+// The runner is removed from the service by the finalizer.
+func (r *EphemeralRunnerReconciler) recycle(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, reason string, log logr.Logger) error {
+	if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ephemeral runner: %v", err)
+	}
+
+	metrics.IncEphemeralRunnerRecycled(ephemeralRunner.Namespace, ephemeralRunnerSetName(ephemeralRunner), reason)
+
+	log.Info("Deleted ephemeral runner to be recycled", "reason", reason)
+	return nil
+}
+
+// runPreDeleteCommand executes the pre-delete command in the runner container, if it is still running.
+// Failures are recorded as events, since they should not prevent the runner pod from being deleted.
+func (r *EphemeralRunnerReconciler) runPreDeleteCommand(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) {
+	if len(ephemeralRunner.Spec.PreDeleteCommand) == 0 || r.PodExecutor == nil {
+		return
+	}
+
+	containerName := runnerContainerName(ephemeralRunner)
+	if cs := runnerContainerStatus(pod, containerName); cs == nil || cs.State.Running == nil {
+		log.Info("Runner container is not running. Skipping pre-delete command")
+		return
+	}
+
+	log.Info("Running pre-delete command in the runner container", "command", ephemeralRunner.Spec.PreDeleteCommand)
+	ctx, cancel := context.WithTimeout(ctx, preDeleteCommandTimeout)
+	defer cancel()
+
+	if err := r.PodExecutor.Exec(ctx, pod, containerName, ephemeralRunner.Spec.PreDeleteCommand); err != nil {
+		log.Error(err, "Pre-delete command failed. Proceeding with the runner pod deletion")
+		r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, "PreDeleteCommandFailed", fmt.Sprintf("Pre-delete command failed: %v", err))
+		return
+	}
+
+	log.Info("Pre-delete command finished")
+}
+
+// runnerContainerLogs returns the last lines of the runner container logs.
+// Fetching logs is best-effort, so failures are only logged and an empty string is returned.
+func (r *EphemeralRunnerReconciler) runnerContainerLogs(ctx context.Context, pod *corev1.Pod, containerName string, log logr.Logger) string {
+	if r.FailureLogLines <= 0 || r.KubeClient == nil {
+		return ""
+	}
+
+	tailLines := r.FailureLogLines
+	limitBytes := int64(maxLastFailureMessageLength)
+	logs, err := r.KubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:  containerName,
+		TailLines:  &tailLines,
+		LimitBytes: &limitBytes,
+	}).DoRaw(ctx)
+	if err != nil {
+		log.Info("Failed to fetch runner container logs", "error", err.Error())
+		return ""
+	}
+
+	return truncateLastFailureMessage(string(logs))
+}
+
+// updateStatusWithRunnerConfig fetches runtime configuration needed by the runner
+// This method should always set .status.runnerId and .status.runnerJITConfig
+func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (ctrl.Result, error) {
+	// Runner is not registered with the service. We need to register it first
+	log.Info("Creating ephemeral runner JIT config")
+	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunner)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get actions client for generating JIT config: %v", err)
+	}
+
+	jitSettings := &actions.RunnerScaleSetJitRunnerSetting{
+		Name: ephemeralRunner.Name,
+	}
+	jitConfig, err := actionsClient.GenerateJitRunnerConfig(ctx, jitSettings, ephemeralRunner.Spec.RunnerScaleSetId)
+	if err != nil {
+		actionsError := &actions.ActionsError{}
+		if !errors.As(err, &actionsError) {
+			return ctrl.Result{}, fmt.Errorf("failed to generate JIT config with generic error: %v", err)
+		}
+
+		if actionsError.StatusCode != http.StatusConflict ||
+			!strings.Contains(actionsError.ExceptionName, "AgentExistsException") {
+			return ctrl.Result{}, fmt.Errorf("failed to generate JIT config with Actions service error: %v", err)
+		}
+
+		// If the runner with the name we want already exists it means:
+		// - We might have a name collision.
+		// - Our previous reconciliation loop failed to update the
+		//   status with the runnerId and runnerJITConfig after the `GenerateJitRunnerConfig`
+		//   created the runner registration on the service.
+		// We will try to get the runner and see if it's belong to this AutoScalingRunnerSet,
+		// if so, we can simply delete the runner registration and create a new one.
+		log.Info("Getting runner jit config failed with conflict error, trying to get the runner by name", "runnerName", ephemeralRunner.Name)
+		existingRunner, err := actionsClient.GetRunnerByName(ctx, ephemeralRunner.Name)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to get runner by name: %v", err)
+		}
+
+		if existingRunner == nil {
+			log.Info("Runner with the same name does not exist, re-queuing the reconciliation")
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		log.Info("Found the runner with the same name", "runnerId", existingRunner.Id, "runnerScaleSetId", existingRunner.RunnerScaleSetId)
+		if existingRunner.RunnerScaleSetId == ephemeralRunner.Spec.RunnerScaleSetId {
+			log.Info("Removing the runner with the same name")
+			err := actionsClient.RemoveRunner(ctx, int64(existingRunner.Id))
+			if err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove runner from the service: %v", err)
+			}
+
+			log.Info("Removed the runner with the same name, re-queuing the reconciliation")
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		// TODO: Do we want to mark the ephemeral runner as failed, and let EphemeralRunnerSet to clean it up, so we can recover from this situation?
+		// The situation is that the EphemeralRunner's name is already used by something else to register a runner, and we can't take the control back.
+		return ctrl.Result{}, fmt.Errorf("runner with the same name but doesn't belong to this RunnerScaleSet: %v", err)
+	}
+	log.Info("Created ephemeral runner JIT config", "runnerId", jitConfig.Runner.Id)
+
+	log.Info("Updating ephemeral runner status with runnerId and runnerJITConfig")
+	err = patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.RunnerId = jitConfig.Runner.Id
+		obj.Status.RunnerName = jitConfig.Runner.Name
+		obj.Status.RunnerJITConfig = jitConfig.EncodedJITConfig
+	})
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update runner status for RunnerId/RunnerName/RunnerJITConfig: %v", err)
+	}
+
+	log.Info("Updated ephemeral runner status with runnerId and runnerJITConfig")
+	return ctrl.Result{}, nil
+}
+
+func (r *EphemeralRunnerReconciler) createPod(ctx context.Context, runner *v1alpha1.EphemeralRunner, secret *corev1.Secret, log logr.Logger) (ctrl.Result, error) {
+	envs := proxyEnvVars(runner, false)
+
 	log.Info("Creating new pod for ephemeral runner")
 	newPod := r.resourceBuilder.newEphemeralRunnerPod(ctx, runner, secret, envs...)
+	if runner.Spec.PreflightCheck {
+		newPod.Spec.InitContainers = append([]corev1.Container{r.preflightCheckContainer(runner)}, newPod.Spec.InitContainers...)
+	}
+	if waitsForSidecarDependency(runner) {
+		if err := withSidecarDependency(newPod, runnerContainerName(runner), runner.Spec.SidecarDependency); err != nil {
+			log.Error(err, "Failed to add the sidecar dependency to the runner container")
+			r.Recorder.Event(runner, corev1.EventTypeWarning, "InvalidSidecarDependency", err.Error())
+			return ctrl.Result{}, err
+		}
+	}
+	if runner.Spec.DinDSharedWorkVolume && runner.Spec.OS != v1alpha1.EphemeralRunnerOSWindows {
+		if err := withDinDSharedWorkVolume(newPod, runnerContainerName(runner)); err != nil {
+			// The pod template won't change for this runner, so creating the pod again would fail the same way.
+			log.Error(err, "Failed to share the work volume of the runner container with the docker in docker container")
+			if err := r.markAsInvalidPodTemplate(ctx, runner, "InvalidDinDSharedWorkVolume", err.Error(), log); err != nil {
+				log.Error(err, "Failed to mark ephemeral runner with an invalid pod template as failed")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+	if runner.Spec.Proxy != nil && runner.Spec.Proxy.InjectEnv {
+		proxyEnvs := append(proxyEnvVars(runner, false), proxyEnvVars(runner, true)...)
+		newPod.Spec.InitContainers = withProxyEnv(newPod.Spec.InitContainers, proxyEnvs)
+		newPod.Spec.Containers = withProxyEnv(newPod.Spec.Containers, proxyEnvs)
+	}
+	if r.RegistrationReadinessGate {
+		newPod.Spec.ReadinessGates = withReadinessGate(newPod.Spec.ReadinessGates, RunnerRegisteredPodConditionType)
+	}
 
 	if err := ctrl.SetControllerReference(runner, newPod, r.Scheme); err != nil {
 		log.Error(err, "Failed to set controller reference to a new pod")
@@ -616,6 +1452,7 @@ func (r *EphemeralRunnerReconciler) createPod(ctx context.Context, runner *v1alp
 	log.Info("Created new pod spec for ephemeral runner")
 	if err := r.Create(ctx, newPod); err != nil {
 		log.Error(err, "Failed to create pod resource for ephemeral runner.")
+		metrics.IncRunnerCreateFailures(runner.Namespace, ephemeralRunnerSetName(runner), runnerCreateFailureReason(err))
 		return ctrl.Result{}, err
 	}
 
@@ -629,6 +1466,189 @@ func (r *EphemeralRunnerReconciler) createPod(ctx context.Context, runner *v1alp
 	return ctrl.Result{}, nil
 }
 
+// preflightCheckContainer returns the init container checking that the GitHub config URL of the runner can be reached.
+// The proxy environment variables are set in both cases, since tools only honor one or the other.
+func (r *EphemeralRunnerReconciler) preflightCheckContainer(runner *v1alpha1.EphemeralRunner) corev1.Container {
+	image, command := r.PreflightCheckImage, r.PreflightCheckCommand
+	defaultImage, defaultCommand := DefaultPreflightCheckImage, DefaultPreflightCheckCommand
+	if runner.Spec.OS == v1alpha1.EphemeralRunnerOSWindows {
+		image, command = r.WindowsPreflightCheckImage, r.WindowsPreflightCheckCommand
+		defaultImage, defaultCommand = DefaultWindowsPreflightCheckImage, DefaultWindowsPreflightCheckCommand
+	}
+	if image == "" {
+		image = defaultImage
+	}
+	if len(command) == 0 {
+		command = defaultCommand
+	}
+
+	envs := []corev1.EnvVar{
+		{
+			Name:  EnvVarGitHubConfigUrl,
+			Value: runner.Spec.GitHubConfigUrl,
+		},
+	}
+	envs = append(envs, proxyEnvVars(runner, false)...)
+	envs = append(envs, proxyEnvVars(runner, true)...)
+
+	return corev1.Container{
+		Name:                     PreflightCheckContainerName,
+		Image:                    image,
+		Command:                  append([]string(nil), command...),
+		Env:                      envs,
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+}
+
+// preflightCheckFailure returns a description of the failure of the preflight check init container of the pod, if any.
+// The last termination is used while the init container waits to be restarted, depending on the pod restart policy.
+func preflightCheckFailure(runner *v1alpha1.EphemeralRunner, pod *corev1.Pod) (string, bool) {
+	if !runner.Spec.PreflightCheck {
+		return "", false
+	}
+
+	for _, cs := range pod.Status.InitContainerStatuses {
+		if cs.Name != PreflightCheckContainerName {
+			continue
+		}
+
+		terminated := cs.State.Terminated
+		if cs.State.Waiting != nil {
+			terminated = cs.LastTerminationState.Terminated
+		}
+		if terminated == nil || terminated.ExitCode == 0 {
+			return "", false
+		}
+
+		message := fmt.Sprintf("Preflight check of %s failed with exit code %d", runner.Spec.GitHubConfigUrl, terminated.ExitCode)
+		if output := strings.TrimSpace(terminated.Message); output != "" {
+			message += ": " + truncateLastFailureMessage(output)
+		}
+		return message, true
+	}
+	return "", false
+}
+
+// waitsForSidecarDependency reports whether the runner container waits for a sidecar before starting the runner.
+func waitsForSidecarDependency(runner *v1alpha1.EphemeralRunner) bool {
+	return runner.Spec.SidecarDependency != nil && runner.Spec.OS != v1alpha1.EphemeralRunnerOSWindows
+}
+
+// withSidecarDependency wraps the command of the runner container in a script polling the sidecar dependency on localhost
+// until it is up or the timeout is exceeded. The runner container gets a startup probe passing once the dependency is up,
+// so it is not considered started before, and fails shortly after the timeout in case the script can't report it.
+func withSidecarDependency(pod *corev1.Pod, containerName string, dependency *v1alpha1.SidecarDependency) error {
+	timeout := defaultSidecarDependencyTimeout
+	if dependency.Timeout != nil && dependency.Timeout.Duration > 0 {
+		timeout = dependency.Timeout.Duration
+	}
+	seconds := int32((timeout + time.Second - 1) / time.Second)
+
+	check := fmt.Sprintf("(: </dev/tcp/127.0.0.1/%d) 2>/dev/null", dependency.Port)
+	if dependency.Path != "" {
+		url := fmt.Sprintf("http://127.0.0.1:%d/%s", dependency.Port, strings.TrimPrefix(dependency.Path, "/"))
+		check = fmt.Sprintf("curl --noproxy '*' -fsS -o /dev/null --max-time 5 '%s'", strings.ReplaceAll(url, "'", `'\''`))
+	}
+	script := fmt.Sprintf(`deadline=$((SECONDS + %d))
+until %s; do
+  if [ "$SECONDS" -ge "$deadline" ]; then
+    echo "Sidecar dependency on port %d is not up after %s" >&2
+    exit 1
+  fi
+  sleep 1
+done
+touch %s
+exec "$@"`, seconds, check, dependency.Port, timeout, sidecarDependencyReadyFile)
+
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if container.Name != containerName {
+			continue
+		}
+		if len(container.Command) == 0 {
+			return fmt.Errorf("runner container %q must set its command to wait for the sidecar dependency", containerName)
+		}
+
+		container.Command = append([]string{"/bin/bash", "-c", script, "wait-for-sidecar"}, container.Command...)
+		container.StartupProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{Command: []string{"test", "-f", sidecarDependencyReadyFile}},
+			},
+			PeriodSeconds:    1,
+			FailureThreshold: seconds + 10,
+		}
+		return nil
+	}
+	return fmt.Errorf("runner container %q not found in the pod template", containerName)
+}
+
+// withDinDSharedWorkVolume mounts the work volume of the runner container into the docker in docker container at the same path.
+// The work volume is the volume mounted at the default runner work directory, or named "work", in the runner container.
+// An emptyDir is added to the pod and mounted in the runner container when there is none. Existing volumes and mounts are kept.
+func withDinDSharedWorkVolume(pod *corev1.Pod, containerName string) error {
+	var runner, dind *corev1.Container
+	for i := range pod.Spec.Containers {
+		switch pod.Spec.Containers[i].Name {
+		case containerName:
+			runner = &pod.Spec.Containers[i]
+		case DinDContainerName:
+			dind = &pod.Spec.Containers[i]
+		}
+	}
+	if runner == nil {
+		return fmt.Errorf("runner container %q not found in the pod template", containerName)
+	}
+	if dind == nil {
+		return fmt.Errorf("docker in docker container %q not found in the pod template", DinDContainerName)
+	}
+
+	workMount, ok := workVolumeMount(runner.VolumeMounts)
+	if !ok {
+		workMount = corev1.VolumeMount{Name: defaultWorkVolumeName, MountPath: DefaultRunnerWorkDir}
+		runner.VolumeMounts = append(runner.VolumeMounts, workMount)
+	}
+
+	shared := false
+	for _, volumeMount := range dind.VolumeMounts {
+		if volumeMount.Name == workMount.Name && volumeMount.MountPath == workMount.MountPath {
+			shared = true
+			break
+		}
+		if volumeMount.MountPath == workMount.MountPath {
+			return fmt.Errorf("docker in docker container %q mounts volume %q at the work directory %s instead of %q", DinDContainerName, volumeMount.Name, workMount.MountPath, workMount.Name)
+		}
+	}
+	if !shared {
+		dind.VolumeMounts = append(dind.VolumeMounts, corev1.VolumeMount{Name: workMount.Name, MountPath: workMount.MountPath})
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == workMount.Name {
+			return nil
+		}
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name:         workMount.Name,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+	return nil
+}
+
+// workVolumeMount returns the mount of the work volume among the volume mounts of the runner container, if any.
+func workVolumeMount(volumeMounts []corev1.VolumeMount) (corev1.VolumeMount, bool) {
+	for _, volumeMount := range volumeMounts {
+		if volumeMount.MountPath == DefaultRunnerWorkDir {
+			return volumeMount, true
+		}
+	}
+	for _, volumeMount := range volumeMounts {
+		if volumeMount.Name == defaultWorkVolumeName {
+			return volumeMount, true
+		}
+	}
+	return corev1.VolumeMount{}, false
+}
+
 func (r *EphemeralRunnerReconciler) createSecret(ctx context.Context, runner *v1alpha1.EphemeralRunner, log logr.Logger) (ctrl.Result, error) {
 	log.Info("Creating new secret for ephemeral runner")
 	jitSecret := r.resourceBuilder.newEphemeralRunnerJitSecret(runner)
@@ -652,28 +1672,171 @@ func (r *EphemeralRunnerReconciler) createSecret(ctx context.Context, runner *v1
 // The event should not be re-queued since the termination status should be set
 // before proceeding with reconciliation logic
 func (r *EphemeralRunnerReconciler) updateRunStatusFromPod(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
-	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+	phase := runnerPhase(pod, runnerContainerStatus(pod, runnerContainerName(ephemeralRunner)))
+	if phase == corev1.PodSucceeded || phase == corev1.PodFailed {
 		return nil
 	}
-	if ephemeralRunner.Status.Phase == pod.Status.Phase {
+	resetFailures := phase == corev1.PodRunning && (len(ephemeralRunner.Status.Failures) > 0 || ephemeralRunner.Status.PodCreationBackoffLevel > 0)
+	if ephemeralRunner.Status.Phase == phase && !resetFailures {
 		return nil
 	}
 
-	log.Info("Updating ephemeral runner status with pod phase", "phase", pod.Status.Phase, "reason", pod.Status.Reason, "message", pod.Status.Message)
+	previousPhase := ephemeralRunner.Status.Phase
+	log.Info("Updating ephemeral runner status with pod phase", "phase", phase, "podPhase", pod.Status.Phase, "reason", pod.Status.Reason, "message", pod.Status.Message)
 	err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
-		obj.Status.Phase = pod.Status.Phase
-		obj.Status.Ready = obj.Status.Ready || (pod.Status.Phase == corev1.PodRunning)
+		obj.Status.Phase = phase
+		obj.Status.Ready = obj.Status.Ready || (phase == corev1.PodRunning)
 		obj.Status.Reason = pod.Status.Reason
 		obj.Status.Message = pod.Status.Message
+		if resetFailures {
+			// The pod started successfully, so the consecutive failure count and the pod creation backoff are reset.
+			obj.Status.Failures = nil
+			obj.Status.FailureCount = 0
+			obj.Status.PodCreationBackoffLevel = 0
+			obj.Status.NextPodCreationTime = nil
+		}
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update runner status for Phase/Reason/Message: %v", err)
 	}
 
+	// The phase is persisted, so the scheduling latency of the pod is only recorded once.
+	if previousPhase != corev1.PodRunning && phase == corev1.PodRunning {
+		r.observeSchedule(ctx, ephemeralRunner, pod, log)
+	}
+
 	log.Info("Updated ephemeral runner status with pod phase")
 	return nil
 }
 
+// observeSchedule records the time between the creation of the runner pod and it being scheduled to a node.
+func (r *EphemeralRunnerReconciler) observeSchedule(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) {
+	var scheduledAt time.Time
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionTrue {
+			scheduledAt = condition.LastTransitionTime.Time
+		}
+	}
+	if scheduledAt.IsZero() {
+		return
+	}
+
+	nodePool := ""
+	if r.ScheduleMetricsNodeLabel != "" && pod.Spec.NodeName != "" {
+		node := new(corev1.Node)
+		if err := r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+			log.Error(err, "Failed to get the node of the runner pod for the scheduling metrics", "node", pod.Spec.NodeName)
+		} else {
+			nodePool = node.Labels[r.ScheduleMetricsNodeLabel]
+		}
+	}
+
+	metrics.ObserveRunnerSchedule(ephemeralRunner.Namespace, ephemeralRunnerSetName(ephemeralRunner), nodePool, scheduledAt.Sub(pod.CreationTimestamp.Time))
+}
+
+// observeBusy records the time between the job being assigned to the ephemeral runner and now, when it completed the job.
+// The completion time is annotated on the ephemeral runner, so each job is only observed once.
+// Ephemeral runners without a valid job assignment time are ignored.
+func (r *EphemeralRunnerReconciler) observeBusy(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, now time.Time, log logr.Logger) {
+	assignedAt, err := time.Parse(time.RFC3339, ephemeralRunner.Annotations[v1alpha1.AnnotationKeyJobAssignedAt])
+	if err != nil {
+		return
+	}
+	if completedAt, err := time.Parse(time.RFC3339, ephemeralRunner.Annotations[AnnotationKeyJobCompletedAt]); err == nil && !completedAt.Before(assignedAt) {
+		return
+	}
+
+	if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		if obj.Annotations == nil {
+			obj.Annotations = make(map[string]string)
+		}
+		obj.Annotations[AnnotationKeyJobCompletedAt] = now.UTC().Format(time.RFC3339)
+	}); err != nil {
+		log.Error(err, "Failed to annotate the ephemeral runner with the job completion time")
+		return
+	}
+
+	busy := now.Sub(assignedAt)
+	if busy < 0 {
+		busy = 0
+	}
+	metrics.ObserveRunnerBusy(ephemeralRunner.Namespace, ephemeralRunnerSetName(ephemeralRunner), busy)
+}
+
+// ephemeralRunnerSetName returns the name of the EphemeralRunnerSet owning the ephemeral runner, or an empty string.
+func ephemeralRunnerSetName(ephemeralRunner *v1alpha1.EphemeralRunner) string {
+	if owner := metav1.GetControllerOf(ephemeralRunner); owner != nil {
+		return owner.Name
+	}
+	return ""
+}
+
+// updateRunnerRegisteredCondition sets the RunnerRegisteredPodConditionType condition of pods having the readiness gate.
+// The condition becomes True once the runner container is running with a runner id.
+func (r *EphemeralRunnerReconciler) updateRunnerRegisteredCondition(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, cs *corev1.ContainerStatus, log logr.Logger) error {
+	if !hasReadinessGate(pod, RunnerRegisteredPodConditionType) {
+		return nil
+	}
+
+	status := corev1.ConditionFalse
+	reason := "RunnerNotRegistered"
+	if ephemeralRunner.Status.RunnerId != 0 && cs.State.Running != nil {
+		status = corev1.ConditionTrue
+		reason = "RunnerRegistered"
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == RunnerRegisteredPodConditionType && condition.Status == status {
+			return nil
+		}
+	}
+
+	log.Info("Updating runner registered pod condition", "status", status, "runnerId", ephemeralRunner.Status.RunnerId)
+
+	// Pod conditions are merged by type with a strategic merge patch, so the conditions
+	// maintained by the kubelet are left untouched.
+	original := pod.DeepCopy()
+	condition := corev1.PodCondition{
+		Type:               RunnerRegisteredPodConditionType,
+		Status:             status,
+		Reason:             reason,
+		LastTransitionTime: metav1.Now(),
+	}
+	updated := false
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == RunnerRegisteredPodConditionType {
+			pod.Status.Conditions[i] = condition
+			updated = true
+		}
+	}
+	if !updated {
+		pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	}
+
+	if err := r.Status().Patch(ctx, pod, client.StrategicMergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to patch pod status: %v", err)
+	}
+	return nil
+}
+
+func hasReadinessGate(pod *corev1.Pod, conditionType corev1.PodConditionType) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == conditionType {
+			return true
+		}
+	}
+	return false
+}
+
+func withReadinessGate(gates []corev1.PodReadinessGate, conditionType corev1.PodConditionType) []corev1.PodReadinessGate {
+	for _, gate := range gates {
+		if gate.ConditionType == conditionType {
+			return gates
+		}
+	}
+	return append(gates, corev1.PodReadinessGate{ConditionType: conditionType})
+}
+
 func (r *EphemeralRunnerReconciler) actionsClientFor(ctx context.Context, runner *v1alpha1.EphemeralRunner) (actions.ActionsService, error) {
 	secret := new(corev1.Secret)
 	if err := r.Get(ctx, types.NamespacedName{Namespace: runner.Namespace, Name: runner.Spec.GitHubConfigSecret}, secret); err != nil {
@@ -682,7 +1845,7 @@ func (r *EphemeralRunnerReconciler) actionsClientFor(ctx context.Context, runner
 
 	var opts []actions.ClientOption
 	if runner.Spec.Proxy != nil {
-		proxyFunc, err := runner.Spec.Proxy.ProxyFunc(func(s string) (*corev1.Secret, error) {
+		secretFetcher := func(s string) (*corev1.Secret, error) {
 			var secret corev1.Secret
 			err := r.Get(ctx, types.NamespacedName{Namespace: runner.Namespace, Name: s}, &secret)
 			if err != nil {
@@ -690,26 +1853,41 @@ func (r *EphemeralRunnerReconciler) actionsClientFor(ctx context.Context, runner
 			}
 
 			return &secret, nil
-		})
+		}
+
+		proxyFunc, err := runner.Spec.Proxy.ProxyFunc(secretFetcher)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get proxy func: %w", err)
 		}
 
 		opts = append(opts, actions.WithProxy(proxyFunc))
+
+		caCertificate, err := runner.Spec.Proxy.CACertificate(secretFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get proxy ca certificate: %w", err)
+		}
+
+		if caCertificate != nil {
+			opts = append(opts, actions.WithProxyCACertificate(caCertificate))
+		}
 	}
 
 	return r.ActionsClient.GetClientFromSecret(
 		ctx,
 		runner.Spec.GitHubConfigUrl,
 		runner.Namespace,
-		secret.Data,
+		actions.KubernetesSecret{
+			Name:            secret.Name,
+			ResourceVersion: secret.ResourceVersion,
+			Data:            secret.Data,
+		},
 		opts...,
 	)
 }
 
 // runnerRegisteredWithService checks if the runner is still registered with the service
 // Returns found=false and err=nil if ephemeral runner does not exist in GitHub service and should be deleted
-func (r EphemeralRunnerReconciler) runnerRegisteredWithService(ctx context.Context, runner *v1alpha1.EphemeralRunner, log logr.Logger) (found bool, err error) {
+func (r *EphemeralRunnerReconciler) runnerRegisteredWithService(ctx context.Context, runner *v1alpha1.EphemeralRunner, log logr.Logger) (found bool, err error) {
 	actionsClient, err := r.actionsClientFor(ctx, runner)
 	if err != nil {
 		return false, fmt.Errorf("failed to get Actions client for ScaleSet: %w", err)
@@ -736,6 +1914,146 @@ func (r EphemeralRunnerReconciler) runnerRegisteredWithService(ctx context.Conte
 	return true, nil
 }
 
+// checkRemovedFromService checks whether the idle registered runner still exists in the service,
+// at most once per RemovedRunnerCheckInterval and only after it has been running for that long.
+// It returns whether the runner was removed from the service, and otherwise how long until the next check,
+// or zero if the runner is not checked. Failures of the check are only logged, the runner is checked again later.
+func (r *EphemeralRunnerReconciler) checkRemovedFromService(ctx context.Context, runner *v1alpha1.EphemeralRunner, pod *corev1.Pod, now time.Time, log logr.Logger) (removed bool, nextCheck time.Duration) {
+	interval := r.RemovedRunnerCheckInterval
+	if interval <= 0 || runner.Status.RunnerId == 0 || runner.Status.JobRequestId > 0 || pod.Status.StartTime == nil {
+		return false, 0
+	}
+
+	next := pod.Status.StartTime.Add(interval)
+	r.removedRunnerChecksMu.Lock()
+	if last, ok := r.removedRunnerChecks[runner.UID]; ok && last.Add(interval).After(next) {
+		next = last.Add(interval)
+	}
+	if now.Before(next) {
+		r.removedRunnerChecksMu.Unlock()
+		return false, next.Sub(now)
+	}
+	if r.removedRunnerChecks == nil {
+		r.removedRunnerChecks = make(map[types.UID]time.Time)
+	}
+	r.removedRunnerChecks[runner.UID] = now
+	r.removedRunnerChecksMu.Unlock()
+
+	found, err := r.runnerRegisteredWithService(ctx, runner.DeepCopy(), log)
+	if err != nil {
+		log.Error(err, "Failed to check if idle runner is registered with the service")
+		return false, interval
+	}
+	if found {
+		return false, interval
+	}
+	return true, 0
+}
+
+// checkHealth checks the health endpoint of the idle registered runner, at most once per health check interval
+// and only after the runner pod has been running for that long. The consecutive failed checks are recorded in the status.
+// It returns whether the runner reached the failure threshold of its health check, and otherwise how long until
+// the next check, or zero if the runner is not checked.
+func (r *EphemeralRunnerReconciler) checkHealth(ctx context.Context, runner *v1alpha1.EphemeralRunner, pod *corev1.Pod, now time.Time, log logr.Logger) (unhealthy bool, nextCheck time.Duration, err error) {
+	healthCheck := runner.Spec.HealthCheck
+	if healthCheck == nil || runner.Status.RunnerId == 0 || runner.Status.JobRequestId > 0 || pod.Status.StartTime == nil || pod.Status.PodIP == "" {
+		return false, 0, nil
+	}
+
+	interval := defaultHealthCheckInterval
+	if healthCheck.Interval != nil && healthCheck.Interval.Duration > 0 {
+		interval = healthCheck.Interval.Duration
+	}
+	failureThreshold := defaultHealthCheckFailureThreshold
+	if healthCheck.FailureThreshold > 0 {
+		failureThreshold = healthCheck.FailureThreshold
+	}
+
+	next := pod.Status.StartTime.Add(interval)
+	r.healthChecksMu.Lock()
+	if last, ok := r.healthChecks[runner.UID]; ok && last.Add(interval).After(next) {
+		next = last.Add(interval)
+	}
+	if now.Before(next) {
+		r.healthChecksMu.Unlock()
+		return false, next.Sub(now), nil
+	}
+	if r.healthChecks == nil {
+		r.healthChecks = make(map[types.UID]time.Time)
+	}
+	r.healthChecks[runner.UID] = now
+	r.healthChecksMu.Unlock()
+
+	failures := 0
+	if err := probeHealthEndpoint(ctx, pod.Status.PodIP, healthCheck); err != nil {
+		failures = runner.Status.HealthCheckFailures + 1
+		log.Info("Ephemeral runner failed its health check", "failures", failures, "failureThreshold", failureThreshold, "error", err.Error())
+	}
+
+	if failures != runner.Status.HealthCheckFailures {
+		if err := patchSubResource(ctx, r.Status(), runner, func(obj *v1alpha1.EphemeralRunner) {
+			obj.Status.HealthCheckFailures = failures
+		}); err != nil {
+			// Check again on the retry, so the failure is not lost.
+			r.forgetHealthCheck(runner.UID)
+			return false, 0, fmt.Errorf("failed to update health check failures: %w", err)
+		}
+	}
+
+	if failures >= failureThreshold {
+		return true, 0, nil
+	}
+	return false, interval, nil
+}
+
+// healthCheckClient requests the health endpoints of runner pods. Pod IPs are reached directly, so the proxy
+// environment of the controller is ignored, and connections are not kept alive since pods come and go.
+var healthCheckClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:             nil,
+		DisableKeepAlives: true,
+	},
+	Timeout: healthCheckTimeout,
+}
+
+// probeHealthEndpoint requests the health endpoint of the runner pod, which must respond with a successful status code.
+func probeHealthEndpoint(ctx context.Context, podIP string, healthCheck *v1alpha1.RunnerHealthCheck) error {
+	path := healthCheck.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := "http://" + net.JoinHostPort(podIP, strconv.Itoa(int(healthCheck.Port))) + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health check request: %w", err)
+	}
+	resp, err := healthCheckClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("health endpoint %s responded with status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// forgetHealthCheck removes the time the health endpoint of the ephemeral runner was last checked.
+func (r *EphemeralRunnerReconciler) forgetHealthCheck(uid types.UID) {
+	r.healthChecksMu.Lock()
+	defer r.healthChecksMu.Unlock()
+	delete(r.healthChecks, uid)
+}
+
+// forgetRemovedRunnerCheck removes the time the ephemeral runner was last checked to exist in the service.
+func (r *EphemeralRunnerReconciler) forgetRemovedRunnerCheck(uid types.UID) {
+	r.removedRunnerChecksMu.Lock()
+	defer r.removedRunnerChecksMu.Unlock()
+	delete(r.removedRunnerChecks, uid)
+}
+
 func (r *EphemeralRunnerReconciler) deleteRunnerFromService(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
 	client, err := r.actionsClientFor(ctx, ephemeralRunner)
 	if err != nil {
@@ -754,22 +2072,191 @@ func (r *EphemeralRunnerReconciler) deleteRunnerFromService(ctx context.Context,
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *EphemeralRunnerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Recorder = mgr.GetEventRecorderFor("ephemeral-runner-controller")
+
+	if r.KubeClient == nil {
+		kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			return fmt.Errorf("failed to create kubernetes client: %v", err)
+		}
+		r.KubeClient = kubeClient
+	}
+
+	if r.PodExecutor == nil {
+		r.PodExecutor = newPodCommandExecutor(mgr.GetConfig(), r.KubeClient)
+	}
+
 	// TODO(nikola-jokic): Add indexing and filtering fields on corev1.Pod{}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.EphemeralRunner{}).
 		Owns(&corev1.Pod{}).
 		Owns(&corev1.Secret{}).
 		WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Named("ephemeral-runner-controller").
-		Complete(r)
+		Complete(instrumentReconciler("ephemeralrunner", r))
+}
+
+// runnerContainerName returns the name of the runner container in the pod of the ephemeral runner.
+func runnerContainerName(ephemeralRunner *v1alpha1.EphemeralRunner) string {
+	return specRunnerContainerName(&ephemeralRunner.Spec)
 }
 
-func runnerContainerStatus(pod *corev1.Pod) *corev1.ContainerStatus {
+// specRunnerContainerName returns the name of the runner container in the pod template of the ephemeral runner spec.
+func specRunnerContainerName(spec *v1alpha1.EphemeralRunnerSpec) string {
+	if spec.RunnerContainerName != "" {
+		return spec.RunnerContainerName
+	}
+	return EphemeralRunnerContainerName
+}
+
+func runnerContainerStatus(pod *corev1.Pod, containerName string) *corev1.ContainerStatus {
 	for i := range pod.Status.ContainerStatuses {
 		cs := &pod.Status.ContainerStatuses[i]
-		if cs.Name == EphemeralRunnerContainerName {
+		if cs.Name == containerName {
 			return cs
 		}
 	}
 	return nil
 }
+
+// runnerPhase returns the phase of the runner, derived from the state of the runner container rather than from the pod.
+// Other containers of the pod, such as sidecars injected by a service mesh, may start before the runner container
+// and keep running after it exited, so they must not decide whether the runner is running or finished.
+func runnerPhase(pod *corev1.Pod, cs *corev1.ContainerStatus) corev1.PodPhase {
+	switch {
+	case cs == nil:
+		return pod.Status.Phase
+	case cs.State.Terminated != nil && cs.State.Terminated.ExitCode == 0:
+		return corev1.PodSucceeded
+	case cs.State.Terminated != nil:
+		return corev1.PodFailed
+	case pod.Status.Phase == corev1.PodRunning && cs.State.Running == nil:
+		// Only the sidecars are running so far.
+		return corev1.PodPending
+	default:
+		return pod.Status.Phase
+	}
+}
+
+// truncateLastFailureMessage keeps the end of the message so it fits in the status.
+func truncateLastFailureMessage(message string) string {
+	if len(message) <= maxLastFailureMessageLength {
+		return message
+	}
+	return message[len(message)-maxLastFailureMessageLength:]
+}
+
+// failureLimit returns the number of consecutive pod failures tolerated for the ephemeral runner.
+func failureLimit(runner *v1alpha1.EphemeralRunner) int {
+	if runner.Spec.FailureLimit <= 0 {
+		return defaultEphemeralRunnerFailureLimit
+	}
+	return runner.Spec.FailureLimit
+}
+
+// runnerRegistrationCheck is whether the runner of a pod was seen online in the service, and when it was last checked.
+type runnerRegistrationCheck struct {
+	podUID    types.UID
+	online    bool
+	failed    bool
+	checkedAt time.Time
+}
+
+// checkRegistration checks whether the runner of the pod came online in the service within its registration timeout,
+// counted from the pod creation. Runners assigned a job are online. Otherwise the service is asked once the runner
+// container is running, at most once per runnerRegistrationCheckInterval, until the runner is seen online.
+// It returns whether the registration timed out, and otherwise how long until the next check, or zero if the runner
+// is not checked. Failures of the check are only logged, and the registration doesn't time out until a check succeeds.
+func (r *EphemeralRunnerReconciler) checkRegistration(ctx context.Context, runner *v1alpha1.EphemeralRunner, pod *corev1.Pod, cs *corev1.ContainerStatus, now time.Time, log logr.Logger) (timedOut bool, nextCheck time.Duration) {
+	timeout := runner.Spec.RegistrationTimeout
+	if timeout == nil || timeout.Duration <= 0 || !pod.DeletionTimestamp.IsZero() || runner.Status.JobRequestId > 0 {
+		return false, 0
+	}
+	if cs != nil && cs.State.Terminated != nil {
+		return false, 0
+	}
+
+	r.registrationChecksMu.Lock()
+	check := r.registrationChecks[runner.UID]
+	r.registrationChecksMu.Unlock()
+	if check.podUID != pod.UID {
+		check = runnerRegistrationCheck{podUID: pod.UID}
+	}
+	if check.online {
+		return false, 0
+	}
+
+	running := runner.Status.RunnerId != 0 && cs != nil && cs.State.Running != nil
+	if running && !now.Before(check.checkedAt.Add(runnerRegistrationCheckInterval)) {
+		online, err := r.runnerOnlineInService(ctx, runner, log)
+		check.checkedAt, check.failed = now, err != nil
+		if err == nil {
+			check.online = online
+		}
+		r.registrationChecksMu.Lock()
+		if r.registrationChecks == nil {
+			r.registrationChecks = make(map[types.UID]runnerRegistrationCheck)
+		}
+		r.registrationChecks[runner.UID] = check
+		r.registrationChecksMu.Unlock()
+
+		if err != nil {
+			log.Error(err, "Failed to check if the runner is online in the service")
+			return false, runnerRegistrationCheckInterval
+		}
+		if online {
+			log.Info("Runner is online in the service", "runnerId", runner.Status.RunnerId)
+			return false, 0
+		}
+	}
+
+	next := check.checkedAt.Add(runnerRegistrationCheckInterval).Sub(now)
+	if running && check.failed {
+		return false, next
+	}
+	remaining := pod.CreationTimestamp.Add(timeout.Duration).Sub(now)
+	if remaining <= 0 {
+		return true, 0
+	}
+	if running && next < remaining {
+		return false, next
+	}
+	return false, remaining
+}
+
+// runnerOnlineInService reports whether the service reports the runner online. Runners the service doesn't report
+// a status for are considered online as long as they are registered, since their status can't be told apart.
+func (r *EphemeralRunnerReconciler) runnerOnlineInService(ctx context.Context, runner *v1alpha1.EphemeralRunner, log logr.Logger) (bool, error) {
+	actionsClient, err := r.actionsClientFor(ctx, runner)
+	if err != nil {
+		return false, fmt.Errorf("failed to get Actions client for ScaleSet: %w", err)
+	}
+
+	reference, err := actionsClient.GetRunner(ctx, int64(runner.Status.RunnerId))
+	if err != nil {
+		actionsError := &actions.ActionsError{}
+		if errors.As(err, &actionsError) && actionsError.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	log.Info("Checked runner status in the service", "runnerId", runner.Status.RunnerId, "status", reference.Status)
+	return reference.Status == "" || reference.Status == actions.RunnerStatusOnline, nil
+}
+
+// forgetRegistrationCheck removes whether the runner of the ephemeral runner was seen online in the service.
+func (r *EphemeralRunnerReconciler) forgetRegistrationCheck(uid types.UID) {
+	r.registrationChecksMu.Lock()
+	defer r.registrationChecksMu.Unlock()
+	delete(r.registrationChecks, uid)
+}
+
+// maxLifetimeRemaining returns the time left before the runner pod exceeds the configured max lifetime,
+// based on the pod start time. It returns false if the max lifetime is not set or the pod has not started yet.
+func maxLifetimeRemaining(runner *v1alpha1.EphemeralRunner, pod *corev1.Pod, now time.Time) (time.Duration, bool) {
+	if runner.Spec.MaxLifetime == nil || runner.Spec.MaxLifetime.Duration <= 0 || pod.Status.StartTime == nil {
+		return 0, false
+	}
+	return pod.Status.StartTime.Add(runner.Spec.MaxLifetime.Duration).Sub(now), true
+}
@@ -3,10 +3,15 @@ package actionsgithubcom
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os/exec"
+	"strconv"
 	"strings"
+	"testing"
 	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
@@ -16,12 +21,17 @@ import (
 	"github.com/actions/actions-runner-controller/github/actions/fake"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 const (
@@ -101,6 +111,8 @@ var _ = Describe("EphemeralRunner", func() {
 				Scheme:        mgr.GetScheme(),
 				Log:           logf.Log,
 				ActionsClient: fake.NewMultiClient(),
+				// Re-create failed pods right away.
+				PodCreationBackoffMax: time.Millisecond,
 			}
 
 			err := controller.SetupWithManager(mgr)
@@ -716,6 +728,8 @@ var _ = Describe("EphemeralRunner", func() {
 				Scheme:        mgr.GetScheme(),
 				Log:           logf.Log,
 				ActionsClient: fake.NewMultiClient(),
+				// Re-create failed pods right away.
+				PodCreationBackoffMax: time.Millisecond,
 			}
 			err := controller.SetupWithManager(mgr)
 			Expect(err).To(BeNil(), "failed to setup controller")
@@ -842,3 +856,1327 @@ var _ = Describe("EphemeralRunner", func() {
 		})
 	})
 })
+
+func TestMaxLifetimeRemaining(t *testing.T) {
+	now := time.Now()
+	startedAt := metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name          string
+		maxLifetime   *metav1.Duration
+		startTime     *metav1.Time
+		wantRemaining time.Duration
+		wantOk        bool
+	}{
+		{
+			name:        "max lifetime not set",
+			maxLifetime: nil,
+			startTime:   &startedAt,
+			wantOk:      false,
+		},
+		{
+			name:        "pod not started",
+			maxLifetime: &metav1.Duration{Duration: time.Hour},
+			startTime:   nil,
+			wantOk:      false,
+		},
+		{
+			name:          "within max lifetime",
+			maxLifetime:   &metav1.Duration{Duration: 90 * time.Minute},
+			startTime:     &startedAt,
+			wantRemaining: 30 * time.Minute,
+			wantOk:        true,
+		},
+		{
+			name:          "exceeded max lifetime",
+			maxLifetime:   &metav1.Duration{Duration: 30 * time.Minute},
+			startTime:     &startedAt,
+			wantRemaining: -30 * time.Minute,
+			wantOk:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := newExampleRunner("test-runner", "default", "test-secret")
+			runner.Spec.MaxLifetime = tt.maxLifetime
+
+			pod := &corev1.Pod{
+				Status: corev1.PodStatus{
+					StartTime: tt.startTime,
+				},
+			}
+
+			remaining, ok := maxLifetimeRemaining(runner, pod, now)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantRemaining, remaining.Round(time.Second))
+		})
+	}
+}
+
+func TestCheckRegistration(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	now := time.Now()
+	waiting := &corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}}
+	running := &corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}
+	newPod := func(age time.Duration) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{UID: "pod-uid", CreationTimestamp: metav1.NewTime(now.Add(-age))}}
+	}
+	newRunner := func(registrationTimeout *metav1.Duration) *v1alpha1.EphemeralRunner {
+		runner := newRegisteredExampleRunner("secret", 1, 0)
+		runner.Spec.RegistrationTimeout = registrationTimeout
+		return runner
+	}
+	newReconciler := func(status string, err error) *EphemeralRunnerReconciler {
+		return &EphemeralRunnerReconciler{
+			Client:        newFakeClient(scheme, newTestConfigSecret("secret")),
+			Scheme:        scheme,
+			ActionsClient: newGetRunnerMultiClient(&actions.RunnerReference{Id: 1, Status: status}, err),
+		}
+	}
+	timeout := &metav1.Duration{Duration: 5 * time.Minute}
+	ctx := context.Background()
+
+	t.Run("registration timeout not set", func(t *testing.T) {
+		timedOut, next := newReconciler("offline", nil).checkRegistration(ctx, newRunner(nil), newPod(10*time.Minute), waiting, now, logr.Discard())
+		assert.False(t, timedOut)
+		assert.Zero(t, next)
+	})
+
+	t.Run("runner container not started", func(t *testing.T) {
+		r := newReconciler("offline", nil)
+		timedOut, next := r.checkRegistration(ctx, newRunner(timeout), newPod(time.Minute), waiting, now, logr.Discard())
+		assert.False(t, timedOut)
+		assert.Equal(t, 4*time.Minute, next.Round(time.Second))
+
+		timedOut, _ = r.checkRegistration(ctx, newRunner(timeout), newPod(10*time.Minute), nil, now, logr.Discard())
+		assert.True(t, timedOut, "pods not starting within the timeout should time out")
+	})
+
+	t.Run("running runner never online", func(t *testing.T) {
+		r := newReconciler("offline", nil)
+		timedOut, next := r.checkRegistration(ctx, newRunner(timeout), newPod(time.Minute), running, now, logr.Discard())
+		assert.False(t, timedOut)
+		assert.Equal(t, runnerRegistrationCheckInterval, next)
+
+		timedOut, _ = r.checkRegistration(ctx, newRunner(timeout), newPod(10*time.Minute), running, now.Add(runnerRegistrationCheckInterval), logr.Discard())
+		assert.True(t, timedOut, "running runners that can't reach the service should time out")
+	})
+
+	t.Run("online runner", func(t *testing.T) {
+		r := newReconciler(actions.RunnerStatusOnline, nil)
+		timedOut, next := r.checkRegistration(ctx, newRunner(timeout), newPod(10*time.Minute), running, now, logr.Discard())
+		assert.False(t, timedOut)
+		assert.Zero(t, next, "online runners are not checked again")
+	})
+
+	t.Run("runner assigned a job", func(t *testing.T) {
+		runner := newRunner(timeout)
+		runner.Status.JobRequestId = 1
+		timedOut, next := newReconciler("offline", nil).checkRegistration(ctx, runner, newPod(10*time.Minute), running, now, logr.Discard())
+		assert.False(t, timedOut)
+		assert.Zero(t, next)
+	})
+
+	t.Run("failed check", func(t *testing.T) {
+		r := newReconciler("", &actions.ActionsError{StatusCode: http.StatusInternalServerError})
+		timedOut, next := r.checkRegistration(ctx, newRunner(timeout), newPod(10*time.Minute), running, now, logr.Discard())
+		assert.False(t, timedOut, "the registration should not time out while the service can't be checked")
+		assert.Equal(t, runnerRegistrationCheckInterval, next)
+	})
+}
+
+type fakePodCommandExecutor struct {
+	err      error
+	commands [][]string
+}
+
+func (e *fakePodCommandExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) error {
+	e.commands = append(e.commands, command)
+	return e.err
+}
+
+func TestRunPreDeleteCommand(t *testing.T) {
+	runningPod := &corev1.Pod{
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  EphemeralRunnerContainerName,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}
+	terminatedPod := &corev1.Pod{
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  EphemeralRunnerContainerName,
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}},
+				},
+			},
+		},
+	}
+	command := []string{"docker", "system", "prune", "-f"}
+
+	tests := []struct {
+		name         string
+		command      []string
+		pod          *corev1.Pod
+		execErr      error
+		wantCommands int
+		wantEvents   int
+	}{
+		{
+			name:         "no command",
+			command:      nil,
+			pod:          runningPod,
+			wantCommands: 0,
+			wantEvents:   0,
+		},
+		{
+			name:         "runner container terminated",
+			command:      command,
+			pod:          terminatedPod,
+			wantCommands: 0,
+			wantEvents:   0,
+		},
+		{
+			name:         "command succeeds",
+			command:      command,
+			pod:          runningPod,
+			wantCommands: 1,
+			wantEvents:   0,
+		},
+		{
+			name:         "command fails",
+			command:      command,
+			pod:          runningPod,
+			execErr:      fmt.Errorf("exit code 1"),
+			wantCommands: 1,
+			wantEvents:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &fakePodCommandExecutor{err: tt.execErr}
+			recorder := record.NewFakeRecorder(10)
+			r := &EphemeralRunnerReconciler{
+				Recorder:    recorder,
+				PodExecutor: executor,
+			}
+
+			runner := &v1alpha1.EphemeralRunner{
+				Spec: v1alpha1.EphemeralRunnerSpec{
+					PreDeleteCommand: tt.command,
+				},
+			}
+
+			r.runPreDeleteCommand(context.Background(), runner, tt.pod, logr.Discard())
+
+			assert.Len(t, executor.commands, tt.wantCommands)
+			assert.Len(t, recorder.Events, tt.wantEvents)
+		})
+	}
+}
+
+func TestRunnerContainerName(t *testing.T) {
+	runner := &v1alpha1.EphemeralRunner{}
+	assert.Equal(t, EphemeralRunnerContainerName, runnerContainerName(runner))
+
+	runner.Spec.RunnerContainerName = "actions-runner"
+	runner.Spec.PodTemplateSpec.Spec.Containers = []corev1.Container{
+		{Name: "runner"},
+		{Name: "actions-runner"},
+	}
+	assert.Equal(t, "actions-runner", runnerContainerName(runner))
+
+	var b resourceBuilder
+	pod := b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{})
+	assert.Empty(t, pod.Spec.Containers[0].Env, "the JIT config must not be injected into other containers")
+	assert.Equal(t, EnvVarRunnerJITConfig, pod.Spec.Containers[1].Env[0].Name)
+
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "runner"},
+		{Name: "actions-runner"},
+	}
+	assert.Equal(t, &pod.Status.ContainerStatuses[1], runnerContainerStatus(pod, runnerContainerName(runner)))
+	assert.Nil(t, runnerContainerStatus(pod, "missing"))
+}
+
+func TestUpdateRunnerRegisteredCondition(t *testing.T) {
+	running := &corev1.ContainerStatus{
+		Name:  EphemeralRunnerContainerName,
+		State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}
+	waiting := &corev1.ContainerStatus{
+		Name:  EphemeralRunnerContainerName,
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}},
+	}
+
+	newPod := func(gates ...corev1.PodReadinessGate) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+			Spec:       corev1.PodSpec{ReadinessGates: gates},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+	}
+	gate := corev1.PodReadinessGate{ConditionType: RunnerRegisteredPodConditionType}
+
+	tests := []struct {
+		name          string
+		pod           *corev1.Pod
+		runnerId      int
+		cs            *corev1.ContainerStatus
+		wantCondition *corev1.ConditionStatus
+	}{
+		{
+			name:     "pod without readiness gate is left untouched",
+			pod:      newPod(),
+			runnerId: 1,
+			cs:       running,
+		},
+		{
+			name:          "registered runner with running container is ready",
+			pod:           newPod(gate),
+			runnerId:      1,
+			cs:            running,
+			wantCondition: func() *corev1.ConditionStatus { s := corev1.ConditionTrue; return &s }(),
+		},
+		{
+			name:          "runner container not running yet is not ready",
+			pod:           newPod(gate),
+			runnerId:      1,
+			cs:            waiting,
+			wantCondition: func() *corev1.ConditionStatus { s := corev1.ConditionFalse; return &s }(),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &EphemeralRunnerReconciler{
+				Client: clientfake.NewClientBuilder().WithObjects(tc.pod).Build(),
+			}
+			ephemeralRunner := &v1alpha1.EphemeralRunner{
+				Status: v1alpha1.EphemeralRunnerStatus{RunnerId: tc.runnerId},
+			}
+
+			ctx := context.Background()
+			pod := new(corev1.Pod)
+			require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(tc.pod), pod))
+			require.NoError(t, r.updateRunnerRegisteredCondition(ctx, ephemeralRunner, pod, tc.cs, logr.Discard()))
+
+			updated := new(corev1.Pod)
+			require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(tc.pod), updated))
+
+			var condition *corev1.PodCondition
+			for i := range updated.Status.Conditions {
+				if updated.Status.Conditions[i].Type == RunnerRegisteredPodConditionType {
+					condition = &updated.Status.Conditions[i]
+				}
+			}
+
+			if tc.wantCondition == nil {
+				assert.Nil(t, condition)
+			} else {
+				require.NotNil(t, condition)
+				assert.Equal(t, *tc.wantCondition, condition.Status)
+			}
+			assert.Contains(t, updated.Status.Conditions, corev1.PodCondition{Type: corev1.ContainersReady, Status: corev1.ConditionTrue}, "kubelet conditions should be kept")
+		})
+	}
+}
+
+func TestWithReadinessGate(t *testing.T) {
+	gates := withReadinessGate(nil, RunnerRegisteredPodConditionType)
+	assert.Equal(t, []corev1.PodReadinessGate{{ConditionType: RunnerRegisteredPodConditionType}}, gates)
+
+	gates = withReadinessGate(gates, RunnerRegisteredPodConditionType)
+	assert.Len(t, gates, 1, "readiness gate should not be added twice")
+}
+
+func TestPodCreationBackoff(t *testing.T) {
+	jittered := func(d time.Duration) time.Duration {
+		return time.Duration(float64(d) * (1 + podCreationBackoffJitter))
+	}
+
+	tests := []struct {
+		name    string
+		level   int
+		max     time.Duration
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "first failure", level: 1, max: time.Hour, wantMin: podCreationBackoffInitial, wantMax: jittered(podCreationBackoffInitial)},
+		{name: "doubles with each failure", level: 3, max: time.Hour, wantMin: 4 * podCreationBackoffInitial, wantMax: jittered(4 * podCreationBackoffInitial)},
+		{name: "capped", level: 20, max: time.Minute, wantMin: time.Minute, wantMax: time.Minute},
+		{name: "default max", level: 20, max: 0, wantMin: DefaultPodCreationBackoffMax, wantMax: DefaultPodCreationBackoffMax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				got := podCreationBackoff(tt.level, tt.max)
+				assert.GreaterOrEqual(t, got, tt.wantMin)
+				assert.LessOrEqual(t, got, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestPodCreationBackoffRemaining(t *testing.T) {
+	now := time.Now()
+	ephemeralRunner := new(v1alpha1.EphemeralRunner)
+	assert.Equal(t, time.Duration(0), podCreationBackoffRemaining(ephemeralRunner, now))
+
+	next := metav1.NewTime(now.Add(time.Minute))
+	ephemeralRunner.Status.NextPodCreationTime = &next
+	assert.Equal(t, time.Minute, podCreationBackoffRemaining(ephemeralRunner, now))
+	assert.True(t, podCreationBackoffRemaining(ephemeralRunner, now.Add(2*time.Minute)) < 0)
+}
+
+func TestTerminationGracePeriodSeconds(t *testing.T) {
+	var b resourceBuilder
+	runner := newExampleRunner("test-runner", "default", "secret")
+	templateGracePeriod := int64(30)
+	runner.Spec.PodTemplateSpec.Spec.TerminationGracePeriodSeconds = &templateGracePeriod
+
+	pod := b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{})
+	assert.Equal(t, int64(30), *pod.Spec.TerminationGracePeriodSeconds, "pod template grace period should be used when unset")
+	assert.Empty(t, podDeleteOptions(runner))
+
+	gracePeriod := int64(600)
+	runner.Spec.TerminationGracePeriodSeconds = &gracePeriod
+
+	pod = b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{})
+	assert.Equal(t, int64(600), *pod.Spec.TerminationGracePeriodSeconds)
+	assert.Equal(t, int64(30), *runner.Spec.PodTemplateSpec.Spec.TerminationGracePeriodSeconds, "pod template should not be modified")
+
+	opts := new(client.DeleteOptions)
+	opts.ApplyOptions(podDeleteOptions(runner))
+	require.NotNil(t, opts.GracePeriodSeconds)
+	assert.Equal(t, int64(600), *opts.GracePeriodSeconds)
+}
+
+func TestCreatePodProxyInjectEnv(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Spec.PodTemplateSpec.Spec.Containers = append(runner.Spec.PodTemplateSpec.Spec.Containers, corev1.Container{
+		Name: "sidecar",
+		Env:  []corev1.EnvVar{{Name: "NO_PROXY", Value: "internal.example.com"}},
+	})
+	runner.Spec.ProxySecretRef = "proxy-secret"
+	runner.Spec.Proxy = &v1alpha1.ProxyConfig{
+		HTTPS:     &v1alpha1.ProxyServerConfig{Url: "http://proxy.example.com:3128", CredentialSecretRef: "proxy-credentials"},
+		NoProxy:   []string{"example.com"},
+		InjectEnv: true,
+	}
+
+	r := &EphemeralRunnerReconciler{
+		Client: newFakeClient(scheme),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+	_, err := r.createPod(ctx, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.Name}}, logr.Discard())
+	require.NoError(t, err)
+
+	pod := new(corev1.Pod)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), pod))
+
+	envs := func(container corev1.Container) map[string]corev1.EnvVar {
+		m := make(map[string]corev1.EnvVar)
+		for _, env := range container.Env {
+			m[env.Name] = env
+		}
+		return m
+	}
+
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		containerEnvs := envs(container)
+		for _, name := range []string{"https_proxy", "HTTPS_PROXY", "no_proxy"} {
+			require.Contains(t, containerEnvs, name, "container %s", container.Name)
+			env := containerEnvs[name]
+			assert.Empty(t, env.Value, "proxy credentials must not be written to the pod spec")
+			require.NotNil(t, env.ValueFrom)
+			assert.Equal(t, "proxy-secret", env.ValueFrom.SecretKeyRef.Name)
+			assert.Equal(t, strings.ToLower(name), env.ValueFrom.SecretKeyRef.Key)
+		}
+		assert.NotContains(t, containerEnvs, "http_proxy", "unset proxies should not be injected")
+	}
+
+	sidecarEnvs := envs(pod.Spec.Containers[1])
+	assert.Equal(t, "internal.example.com", sidecarEnvs["NO_PROXY"].Value, "variables defined by the container should win")
+}
+
+func TestCreatePodPreflightCheck(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Spec.PreflightCheck = true
+	runner.Spec.PodTemplateSpec.Spec.InitContainers = []corev1.Container{{Name: "init"}}
+	runner.Spec.ProxySecretRef = "proxy-secret"
+	runner.Spec.Proxy = &v1alpha1.ProxyConfig{
+		HTTPS: &v1alpha1.ProxyServerConfig{Url: "http://proxy.example.com:3128"},
+	}
+
+	r := &EphemeralRunnerReconciler{
+		Client:              newFakeClient(scheme),
+		Scheme:              scheme,
+		PreflightCheckImage: "curl:latest",
+	}
+	ctx := context.Background()
+	_, err := r.createPod(ctx, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.Name}}, logr.Discard())
+	require.NoError(t, err)
+
+	pod := new(corev1.Pod)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), pod))
+
+	require.Len(t, pod.Spec.InitContainers, 2)
+	preflight := pod.Spec.InitContainers[0]
+	assert.Equal(t, PreflightCheckContainerName, preflight.Name, "the preflight check should run before the other init containers")
+	assert.Equal(t, "curl:latest", preflight.Image)
+	assert.Equal(t, DefaultPreflightCheckCommand, preflight.Command)
+	assert.Equal(t, corev1.TerminationMessageFallbackToLogsOnError, preflight.TerminationMessagePolicy)
+
+	envs := make(map[string]corev1.EnvVar)
+	for _, env := range preflight.Env {
+		envs[env.Name] = env
+	}
+	assert.Equal(t, runner.Spec.GitHubConfigUrl, envs[EnvVarGitHubConfigUrl].Value)
+	for _, name := range []string{"https_proxy", "HTTPS_PROXY"} {
+		require.Contains(t, envs, name)
+		assert.Equal(t, "proxy-secret", envs[name].ValueFrom.SecretKeyRef.Name)
+	}
+}
+
+func TestCreatePodWindows(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Spec.OS = v1alpha1.EphemeralRunnerOSWindows
+	runner.Spec.PreflightCheck = true
+	runner.Spec.PodTemplateSpec.Spec.NodeSelector = map[string]string{"pool": "windows"}
+
+	r := &EphemeralRunnerReconciler{
+		Client:              newFakeClient(scheme),
+		Scheme:              scheme,
+		PreflightCheckImage: "curl:latest",
+	}
+	ctx := context.Background()
+	_, err := r.createPod(ctx, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.Name}}, logr.Discard())
+	require.NoError(t, err)
+
+	pod := new(corev1.Pod)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), pod))
+
+	assert.Equal(t, map[string]string{"pool": "windows", corev1.LabelOSStable: "windows"}, pod.Spec.NodeSelector)
+	assert.Equal(t, map[string]string{"pool": "windows"}, runner.Spec.PodTemplateSpec.Spec.NodeSelector, "the pod template should not be modified")
+
+	require.NotEmpty(t, pod.Spec.InitContainers)
+	preflight := pod.Spec.InitContainers[0]
+	assert.Equal(t, DefaultWindowsPreflightCheckImage, preflight.Image, "the Linux preflight check image should not be used")
+	assert.Equal(t, DefaultWindowsPreflightCheckCommand, preflight.Command)
+}
+
+func TestWithOSNodeSelector(t *testing.T) {
+	assert.Nil(t, withOSNodeSelector(nil, ""), "no node selector should be added without OS")
+	assert.Equal(t, map[string]string{corev1.LabelOSStable: "linux"}, withOSNodeSelector(nil, v1alpha1.EphemeralRunnerOSLinux))
+
+	nodeSelector := map[string]string{corev1.LabelOSStable: "linux"}
+	assert.Equal(t, nodeSelector, withOSNodeSelector(nodeSelector, v1alpha1.EphemeralRunnerOSWindows), "the template node selector should win")
+}
+
+func TestPreflightCheckFailure(t *testing.T) {
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Spec.PreflightCheck = true
+
+	newPod := func(status corev1.ContainerStatus) *corev1.Pod {
+		status.Name = PreflightCheckContainerName
+		return &corev1.Pod{Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{status}}}
+	}
+	failed := &corev1.ContainerStateTerminated{ExitCode: 6, Message: "curl: (6) Could not resolve host\n"}
+
+	t.Run("running", func(t *testing.T) {
+		_, ok := preflightCheckFailure(runner, newPod(corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}))
+		assert.False(t, ok)
+	})
+
+	t.Run("succeeded after a restart", func(t *testing.T) {
+		_, ok := preflightCheckFailure(runner, newPod(corev1.ContainerStatus{
+			State:                corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}},
+			LastTerminationState: corev1.ContainerState{Terminated: failed},
+		}))
+		assert.False(t, ok)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		message, ok := preflightCheckFailure(runner, newPod(corev1.ContainerStatus{State: corev1.ContainerState{Terminated: failed}}))
+		assert.True(t, ok)
+		assert.Equal(t, "Preflight check of "+runner.Spec.GitHubConfigUrl+" failed with exit code 6: curl: (6) Could not resolve host", message)
+	})
+
+	t.Run("waiting to be restarted after a failure", func(t *testing.T) {
+		_, ok := preflightCheckFailure(runner, newPod(corev1.ContainerStatus{
+			State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: corev1.ContainerState{Terminated: failed},
+		}))
+		assert.True(t, ok)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		runner := runner.DeepCopy()
+		runner.Spec.PreflightCheck = false
+		_, ok := preflightCheckFailure(runner, newPod(corev1.ContainerStatus{State: corev1.ContainerState{Terminated: failed}}))
+		assert.False(t, ok)
+	})
+}
+
+// getRunnerCounter counts the GetRunner calls of the wrapped client.
+type getRunnerCounter struct {
+	actions.ActionsService
+	calls int
+}
+
+func (c *getRunnerCounter) GetRunner(ctx context.Context, runnerId int64) (*actions.RunnerReference, error) {
+	c.calls++
+	return c.ActionsService.GetRunner(ctx, runnerId)
+}
+
+func TestCheckRemovedFromService(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	configSecret := newTestConfigSecret("secret")
+	now := time.Now()
+	pod := &corev1.Pod{Status: corev1.PodStatus{StartTime: &metav1.Time{Time: now.Add(-15 * time.Minute)}}}
+
+	newReconciler := func(getRunnerErr error) (*EphemeralRunnerReconciler, *getRunnerCounter) {
+		actionsClient := &getRunnerCounter{
+			ActionsService: fake.NewFakeClient(fake.WithGetRunner(&actions.RunnerReference{Id: 1}, getRunnerErr)),
+		}
+		return &EphemeralRunnerReconciler{
+			Client:                     newFakeClient(scheme, configSecret),
+			Scheme:                     scheme,
+			ActionsClient:              fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+			RemovedRunnerCheckInterval: 10 * time.Minute,
+		}, actionsClient
+	}
+	newRunner := func(runnerID int, jobRequestID int64) *v1alpha1.EphemeralRunner {
+		return newRegisteredExampleRunner(configSecret.Name, runnerID, jobRequestID)
+	}
+	ctx := context.Background()
+
+	t.Run("runner removed from the service", func(t *testing.T) {
+		r, actionsClient := newReconciler(runnerNotFoundError)
+		removed, _ := r.checkRemovedFromService(ctx, newRunner(1, 0), pod, now, logr.Discard())
+		assert.True(t, removed)
+		assert.Equal(t, 1, actionsClient.calls)
+	})
+
+	t.Run("runner checked at most once per interval", func(t *testing.T) {
+		r, actionsClient := newReconciler(nil)
+		removed, nextCheck := r.checkRemovedFromService(ctx, newRunner(1, 0), pod, now, logr.Discard())
+		assert.False(t, removed)
+		assert.Equal(t, 10*time.Minute, nextCheck)
+
+		removed, nextCheck = r.checkRemovedFromService(ctx, newRunner(1, 0), pod, now.Add(time.Minute), logr.Discard())
+		assert.False(t, removed)
+		assert.Equal(t, 9*time.Minute, nextCheck)
+		assert.Equal(t, 1, actionsClient.calls, "the service should not be called again before the interval elapses")
+
+		r.forgetRemovedRunnerCheck("runner-uid")
+		r.checkRemovedFromService(ctx, newRunner(1, 0), pod, now.Add(time.Minute), logr.Discard())
+		assert.Equal(t, 2, actionsClient.calls)
+	})
+
+	t.Run("failed check is retried after the interval", func(t *testing.T) {
+		r, actionsClient := newReconciler(errors.New("service unavailable"))
+		removed, nextCheck := r.checkRemovedFromService(ctx, newRunner(1, 0), pod, now, logr.Discard())
+		assert.False(t, removed, "a failed check should not delete the runner")
+		assert.Equal(t, 10*time.Minute, nextCheck)
+		assert.Equal(t, 1, actionsClient.calls)
+	})
+
+	t.Run("runners not checked", func(t *testing.T) {
+		r, actionsClient := newReconciler(nil)
+
+		removed, nextCheck := r.checkRemovedFromService(ctx, newRunner(1, 0), &corev1.Pod{Status: corev1.PodStatus{StartTime: &metav1.Time{Time: now.Add(-time.Minute)}}}, now, logr.Discard())
+		assert.False(t, removed)
+		assert.Equal(t, 9*time.Minute, nextCheck, "runner idle for less than the interval should be checked later")
+
+		removed, nextCheck = r.checkRemovedFromService(ctx, newRunner(1, 10), pod, now, logr.Discard())
+		assert.False(t, removed)
+		assert.Zero(t, nextCheck, "busy runner should not be checked")
+
+		removed, nextCheck = r.checkRemovedFromService(ctx, newRunner(0, 0), pod, now, logr.Discard())
+		assert.False(t, removed)
+		assert.Zero(t, nextCheck, "unregistered runner should not be checked")
+
+		r.RemovedRunnerCheckInterval = 0
+		removed, nextCheck = r.checkRemovedFromService(ctx, newRunner(1, 0), pod, now, logr.Discard())
+		assert.False(t, removed)
+		assert.Zero(t, nextCheck, "check should be disabled")
+		assert.Zero(t, actionsClient.calls)
+	})
+}
+
+func TestCheckHealth(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	healthy := true
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		assert.Equal(t, "/healthz", req.URL.Path)
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	host, portString, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portString)
+	require.NoError(t, err)
+
+	now := time.Now()
+	pod := &corev1.Pod{Status: corev1.PodStatus{PodIP: host, StartTime: &metav1.Time{Time: now.Add(-time.Minute)}}}
+
+	newRunner := func(jobRequestID int64) *v1alpha1.EphemeralRunner {
+		runner := newRegisteredExampleRunner("secret", 1, jobRequestID)
+		runner.Spec.HealthCheck = &v1alpha1.RunnerHealthCheck{
+			Port:             int32(port),
+			Path:             "healthz",
+			FailureThreshold: 2,
+		}
+		return runner
+	}
+	newReconciler := func(runner *v1alpha1.EphemeralRunner) *EphemeralRunnerReconciler {
+		return &EphemeralRunnerReconciler{
+			Client: newFakeClient(scheme, runner),
+			Scheme: scheme,
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("health check client ignores the proxy environment", func(t *testing.T) {
+		transport, ok := healthCheckClient.Transport.(*http.Transport)
+		require.True(t, ok)
+		assert.Nil(t, transport.Proxy)
+		assert.Equal(t, healthCheckTimeout, healthCheckClient.Timeout)
+	})
+
+	t.Run("unhealthy runner reaches the failure threshold", func(t *testing.T) {
+		healthy, requests = false, 0
+		runner := newRunner(0)
+		r := newReconciler(runner)
+
+		unhealthy, nextCheck, err := r.checkHealth(ctx, runner, pod, now, logr.Discard())
+		require.NoError(t, err)
+		assert.False(t, unhealthy)
+		assert.Equal(t, defaultHealthCheckInterval, nextCheck)
+		assert.Equal(t, 1, runner.Status.HealthCheckFailures)
+
+		unhealthy, nextCheck, err = r.checkHealth(ctx, runner, pod, now.Add(time.Second), logr.Discard())
+		require.NoError(t, err)
+		assert.False(t, unhealthy)
+		assert.Equal(t, defaultHealthCheckInterval-time.Second, nextCheck)
+		assert.Equal(t, 1, requests, "the endpoint should not be checked again before the interval elapses")
+
+		unhealthy, _, err = r.checkHealth(ctx, runner, pod, now.Add(defaultHealthCheckInterval), logr.Discard())
+		require.NoError(t, err)
+		assert.True(t, unhealthy)
+
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+		assert.Equal(t, 2, updated.Status.HealthCheckFailures)
+	})
+
+	t.Run("passed check resets the failures", func(t *testing.T) {
+		healthy, requests = true, 0
+		runner := newRunner(0)
+		runner.Status.HealthCheckFailures = 1
+		r := newReconciler(runner)
+
+		unhealthy, _, err := r.checkHealth(ctx, runner, pod, now, logr.Discard())
+		require.NoError(t, err)
+		assert.False(t, unhealthy)
+		assert.Zero(t, runner.Status.HealthCheckFailures)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("runners not checked", func(t *testing.T) {
+		healthy, requests = false, 0
+		runner := newRunner(10)
+		r := newReconciler(runner)
+
+		unhealthy, nextCheck, err := r.checkHealth(ctx, runner, pod, now, logr.Discard())
+		require.NoError(t, err)
+		assert.False(t, unhealthy)
+		assert.Zero(t, nextCheck, "busy runner should not be checked")
+
+		runner = newRunner(0)
+		unhealthy, nextCheck, err = r.checkHealth(ctx, runner, &corev1.Pod{Status: corev1.PodStatus{PodIP: host, StartTime: &metav1.Time{Time: now}}}, now, logr.Discard())
+		require.NoError(t, err)
+		assert.False(t, unhealthy)
+		assert.Equal(t, defaultHealthCheckInterval, nextCheck, "runner pod should run for an interval before it is checked")
+
+		runner.Spec.HealthCheck = nil
+		unhealthy, nextCheck, err = r.checkHealth(ctx, runner, pod, now, logr.Discard())
+		require.NoError(t, err)
+		assert.False(t, unhealthy)
+		assert.Zero(t, nextCheck, "check should be disabled")
+		assert.Zero(t, requests)
+	})
+}
+
+func TestRecordJobEvents(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newRegisteredExampleRunner("secret", 1, 10)
+	runner.Status.JobRepositoryName = "owner/repo"
+	runner.Status.JobWorkflowRef = "owner/repo/.github/workflows/ci.yaml@refs/heads/main"
+	runner.Status.WorkflowRunId = 100
+
+	recorder := record.NewFakeRecorder(10)
+	r := &EphemeralRunnerReconciler{
+		Client:    newFakeClient(scheme, runner),
+		Scheme:    scheme,
+		Recorder:  recorder,
+		JobEvents: true,
+	}
+
+	r.recordJobAssigned(runner)
+	r.recordJobAssigned(runner)
+	require.Len(t, recorder.Events, 1, "the assignment should be recorded once")
+	assert.Equal(t, "Normal JobAssigned Assigned job request 10 of workflow run 100 of owner/repo (owner/repo/.github/workflows/ci.yaml@refs/heads/main)", <-recorder.Events)
+
+	require.NoError(t, r.markAsFinished(context.Background(), runner, logr.Discard()))
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal JobCompleted Completed job request 10", <-recorder.Events)
+
+	r.JobEvents = false
+	runner.Status.JobRequestId = 11
+	r.recordJobAssigned(runner)
+	assert.Empty(t, recorder.Events, "events should be disabled")
+}
+
+func TestObserveBusy(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	assignedAt := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Second)
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Annotations = map[string]string{v1alpha1.AnnotationKeyJobAssignedAt: assignedAt.Format(time.RFC3339)}
+
+	r := &EphemeralRunnerReconciler{
+		Client: newFakeClient(scheme, runner),
+		Log:    logr.Discard(),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+
+	completedAt := assignedAt.Add(5 * time.Minute)
+	r.observeBusy(ctx, runner, completedAt, r.Log)
+
+	updated := new(v1alpha1.EphemeralRunner)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+	assert.Equal(t, completedAt.Format(time.RFC3339), updated.Annotations[AnnotationKeyJobCompletedAt])
+
+	r.observeBusy(ctx, updated, completedAt.Add(time.Minute), r.Log)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+	assert.Equal(t, completedAt.Format(time.RFC3339), updated.Annotations[AnnotationKeyJobCompletedAt], "a job should only be observed once")
+}
+
+func TestAnnotateNode(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	r := &EphemeralRunnerReconciler{
+		Client:         newFakeClient(scheme, runner),
+		Log:            logr.Discard(),
+		Scheme:         scheme,
+		NodeAnnotation: true,
+	}
+	ctx := context.Background()
+
+	pod := &corev1.Pod{}
+	require.NoError(t, r.annotateNode(ctx, runner, pod, r.Log))
+	assert.NotContains(t, runner.Annotations, AnnotationKeyNode, "pods not scheduled yet should be skipped")
+
+	pod.Spec.NodeName = "node-a"
+	require.NoError(t, r.annotateNode(ctx, runner, pod, r.Log))
+
+	updated := new(v1alpha1.EphemeralRunner)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+	assert.Equal(t, "node-a", updated.Annotations[AnnotationKeyNode])
+
+	pod.Spec.NodeName = "node-b"
+	require.NoError(t, r.annotateNode(ctx, updated, pod, r.Log))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+	assert.Equal(t, "node-b", updated.Annotations[AnnotationKeyNode], "the annotation should follow the re-created pod")
+}
+
+func TestReconcileSkipDeregistration(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Finalizers = []string{ephemeralRunnerFinalizerName, ephemeralRunnerActionsFinalizerName}
+	runner.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	runner.Status.RunnerId = 1
+
+	r := &EphemeralRunnerReconciler{
+		Client:             newFakeClient(scheme, runner),
+		Log:                logr.Discard(),
+		Scheme:             scheme,
+		Recorder:           record.NewFakeRecorder(10),
+		ActionsClient:      fake.NewMultiClient(fake.WithDefaultClient(fake.NewFakeClient(fake.WithRemoveRunner(errors.New("unreachable"))), nil)),
+		SkipDeregistration: true,
+	}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)})
+	require.NoError(t, err, "the runner should not be removed from the service")
+
+	updated := new(v1alpha1.EphemeralRunner)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+	assert.NotContains(t, updated.Finalizers, ephemeralRunnerActionsFinalizerName)
+	assert.Contains(t, updated.Finalizers, ephemeralRunnerFinalizerName, "the resources of the runner should still be cleaned up")
+}
+
+func TestReconcileReclaimsStaleJob(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	configSecret := newTestConfigSecret("secret")
+
+	newReconciler := func(getRunnerErr error) (*EphemeralRunnerReconciler, *v1alpha1.EphemeralRunner, *record.FakeRecorder) {
+		runner := newRegisteredExampleRunner(configSecret.Name, 1, 10)
+		runner.Finalizers = []string{ephemeralRunnerFinalizerName, ephemeralRunnerActionsFinalizerName}
+		runner.Status.JobRepositoryName = "owner/repo"
+		runner.Status.WorkflowRunId = 100
+		jitSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.Name, Namespace: runner.Namespace}}
+
+		recorder := record.NewFakeRecorder(10)
+		return &EphemeralRunnerReconciler{
+			Client:        newFakeClient(scheme, configSecret, jitSecret, runner),
+			Log:           logr.Discard(),
+			Scheme:        scheme,
+			Recorder:      recorder,
+			ActionsClient: newGetRunnerMultiClient(&actions.RunnerReference{Id: 1}, getRunnerErr),
+		}, runner, recorder
+	}
+	ctx := context.Background()
+
+	t.Run("runner removed from the service", func(t *testing.T) {
+		r, runner, recorder := newReconciler(runnerNotFoundError)
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)})
+		require.NoError(t, err)
+
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+		assert.Equal(t, corev1.PodSucceeded, updated.Status.Phase)
+		assert.Zero(t, updated.Status.JobRequestId)
+		assert.Empty(t, updated.Status.JobRepositoryName)
+		assert.Zero(t, updated.Status.WorkflowRunId)
+
+		pods := new(corev1.PodList)
+		require.NoError(t, r.List(ctx, pods))
+		assert.Empty(t, pods.Items, "no pod should be created for the stale job")
+		assert.Len(t, recorder.Events, 1)
+	})
+
+	t.Run("runner still exists in the service", func(t *testing.T) {
+		r, runner, _ := newReconciler(nil)
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)})
+		require.NoError(t, err)
+
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+		assert.Equal(t, int64(10), updated.Status.JobRequestId, "the job may still run on the runner")
+
+		pods := new(corev1.PodList)
+		require.NoError(t, r.List(ctx, pods))
+		assert.Len(t, pods.Items, 1, "the pod should be re-created")
+	})
+
+	t.Run("failed check is retried", func(t *testing.T) {
+		r, runner, _ := newReconciler(errors.New("service unavailable"))
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)})
+		assert.Error(t, err)
+
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+		assert.Equal(t, int64(10), updated.Status.JobRequestId)
+	})
+}
+
+func TestUpdateRunStatusFromPodObservesSchedule(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	controller := true
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: v1alpha1.GroupVersion.String(),
+		Kind:       "EphemeralRunnerSet",
+		Name:       "schedule-metrics-set",
+		UID:        "ers-uid",
+		Controller: &controller,
+	}}
+	runner.Status.Phase = corev1.PodPending
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"karpenter.sh/nodepool": "burst"}}}
+
+	r := &EphemeralRunnerReconciler{
+		Client:                   newFakeClient(scheme, runner, node),
+		Scheme:                   scheme,
+		ScheduleMetricsNodeLabel: "karpenter.sh/nodepool",
+	}
+
+	created := time.Now().Add(-time.Minute)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: runner.Name, Namespace: runner.Namespace, CreationTimestamp: metav1.NewTime(created)},
+		Spec:       corev1.PodSpec{NodeName: node.Name},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(created.Add(20 * time.Second))},
+			},
+		},
+	}
+
+	scheduleSampleCount := func() uint64 {
+		families, err := ctrlmetrics.Registry.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != "arc_runner_schedule_seconds" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				if labels["ephemeral_runner_set"] == "schedule-metrics-set" && labels["node_pool"] == "burst" {
+					assert.Equal(t, 20.0, metric.GetHistogram().GetSampleSum())
+					return metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+		return 0
+	}
+
+	ctx := context.Background()
+	require.NoError(t, r.updateRunStatusFromPod(ctx, runner, pod, logr.Discard()))
+	assert.Equal(t, uint64(1), scheduleSampleCount())
+
+	runner.Status.Failures = map[string]bool{"pod-uid": true}
+	require.NoError(t, r.updateRunStatusFromPod(ctx, runner, pod, logr.Discard()))
+	assert.Equal(t, uint64(1), scheduleSampleCount(), "the scheduling latency should only be recorded once")
+}
+
+func TestPodUnschedulableSince(t *testing.T) {
+	since := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	unschedulable := corev1.PodCondition{
+		Type:               corev1.PodScheduled,
+		Status:             corev1.ConditionFalse,
+		Reason:             corev1.PodReasonUnschedulable,
+		Message:            "0/3 nodes are available: 3 Insufficient cpu.",
+		LastTransitionTime: since,
+	}
+
+	tests := []struct {
+		name      string
+		phase     corev1.PodPhase
+		condition corev1.PodCondition
+		want      bool
+	}{
+		{
+			name:      "pending and unschedulable",
+			phase:     corev1.PodPending,
+			condition: unschedulable,
+			want:      true,
+		},
+		{
+			name:      "pending and scheduled",
+			phase:     corev1.PodPending,
+			condition: corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		},
+		{
+			name:      "pending with another reason",
+			phase:     corev1.PodPending,
+			condition: corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "SchedulingGated"},
+		},
+		{
+			name:      "running",
+			phase:     corev1.PodRunning,
+			condition: unschedulable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{Status: corev1.PodStatus{Phase: tt.phase, Conditions: []corev1.PodCondition{tt.condition}}}
+			got, ok := podUnschedulableSince(pod)
+			assert.Equal(t, tt.want, ok)
+			if tt.want {
+				assert.True(t, since.Time.Equal(got))
+				assert.Equal(t, unschedulable.Message, podScheduledMessage(pod))
+			}
+		})
+	}
+}
+
+func TestMarkAsUnschedulable(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	recorder := record.NewFakeRecorder(10)
+	r := &EphemeralRunnerReconciler{
+		Client:                 newFakeClient(scheme, runner),
+		Scheme:                 scheme,
+		Recorder:               recorder,
+		UnschedulableThreshold: 10 * time.Minute,
+	}
+
+	pod := &corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  corev1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 3 Insufficient cpu.",
+			}},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, r.markAsUnschedulable(ctx, runner, pod, logr.Discard()))
+
+	updated := new(v1alpha1.EphemeralRunner)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+	assert.True(t, updated.Status.Unschedulable)
+	assert.Equal(t, corev1.PodReasonUnschedulable, updated.Status.Reason)
+	assert.Equal(t, "0/3 nodes are available: 3 Insufficient cpu.", updated.Status.Message)
+
+	select {
+	case event := <-recorder.Events:
+		assert.Equal(t, "Warning Unschedulable Runner pod has been unschedulable for longer than 10m0s: 0/3 nodes are available: 3 Insufficient cpu.", event)
+	default:
+		t.Fatal("expected an event")
+	}
+}
+
+func TestCreatePodSidecarDependency(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Spec.SidecarDependency = &v1alpha1.SidecarDependency{
+		Port:    8080,
+		Path:    "/healthz",
+		Timeout: &metav1.Duration{Duration: 30 * time.Second},
+	}
+
+	r := &EphemeralRunnerReconciler{
+		Client:   newFakeClient(scheme),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	_, err := r.createPod(ctx, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.Name}}, logr.Discard())
+	require.NoError(t, err)
+
+	pod := new(corev1.Pod)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), pod))
+
+	container := pod.Spec.Containers[0]
+	require.Equal(t, EphemeralRunnerContainerName, container.Name)
+	require.Len(t, container.Command, 5)
+	assert.Equal(t, []string{"/bin/bash", "-c"}, container.Command[:2])
+	assert.Contains(t, container.Command[2], "deadline=$((SECONDS + 30))")
+	assert.Contains(t, container.Command[2], "'http://127.0.0.1:8080/healthz'")
+	assert.Equal(t, []string{"wait-for-sidecar", "/runner/run.sh"}, container.Command[3:])
+
+	require.NotNil(t, container.StartupProbe)
+	assert.Equal(t, []string{"test", "-f", sidecarDependencyReadyFile}, container.StartupProbe.Exec.Command)
+	assert.Equal(t, int32(40), container.StartupProbe.FailureThreshold)
+
+	if _, err := exec.LookPath("bash"); err == nil {
+		out, err := exec.Command("bash", "-n", "-c", container.Command[2]).CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+
+	runner.Spec.OS = v1alpha1.EphemeralRunnerOSWindows
+	assert.False(t, waitsForSidecarDependency(runner))
+}
+
+func TestWithSidecarDependency(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "runner", Command: []string{"/home/runner/run.sh"}}}}}
+	require.NoError(t, withSidecarDependency(pod, "runner", &v1alpha1.SidecarDependency{Port: 2375}))
+	assert.Contains(t, pod.Spec.Containers[0].Command[2], "</dev/tcp/127.0.0.1/2375")
+	assert.Contains(t, pod.Spec.Containers[0].Command[2], "deadline=$((SECONDS + 120))")
+
+	pod = &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "runner"}}}}
+	assert.Error(t, withSidecarDependency(pod, "runner", &v1alpha1.SidecarDependency{Port: 2375}))
+	assert.Error(t, withSidecarDependency(pod, "missing", &v1alpha1.SidecarDependency{Port: 2375}))
+}
+
+func TestWithDinDSharedWorkVolume(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "runner"}, {Name: DinDContainerName}}}}
+	require.NoError(t, withDinDSharedWorkVolume(pod, "runner"))
+	workMount := corev1.VolumeMount{Name: "work", MountPath: DefaultRunnerWorkDir}
+	assert.Equal(t, []corev1.VolumeMount{workMount}, pod.Spec.Containers[0].VolumeMounts)
+	assert.Equal(t, []corev1.VolumeMount{workMount}, pod.Spec.Containers[1].VolumeMounts)
+	require.Len(t, pod.Spec.Volumes, 1)
+	assert.Equal(t, "work", pod.Spec.Volumes[0].Name)
+	assert.NotNil(t, pod.Spec.Volumes[0].EmptyDir)
+
+	require.NoError(t, withDinDSharedWorkVolume(pod, "runner"), "applying it again doesn't change the pod")
+	assert.Len(t, pod.Spec.Containers[0].VolumeMounts, 1)
+	assert.Len(t, pod.Spec.Containers[1].VolumeMounts, 1)
+	assert.Len(t, pod.Spec.Volumes, 1)
+
+	customMount := corev1.VolumeMount{Name: "cache", MountPath: DefaultRunnerWorkDir}
+	pod = &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "runner", VolumeMounts: []corev1.VolumeMount{customMount}}, {Name: DinDContainerName}},
+		Volumes:    []corev1.Volume{{Name: "cache", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "cache"}}}},
+	}}
+	require.NoError(t, withDinDSharedWorkVolume(pod, "runner"))
+	assert.Equal(t, []corev1.VolumeMount{customMount}, pod.Spec.Containers[0].VolumeMounts)
+	assert.Equal(t, []corev1.VolumeMount{customMount}, pod.Spec.Containers[1].VolumeMounts)
+	require.Len(t, pod.Spec.Volumes, 1, "the configured volume is not duplicated")
+	assert.NotNil(t, pod.Spec.Volumes[0].PersistentVolumeClaim)
+
+	pod = &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "runner"},
+		{Name: DinDContainerName, VolumeMounts: []corev1.VolumeMount{{Name: "other", MountPath: DefaultRunnerWorkDir}}},
+	}}}
+	assert.Error(t, withDinDSharedWorkVolume(pod, "runner"))
+
+	pod = &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "runner"}}}}
+	assert.Error(t, withDinDSharedWorkVolume(pod, "runner"))
+}
+
+func TestCreatePodDinDSharedWorkVolumeWithoutDinDContainer(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Spec.DinDSharedWorkVolume = true
+	runner.Status.RunnerId = 1
+
+	r := &EphemeralRunnerReconciler{
+		Client:        newFakeClient(scheme, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"}}),
+		Scheme:        scheme,
+		Recorder:      record.NewFakeRecorder(10),
+		ActionsClient: fake.NewMultiClient(),
+	}
+	ctx := context.Background()
+	result, err := r.createPod(ctx, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.Name}}, logr.Discard())
+	require.NoError(t, err, "the pod creation should not be retried")
+	assert.Equal(t, ctrl.Result{}, result)
+
+	pods := new(corev1.PodList)
+	require.NoError(t, r.List(ctx, pods))
+	assert.Empty(t, pods.Items)
+
+	updated := new(v1alpha1.EphemeralRunner)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+	assert.Equal(t, corev1.PodFailed, updated.Status.Phase)
+	assert.Equal(t, "InvalidDinDSharedWorkVolume", updated.Status.Reason)
+	assert.Contains(t, updated.Status.Message, DinDContainerName)
+}
+
+func TestProxyEnvVarsInheritFromController(t *testing.T) {
+	runner := newExampleRunner("test-runner", "default", "secret")
+	runner.Spec.ProxySecretRef = "proxy-secret"
+	runner.Spec.Proxy = &v1alpha1.ProxyConfig{InheritFromController: true}
+
+	var names []string
+	for _, env := range proxyEnvVars(runner, true) {
+		assert.Equal(t, "proxy-secret", env.ValueFrom.SecretKeyRef.Name)
+		names = append(names, env.Name)
+	}
+	assert.Equal(t, []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}, names)
+
+	runner.Spec.Proxy.HTTPS = &v1alpha1.ProxyServerConfig{Url: "http://proxy.example.com:3128"}
+	envs := proxyEnvVars(runner, false)
+	require.Len(t, envs, 1)
+	assert.Equal(t, EnvVarHTTPSProxy, envs[0].Name)
+}
+
+func TestReconcileIgnoresInjectedSidecars(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	configSecret := newTestConfigSecret("secret")
+	ctx := context.Background()
+
+	// The sidecar injected by the service mesh is still running after the runner exited, so the pod keeps running,
+	// and it fails when it is eventually stopped, so the pod fails.
+	for _, sidecar := range []corev1.ContainerStatus{
+		{Name: "istio-proxy", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{Name: "istio-proxy", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137}}},
+	} {
+		runner := newExampleRunner("test-runner", "default", configSecret.Name)
+		runner.Finalizers = []string{ephemeralRunnerFinalizerName, ephemeralRunnerActionsFinalizerName}
+		runner.Status.RunnerId = 1
+		runner.Status.RunnerJITConfig = "jit-config"
+		runner.Status.JobRequestId = 10
+		jitSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.Name, Namespace: runner.Namespace}}
+		phase := corev1.PodRunning
+		if sidecar.State.Terminated != nil {
+			phase = corev1.PodFailed
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: runner.Name, Namespace: runner.Namespace},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: EphemeralRunnerContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+					sidecar,
+				},
+			},
+		}
+
+		r := &EphemeralRunnerReconciler{
+			Client:        newFakeClient(scheme, configSecret, jitSecret, pod, runner),
+			Log:           logr.Discard(),
+			Scheme:        scheme,
+			Recorder:      record.NewFakeRecorder(10),
+			ActionsClient: newGetRunnerMultiClient(nil, runnerNotFoundError),
+		}
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)})
+		require.NoError(t, err)
+
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+		assert.Equal(t, corev1.PodSucceeded, updated.Status.Phase, "pod phase %s", phase)
+	}
+
+	t.Run("sidecar started before the runner", func(t *testing.T) {
+		runner := newExampleRunner("test-runner", "default", configSecret.Name)
+		runner.Status.Phase = corev1.PodPending
+		r := &EphemeralRunnerReconciler{
+			Client: newFakeClient(scheme, runner),
+			Scheme: scheme,
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: runner.Name, Namespace: runner.Namespace},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: EphemeralRunnerContainerName, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+					{Name: "istio-proxy", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				},
+			},
+		}
+		require.NoError(t, r.updateRunStatusFromPod(ctx, runner, pod, logr.Discard()))
+
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(runner), updated))
+		assert.Equal(t, corev1.PodPending, updated.Status.Phase)
+		assert.False(t, updated.Status.Ready)
+	})
+}
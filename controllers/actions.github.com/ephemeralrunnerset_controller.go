@@ -20,28 +20,76 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"path"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/controllers/actions.github.com/metrics"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
 	"go.uber.org/multierr"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	ephemeralRunnerSetReconcilerOwnerKey = ".metadata.controller"
 	ephemeralRunnerSetFinalizerName      = "ephemeralrunner.actions.github.com/finalizer"
+
+	// ephemeralRunnerSetProxySecretsKey indexes EphemeralRunnerSets by the secrets referenced by their proxy configuration.
+	ephemeralRunnerSetProxySecretsKey = ".spec.ephemeralRunnerSpec.proxy.secretRefs"
+
+	// defaultRunnerRegistrationThreshold is used when the EphemeralRunnerSet does not set RunnerRegistrationThreshold.
+	defaultRunnerRegistrationThreshold = 5 * time.Minute
+
+	// invalidProxyConfigRequeueInterval is how often an EphemeralRunnerSet with an invalid proxy configuration is checked again.
+	invalidProxyConfigRequeueInterval = time.Minute
+
+	// lastReconcileTimeRefreshInterval is the age after which the last reconcile time in the status is refreshed
+	// even though the rest of the status is unchanged.
+	lastReconcileTimeRefreshInterval = 5 * time.Minute
+
+	// throttledScalingRequeueInterval is how soon an EphemeralRunnerSet is reconciled again when
+	// MaxConcurrentCreations or MaxConcurrentDeletions deferred part of the scaling to a later reconcile.
+	throttledScalingRequeueInterval = time.Second
+
+	// namespaceRunnerLimitRequeueInterval is how soon an EphemeralRunnerSet is reconciled again when
+	// MaxRunnersPerNamespace deferred part of its scale up, since other runner sets free their runners without triggering it.
+	namespaceRunnerLimitRequeueInterval = 30 * time.Second
+
+	// DefaultRunnerWorkDir is used when the reconciler does not set RunnerWorkDir.
+	DefaultRunnerWorkDir = "/actions-runner/_work"
+	// DefaultWindowsRunnerWorkDir is used for Windows runners when the reconciler does not set WindowsRunnerWorkDir.
+	DefaultWindowsRunnerWorkDir = `C:\actions-runner\_work`
+	// defaultWorkVolumeName is the name of the emptyDir volume added by DefaultWorkVolume.
+	defaultWorkVolumeName = "work"
 )
 
 // EphemeralRunnerSetReconciler reconciles a EphemeralRunnerSet object
@@ -50,14 +98,112 @@ type EphemeralRunnerSetReconciler struct {
 	Log           logr.Logger
 	Scheme        *runtime.Scheme
 	ActionsClient actions.MultiClient
+	Recorder      record.EventRecorder
+
+	// RequeueInterval is the base interval after which an EphemeralRunnerSet is reconciled again.
+	// Zero disables periodic requeues.
+	RequeueInterval time.Duration
+	// RequeueJitter is the maximum fraction of the requeue delay randomly added to it,
+	// so the reconciles of many runner sets do not hit the APIs at the same time.
+	RequeueJitter float64
+
+	// DryRun makes the reconciler log the ephemeral runners it would create and delete, without creating or deleting them.
+	// It is a debugging tool and must not be enabled in production.
+	DryRun bool
+
+	// FinalizerTimeout is how long after its deletion was requested an EphemeralRunnerSet waits for its ephemeral runners
+	// to be removed from the service. Once exceeded, the ephemeral runners are deleted without removing them
+	// from the service and the finalizer is removed. Zero waits forever.
+	FinalizerTimeout time.Duration
+
+	// SkipDeregistration deletes the ephemeral runners of deleted EphemeralRunnerSets without removing them
+	// from the service, e.g. in throwaway clusters torn down wholesale. The runners remain registered with GitHub.
+	SkipDeregistration bool
+
+	// DeregistrationLimiter paces the removal of idle runners from the service on scale-down.
+	// Once no token is left, the remaining idle runners are kept and the reconcile is requeued
+	// for when the next token is available. Nil disables the limit.
+	DeregistrationLimiter *DeregistrationLimiter
+
+	// DefaultRunnerResources are the resource requests and limits applied to the runner container of new ephemeral runners
+	// when their pod template leaves them unset.
+	DefaultRunnerResources corev1.ResourceRequirements
+
+	// DefaultRunnerSecurityContext holds the security context settings applied to the runner container of new ephemeral runners
+	// when neither the container nor the pod security context of the pod template set them.
+	DefaultRunnerSecurityContext *corev1.SecurityContext
+
+	// ForbidPrivilegedRunners removes privileged mode from the containers of new ephemeral runners whose pod template
+	// requests it, with a warning event on the EphemeralRunnerSet.
+	ForbidPrivilegedRunners bool
+
+	// RunnerImagePullPolicy is the image pull policy set on the runner container of new ephemeral runners.
+	// The pod template value is kept when empty.
+	RunnerImagePullPolicy corev1.PullPolicy
+
+	// RunnerImagePullPolicyKeepTemplate only sets RunnerImagePullPolicy when the pod template leaves the image pull policy unset.
+	RunnerImagePullPolicyKeepTemplate bool
+
+	// MaxConcurrentReconciles is the number of EphemeralRunnerSets reconciled in parallel. Defaults to 1.
+	// An EphemeralRunnerSet is never reconciled by two workers at once, and the state the reconciler writes,
+	// such as the proxy secret and the ephemeral runners, belongs to a single EphemeralRunnerSet, so no locking is needed.
+	MaxConcurrentReconciles int
+
+	// DefaultWorkVolume adds an emptyDir volume mounted at RunnerWorkDir to the runner container of new ephemeral runners
+	// that have nothing mounted there, so the jobs don't fill the ephemeral storage of the node.
+	DefaultWorkVolume bool
+	// DefaultWorkVolumeSizeLimit is the size limit of the emptyDir volume added by DefaultWorkVolume. Unlimited when nil.
+	DefaultWorkVolumeSizeLimit *resource.Quantity
+	// RunnerWorkDir is the work directory of the runner. Defaults to DefaultRunnerWorkDir.
+	RunnerWorkDir string
+	// WindowsRunnerWorkDir is the work directory of Windows runners. Defaults to DefaultWindowsRunnerWorkDir.
+	WindowsRunnerWorkDir string
+
+	// OrphanedProxySecretSweepInterval is how often the proxy secrets whose EphemeralRunnerSet no longer exists are deleted.
+	// Zero disables the sweep.
+	OrphanedProxySecretSweepInterval time.Duration
+
+	// Selector restricts the reconciler to the EphemeralRunnerSets whose labels match it, so the runner sets
+	// can be sharded across several controller deployments. EphemeralRunnerSets that don't match are ignored.
+	// All EphemeralRunnerSets are reconciled when nil.
+	Selector labels.Selector
+
+	// MaxRunnersPerNamespace caps the number of EphemeralRunners of all runner sets in a namespace.
+	// Scale ups are limited to the runners left in the namespace, and the NamespaceRunnerLimitReached condition
+	// explains the missing runners. Existing runners are never deleted to enforce it. Zero disables the limit.
+	// Failed runners retained for inspection don't count towards it. It is enforced within this process only,
+	// so controller deployments sharded with Selector can exceed it together.
+	MaxRunnersPerNamespace int
+
+	// StartupScaleDownDelay defers scaling down for this long after the reconciler starts, e.g. after the controller
+	// acquires the leader election, so the runner counts don't go down and back up while the new leader catches up
+	// with the ephemeral runners left by the previous one. Scaling up is not deferred. Zero disables it.
+	StartupScaleDownDelay time.Duration
+
+	// APIReader reads the EphemeralRunners of the namespace from the API server when MaxRunnersPerNamespace is set,
+	// since the cache may not have the runners just created by the reconcile of another runner set yet.
+	// Defaults to the API reader of the manager.
+	APIReader client.Reader
 
 	resourceBuilder resourceBuilder
+
+	// startOnce records startTime on the first reconcile, which only happens once the controller is the leader.
+	startOnce sync.Once
+	startTime time.Time
+
+	// namespaceRunnerLimitLocks serializes the scale ups limited by MaxRunnersPerNamespace per namespace,
+	// so concurrent reconciles of runner sets of the same namespace don't exceed it together.
+	namespaceRunnerLimitLocksMu sync.Mutex
+	namespaceRunnerLimitLocks   map[string]*sync.Mutex
 }
 
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners/status,verbs=get
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -72,12 +218,19 @@ type EphemeralRunnerSetReconciler struct {
 // be to bring the count of EphemeralRunners to the desired one, not to patch this resource
 // until it is safe to do so
 func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("ephemeralrunnerset", req.NamespacedName)
+	log := r.Log.WithValues("namespace", req.Namespace, "name", req.Name)
+
+	r.startOnce.Do(func() { r.startTime = time.Now() })
 
 	ephemeralRunnerSet := new(v1alpha1.EphemeralRunnerSet)
 	if err := r.Get(ctx, req.NamespacedName, ephemeralRunnerSet); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// Events of the owned ephemeral runners are not filtered by the selector.
+	if !r.selects(ephemeralRunnerSet) {
+		return ctrl.Result{}, nil
+	}
+	log = log.WithValues("runnerScaleSetId", ephemeralRunnerSet.Spec.EphemeralRunnerSpec.RunnerScaleSetId)
 
 	// Requested deletion does not need reconciled.
 	if !ephemeralRunnerSet.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -85,15 +238,34 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 			return ctrl.Result{}, nil
 		}
 
+		metrics.DeleteEphemeralRunners(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name)
+		metrics.DeleteProxySecretErrors(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name)
+
 		log.Info("Deleting resources")
-		done, err := r.cleanUpEphemeralRunners(ctx, ephemeralRunnerSet, log)
-		if err != nil {
-			log.Error(err, "Failed to clean up EphemeralRunners")
-			return ctrl.Result{}, err
-		}
-		if !done {
-			log.Info("Waiting for resources to be deleted")
-			return ctrl.Result{}, nil
+		remaining, hasTimeout := r.finalizerTimeoutRemaining(ephemeralRunnerSet, time.Now())
+		if r.SkipDeregistration || (hasTimeout && remaining <= 0) {
+			if r.SkipDeregistration {
+				log.Info("WARNING: Deregistration is skipped, deleting ephemeral runners without removing them from the service. They remain registered with GitHub")
+			} else {
+				log.Info("WARNING: Finalizer timeout exceeded, deleting ephemeral runners without removing them from the service. They may remain registered with GitHub", "finalizerTimeout", r.FinalizerTimeout)
+			}
+			if err := r.forceCleanUpEphemeralRunners(ctx, ephemeralRunnerSet, log); err != nil {
+				log.Error(err, "Failed to force the clean up of EphemeralRunners")
+				return ctrl.Result{}, err
+			}
+		} else {
+			done, err := r.cleanUpEphemeralRunners(ctx, ephemeralRunnerSet, log)
+			if err != nil {
+				log.Error(err, "Failed to clean up EphemeralRunners")
+				return ctrl.Result{}, err
+			}
+			if !done {
+				log.Info("Waiting for resources to be deleted")
+				if hasTimeout {
+					return ctrl.Result{RequeueAfter: remaining}, nil
+				}
+				return ctrl.Result{}, nil
+			}
 		}
 
 		log.Info("Removing finalizer")
@@ -122,8 +294,58 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, nil
 	}
 
+	// The conditions are written along with the rest of the status at the end of the reconcile, so the status
+	// is written at most once per reconcile. They are only written on their own when the reconcile stops early.
+	var conditions []metav1.Condition
+
+	nodeSelectorCondition := nodeSelectorCondition(ephemeralRunnerSet.Generation, validateNodeSelector(ephemeralRunnerSet.Spec.NodeSelector))
+	conditions = append(conditions, nodeSelectorCondition)
+	if nodeSelectorCondition.Status == metav1.ConditionTrue {
+		if err := r.updateConditions(ctx, ephemeralRunnerSet, conditions); err != nil {
+			log.Error(err, "Failed to update status with node selector condition")
+			return ctrl.Result{}, err
+		}
+		log.Info("Node selector is invalid, not creating ephemeral runners", "reason", nodeSelectorCondition.Message)
+		return ctrl.Result{}, nil
+	}
+
+	tolerationsCondition := tolerationsCondition(ephemeralRunnerSet.Generation, validateTolerations(ephemeralRunnerSet.Spec.Tolerations))
+	conditions = append(conditions, tolerationsCondition)
+	if tolerationsCondition.Status == metav1.ConditionTrue {
+		if err := r.updateConditions(ctx, ephemeralRunnerSet, conditions); err != nil {
+			log.Error(err, "Failed to update status with tolerations condition")
+			return ctrl.Result{}, err
+		}
+		log.Info("Tolerations are invalid, not creating ephemeral runners", "reason", tolerationsCondition.Message)
+		return ctrl.Result{}, nil
+	}
+
+	dnsConfigCondition := dnsConfigCondition(ephemeralRunnerSet.Generation, validateDNSConfig(ephemeralRunnerSet.Spec.DNSPolicy, ephemeralRunnerSet.Spec.DNSConfig))
+	conditions = append(conditions, dnsConfigCondition)
+	if dnsConfigCondition.Status == metav1.ConditionTrue {
+		if err := r.updateConditions(ctx, ephemeralRunnerSet, conditions); err != nil {
+			log.Error(err, "Failed to update status with DNS config condition")
+			return ctrl.Result{}, err
+		}
+		log.Info("DNS config is invalid, not creating ephemeral runners", "reason", dnsConfigCondition.Message)
+		return ctrl.Result{}, nil
+	}
+
 	// Create proxy secret if not present
 	if ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Proxy != nil {
+		proxyCondition := proxyConfigCondition(ephemeralRunnerSet.Generation, ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Proxy.Validate(r.secretFetcher(ctx, ephemeralRunnerSet.Namespace)))
+		conditions = append(conditions, proxyCondition)
+		if proxyCondition.Status == metav1.ConditionTrue {
+			if err := r.updateConditions(ctx, ephemeralRunnerSet, conditions); err != nil {
+				log.Error(err, "Failed to update status with proxy config condition")
+				return ctrl.Result{}, err
+			}
+			metrics.IncProxySecretErrors(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, metrics.ProxySecretErrorInvalidConfig)
+			// The referenced secrets are watched, checking again later only guards against missed events.
+			log.Info("Proxy configuration is invalid, not creating ephemeral runners", "reason", proxyCondition.Message)
+			return ctrl.Result{RequeueAfter: invalidProxyConfigRequeueInterval}, nil
+		}
+
 		proxySecret := new(corev1.Secret)
 		if err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunnerSet.Namespace, Name: proxyEphemeralRunnerSetSecretName(ephemeralRunnerSet)}, proxySecret); err != nil {
 			if !kerrors.IsNotFound(err) {
@@ -137,9 +359,17 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 				log.Error(err, "Unable to create ephemeralRunnerSet proxy secret", "namespace", ephemeralRunnerSet.Namespace, "set-name", ephemeralRunnerSet.Name)
 				return ctrl.Result{}, err
 			}
+		} else if err := r.updateProxySecret(ctx, ephemeralRunnerSet, proxySecret, log); err != nil {
+			log.Error(err, "Unable to update ephemeralRunnerSet proxy secret", "namespace", ephemeralRunnerSet.Namespace, "set-name", ephemeralRunnerSet.Name)
+			return ctrl.Result{}, err
 		}
 	}
 
+	if err := r.reconcileBusyRunnersPodDisruptionBudget(ctx, ephemeralRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile the busy runners pod disruption budget")
+		return ctrl.Result{}, err
+	}
+
 	// Find all EphemeralRunner with matching namespace and own by this EphemeralRunnerSet.
 	ephemeralRunnerList := new(v1alpha1.EphemeralRunnerList)
 	err := r.List(
@@ -154,18 +384,69 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 	}
 
 	pendingEphemeralRunners, runningEphemeralRunners, finishedEphemeralRunners, failedEphemeralRunners, deletingEphemeralRunners := categorizeEphemeralRunners(ephemeralRunnerList)
+	failedEphemeralRunners, retainedEphemeralRunners := splitRetainedEphemeralRunners(failedEphemeralRunners)
+
+	if duplicates := duplicateEphemeralRunners(pendingEphemeralRunners, runningEphemeralRunners); len(duplicates) > 0 {
+		if err := r.deleteDuplicateEphemeralRunners(ctx, ephemeralRunnerSet, duplicates, log); err != nil {
+			log.Error(err, "Failed to delete ephemeral runners with duplicate runner IDs")
+			return ctrl.Result{}, err
+		}
+		if !r.DryRun {
+			// The deleted runners are replaced in this reconcile.
+			pendingEphemeralRunners = withoutEphemeralRunners(pendingEphemeralRunners, duplicates)
+			runningEphemeralRunners = withoutEphemeralRunners(runningEphemeralRunners, duplicates)
+		}
+	}
 
 	log.Info("Ephemeral runner counts",
 		"pending", len(pendingEphemeralRunners),
 		"running", len(runningEphemeralRunners),
 		"finished", len(finishedEphemeralRunners),
 		"failed", len(failedEphemeralRunners),
+		"retained", len(retainedEphemeralRunners),
 		"deleting", len(deletingEphemeralRunners),
 	)
 
+	runnerScaleSetID := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.RunnerScaleSetId
+	metrics.SetEphemeralRunners(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, runnerScaleSetID, metrics.PhasePending, len(pendingEphemeralRunners))
+	metrics.SetEphemeralRunners(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, runnerScaleSetID, metrics.PhaseRunning, len(runningEphemeralRunners))
+	metrics.SetEphemeralRunners(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, runnerScaleSetID, metrics.PhaseSucceeded, len(finishedEphemeralRunners))
+	metrics.SetEphemeralRunners(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, runnerScaleSetID, metrics.PhaseFailed, len(failedEphemeralRunners)+len(retainedEphemeralRunners))
+
+	if err := r.updateResourceMetadata(ctx, ephemeralRunnerSet, log, pendingEphemeralRunners, runningEphemeralRunners); err != nil {
+		log.Error(err, "Failed to update resource labels and annotations of ephemeral runners")
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+
 	// cleanup finished runners and proceed
 	var errs []error
+	var nextFinishedCheck time.Duration
+	deletableEphemeralRunners := 0
 	for i := range finishedEphemeralRunners {
+		remaining, err := r.postJobGracePeriodRemaining(ctx, ephemeralRunnerSet, finishedEphemeralRunners[i], now.Time)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if remaining > 0 {
+			log.Info("Keeping finished ephemeral runner during the post job grace period", "name", finishedEphemeralRunners[i].Name, "remaining", remaining)
+			if nextFinishedCheck == 0 || remaining < nextFinishedCheck {
+				nextFinishedCheck = remaining
+			}
+			continue
+		}
+		if r.uploadInProgress(finishedEphemeralRunners[i], log) {
+			continue
+		}
+
+		deletableEphemeralRunners++
+		if r.DryRun {
+			log.Info("Dry run: skipping deletion of finished ephemeral runner", "name", finishedEphemeralRunners[i].Name)
+			continue
+		}
+
 		log.Info("Deleting finished ephemeral runner", "name", finishedEphemeralRunners[i].Name)
 		if err := r.Delete(ctx, finishedEphemeralRunners[i]); err != nil {
 			if !kerrors.IsNotFound(err) {
@@ -180,38 +461,1025 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, mergedErrs
 	}
 
+	if err := r.deleteExcessRetainedEphemeralRunners(ctx, ephemeralRunnerSet, retainedEphemeralRunners, log); err != nil {
+		log.Error(err, "Failed to delete retained failed ephemeral runners")
+		return ctrl.Result{}, err
+	}
+
+	// Pending and failed runners are counted towards the total, so a desired count that cannot be
+	// scheduled does not result in creating new ephemeral runners on every reconcile.
+	// Failed runners retained for inspection are not counted, so they are replaced.
+	// Runners annotated to not be scaled down are counted, so they are not replaced either.
 	total := len(pendingEphemeralRunners) + len(runningEphemeralRunners) + len(failedEphemeralRunners)
-	log.Info("Scaling comparison", "current", total, "desired", ephemeralRunnerSet.Spec.Replicas)
+	desired := ephemeralRunnerSet.DesiredReplicas()
+	log.Info("Scaling comparison", "current", total, "desired", desired, "minIdle", ephemeralRunnerSet.Spec.MinIdleReplicas, "overProvisioned", ephemeralRunnerSet.OverProvisionedReplicas())
+
+	lastScaleUpTime := ephemeralRunnerSet.Status.LastScaleUpTime
+	scaledUp := desired > ephemeralRunnerSet.Status.DesiredReplicas
+	if scaledUp {
+		lastScaleUpTime = &now
+	}
+
+	var result ctrl.Result
+	creating, deleting := 0, deletableEphemeralRunners
+	queuedCreations, namespaceLimited := 0, 0
 	switch {
-	case total < ephemeralRunnerSet.Spec.Replicas: // Handle scale up
-		count := ephemeralRunnerSet.Spec.Replicas - total
-		log.Info("Creating new ephemeral runners (scale up)", "count", count)
-		if err := r.createEphemeralRunners(ctx, ephemeralRunnerSet, count, log); err != nil {
-			log.Error(err, "failed to make ephemeral runner")
+	case total < desired: // Handle scale up
+		count := capScalingCount(desired-total, ephemeralRunnerSet.Spec.MaxConcurrentCreations)
+		if queuedCreations = desired - total - count; queuedCreations > 0 {
+			log.Info("Limiting the number of ephemeral runners created in this reconcile", "count", count, "queued", queuedCreations)
+			result.RequeueAfter = throttledScalingRequeueInterval
+		}
+		created, limited, err := r.scaleUpEphemeralRunners(ctx, ephemeralRunnerSet, count, ephemeralRunnerList.Items, log)
+		if err != nil {
 			return ctrl.Result{}, err
 		}
+		creating = created
+		if limited {
+			// The runners queued by MaxConcurrentCreations are held back by the namespace limit as well.
+			namespaceLimited, queuedCreations = desired-total-created, 0
+			log.Info("Limited the number of ephemeral runners created to the runners left in the namespace", "count", created, "limited", namespaceLimited, "maxRunnersPerNamespace", r.MaxRunnersPerNamespace)
+			result.RequeueAfter = namespaceRunnerLimitRequeueInterval
+		}
+
+	case total > desired: // Handle scale down scenario.
+		if remaining := startupScaleDownDelayRemaining(r.StartupScaleDownDelay, r.startTime, now.Time); remaining > 0 {
+			log.Info("Deferring scale down until the startup delay of the controller elapses", "remaining", remaining, "current", total, "desired", desired)
+			result.RequeueAfter = remaining
+			break
+		}
+		if remaining := scaleDownStabilizationRemaining(ephemeralRunnerSet.Spec.ScaleDownStabilizationWindow, lastScaleUpTime, now.Time); remaining > 0 {
+			log.Info("Deferring scale down until the stabilization window elapses", "remaining", remaining)
+			result.RequeueAfter = remaining
+			break
+		}
 
-	case total > ephemeralRunnerSet.Spec.Replicas: // Handle scale down scenario.
-		count := total - ephemeralRunnerSet.Spec.Replicas
+		count := capScalingCount(total-desired, ephemeralRunnerSet.Spec.MaxConcurrentDeletions)
+		if count < total-desired {
+			log.Info("Limiting the number of ephemeral runners deleted in this reconcile", "count", count, "queued", total-desired-count)
+			result.RequeueAfter = throttledScalingRequeueInterval
+		}
 		log.Info("Deleting ephemeral runners (scale down)", "count", count)
-		if err := r.deleteIdleEphemeralRunners(ctx, ephemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners, count, log); err != nil {
+		deleted, deferred, err := r.deleteIdleEphemeralRunners(ctx, ephemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners, count, log)
+		deleting += deleted
+		if err != nil {
 			log.Error(err, "failed to delete idle runners")
 			return ctrl.Result{}, err
 		}
+		if deferred > 0 && (result.RequeueAfter == 0 || deferred < result.RequeueAfter) {
+			result.RequeueAfter = deferred
+		}
+		if deleted == 0 && scaleDownBlockedByNoScaleDown(pendingEphemeralRunners, runningEphemeralRunners) {
+			log.Info("Scale down is blocked by idle ephemeral runners annotated to not be scaled down", "annotation", AnnotationKeyNoScaleDown)
+			r.Recorder.Eventf(ephemeralRunnerSet, corev1.EventTypeNormal, "ScaleDownBlocked", "Scale down by %d runners blocked by idle runners annotated with %s", total-desired, AnnotationKeyNoScaleDown)
+		}
+
+	case ephemeralRunnerSet.Spec.UpdateStrategy == v1alpha1.UpdateStrategyRollingUpdate: // Handle replacing outdated runners.
+		deleted, deferred, err := r.replaceOutdatedEphemeralRunners(ctx, ephemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners, len(deletingEphemeralRunners), log)
+		deleting += deleted
+		if err != nil {
+			log.Error(err, "failed to replace outdated runners")
+			return ctrl.Result{}, err
+		}
+		if deferred > 0 && (result.RequeueAfter == 0 || deferred < result.RequeueAfter) {
+			result.RequeueAfter = deferred
+		}
+	}
+
+	metrics.SetEphemeralRunnerChanges(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, metrics.ActionCreate, creating)
+	metrics.SetEphemeralRunnerChanges(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, metrics.ActionDelete, deleting)
+
+	idle, busy := countIdleAndBusyEphemeralRunners(runningEphemeralRunners)
+
+	threshold := defaultRunnerRegistrationThreshold
+	if ephemeralRunnerSet.Spec.RunnerRegistrationThreshold != nil {
+		threshold = ephemeralRunnerSet.Spec.RunnerRegistrationThreshold.Duration
+	}
+	unregistered, nextRegistrationCheck := countUnregisteredEphemeralRunners(threshold, now.Time, pendingEphemeralRunners, runningEphemeralRunners)
+	if unregistered > 0 {
+		log.Info("Ephemeral runners are waiting for registration", "count", unregistered, "threshold", threshold)
+	}
+	// Requeue when the next runner reaches the threshold, so the condition does not depend on other events.
+	if nextRegistrationCheck > 0 && (result.RequeueAfter == 0 || nextRegistrationCheck < result.RequeueAfter) {
+		result.RequeueAfter = nextRegistrationCheck
+	}
+	// Requeue when the post job grace period of the next finished runner elapses.
+	if nextFinishedCheck > 0 && (result.RequeueAfter == 0 || nextFinishedCheck < result.RequeueAfter) {
+		result.RequeueAfter = nextFinishedCheck
+	}
+	oldestJobAssignedAt := oldestRunningJobAssignedAt(runningEphemeralRunners)
+	registrationCondition := runnerRegistrationCondition(ephemeralRunnerSet.Generation, unregistered, threshold)
+
+	runnerImage, found := runnerContainerImage(&ephemeralRunnerSet.Spec.EphemeralRunnerSpec)
+	containerName := specRunnerContainerName(&ephemeralRunnerSet.Spec.EphemeralRunnerSpec)
+	if !found {
+		log.Info("Runner container not found in the pod template", "containerName", containerName)
+	}
+	metrics.SetRunnerImage(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, runnerImage)
+	runnerGroup := r.runnerGroupStatus(ctx, ephemeralRunnerSet, log)
+
+	runnerContainerCondition := runnerContainerNotFoundCondition(ephemeralRunnerSet.Generation, containerName, found)
+
+	unschedulable := countUnschedulableEphemeralRunners(pendingEphemeralRunners)
+	unschedulableCondition := runnersUnschedulableCondition(ephemeralRunnerSet.Generation, unschedulable)
+	if unschedulableCondition.Status == metav1.ConditionTrue && !meta.IsStatusConditionTrue(ephemeralRunnerSet.Status.Conditions, unschedulableCondition.Type) {
+		log.Info("Ephemeral runners can't be scheduled", "count", unschedulable)
+		r.Recorder.Event(ephemeralRunnerSet, corev1.EventTypeWarning, "RunnersUnschedulable", unschedulableCondition.Message)
+	}
+
+	conditions = append(conditions, registrationCondition, runnerContainerCondition, unschedulableCondition)
+	if r.MaxRunnersPerNamespace > 0 {
+		conditions = append(conditions, namespaceRunnerLimitCondition(ephemeralRunnerSet.Generation, namespaceLimited, r.MaxRunnersPerNamespace))
+	}
+
+	emptySince := ephemeralRunnerSet.Status.EmptySince
+	switch {
+	case total > 0 || desired > 0:
+		emptySince = nil
+	case emptySince == nil:
+		emptySince = &now
 	}
 
 	// Update the status if needed.
-	if ephemeralRunnerSet.Status.CurrentReplicas != total {
-		log.Info("Updating status with current runners count", "count", total)
+	if ephemeralRunnerSet.Status.CurrentReplicas != total ||
+		ephemeralRunnerSet.Status.IdleReplicas != idle ||
+		ephemeralRunnerSet.Status.BusyReplicas != busy ||
+		ephemeralRunnerSet.Status.DesiredReplicas != desired ||
+		ephemeralRunnerSet.Status.QueuedCreations != queuedCreations ||
+		ephemeralRunnerSet.Status.RunnerImage != runnerImage ||
+		lastReconcileTimeExpired(ephemeralRunnerSet.Status.LastReconcileTime, now.Time) ||
+		(ephemeralRunnerSet.Status.EmptySince == nil) != (emptySince == nil) ||
+		!ephemeralRunnerSet.Status.OldestRunningJobAssignedAt.Equal(oldestJobAssignedAt) ||
+		!reflect.DeepEqual(ephemeralRunnerSet.Status.RunnerGroup, runnerGroup) ||
+		conditionsChanged(ephemeralRunnerSet.Status.Conditions, conditions) {
+		log.Info("Updating status with current runners count", "count", total, "idle", idle, "busy", busy, "desired", desired)
 		if err := patchSubResource(ctx, r.Status(), ephemeralRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
 			obj.Status.CurrentReplicas = total
+			obj.Status.IdleReplicas = idle
+			obj.Status.BusyReplicas = busy
+			obj.Status.DesiredReplicas = desired
+			obj.Status.QueuedCreations = queuedCreations
+			obj.Status.RunnerImage = runnerImage
+			obj.Status.OldestRunningJobAssignedAt = oldestJobAssignedAt
+			obj.Status.RunnerGroup = runnerGroup
+			obj.Status.LastReconcileTime = &now
+			obj.Status.EmptySince = emptySince
+			if scaledUp {
+				obj.Status.LastScaleUpTime = lastScaleUpTime
+			}
+			for _, condition := range conditions {
+				meta.SetStatusCondition(&obj.Status.Conditions, condition)
+			}
 		}); err != nil {
 			log.Error(err, "Failed to update status with current runners count")
 			return ctrl.Result{}, err
 		}
 	}
 
-	return ctrl.Result{}, nil
+	if remaining, ok := emptyTTLRemaining(ephemeralRunnerSet.Spec.EmptyTTL, emptySince, now.Time); ok {
+		if remaining > 0 {
+			// Requeue when the empty TTL expires.
+			if result.RequeueAfter == 0 || remaining < result.RequeueAfter {
+				result.RequeueAfter = remaining
+			}
+		} else {
+			deleted, err := r.deleteEmptyEphemeralRunnerSet(ctx, ephemeralRunnerSet, log)
+			if err != nil {
+				log.Error(err, "Failed to delete empty ephemeral runner set")
+				return ctrl.Result{}, err
+			}
+			if deleted {
+				return ctrl.Result{}, nil
+			}
+		}
+	}
+
+	return r.requeueResult(result), nil
+}
+
+// emptyTTLRemaining returns how long an empty EphemeralRunnerSet is kept before being deleted,
+// and false if it is not empty or has no empty TTL.
+func emptyTTLRemaining(emptyTTL *metav1.Duration, emptySince *metav1.Time, now time.Time) (time.Duration, bool) {
+	if emptyTTL == nil || emptySince == nil {
+		return 0, false
+	}
+	return emptySince.Add(emptyTTL.Duration).Sub(now), true
+}
+
+// deleteEmptyEphemeralRunnerSet deletes the EphemeralRunnerSet whose empty TTL expired,
+// unless it is the active EphemeralRunnerSet of its AutoscalingRunnerSet.
+func (r *EphemeralRunnerSetReconciler) deleteEmptyEphemeralRunnerSet(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) (bool, error) {
+	active, err := r.isActiveEphemeralRunnerSet(ctx, ephemeralRunnerSet)
+	if err != nil {
+		return false, err
+	}
+	if active {
+		log.Info("Empty TTL expired, but the ephemeral runner set is the active one of its AutoscalingRunnerSet. Keeping it")
+		return false, nil
+	}
+
+	log.Info("Empty TTL expired. Deleting the ephemeral runner set", "emptySince", ephemeralRunnerSet.Status.EmptySince, "emptyTTL", ephemeralRunnerSet.Spec.EmptyTTL.Duration)
+	r.Recorder.Eventf(ephemeralRunnerSet, corev1.EventTypeNormal, "EmptyTTLExpired", "Deleting the runner set after it stayed empty for %s", ephemeralRunnerSet.Spec.EmptyTTL.Duration)
+	if err := r.Delete(ctx, ephemeralRunnerSet); err != nil && !kerrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to delete ephemeral runner set: %v", err)
+	}
+	return true, nil
+}
+
+// isActiveEphemeralRunnerSet reports whether the EphemeralRunnerSet is the active EphemeralRunnerSet of the
+// AutoscalingRunnerSet controlling it, which is the most recently created one. EphemeralRunnerSets without
+// an existing AutoscalingRunnerSet are not active.
+func (r *EphemeralRunnerSetReconciler) isActiveEphemeralRunnerSet(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) (bool, error) {
+	owner := metav1.GetControllerOf(ephemeralRunnerSet)
+	if owner == nil || owner.Kind != "AutoscalingRunnerSet" {
+		return false, nil
+	}
+
+	autoscalingRunnerSet := new(v1alpha1.AutoscalingRunnerSet)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunnerSet.Namespace, Name: owner.Name}, autoscalingRunnerSet); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get the AutoscalingRunnerSet: %v", err)
+	}
+	if autoscalingRunnerSet.UID != owner.UID {
+		return false, nil
+	}
+
+	list := new(v1alpha1.EphemeralRunnerSetList)
+	if err := r.List(ctx, list, client.InNamespace(ephemeralRunnerSet.Namespace)); err != nil {
+		return false, fmt.Errorf("failed to list ephemeral runner sets: %v", err)
+	}
+	owned := new(v1alpha1.EphemeralRunnerSetList)
+	for i := range list.Items {
+		if metav1.IsControlledBy(&list.Items[i], autoscalingRunnerSet) {
+			owned.Items = append(owned.Items, list.Items[i])
+		}
+	}
+
+	latest := (&EphemeralRunnerSets{list: owned}).latest()
+	return latest == nil || latest.Name == ephemeralRunnerSet.Name, nil
+}
+
+// lastReconcileTimeExpired reports whether the last reconcile time in the status must be refreshed
+// even though the rest of the status is unchanged. It is not compared otherwise, so writing it doesn't
+// trigger another status write from the reconcile caused by the update.
+func lastReconcileTimeExpired(lastReconcileTime *metav1.Time, now time.Time) bool {
+	return lastReconcileTime == nil || now.Sub(lastReconcileTime.Time) >= lastReconcileTimeRefreshInterval
+}
+
+// runnerGroupStatus returns the runner group of the runner scale set, recorded on the AutoscalingRunnerSet
+// owning the EphemeralRunnerSet when it resolves the runner scale set.
+// It returns nil if the runner group can't be resolved.
+func (r *EphemeralRunnerSetReconciler) runnerGroupStatus(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) *v1alpha1.RunnerGroupStatus {
+	owner := metav1.GetControllerOf(ephemeralRunnerSet)
+	if owner == nil || owner.Kind != "AutoscalingRunnerSet" {
+		return nil
+	}
+
+	autoscalingRunnerSet := new(v1alpha1.AutoscalingRunnerSet)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunnerSet.Namespace, Name: owner.Name}, autoscalingRunnerSet); err != nil {
+		log.Info("Unable to get the AutoscalingRunnerSet to resolve the runner group", "name", owner.Name, "error", err.Error())
+		return nil
+	}
+
+	name := autoscalingRunnerSet.Annotations[runnerScaleSetRunnerGroupNameKey]
+	if name == "" {
+		return nil
+	}
+	id, _ := strconv.Atoi(autoscalingRunnerSet.Annotations[runnerScaleSetRunnerGroupIdKey])
+	return &v1alpha1.RunnerGroupStatus{
+		Name: name,
+		Id:   id,
+	}
+}
+
+// requeueResult applies the requeue interval and jitter to the result of a successful reconcile.
+// The earliest of the requested requeue and the requeue interval is used.
+func (r *EphemeralRunnerSetReconciler) requeueResult(result ctrl.Result) ctrl.Result {
+	if r.RequeueInterval > 0 && (result.RequeueAfter == 0 || r.RequeueInterval < result.RequeueAfter) {
+		result.RequeueAfter = r.RequeueInterval
+	}
+
+	if result.RequeueAfter > 0 && r.RequeueJitter > 0 {
+		result.RequeueAfter = wait.Jitter(result.RequeueAfter, r.RequeueJitter)
+	}
+
+	return result
+}
+
+// updateResourceMetadata merges the resource labels and annotations of the EphemeralRunnerSet onto the ephemeral runners.
+// The EphemeralRunner controller propagates them to the runner pods.
+func (r *EphemeralRunnerSetReconciler) updateResourceMetadata(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger, ephemeralRunners ...[]*v1alpha1.EphemeralRunner) error {
+	labels := ephemeralRunnerSet.Spec.ResourceLabels
+	annotations := ephemeralRunnerSet.Spec.ResourceAnnotations
+	if len(labels) == 0 && len(annotations) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, runners := range ephemeralRunners {
+		for _, ephemeralRunner := range runners {
+			if !mergeResourceMetadata(ephemeralRunner.DeepCopy(), labels, annotations) {
+				continue
+			}
+
+			log.Info("Updating resource labels and annotations of the ephemeral runner", "name", ephemeralRunner.Name)
+			if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+				mergeResourceMetadata(obj, labels, annotations)
+			}); err != nil && !kerrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// splitRetainedEphemeralRunners separates the failed ephemeral runners retained for inspection from the other failed ones.
+func splitRetainedEphemeralRunners(failedEphemeralRunners []*v1alpha1.EphemeralRunner) (failed, retained []*v1alpha1.EphemeralRunner) {
+	for _, ephemeralRunner := range failedEphemeralRunners {
+		if _, ok := ephemeralRunner.Labels[LabelKeyRetainedFailure]; ok {
+			retained = append(retained, ephemeralRunner)
+			continue
+		}
+		failed = append(failed, ephemeralRunner)
+	}
+	return failed, retained
+}
+
+// duplicateEphemeralRunners returns the ephemeral runners reporting the same non-zero RunnerId as an older ephemeral runner,
+// which can happen after a registration retry races with the previous registration. The oldest ephemeral runner of each RunnerId
+// is kept, the name breaking ties, so the same runners are picked on every reconcile.
+func duplicateEphemeralRunners(ephemeralRunners ...[]*v1alpha1.EphemeralRunner) []*v1alpha1.EphemeralRunner {
+	var all []*v1alpha1.EphemeralRunner
+	for _, runners := range ephemeralRunners {
+		all = append(all, runners...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if !all[i].CreationTimestamp.Equal(&all[j].CreationTimestamp) {
+			return all[i].CreationTimestamp.Before(&all[j].CreationTimestamp)
+		}
+		return all[i].Name < all[j].Name
+	})
+
+	var duplicates []*v1alpha1.EphemeralRunner
+	kept := make(map[int]*v1alpha1.EphemeralRunner)
+	for _, ephemeralRunner := range all {
+		runnerId := ephemeralRunner.Status.RunnerId
+		if runnerId == 0 {
+			continue
+		}
+		if _, ok := kept[runnerId]; ok {
+			duplicates = append(duplicates, ephemeralRunner)
+			continue
+		}
+		kept[runnerId] = ephemeralRunner
+	}
+	return duplicates
+}
+
+// deleteDuplicateEphemeralRunners deletes the ephemeral runners reporting the RunnerId of an older ephemeral runner,
+// to be replaced by new ones. Their runner registration finalizer is removed first, so the runner of the older
+// ephemeral runner is not removed from the service along with them.
+func (r *EphemeralRunnerSetReconciler) deleteDuplicateEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, duplicates []*v1alpha1.EphemeralRunner, log logr.Logger) error {
+	var errs []error
+	for _, ephemeralRunner := range duplicates {
+		log.Info("WARNING: Ephemeral runner reports the runner ID of an older ephemeral runner", "name", ephemeralRunner.Name, "runnerId", ephemeralRunner.Status.RunnerId)
+		if r.DryRun {
+			log.Info("Dry run: skipping deletion of ephemeral runner with a duplicate runner ID", "name", ephemeralRunner.Name)
+			continue
+		}
+
+		r.Recorder.Eventf(ephemeralRunnerSet, corev1.EventTypeWarning, "DuplicateRunnerId", "Deleting ephemeral runner %s reporting the runner ID %d of an older ephemeral runner", ephemeralRunner.Name, ephemeralRunner.Status.RunnerId)
+		if controllerutil.ContainsFinalizer(ephemeralRunner, ephemeralRunnerActionsFinalizerName) {
+			if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+				controllerutil.RemoveFinalizer(obj, ephemeralRunnerActionsFinalizerName)
+			}); err != nil {
+				if !kerrors.IsNotFound(err) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+		}
+
+		if err := r.Delete(ctx, ephemeralRunner); err != nil {
+			if !kerrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		metrics.IncEphemeralRunnerRecycled(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, "DuplicateRunnerId")
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// withoutEphemeralRunners returns the ephemeral runners that are not in excluded.
+func withoutEphemeralRunners(ephemeralRunners, excluded []*v1alpha1.EphemeralRunner) []*v1alpha1.EphemeralRunner {
+	var result []*v1alpha1.EphemeralRunner
+	for _, ephemeralRunner := range ephemeralRunners {
+		found := false
+		for _, e := range excluded {
+			if e == ephemeralRunner {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, ephemeralRunner)
+		}
+	}
+	return result
+}
+
+// deleteExcessRetainedEphemeralRunners deletes the oldest failed ephemeral runners retained for inspection,
+// so at most MaxRetainedFailedPods of them are kept.
+func (r *EphemeralRunnerSetReconciler) deleteExcessRetainedEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, retainedEphemeralRunners []*v1alpha1.EphemeralRunner, log logr.Logger) error {
+	max := ephemeralRunnerSet.Spec.MaxRetainedFailedPods
+	if max <= 0 || len(retainedEphemeralRunners) <= max {
+		return nil
+	}
+
+	sort.Slice(retainedEphemeralRunners, func(i, j int) bool {
+		return retainedEphemeralRunners[i].CreationTimestamp.Before(&retainedEphemeralRunners[j].CreationTimestamp)
+	})
+
+	var errs []error
+	for _, ephemeralRunner := range retainedEphemeralRunners[:len(retainedEphemeralRunners)-max] {
+		if r.DryRun {
+			log.Info("Dry run: skipping deletion of retained failed ephemeral runner", "name", ephemeralRunner.Name)
+			continue
+		}
+
+		log.Info("Deleting retained failed ephemeral runner", "name", ephemeralRunner.Name, "maxRetainedFailedPods", max)
+		if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// postJobGracePeriodRemaining returns how long the finished ephemeral runner is kept before it is deleted.
+// The time the ephemeral runner was first observed as finished is stored in an annotation,
+// so the grace period survives controller restarts.
+func (r *EphemeralRunnerSetReconciler) postJobGracePeriodRemaining(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, ephemeralRunner *v1alpha1.EphemeralRunner, now time.Time) (time.Duration, error) {
+	gracePeriod := ephemeralRunnerSet.Spec.PostJobGracePeriod
+	if gracePeriod == nil || gracePeriod.Duration <= 0 {
+		return 0, nil
+	}
+
+	finishedAt, err := time.Parse(time.RFC3339, ephemeralRunner.Annotations[AnnotationKeyFinishedAt])
+	if err != nil {
+		finishedAt = now
+		if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+			if obj.Annotations == nil {
+				obj.Annotations = make(map[string]string)
+			}
+			obj.Annotations[AnnotationKeyFinishedAt] = finishedAt.Format(time.RFC3339)
+		}); err != nil {
+			return 0, fmt.Errorf("failed to annotate finished ephemeral runner %s: %v", ephemeralRunner.Name, err)
+		}
+	}
+
+	return finishedAt.Add(gracePeriod.Duration).Sub(now), nil
+}
+
+// uploadInProgress reports whether the deletion of the finished ephemeral runner is deferred because
+// it is annotated with an upload in progress. Removing the annotation updates the ephemeral runner,
+// which triggers a new reconcile of its runner set.
+func (r *EphemeralRunnerSetReconciler) uploadInProgress(ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) bool {
+	if _, ok := ephemeralRunner.Annotations[AnnotationKeyUploadInProgress]; !ok {
+		return false
+	}
+
+	log.Info("Deferring deletion of finished ephemeral runner with an upload in progress", "name", ephemeralRunner.Name)
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeNormal, "UploadInProgress", fmt.Sprintf("Deletion deferred until the %s annotation is removed", AnnotationKeyUploadInProgress))
+	return true
+}
+
+// noScaleDown reports whether the ephemeral runner is annotated to not be deleted on scale down.
+func noScaleDown(ephemeralRunner *v1alpha1.EphemeralRunner) bool {
+	return ephemeralRunner.Annotations[AnnotationKeyNoScaleDown] == "true"
+}
+
+// scaleDownBlockedByNoScaleDown reports whether all the idle registered ephemeral runners, which are the only ones
+// deleted on scale down, are annotated to not be scaled down.
+func scaleDownBlockedByNoScaleDown(pendingEphemeralRunners, runningEphemeralRunners []*v1alpha1.EphemeralRunner) bool {
+	blocked := false
+	for _, runners := range [][]*v1alpha1.EphemeralRunner{pendingEphemeralRunners, runningEphemeralRunners} {
+		for _, ephemeralRunner := range runners {
+			if ephemeralRunner.Status.RunnerId == 0 || ephemeralRunner.Status.JobRequestId > 0 {
+				continue
+			}
+			if !noScaleDown(ephemeralRunner) {
+				return false
+			}
+			blocked = true
+		}
+	}
+	return blocked
+}
+
+// capScalingCount limits the number of ephemeral runners created or deleted in a single reconcile.
+// A max of zero or less does not limit the count.
+func capScalingCount(count, max int) int {
+	if max > 0 && count > max {
+		return max
+	}
+	return count
+}
+
+// scaleDownStabilizationRemaining returns how long scaling down should still be deferred
+// after the last scale up. A non-positive value means scaling down can proceed.
+func scaleDownStabilizationRemaining(window *metav1.Duration, lastScaleUpTime *metav1.Time, now time.Time) time.Duration {
+	if window == nil || lastScaleUpTime == nil {
+		return 0
+	}
+
+	return lastScaleUpTime.Add(window.Duration).Sub(now)
+}
+
+// startupScaleDownDelayRemaining returns how long scaling down should still be deferred after the reconciler
+// started. A non-positive value means scaling down can proceed.
+func startupScaleDownDelayRemaining(delay time.Duration, startTime, now time.Time) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+
+	return startTime.Add(delay).Sub(now)
+}
+
+// countUnregisteredEphemeralRunners returns the number of ephemeral runners that have been pending or running
+// without a RunnerId for longer than the threshold, and how long until the next one of them reaches it.
+func countUnregisteredEphemeralRunners(threshold time.Duration, now time.Time, ephemeralRunners ...[]*v1alpha1.EphemeralRunner) (count int, next time.Duration) {
+	for _, runners := range ephemeralRunners {
+		for _, r := range runners {
+			if r.Status.RunnerId != 0 {
+				continue
+			}
+
+			remaining := r.CreationTimestamp.Add(threshold).Sub(now)
+			if remaining <= 0 {
+				count++
+				continue
+			}
+
+			if next == 0 || remaining < next {
+				next = remaining
+			}
+		}
+	}
+	return
+}
+
+func runnerRegistrationCondition(generation int64, unregistered int, threshold time.Duration) metav1.Condition {
+	if unregistered == 0 {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionWaitingForRunnerRegistration,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "RunnersRegistered",
+			Message:            fmt.Sprintf("No ephemeral runners have been waiting for registration for longer than %s", threshold),
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionWaitingForRunnerRegistration,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "RunnersNotRegistered",
+		Message:            fmt.Sprintf("%d ephemeral runners have not registered with GitHub within %s", unregistered, threshold),
+	}
+}
+
+func proxyConfigCondition(generation int64, err error) metav1.Condition {
+	if err == nil {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionInvalidProxyConfig,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "ProxyConfigValid",
+			Message:            "The proxy configuration is valid",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionInvalidProxyConfig,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "ProxyConfigInvalid",
+		Message:            err.Error(),
+	}
+}
+
+// validateNodeSelector checks that the keys and values of the node selector are valid labels.
+func validateNodeSelector(nodeSelector map[string]string) error {
+	keys := make([]string, 0, len(nodeSelector))
+	for key := range nodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("invalid node selector key %q: %s", key, strings.Join(msgs, "; ")))
+		}
+		if msgs := validation.IsValidLabelValue(nodeSelector[key]); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("invalid node selector value %q for key %q: %s", nodeSelector[key], key, strings.Join(msgs, "; ")))
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+func nodeSelectorCondition(generation int64, err error) metav1.Condition {
+	if err == nil {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionInvalidNodeSelector,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "NodeSelectorValid",
+			Message:            "The node selector is valid",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionInvalidNodeSelector,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "NodeSelectorInvalid",
+		Message:            err.Error(),
+	}
+}
+
+// validateTolerations checks the tolerations for the mistakes the API server would reject runner pods for,
+// like an invalid key, an Exists toleration with a value or toleration seconds without the NoExecute effect.
+func validateTolerations(tolerations []corev1.Toleration) error {
+	var errs []error
+	for i, t := range tolerations {
+		if t.Key != "" {
+			if msgs := validation.IsQualifiedName(t.Key); len(msgs) > 0 {
+				errs = append(errs, fmt.Errorf("invalid key %q of toleration %d: %s", t.Key, i, strings.Join(msgs, "; ")))
+			}
+		}
+
+		switch t.Operator {
+		case corev1.TolerationOpEqual, "":
+			if t.Key == "" {
+				errs = append(errs, fmt.Errorf("toleration %d without key must use the Exists operator", i))
+			}
+			if msgs := validation.IsValidLabelValue(t.Value); len(msgs) > 0 {
+				errs = append(errs, fmt.Errorf("invalid value %q of toleration %d: %s", t.Value, i, strings.Join(msgs, "; ")))
+			}
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				errs = append(errs, fmt.Errorf("toleration %d with the Exists operator must not have a value", i))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("invalid operator %q of toleration %d, must be Equal or Exists", t.Operator, i))
+		}
+
+		switch t.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			errs = append(errs, fmt.Errorf("invalid effect %q of toleration %d, must be NoSchedule, PreferNoSchedule or NoExecute", t.Effect, i))
+		}
+		if t.TolerationSeconds != nil && t.Effect != corev1.TaintEffectNoExecute {
+			errs = append(errs, fmt.Errorf("toleration %d sets toleration seconds without the NoExecute effect", i))
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+func tolerationsCondition(generation int64, err error) metav1.Condition {
+	if err == nil {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionInvalidTolerations,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "TolerationsValid",
+			Message:            "The tolerations are valid",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionInvalidTolerations,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "TolerationsInvalid",
+		Message:            err.Error(),
+	}
+}
+
+// maxDNSNameservers and maxDNSSearches are the limits of the API server on the DNS config of a pod.
+const (
+	maxDNSNameservers = 3
+	maxDNSSearches    = 32
+)
+
+// validateDNSConfig checks the DNS policy and DNS config for the mistakes the API server would reject runner pods for,
+// like an unknown policy, a nameserver that is not an IP address or a search domain that is not a valid DNS name.
+func validateDNSConfig(policy corev1.DNSPolicy, config *corev1.PodDNSConfig) error {
+	var errs []error
+	switch policy {
+	case "", corev1.DNSClusterFirstWithHostNet, corev1.DNSClusterFirst, corev1.DNSDefault:
+	case corev1.DNSNone:
+		if config == nil || len(config.Nameservers) == 0 {
+			errs = append(errs, fmt.Errorf("DNS policy %s requires at least one nameserver in the DNS config", policy))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid DNS policy %q, must be ClusterFirstWithHostNet, ClusterFirst, Default or None", policy))
+	}
+	if config == nil {
+		return multierr.Combine(errs...)
+	}
+
+	if len(config.Nameservers) > maxDNSNameservers {
+		errs = append(errs, fmt.Errorf("DNS config has %d nameservers, at most %d are allowed", len(config.Nameservers), maxDNSNameservers))
+	}
+	for _, nameserver := range config.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			errs = append(errs, fmt.Errorf("invalid nameserver %q, must be an IP address", nameserver))
+		}
+	}
+	if len(config.Searches) > maxDNSSearches {
+		errs = append(errs, fmt.Errorf("DNS config has %d search domains, at most %d are allowed", len(config.Searches), maxDNSSearches))
+	}
+	for _, search := range config.Searches {
+		if msgs := validation.IsDNS1123Subdomain(strings.TrimSuffix(search, ".")); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("invalid search domain %q: %s", search, strings.Join(msgs, "; ")))
+		}
+	}
+	for i, option := range config.Options {
+		if option.Name == "" {
+			errs = append(errs, fmt.Errorf("DNS option %d must have a name", i))
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+func dnsConfigCondition(generation int64, err error) metav1.Condition {
+	if err == nil {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionInvalidDNSConfig,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "DNSConfigValid",
+			Message:            "The DNS config is valid",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionInvalidDNSConfig,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "DNSConfigInvalid",
+		Message:            err.Error(),
+	}
+}
+
+// runnerContainerImage returns the image of the runner container in the pod template of the ephemeral runner spec,
+// and false if the pod template has no runner container.
+func runnerContainerImage(spec *v1alpha1.EphemeralRunnerSpec) (string, bool) {
+	name := specRunnerContainerName(spec)
+	for _, c := range spec.PodTemplateSpec.Spec.Containers {
+		if c.Name == name {
+			return c.Image, true
+		}
+	}
+	return "", false
+}
+
+func runnerContainerNotFoundCondition(generation int64, containerName string, found bool) metav1.Condition {
+	if found {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionRunnerContainerNotFound,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "RunnerContainerFound",
+			Message:            fmt.Sprintf("The pod template has a %q container", containerName),
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionRunnerContainerNotFound,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "RunnerContainerNotFound",
+		Message:            fmt.Sprintf("The pod template has no %q container, the runner image can't be resolved", containerName),
+	}
+}
+
+// countUnschedulableEphemeralRunners returns the number of pending ephemeral runners reported as unschedulable.
+func countUnschedulableEphemeralRunners(pendingEphemeralRunners []*v1alpha1.EphemeralRunner) int {
+	count := 0
+	for _, ephemeralRunner := range pendingEphemeralRunners {
+		if ephemeralRunner.Status.Unschedulable {
+			count++
+		}
+	}
+	return count
+}
+
+func runnersUnschedulableCondition(generation int64, unschedulable int) metav1.Condition {
+	if unschedulable == 0 {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionRunnersUnschedulable,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "RunnersSchedulable",
+			Message:            "No ephemeral runner pods are unschedulable",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionRunnersUnschedulable,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "RunnersUnschedulable",
+		Message:            fmt.Sprintf("%d ephemeral runner pods can't be scheduled", unschedulable),
+	}
+}
+
+// scaleUpEphemeralRunners creates up to `count` ephemeral runners, or only counts them in dry run mode.
+// When MaxRunnersPerNamespace is set, the runners of the namespace are counted and the new ones created
+// under the lock of the namespace, and it reports whether the runners left in the namespace limited the count.
+// It returns the number of ephemeral runners created.
+func (r *EphemeralRunnerSetReconciler) scaleUpEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, count int, existing []v1alpha1.EphemeralRunner, log logr.Logger) (int, bool, error) {
+	limited := false
+	if r.MaxRunnersPerNamespace > 0 {
+		mu := r.namespaceRunnerLimitLock(ephemeralRunnerSet.Namespace)
+		mu.Lock()
+		defer mu.Unlock()
+
+		available, err := r.namespaceRunnersAvailable(ctx, ephemeralRunnerSet.Namespace)
+		if err != nil {
+			log.Error(err, "Failed to count the ephemeral runners of the namespace")
+			return 0, false, err
+		}
+		if count > available {
+			count, limited = available, true
+		}
+	}
+	if count == 0 {
+		return 0, limited, nil
+	}
+	if r.DryRun {
+		log.Info("Dry run: skipping creation of new ephemeral runners (scale up)", "count", count)
+		return count, limited, nil
+	}
+
+	log.Info("Creating new ephemeral runners (scale up)", "count", count)
+	if err := r.createEphemeralRunners(ctx, ephemeralRunnerSet, count, existing, log); err != nil {
+		log.Error(err, "failed to make ephemeral runner")
+		return 0, limited, err
+	}
+	return count, limited, nil
+}
+
+// namespaceRunnerLimitLock returns the lock serializing the scale ups of the runner sets of the namespace.
+func (r *EphemeralRunnerSetReconciler) namespaceRunnerLimitLock(namespace string) *sync.Mutex {
+	r.namespaceRunnerLimitLocksMu.Lock()
+	defer r.namespaceRunnerLimitLocksMu.Unlock()
+
+	if r.namespaceRunnerLimitLocks == nil {
+		r.namespaceRunnerLimitLocks = make(map[string]*sync.Mutex)
+	}
+	mu, ok := r.namespaceRunnerLimitLocks[namespace]
+	if !ok {
+		mu = new(sync.Mutex)
+		r.namespaceRunnerLimitLocks[namespace] = mu
+	}
+	return mu
+}
+
+// namespaceRunnersAvailable returns how many EphemeralRunners can still be created in the namespace
+// before reaching MaxRunnersPerNamespace. Like in the total of each runner set, finished runners, runners being
+// deleted and failed runners retained for inspection are not counted.
+func (r *EphemeralRunnerSetReconciler) namespaceRunnersAvailable(ctx context.Context, namespace string) (int, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+
+	ephemeralRunnerList := new(v1alpha1.EphemeralRunnerList)
+	if err := reader.List(ctx, ephemeralRunnerList, client.InNamespace(namespace)); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range ephemeralRunnerList.Items {
+		ephemeralRunner := &ephemeralRunnerList.Items[i]
+		if ephemeralRunner.Status.Phase == corev1.PodSucceeded || !ephemeralRunner.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if _, ok := ephemeralRunner.Labels[LabelKeyRetainedFailure]; ok {
+			continue
+		}
+		count++
+	}
+	if count >= r.MaxRunnersPerNamespace {
+		return 0, nil
+	}
+	return r.MaxRunnersPerNamespace - count, nil
+}
+
+// namespaceRunnerLimitCondition returns the NamespaceRunnerLimitReached condition from the number of
+// ephemeral runners that were not created because of the limit of runners per namespace.
+func namespaceRunnerLimitCondition(generation int64, limited, maxRunners int) metav1.Condition {
+	if limited == 0 {
+		return metav1.Condition{
+			Type:               v1alpha1.EphemeralRunnerSetConditionNamespaceRunnerLimitReached,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "NamespaceRunnerLimitNotReached",
+			Message:            "No ephemeral runners are held back by the limit of runners per namespace",
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.EphemeralRunnerSetConditionNamespaceRunnerLimitReached,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "NamespaceRunnerLimitReached",
+		Message:            fmt.Sprintf("%d ephemeral runners are not created because the namespace reached its limit of %d runners", limited, maxRunners),
+	}
+}
+
+// updateConditions writes the conditions to the status of the EphemeralRunnerSet in a single patch,
+// if setting any of them modifies the conditions.
+func (r *EphemeralRunnerSetReconciler) updateConditions(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, conditions []metav1.Condition) error {
+	if !conditionsChanged(ephemeralRunnerSet.Status.Conditions, conditions) {
+		return nil
+	}
+	return patchSubResource(ctx, r.Status(), ephemeralRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+		for _, condition := range conditions {
+			meta.SetStatusCondition(&obj.Status.Conditions, condition)
+		}
+	})
+}
+
+// conditionsChanged reports whether setting the conditions would modify the existing conditions.
+func conditionsChanged(existing []metav1.Condition, conditions []metav1.Condition) bool {
+	for _, condition := range conditions {
+		if conditionChanged(existing, condition) {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionChanged reports whether setting the condition would modify the conditions.
+func conditionChanged(conditions []metav1.Condition, condition metav1.Condition) bool {
+	existing := meta.FindStatusCondition(conditions, condition.Type)
+	return existing == nil ||
+		existing.Status != condition.Status ||
+		existing.Reason != condition.Reason ||
+		existing.Message != condition.Message ||
+		existing.ObservedGeneration != condition.ObservedGeneration
+}
+
+// reconcileBusyRunnersPodDisruptionBudget creates the busy runners PodDisruptionBudget of the EphemeralRunnerSet
+// with ProtectBusyRunners, and deletes it once ProtectBusyRunners is disabled. The PodDisruptionBudget is owned by
+// the EphemeralRunnerSet, so it is garbage collected along with it.
+func (r *EphemeralRunnerSetReconciler) reconcileBusyRunnersPodDisruptionBudget(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
+	pdb := new(policyv1.PodDisruptionBudget)
+	err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunnerSet.Namespace, Name: busyRunnersPodDisruptionBudgetName(ephemeralRunnerSet)}, pdb)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get the busy runners pod disruption budget: %v", err)
+	}
+	exists := err == nil
+
+	if !ephemeralRunnerSet.Spec.ProtectBusyRunners {
+		if !exists || !metav1.IsControlledBy(pdb, ephemeralRunnerSet) {
+			return nil
+		}
+		log.Info("Deleting the busy runners pod disruption budget", "name", pdb.Name)
+		if err := r.Delete(ctx, pdb); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete the busy runners pod disruption budget: %v", err)
+		}
+		return nil
+	}
+
+	if exists {
+		return nil
+	}
+	if msgs := validation.IsValidLabelValue(ephemeralRunnerSet.Name); len(msgs) > 0 {
+		log.Info("The name of the ephemeral runner set is not a valid label value, busy runners are not protected", "reason", strings.Join(msgs, "; "))
+		return nil
+	}
+
+	pdb = r.resourceBuilder.newBusyRunnersPodDisruptionBudget(ephemeralRunnerSet)
+	if err := ctrl.SetControllerReference(ephemeralRunnerSet, pdb, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set controller reference on the busy runners pod disruption budget: %v", err)
+	}
+	log.Info("Creating the busy runners pod disruption budget", "name", pdb.Name)
+	if err := r.Create(ctx, pdb); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create the busy runners pod disruption budget: %v", err)
+	}
+	return nil
 }
 
 func (r *EphemeralRunnerSetReconciler) cleanUpProxySecret(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
@@ -305,15 +1573,88 @@ func (r *EphemeralRunnerSetReconciler) cleanUpEphemeralRunners(ctx context.Conte
 	return false, nil
 }
 
+// finalizerTimeoutRemaining returns how long the clean up of the EphemeralRunnerSet can still wait for its ephemeral runners
+// to be removed from the service. It returns false if the reconciler has no finalizer timeout.
+func (r *EphemeralRunnerSetReconciler) finalizerTimeoutRemaining(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, now time.Time) (time.Duration, bool) {
+	if r.FinalizerTimeout <= 0 || ephemeralRunnerSet.DeletionTimestamp.IsZero() {
+		return 0, false
+	}
+	return ephemeralRunnerSet.DeletionTimestamp.Add(r.FinalizerTimeout).Sub(now), true
+}
+
+// forceCleanUpEphemeralRunners deletes the ephemeral runners of the EphemeralRunnerSet without removing them from the service,
+// by removing their runner registration finalizer, and deletes the proxy secret.
+// The ephemeral runner controller still cleans up the resources owned by each ephemeral runner.
+func (r *EphemeralRunnerSetReconciler) forceCleanUpEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
+	ephemeralRunnerList := new(v1alpha1.EphemeralRunnerList)
+	err := r.List(ctx, ephemeralRunnerList, client.InNamespace(ephemeralRunnerSet.Namespace), client.MatchingFields{ephemeralRunnerSetReconcilerOwnerKey: ephemeralRunnerSet.Name})
+	if err != nil {
+		return fmt.Errorf("failed to list child ephemeral runners: %v", err)
+	}
+
+	var errs []error
+	for i := range ephemeralRunnerList.Items {
+		ephemeralRunner := &ephemeralRunnerList.Items[i]
+		if controllerutil.ContainsFinalizer(ephemeralRunner, ephemeralRunnerActionsFinalizerName) {
+			log.Info("Removing the runner registration finalizer without removing the runner from the service", "name", ephemeralRunner.Name, "runnerId", ephemeralRunner.Status.RunnerId)
+			if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+				controllerutil.RemoveFinalizer(obj, ephemeralRunnerActionsFinalizerName)
+			}); err != nil {
+				if !kerrors.IsNotFound(err) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+		}
+
+		if ephemeralRunner.DeletionTimestamp.IsZero() {
+			log.Info("Deleting ephemeral runner", "name", ephemeralRunner.Name)
+			if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return multierr.Combine(errs...)
+	}
+
+	return r.cleanUpProxySecret(ctx, ephemeralRunnerSet, log)
+}
+
 // createEphemeralRunners provisions `count` number of v1alpha1.EphemeralRunner resources in the cluster.
-func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet, count int, log logr.Logger) error {
+// The existing ephemeral runners, including the ones being deleted, reserve their names when using the Ordinal naming strategy.
+func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet, count int, existing []v1alpha1.EphemeralRunner, log logr.Logger) error {
+	var ordinals []int
+	if runnerSet.Spec.NamingStrategy == v1alpha1.EphemeralRunnerNamingStrategyOrdinal {
+		ordinals = nextEphemeralRunnerOrdinals(runnerSet.Name, existing, count)
+	}
+
+	if r.ForbidPrivilegedRunners {
+		if containers := privilegedContainers(&runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec.Spec); len(containers) > 0 {
+			log.Info("Privileged mode is forbidden, removing it from the containers of new ephemeral runners", "containers", containers)
+			r.Recorder.Eventf(runnerSet, corev1.EventTypeWarning, "PrivilegedContainersForbidden", "Privileged mode is forbidden and was removed from containers %s of new runners", strings.Join(containers, ", "))
+		}
+	}
+
 	// Track multiple errors at once and return the bundle.
 	errs := make([]error, 0)
 	for i := 0; i < count; i++ {
 		ephemeralRunner := r.resourceBuilder.newEphemeralRunner(runnerSet)
+		if ordinals != nil {
+			ephemeralRunner.GenerateName = ""
+			ephemeralRunner.Name = ordinalEphemeralRunnerName(runnerSet.Name, ordinals[i])
+		}
 		if runnerSet.Spec.EphemeralRunnerSpec.Proxy != nil {
 			ephemeralRunner.Spec.ProxySecretRef = proxyEphemeralRunnerSetSecretName(runnerSet)
 		}
+		r.applyDefaultRunnerResources(ephemeralRunner)
+		r.applyRunnerImagePullPolicy(ephemeralRunner)
+		r.applyDefaultWorkVolume(ephemeralRunner)
+		r.applyDefaultRunnerSecurityContext(ephemeralRunner)
+		if r.ForbidPrivilegedRunners {
+			dropPrivileged(&ephemeralRunner.Spec.PodTemplateSpec.Spec)
+		}
 
 		// Make sure that we own the resource we create.
 		if err := ctrl.SetControllerReference(runnerSet, ephemeralRunner, r.Scheme); err != nil {
@@ -324,7 +1665,13 @@ func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Contex
 
 		log.Info("Creating new ephemeral runner", "progress", i+1, "total", count)
 		if err := r.Create(ctx, ephemeralRunner); err != nil {
+			if ordinals != nil && kerrors.IsAlreadyExists(err) {
+				// The cache is behind, the ephemeral runner is created with the next free ordinal in a subsequent reconcile.
+				log.Info("Ephemeral runner already exists, skipping", "runner", ephemeralRunner.Name)
+				continue
+			}
 			log.Error(err, "failed to make ephemeral runner")
+			metrics.IncRunnerCreateFailures(runnerSet.Namespace, runnerSet.Name, runnerCreateFailureReason(err))
 			errs = append(errs, err)
 			continue
 		}
@@ -335,13 +1682,213 @@ func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Contex
 	return multierr.Combine(errs...)
 }
 
-func (r *EphemeralRunnerSetReconciler) createProxySecret(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
-	proxySecretData, err := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Proxy.ToSecretData(func(s string) (*corev1.Secret, error) {
+// runnerCreateFailureReason classifies the error of the Kubernetes API rejecting the creation of an ephemeral runner
+// or of its pod: exceeding a ResourceQuota of the namespace, being forbidden otherwise, e.g. by RBAC or an admission
+// policy, or any other error.
+func runnerCreateFailureReason(err error) string {
+	switch {
+	case kerrors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota"):
+		return metrics.RunnerCreateFailureQuota
+	case kerrors.IsForbidden(err):
+		return metrics.RunnerCreateFailureForbidden
+	default:
+		return metrics.RunnerCreateFailureOther
+	}
+}
+
+// ordinalEphemeralRunnerName returns the name of the ephemeral runner with the given ordinal when using the Ordinal naming strategy.
+func ordinalEphemeralRunnerName(runnerSetName string, ordinal int) string {
+	return fmt.Sprintf("%s-runner-%d", runnerSetName, ordinal)
+}
+
+// nextEphemeralRunnerOrdinals returns the `count` lowest ordinals not used by the existing ephemeral runners.
+func nextEphemeralRunnerOrdinals(runnerSetName string, existing []v1alpha1.EphemeralRunner, count int) []int {
+	prefix := runnerSetName + "-runner-"
+	used := make(map[int]bool, len(existing))
+	for i := range existing {
+		if !strings.HasPrefix(existing[i].Name, prefix) {
+			continue
+		}
+		suffix := strings.TrimPrefix(existing[i].Name, prefix)
+		ordinal, err := strconv.Atoi(suffix)
+		if err != nil || ordinal < 0 || strconv.Itoa(ordinal) != suffix {
+			continue
+		}
+		used[ordinal] = true
+	}
+
+	ordinals := make([]int, 0, count)
+	for ordinal := 0; len(ordinals) < count; ordinal++ {
+		if !used[ordinal] {
+			ordinals = append(ordinals, ordinal)
+		}
+	}
+	return ordinals
+}
+
+// applyDefaultRunnerResources applies the default runner resources to the runner container of the ephemeral runner.
+func (r *EphemeralRunnerSetReconciler) applyDefaultRunnerResources(ephemeralRunner *v1alpha1.EphemeralRunner) {
+	if len(r.DefaultRunnerResources.Requests) == 0 && len(r.DefaultRunnerResources.Limits) == 0 {
+		return
+	}
+
+	containerName := runnerContainerName(ephemeralRunner)
+	containers := ephemeralRunner.Spec.PodTemplateSpec.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == containerName {
+			containers[i].Resources = withDefaultResources(containers[i].Resources, r.DefaultRunnerResources)
+		}
+	}
+}
+
+// applyDefaultRunnerSecurityContext applies the default security context to the runner container of the ephemeral runner.
+func (r *EphemeralRunnerSetReconciler) applyDefaultRunnerSecurityContext(ephemeralRunner *v1alpha1.EphemeralRunner) {
+	if r.DefaultRunnerSecurityContext == nil {
+		return
+	}
+
+	containerName := runnerContainerName(ephemeralRunner)
+	spec := &ephemeralRunner.Spec.PodTemplateSpec.Spec
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == containerName {
+			spec.Containers[i].SecurityContext = withDefaultSecurityContext(spec.Containers[i].SecurityContext, spec.SecurityContext, r.DefaultRunnerSecurityContext)
+		}
+	}
+}
+
+// privilegedContainers returns the names of the containers and init containers of the pod spec requesting privileged mode.
+func privilegedContainers(spec *corev1.PodSpec) []string {
+	var names []string
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+				names = append(names, container.Name)
+			}
+		}
+	}
+	return names
+}
+
+// dropPrivileged removes privileged mode from the containers and init containers of the pod spec.
+func dropPrivileged(spec *corev1.PodSpec) {
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			if containers[i].SecurityContext != nil && containers[i].SecurityContext.Privileged != nil && *containers[i].SecurityContext.Privileged {
+				privileged := false
+				containers[i].SecurityContext.Privileged = &privileged
+			}
+		}
+	}
+}
+
+// applyRunnerImagePullPolicy sets the image pull policy of the runner container of the ephemeral runner.
+// Other containers are left untouched.
+func (r *EphemeralRunnerSetReconciler) applyRunnerImagePullPolicy(ephemeralRunner *v1alpha1.EphemeralRunner) {
+	if r.RunnerImagePullPolicy == "" {
+		return
+	}
+
+	containerName := runnerContainerName(ephemeralRunner)
+	containers := ephemeralRunner.Spec.PodTemplateSpec.Spec.Containers
+	for i := range containers {
+		if containers[i].Name != containerName {
+			continue
+		}
+		if r.RunnerImagePullPolicyKeepTemplate && containers[i].ImagePullPolicy != "" {
+			continue
+		}
+		containers[i].ImagePullPolicy = r.RunnerImagePullPolicy
+	}
+}
+
+// applyDefaultWorkVolume adds an emptyDir volume mounted at the runner work directory to the runner container
+// of the ephemeral runner, unless something is already mounted at the work directory or one of its parents.
+// A pod template that already has a volume with the same name is left untouched.
+func (r *EphemeralRunnerSetReconciler) applyDefaultWorkVolume(ephemeralRunner *v1alpha1.EphemeralRunner) {
+	if !r.DefaultWorkVolume {
+		return
+	}
+
+	workDir := r.RunnerWorkDir
+	if workDir == "" {
+		workDir = DefaultRunnerWorkDir
+	}
+	if ephemeralRunner.Spec.OS == v1alpha1.EphemeralRunnerOSWindows {
+		workDir = r.WindowsRunnerWorkDir
+		if workDir == "" {
+			workDir = DefaultWindowsRunnerWorkDir
+		}
+	}
+
+	spec := &ephemeralRunner.Spec.PodTemplateSpec.Spec
+	for _, volume := range spec.Volumes {
+		if volume.Name == defaultWorkVolumeName {
+			return
+		}
+	}
+
+	containerName := runnerContainerName(ephemeralRunner)
+	for i := range spec.Containers {
+		if spec.Containers[i].Name != containerName {
+			continue
+		}
+		if hasVolumeMountAt(spec.Containers[i].VolumeMounts, workDir) {
+			return
+		}
+
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, corev1.VolumeMount{
+			Name:      defaultWorkVolumeName,
+			MountPath: workDir,
+		})
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: defaultWorkVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{
+					SizeLimit: r.DefaultWorkVolumeSizeLimit,
+				},
+			},
+		})
+		return
+	}
+}
+
+// hasVolumeMountAt reports whether one of the volume mounts is mounted at the directory or one of its parents.
+// Windows paths are compared case-insensitively, with either path separator.
+func hasVolumeMountAt(volumeMounts []corev1.VolumeMount, dir string) bool {
+	dir = cleanMountPath(dir)
+	for _, volumeMount := range volumeMounts {
+		mountPath := cleanMountPath(volumeMount.MountPath)
+		if mountPath == "/" || strings.HasSuffix(mountPath, ":") {
+			continue
+		}
+		if dir == mountPath || strings.HasPrefix(dir, mountPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanMountPath cleans the mount path, converting Windows paths such as C:\work to lower case c:/work.
+func cleanMountPath(mountPath string) string {
+	if strings.Contains(mountPath, `\`) || (len(mountPath) >= 2 && mountPath[1] == ':') {
+		mountPath = strings.ToLower(strings.ReplaceAll(mountPath, `\`, "/"))
+	}
+	return path.Clean(mountPath)
+}
+
+// secretFetcher returns a function getting secrets by name from the namespace.
+func (r *EphemeralRunnerSetReconciler) secretFetcher(ctx context.Context, namespace string) func(string) (*corev1.Secret, error) {
+	return func(name string) (*corev1.Secret, error) {
 		secret := new(corev1.Secret)
-		err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunnerSet.Namespace, Name: s}, secret)
+		err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret)
 		return secret, err
-	})
+	}
+}
+
+func (r *EphemeralRunnerSetReconciler) createProxySecret(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
+	proxySecretData, err := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Proxy.ToSecretData(r.secretFetcher(ctx, ephemeralRunnerSet.Namespace))
 	if err != nil {
+		metrics.IncProxySecretErrors(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, metrics.ProxySecretErrorSecretData)
 		return fmt.Errorf("failed to convert proxy config to secret data: %w", err)
 	}
 
@@ -349,10 +1896,11 @@ func (r *EphemeralRunnerSetReconciler) createProxySecret(ctx context.Context, ep
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      proxyEphemeralRunnerSetSecretName(ephemeralRunnerSet),
 			Namespace: ephemeralRunnerSet.Namespace,
-			Labels:    map[string]string{
-				// TODO: figure out autoScalingRunnerSet name and set it as a label for this secret
-				// "auto-scaling-runner-set-namespace": ephemeralRunnerSet.Namespace,
-				// "auto-scaling-runner-set-name": ephemeralRunnerSet.Name,
+			Labels: map[string]string{
+				LabelKeyManagedProxySecret: "true",
+			},
+			Annotations: map[string]string{
+				AnnotationKeyEphemeralRunnerSetName: ephemeralRunnerSet.Name,
 			},
 		},
 		Data: proxySecretData,
@@ -366,6 +1914,7 @@ func (r *EphemeralRunnerSetReconciler) createProxySecret(ctx context.Context, ep
 
 	log.Info("Creating new proxy secret")
 	if err := r.Create(ctx, runnerPodProxySecret); err != nil {
+		metrics.IncProxySecretErrors(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, metrics.ProxySecretErrorCreate)
 		log.Error(err, "failed to create proxy secret")
 		return err
 	}
@@ -374,6 +1923,126 @@ func (r *EphemeralRunnerSetReconciler) createProxySecret(ctx context.Context, ep
 	return nil
 }
 
+// updateProxySecret updates the proxy secret when the data built from the proxy configuration changed,
+// e.g. because the proxy credentials were rotated. Running ephemeral runners keep the values they were started with,
+// the new values are used by the runners created afterwards.
+func (r *EphemeralRunnerSetReconciler) updateProxySecret(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, proxySecret *corev1.Secret, log logr.Logger) error {
+	proxySecretData, err := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Proxy.ToSecretData(r.secretFetcher(ctx, ephemeralRunnerSet.Namespace))
+	if err != nil {
+		metrics.IncProxySecretErrors(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, metrics.ProxySecretErrorSecretData)
+		return fmt.Errorf("failed to convert proxy config to secret data: %w", err)
+	}
+
+	if reflect.DeepEqual(proxySecret.Data, proxySecretData) {
+		return nil
+	}
+
+	log.Info("Updating proxy secret with the current proxy configuration")
+	if err := patch(ctx, r.Client, proxySecret, func(obj *corev1.Secret) {
+		obj.Data = proxySecretData
+	}); err != nil {
+		metrics.IncProxySecretErrors(ephemeralRunnerSet.Namespace, ephemeralRunnerSet.Name, metrics.ProxySecretErrorUpdate)
+		return fmt.Errorf("failed to update proxy secret: %w", err)
+	}
+
+	log.Info("Updated proxy secret")
+	return nil
+}
+
+// proxySecretRefs returns the names of the secrets referenced by the proxy configuration of the EphemeralRunnerSet.
+func proxySecretRefs(rawObj client.Object) []string {
+	ephemeralRunnerSet := rawObj.(*v1alpha1.EphemeralRunnerSet)
+	proxy := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Proxy
+	if proxy == nil {
+		return nil
+	}
+
+	refs := []string{proxy.CACertificateSecretRef}
+	for _, server := range []*v1alpha1.ProxyServerConfig{proxy.HTTP, proxy.HTTPS} {
+		if server != nil {
+			refs = append(refs, server.CredentialSecretRef)
+		}
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		names = append(names, ref)
+	}
+	return names
+}
+
+// proxySecretRequests maps a secret to the EphemeralRunnerSets referencing it in their proxy configuration,
+// so their proxy secret is updated when the referenced secret changes.
+func (r *EphemeralRunnerSetReconciler) proxySecretRequests(o client.Object) []reconcile.Request {
+	ephemeralRunnerSets := new(v1alpha1.EphemeralRunnerSetList)
+	if err := r.List(
+		context.Background(),
+		ephemeralRunnerSets,
+		client.InNamespace(o.GetNamespace()),
+		client.MatchingFields{ephemeralRunnerSetProxySecretsKey: o.GetName()},
+	); err != nil {
+		r.Log.Error(err, "Unable to list EphemeralRunnerSets referencing the secret", "namespace", o.GetNamespace(), "secret", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range ephemeralRunnerSets.Items {
+		if !r.selects(&ephemeralRunnerSets.Items[i]) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&ephemeralRunnerSets.Items[i]),
+		})
+	}
+	return requests
+}
+
+// sweepOrphanedProxySecrets deletes the proxy secrets created for EphemeralRunnerSet resources that no longer exist.
+// These are normally garbage collected through their owner reference, this catches the ones left behind otherwise.
+// Secrets without the LabelKeyManagedProxySecret label are never deleted.
+func (r *EphemeralRunnerSetReconciler) sweepOrphanedProxySecrets(ctx context.Context) error {
+	log := r.Log.WithName("proxysecretsweep")
+
+	secrets := new(corev1.SecretList)
+	if err := r.List(ctx, secrets, client.MatchingLabels{LabelKeyManagedProxySecret: "true"}); err != nil {
+		return fmt.Errorf("failed to list proxy secrets: %v", err)
+	}
+
+	var errs []error
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !secret.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		name := secret.Annotations[AnnotationKeyEphemeralRunnerSetName]
+		if name == "" {
+			continue
+		}
+
+		err := r.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: name}, new(v1alpha1.EphemeralRunnerSet))
+		if err == nil {
+			continue
+		}
+		if !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to get ephemeral runner set %s/%s: %v", secret.Namespace, name, err))
+			continue
+		}
+
+		log.Info("Deleting orphaned proxy secret", "namespace", secret.Namespace, "name", secret.Name, "ephemeralRunnerSet", name)
+		if err := r.Delete(ctx, secret); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete orphaned proxy secret %s/%s: %v", secret.Namespace, secret.Name, err))
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
 // deleteIdleEphemeralRunners try to deletes `count` number of v1alpha1.EphemeralRunner resources in the cluster.
 // It will only delete `v1alpha1.EphemeralRunner` that has registered with Actions service
 // which has a `v1alpha1.EphemeralRunner.Status.RunnerId` set.
@@ -381,17 +2050,26 @@ func (r *EphemeralRunnerSetReconciler) createProxySecret(ctx context.Context, ep
 // if there are not enough ephemeral runners that have registered with Actions service.
 // When this happens, the next reconcile loop will try to delete the remaining ephemeral runners
 // after we get notified by any of the `v1alpha1.EphemeralRunner.Status` updates.
-func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners []*v1alpha1.EphemeralRunner, count int, log logr.Logger) error {
-	runners := newEphemeralRunnerStepper(pendingEphemeralRunners, runningEphemeralRunners)
+// It returns the number of deleted ephemeral runners. In dry run mode, nothing is deleted
+// and the number of ephemeral runners that would have been deleted is returned.
+// Runners are never deleted before they are removed from the service: when the deregistration limiter
+// has no token left, the remaining runners are kept and it also returns how long to wait before trying again.
+func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners []*v1alpha1.EphemeralRunner, count int, log logr.Logger) (int, time.Duration, error) {
+	runners := newEphemeralRunnerStepper(ephemeralRunnerSet.Spec.ScaleDownPolicy, pendingEphemeralRunners, runningEphemeralRunners)
 	if runners.len() == 0 {
 		log.Info("No pending or running ephemeral runners running at this time for scale down")
-		return nil
+		return 0, 0, nil
 	}
-	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunnerSet)
-	if err != nil {
-		return fmt.Errorf("failed to create actions client for ephemeral runner replica set: %v", err)
+	var actionsClient actions.ActionsService
+	if !r.DryRun {
+		var err error
+		actionsClient, err = r.actionsClientFor(ctx, ephemeralRunnerSet)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to create actions client for ephemeral runner replica set: %v", err)
+		}
 	}
 	var errs []error
+	var deferred time.Duration
 	deletedCount := 0
 	for runners.next() {
 		ephemeralRunner := runners.object()
@@ -405,6 +2083,26 @@ func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Co
 			continue
 		}
 
+		if noScaleDown(ephemeralRunner) {
+			log.Info("Skipping ephemeral runner since it is annotated to not be scaled down", "name", ephemeralRunner.Name, "annotation", AnnotationKeyNoScaleDown)
+			continue
+		}
+
+		if r.DryRun {
+			log.Info("Dry run: skipping removal of the idle ephemeral runner", "name", ephemeralRunner.Name)
+			deletedCount++
+			if deletedCount == count {
+				break
+			}
+			continue
+		}
+
+		if delay := r.DeregistrationLimiter.Reserve(ephemeralRunnerSet.Spec.EphemeralRunnerSpec.RunnerScaleSetId, time.Now()); delay > 0 {
+			log.Info("Deferring the removal of idle ephemeral runners by the deregistration rate limit", "name", ephemeralRunner.Name, "delay", delay)
+			deferred = delay
+			break
+		}
+
 		log.Info("Removing the idle ephemeral runner", "name", ephemeralRunner.Name)
 		ok, err := r.deleteEphemeralRunnerWithActionsClient(ctx, ephemeralRunner, actionsClient, log)
 		if err != nil {
@@ -420,7 +2118,49 @@ func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Co
 		}
 	}
 
-	return multierr.Combine(errs...)
+	return deletedCount, deferred, multierr.Combine(errs...)
+}
+
+// replaceOutdatedEphemeralRunners deletes idle ephemeral runners created from an outdated ephemeral runner spec,
+// so the next reconcile loop re-creates them from the current spec.
+// Deleting runners and pending runners created from the current spec count as unavailable,
+// so at most `MaxUnavailable` runners are being replaced at the same time.
+// It returns the number of deleted ephemeral runners, and how long to wait when the deregistration limit deferred some.
+func (r *EphemeralRunnerSetReconciler) replaceOutdatedEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners []*v1alpha1.EphemeralRunner, deleting int, log logr.Logger) (int, time.Duration, error) {
+	specHash := ephemeralRunnerSet.EphemeralRunnerSpecHash()
+
+	unavailable := deleting
+	var outdatedPending, outdatedRunning []*v1alpha1.EphemeralRunner
+	for _, ephemeralRunner := range pendingEphemeralRunners {
+		if ephemeralRunner.Annotations[AnnotationKeyRunnerSpecHash] == specHash {
+			unavailable++
+			continue
+		}
+		outdatedPending = append(outdatedPending, ephemeralRunner)
+	}
+	for _, ephemeralRunner := range runningEphemeralRunners {
+		if ephemeralRunner.Annotations[AnnotationKeyRunnerSpecHash] != specHash {
+			outdatedRunning = append(outdatedRunning, ephemeralRunner)
+		}
+	}
+
+	if len(outdatedPending)+len(outdatedRunning) == 0 {
+		return 0, 0, nil
+	}
+
+	maxUnavailable := ephemeralRunnerSet.Spec.MaxUnavailable
+	if maxUnavailable <= 0 {
+		maxUnavailable = 1
+	}
+
+	count := maxUnavailable - unavailable
+	if count <= 0 {
+		log.Info("Waiting for unavailable ephemeral runners before replacing outdated ones", "unavailable", unavailable, "maxUnavailable", maxUnavailable)
+		return 0, 0, nil
+	}
+
+	log.Info("Replacing idle ephemeral runners created from an outdated spec", "outdated", len(outdatedPending)+len(outdatedRunning), "count", count)
+	return r.deleteIdleEphemeralRunners(ctx, ephemeralRunnerSet, outdatedPending, outdatedRunning, count, log)
 }
 
 func (r *EphemeralRunnerSetReconciler) deleteEphemeralRunnerWithActionsClient(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, actionsClient actions.ActionsService, log logr.Logger) (bool, error) {
@@ -452,7 +2192,7 @@ func (r *EphemeralRunnerSetReconciler) actionsClientFor(ctx context.Context, rs
 	}
 	var opts []actions.ClientOption
 	if rs.Spec.EphemeralRunnerSpec.Proxy != nil {
-		proxyFunc, err := rs.Spec.EphemeralRunnerSpec.Proxy.ProxyFunc(func(s string) (*corev1.Secret, error) {
+		secretFetcher := func(s string) (*corev1.Secret, error) {
 			var secret corev1.Secret
 			err := r.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: s}, &secret)
 			if err != nil {
@@ -460,52 +2200,101 @@ func (r *EphemeralRunnerSetReconciler) actionsClientFor(ctx context.Context, rs
 			}
 
 			return &secret, nil
-		})
+		}
+
+		proxyFunc, err := rs.Spec.EphemeralRunnerSpec.Proxy.ProxyFunc(secretFetcher)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get proxy func: %w", err)
 		}
 
 		opts = append(opts, actions.WithProxy(proxyFunc))
+
+		caCertificate, err := rs.Spec.EphemeralRunnerSpec.Proxy.CACertificate(secretFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get proxy ca certificate: %w", err)
+		}
+
+		if caCertificate != nil {
+			opts = append(opts, actions.WithProxyCACertificate(caCertificate))
+		}
 	}
 
 	return r.ActionsClient.GetClientFromSecret(
 		ctx,
 		rs.Spec.EphemeralRunnerSpec.GitHubConfigUrl,
 		rs.Namespace,
-		secret.Data,
+		actions.KubernetesSecret{
+			Name:            secret.Name,
+			ResourceVersion: secret.ResourceVersion,
+			Data:            secret.Data,
+		},
 		opts...,
 	)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *EphemeralRunnerSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Recorder = mgr.GetEventRecorderFor("ephemeral-runner-set-controller")
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+
 	// Index EphemeralRunner owned by EphemeralRunnerSet so we can perform faster look ups.
-	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.EphemeralRunner{}, ephemeralRunnerSetReconcilerOwnerKey, func(rawObj client.Object) []string {
-		groupVersion := v1alpha1.GroupVersion.String()
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.EphemeralRunner{}, ephemeralRunnerSetReconcilerOwnerKey, ephemeralRunnerSetOwner); err != nil {
+		return err
+	}
 
-		// grab the job object, extract the owner...
-		ephemeralRunner := rawObj.(*v1alpha1.EphemeralRunner)
-		owner := metav1.GetControllerOf(ephemeralRunner)
-		if owner == nil {
-			return nil
-		}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.EphemeralRunnerSet{}, ephemeralRunnerSetProxySecretsKey, proxySecretRefs); err != nil {
+		return err
+	}
 
-		// ...make sure it is owned by this controller
-		if owner.APIVersion != groupVersion || owner.Kind != "EphemeralRunnerSet" {
+	if r.OrphanedProxySecretSweepInterval > 0 {
+		// The sweep only runs on the leader, like the reconcilers.
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				if err := r.sweepOrphanedProxySecrets(ctx); err != nil {
+					r.Log.Error(err, "Failed to sweep orphaned proxy secrets")
+				}
+			}, r.OrphanedProxySecretSweepInterval)
 			return nil
+		})); err != nil {
+			return err
 		}
-
-		// ...and if so, return it
-		return []string{owner.Name}
-	}); err != nil {
-		return err
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.EphemeralRunnerSet{}).
+		For(&v1alpha1.EphemeralRunnerSet{}, builder.WithPredicates(predicate.NewPredicateFuncs(r.selects))).
 		Owns(&v1alpha1.EphemeralRunner{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.proxySecretRequests)).
 		WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(instrumentReconciler("ephemeralrunnerset", r))
+}
+
+// ephemeralRunnerSetOwner indexes the ephemeral runners by the name of the EphemeralRunnerSet owning them.
+func ephemeralRunnerSetOwner(rawObj client.Object) []string {
+	groupVersion := v1alpha1.GroupVersion.String()
+
+	// grab the job object, extract the owner...
+	ephemeralRunner := rawObj.(*v1alpha1.EphemeralRunner)
+	owner := metav1.GetControllerOf(ephemeralRunner)
+	if owner == nil {
+		return nil
+	}
+
+	// ...make sure it is owned by this controller
+	if owner.APIVersion != groupVersion || owner.Kind != "EphemeralRunnerSet" {
+		return nil
+	}
+
+	// ...and if so, return it
+	return []string{owner.Name}
+}
+
+// selects reports whether the EphemeralRunnerSet is managed by this reconciler according to its Selector.
+func (r *EphemeralRunnerSetReconciler) selects(obj client.Object) bool {
+	return r.Selector == nil || r.Selector.Matches(labels.Set(obj.GetLabels()))
 }
 
 type ephemeralRunnerStepper struct {
@@ -513,13 +2302,21 @@ type ephemeralRunnerStepper struct {
 	index int
 }
 
-func newEphemeralRunnerStepper(pending, running []*v1alpha1.EphemeralRunner) *ephemeralRunnerStepper {
-	sort.Slice(pending, func(i, j int) bool {
-		return pending[i].GetCreationTimestamp().Time.Before(pending[j].GetCreationTimestamp().Time)
-	})
-	sort.Slice(running, func(i, j int) bool {
-		return running[i].GetCreationTimestamp().Time.Before(running[j].GetCreationTimestamp().Time)
-	})
+// newEphemeralRunnerStepper returns a stepper over the pending runners followed by the running runners.
+// Within each group, runners are ordered by creation timestamp according to the scale down policy.
+func newEphemeralRunnerStepper(policy v1alpha1.ScaleDownPolicy, pending, running []*v1alpha1.EphemeralRunner) *ephemeralRunnerStepper {
+	byCreationTimestamp := func(runners []*v1alpha1.EphemeralRunner) func(i, j int) bool {
+		return func(i, j int) bool {
+			iTime := runners[i].GetCreationTimestamp().Time
+			jTime := runners[j].GetCreationTimestamp().Time
+			if policy == v1alpha1.ScaleDownPolicyNewestFirst {
+				return iTime.After(jTime)
+			}
+			return iTime.Before(jTime)
+		}
+	}
+	sort.SliceStable(pending, byCreationTimestamp(pending))
+	sort.SliceStable(running, byCreationTimestamp(running))
 
 	return &ephemeralRunnerStepper{
 		items: append(pending, running...),
@@ -571,3 +2368,35 @@ func categorizeEphemeralRunners(ephemeralRunnerList *v1alpha1.EphemeralRunnerLis
 	}
 	return
 }
+
+// oldestRunningJobAssignedAt returns when the oldest job running on the ephemeral runners was assigned.
+// Ephemeral runners without a valid job assignment time are ignored. It returns nil when no job is running.
+func oldestRunningJobAssignedAt(runningEphemeralRunners []*v1alpha1.EphemeralRunner) *metav1.Time {
+	var oldest *metav1.Time
+	for _, r := range runningEphemeralRunners {
+		if r.Status.JobRequestId == 0 {
+			continue
+		}
+		assignedAt, err := time.Parse(time.RFC3339, r.Annotations[v1alpha1.AnnotationKeyJobAssignedAt])
+		if err != nil {
+			continue
+		}
+		if oldest == nil || assignedAt.Before(oldest.Time) {
+			oldest = &metav1.Time{Time: assignedAt}
+		}
+	}
+	return oldest
+}
+
+// countIdleAndBusyEphemeralRunners returns the number of running ephemeral runners
+// that are waiting for a job and the number of those that are assigned to a job.
+func countIdleAndBusyEphemeralRunners(runningEphemeralRunners []*v1alpha1.EphemeralRunner) (idle, busy int) {
+	for _, r := range runningEphemeralRunners {
+		if r.Status.JobRequestId > 0 {
+			busy++
+			continue
+		}
+		idle++
+	}
+	return
+}
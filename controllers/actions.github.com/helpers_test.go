@@ -2,14 +2,21 @@ package actionsgithubcom
 
 import (
 	"context"
+	"net/http"
+	"testing"
 
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/actions/actions-runner-controller/github/actions/fake"
 	"github.com/onsi/ginkgo/v2"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
@@ -69,3 +76,60 @@ func createDefaultSecret(t ginkgo.GinkgoTInterface, client client.Client, namesp
 
 	return secret
 }
+
+// runnerNotFoundError is the error returned by the service for runners that are not registered.
+var runnerNotFoundError = &actions.ActionsError{StatusCode: http.StatusNotFound, ExceptionName: "AgentNotFoundException"}
+
+// newTestScheme returns a scheme with the core and actions.github.com types,
+// and the types of addToScheme.
+func newTestScheme(t *testing.T, addToScheme ...func(*runtime.Scheme) error) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	for _, add := range addToScheme {
+		require.NoError(t, add(scheme))
+	}
+
+	return scheme
+}
+
+// newFakeClient returns a fake client of scheme holding objs.
+func newFakeClient(scheme *runtime.Scheme, objs ...client.Object) client.Client {
+	return clientfake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+// newGetRunnerMultiClient returns a multi client whose clients return runner and err from GetRunner.
+func newGetRunnerMultiClient(runner *actions.RunnerReference, err error) actions.MultiClient {
+	return fake.NewMultiClient(fake.WithDefaultClient(fake.NewFakeClient(fake.WithGetRunner(runner, err)), nil))
+}
+
+// newTestConfigSecret returns a GitHub config secret in the default namespace.
+func newTestConfigSecret(name string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(defaultGitHubToken)},
+	}
+}
+
+// newTestEphemeralRunner returns an ephemeral runner in the default namespace with the given runner
+// and job request ids. A zero runnerId means the runner is not registered yet, and a zero
+// jobRequestId means the runner is idle.
+func newTestEphemeralRunner(name string, runnerId int, jobRequestId int64) *v1alpha1.EphemeralRunner {
+	return &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status: v1alpha1.EphemeralRunnerStatus{
+			RunnerId:     runnerId,
+			JobRequestId: jobRequestId,
+		},
+	}
+}
+
+// newRegisteredExampleRunner returns the example runner with a UID, registered with runnerId
+// and assigned jobRequestId.
+func newRegisteredExampleRunner(configSecretName string, runnerId int, jobRequestId int64) *v1alpha1.EphemeralRunner {
+	runner := newExampleRunner("test-runner", "default", configSecretName)
+	runner.UID = "runner-uid"
+	runner.Status.RunnerId = runnerId
+	runner.Status.JobRequestId = jobRequestId
+	return runner
+}
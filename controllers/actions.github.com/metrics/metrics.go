@@ -0,0 +1,265 @@
+// Package metrics provides the metrics of the actions.github.com custom resources.
+//
+// This depends on the metrics exporter of kubebuilder.
+// See https://book.kubebuilder.io/reference/metrics.html for details.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	labelKeyNamespace          = "namespace"
+	labelKeyEphemeralRunnerSet = "ephemeral_runner_set"
+	labelKeyReason             = "reason"
+	labelKeyRunnerScaleSetID   = "runner_scale_set_id"
+	labelKeyPhase              = "phase"
+	labelKeyAction             = "action"
+	labelKeyController         = "controller"
+	labelKeyNodePool           = "node_pool"
+	labelKeyImage              = "image"
+)
+
+// Phases reported by the arc_ephemeral_runners gauge.
+const (
+	PhasePending   = "pending"
+	PhaseRunning   = "running"
+	PhaseSucceeded = "succeeded"
+	PhaseFailed    = "failed"
+)
+
+// Actions reported by the arc_ephemeral_runner_changes gauge.
+const (
+	ActionCreate = "create"
+	ActionDelete = "delete"
+)
+
+// Reasons reported by the arc_proxy_secret_errors_total counter.
+const (
+	ProxySecretErrorInvalidConfig = "InvalidProxyConfig"
+	ProxySecretErrorSecretData    = "SecretDataFailed"
+	ProxySecretErrorCreate        = "CreateFailed"
+	ProxySecretErrorUpdate        = "UpdateFailed"
+)
+
+// Reasons reported by the arc_runner_create_failures_total counter.
+const (
+	RunnerCreateFailureQuota     = "quota"
+	RunnerCreateFailureForbidden = "forbidden"
+	RunnerCreateFailureOther     = "other"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		ephemeralRunnerRecycledTotal,
+		ephemeralRunners,
+		ephemeralRunnerChanges,
+		proxySecretErrorsTotal,
+		reconcileDurationSeconds,
+		reconcileErrorsTotal,
+		runnerScheduleSeconds,
+		runnerCreateFailuresTotal,
+		runnerImageInfo,
+		runnerBusySeconds,
+	)
+}
+
+var ephemeralRunnerRecycledTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "arc_ephemeral_runner_recycled_total",
+		Help: "Number of idle ephemeral runners deleted by the controller to be re-created by their EphemeralRunnerSet.",
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet, labelKeyReason},
+)
+
+// IncEphemeralRunnerRecycled increments the number of ephemeral runners recycled for the given reason.
+func IncEphemeralRunnerRecycled(namespace, ephemeralRunnerSet, reason string) {
+	ephemeralRunnerRecycledTotal.With(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+		labelKeyReason:             reason,
+	}).Inc()
+}
+
+var ephemeralRunners = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_ephemeral_runners",
+		Help: "Number of ephemeral runners per phase, as observed by the EphemeralRunnerSet controller.",
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet, labelKeyRunnerScaleSetID, labelKeyPhase},
+)
+
+// SetEphemeralRunners sets the number of ephemeral runners of the runner set in the given phase.
+func SetEphemeralRunners(namespace, ephemeralRunnerSet string, runnerScaleSetID int, phase string, count int) {
+	ephemeralRunners.With(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+		labelKeyRunnerScaleSetID:   strconv.Itoa(runnerScaleSetID),
+		labelKeyPhase:              phase,
+	}).Set(float64(count))
+}
+
+var ephemeralRunnerChanges = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_ephemeral_runner_changes",
+		Help: "Number of ephemeral runners the EphemeralRunnerSet controller decided to create or delete in its last reconcile. In dry run mode, these changes are not applied.",
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet, labelKeyAction},
+)
+
+// SetEphemeralRunnerChanges sets the number of ephemeral runners of the runner set the last reconcile decided to create or delete.
+func SetEphemeralRunnerChanges(namespace, ephemeralRunnerSet, action string, count int) {
+	ephemeralRunnerChanges.With(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+		labelKeyAction:             action,
+	}).Set(float64(count))
+}
+
+// DeleteEphemeralRunners removes all ephemeral runner series of the runner set, including their scheduling latency.
+func DeleteEphemeralRunners(namespace, ephemeralRunnerSet string) {
+	labels := prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+	}
+	ephemeralRunners.DeletePartialMatch(labels)
+	ephemeralRunnerChanges.DeletePartialMatch(labels)
+	runnerScheduleSeconds.DeletePartialMatch(labels)
+	runnerCreateFailuresTotal.DeletePartialMatch(labels)
+	runnerImageInfo.DeletePartialMatch(labels)
+	runnerBusySeconds.DeletePartialMatch(labels)
+}
+
+var runnerScheduleSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "arc_runner_schedule_seconds",
+		Help:    "Time between the creation of a runner pod and it being scheduled to a node.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600, 1200},
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet, labelKeyNodePool},
+)
+
+// ObserveRunnerSchedule records how long a runner pod of the runner set waited to be scheduled.
+// The node pool is the value of a node label chosen by the operator, so the cardinality stays bounded.
+// It is empty when no node label is configured.
+func ObserveRunnerSchedule(namespace, ephemeralRunnerSet, nodePool string, duration time.Duration) {
+	runnerScheduleSeconds.With(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+		labelKeyNodePool:           nodePool,
+	}).Observe(duration.Seconds())
+}
+
+var runnerBusySeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "arc_runner_busy_seconds",
+		Help:    "Time between a job being assigned to a runner and the runner completing it.",
+		Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600},
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet},
+)
+
+// ObserveRunnerBusy records how long a runner of the runner set was busy with a job.
+func ObserveRunnerBusy(namespace, ephemeralRunnerSet string, duration time.Duration) {
+	runnerBusySeconds.With(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+	}).Observe(duration.Seconds())
+}
+
+var runnerCreateFailuresTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "arc_runner_create_failures_total",
+		Help: "Number of failures to create the ephemeral runners of an EphemeralRunnerSet or their pods, by the reason the Kubernetes API rejected them.",
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet, labelKeyReason},
+)
+
+// IncRunnerCreateFailures increments the number of ephemeral runners or runner pods of the runner set
+// that failed to be created for the given reason.
+func IncRunnerCreateFailures(namespace, ephemeralRunnerSet, reason string) {
+	runnerCreateFailuresTotal.With(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+		labelKeyReason:             reason,
+	}).Inc()
+}
+
+var runnerImageInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_runner_image_info",
+		Help: "Image of the runner container of an EphemeralRunnerSet, always 1.",
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet, labelKeyImage},
+)
+
+// SetRunnerImage reports the image of the runner container of the runner set, replacing the image reported before.
+// Nothing is reported when the image is empty.
+func SetRunnerImage(namespace, ephemeralRunnerSet, image string) {
+	labels := prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+	}
+	runnerImageInfo.DeletePartialMatch(labels)
+	if image == "" {
+		return
+	}
+
+	labels[labelKeyImage] = image
+	runnerImageInfo.With(labels).Set(1)
+}
+
+var proxySecretErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "arc_proxy_secret_errors_total",
+		Help: "Number of failures to build, create or update the proxy secret of the runner pods of an EphemeralRunnerSet.",
+	},
+	[]string{labelKeyNamespace, labelKeyEphemeralRunnerSet, labelKeyReason},
+)
+
+// IncProxySecretErrors increments the number of proxy secret failures of the runner set for the given reason.
+func IncProxySecretErrors(namespace, ephemeralRunnerSet, reason string) {
+	proxySecretErrorsTotal.With(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+		labelKeyReason:             reason,
+	}).Inc()
+}
+
+// DeleteProxySecretErrors removes all proxy secret error series of the runner set.
+func DeleteProxySecretErrors(namespace, ephemeralRunnerSet string) {
+	proxySecretErrorsTotal.DeletePartialMatch(prometheus.Labels{
+		labelKeyNamespace:          namespace,
+		labelKeyEphemeralRunnerSet: ephemeralRunnerSet,
+	})
+}
+
+var reconcileDurationSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "arc_reconcile_duration_seconds",
+		Help:    "Duration of the reconciles of the controller.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	},
+	[]string{labelKeyController},
+)
+
+var reconcileErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "arc_reconcile_errors_total",
+		Help: "Number of reconciles of the controller that returned an error.",
+	},
+	[]string{labelKeyController},
+)
+
+// ObserveReconcile records the duration of a reconcile of the controller, and whether it failed.
+func ObserveReconcile(controller string, duration time.Duration, err error) {
+	labels := prometheus.Labels{labelKeyController: controller}
+	reconcileDurationSeconds.With(labels).Observe(duration.Seconds())
+	if err != nil {
+		reconcileErrorsTotal.With(labels).Inc()
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxySecretErrors(t *testing.T) {
+	IncProxySecretErrors("default", "set-a", ProxySecretErrorInvalidConfig)
+	IncProxySecretErrors("default", "set-a", ProxySecretErrorInvalidConfig)
+	IncProxySecretErrors("default", "set-a", ProxySecretErrorCreate)
+	IncProxySecretErrors("default", "set-b", ProxySecretErrorSecretData)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(proxySecretErrorsTotal.WithLabelValues("default", "set-a", ProxySecretErrorInvalidConfig)))
+	assert.Equal(t, 3, testutil.CollectAndCount(proxySecretErrorsTotal))
+
+	DeleteProxySecretErrors("default", "set-a")
+	assert.Equal(t, 1, testutil.CollectAndCount(proxySecretErrorsTotal), "only the series of the deleted runner set should be removed")
+}
+
+func TestRunnerCreateFailures(t *testing.T) {
+	IncRunnerCreateFailures("default", "set-a", RunnerCreateFailureQuota)
+	IncRunnerCreateFailures("default", "set-a", RunnerCreateFailureQuota)
+	IncRunnerCreateFailures("default", "set-b", RunnerCreateFailureForbidden)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(runnerCreateFailuresTotal.WithLabelValues("default", "set-a", RunnerCreateFailureQuota)))
+	assert.Equal(t, 2, testutil.CollectAndCount(runnerCreateFailuresTotal))
+
+	DeleteEphemeralRunners("default", "set-a")
+	assert.Equal(t, 1, testutil.CollectAndCount(runnerCreateFailuresTotal), "only the series of the deleted runner set should be removed")
+}
+
+func TestSetRunnerImage(t *testing.T) {
+	SetRunnerImage("default", "set-a", "runner:1")
+	SetRunnerImage("default", "set-b", "runner:1")
+	SetRunnerImage("default", "set-a", "runner:2")
+
+	assert.Equal(t, 2, testutil.CollectAndCount(runnerImageInfo), "the previous image of the runner set should be replaced")
+	assert.Equal(t, float64(1), testutil.ToFloat64(runnerImageInfo.WithLabelValues("default", "set-a", "runner:2")))
+
+	SetRunnerImage("default", "set-b", "")
+	assert.Equal(t, 1, testutil.CollectAndCount(runnerImageInfo), "an empty image should not be reported")
+
+	DeleteEphemeralRunners("default", "set-a")
+	assert.Equal(t, 0, testutil.CollectAndCount(runnerImageInfo))
+}
+
+func TestObserveReconcile(t *testing.T) {
+	ObserveReconcile("ephemeralrunnerset", 10*time.Millisecond, nil)
+	ObserveReconcile("ephemeralrunnerset", 20*time.Millisecond, errors.New("conflict"))
+	ObserveReconcile("ephemeralrunner", 30*time.Millisecond, nil)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(reconcileDurationSeconds))
+	assert.Equal(t, float64(1), testutil.ToFloat64(reconcileErrorsTotal.WithLabelValues("ephemeralrunnerset")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(reconcileErrorsTotal.WithLabelValues("ephemeralrunner")))
+}
+
+func TestObserveRunnerSchedule(t *testing.T) {
+	ObserveRunnerSchedule("default", "set-a", "", 3*time.Second)
+	ObserveRunnerSchedule("default", "set-a", "", 5*time.Second)
+	ObserveRunnerSchedule("default", "set-b", "gpu", time.Minute)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(runnerScheduleSeconds))
+
+	DeleteEphemeralRunners("default", "set-a")
+	assert.Equal(t, 1, testutil.CollectAndCount(runnerScheduleSeconds), "only the series of the deleted runner set should be removed")
+}
+
+func TestObserveRunnerBusy(t *testing.T) {
+	ObserveRunnerBusy("default", "set-a", 90*time.Second)
+	ObserveRunnerBusy("default", "set-a", 20*time.Minute)
+	ObserveRunnerBusy("default", "set-b", time.Minute)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(runnerBusySeconds))
+
+	DeleteEphemeralRunners("default", "set-a")
+	assert.Equal(t, 1, testutil.CollectAndCount(runnerBusySeconds), "only the series of the deleted runner set should be removed")
+}
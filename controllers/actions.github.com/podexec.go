@@ -0,0 +1,194 @@
+package actionsgithubcom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/websocket"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// execProtocol is the streaming protocol used to exec commands in containers over websockets.
+	// Each frame is prefixed with the channel it belongs to.
+	execProtocol = "v4.channel.k8s.io"
+
+	execStdoutChannel = 1
+	execStderrChannel = 2
+	execErrorChannel  = 3
+
+	// maxExecStderrLength is the maximum number of bytes of stderr reported when a command fails.
+	maxExecStderrLength = 1024
+)
+
+// PodCommandExecutor runs a command in a container of a pod.
+type PodCommandExecutor interface {
+	Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) error
+}
+
+type websocketPodCommandExecutor struct {
+	config     *rest.Config
+	kubeClient kubernetes.Interface
+}
+
+func newPodCommandExecutor(config *rest.Config, kubeClient kubernetes.Interface) PodCommandExecutor {
+	return &websocketPodCommandExecutor{
+		config:     config,
+		kubeClient: kubeClient,
+	}
+}
+
+// Exec runs the command and waits for it to finish.
+// An error is returned if the command could not be started, exits with a non-zero code, or the context is done.
+func (e *websocketPodCommandExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) error {
+	u := e.kubeClient.CoreV1().RESTClient().
+		Post().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec).
+		URL()
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+
+	wsConfig, err := websocket.NewConfig(u.String(), "http://localhost")
+	if err != nil {
+		return fmt.Errorf("failed to create websocket config: %v", err)
+	}
+	wsConfig.Protocol = []string{execProtocol}
+
+	wsConfig.TlsConfig, err = rest.TLSConfigFor(e.config)
+	if err != nil {
+		return fmt.Errorf("failed to create tls config: %v", err)
+	}
+
+	wsConfig.Header, err = e.authHeader()
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+	wsConfig.Dialer = dialer
+
+	conn, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to open exec stream: %v", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("failed to set exec stream deadline: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var stderr bytes.Buffer
+	for {
+		var frame []byte
+		if err := websocket.Message.Receive(conn, &frame); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("exec stream closed before the command finished")
+			}
+			return fmt.Errorf("failed to read exec stream: %v", err)
+		}
+
+		if len(frame) == 0 {
+			continue
+		}
+
+		switch frame[0] {
+		case execStdoutChannel:
+			// stdout is only read to keep the stream flowing.
+		case execStderrChannel:
+			if stderr.Len() < maxExecStderrLength {
+				stderr.Write(frame[1:])
+			}
+		case execErrorChannel:
+			if len(frame) == 1 {
+				continue
+			}
+			return execStatusError(frame[1:], stderr.String())
+		}
+	}
+}
+
+func (e *websocketPodCommandExecutor) authHeader() (http.Header, error) {
+	header := http.Header{}
+
+	token := e.config.BearerToken
+	if token == "" && e.config.BearerTokenFile != "" {
+		b, err := os.ReadFile(e.config.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bearer token file: %v", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	switch {
+	case token != "":
+		header.Set("Authorization", "Bearer "+token)
+	case e.config.Username != "" || e.config.Password != "":
+		req := &http.Request{Header: header}
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+
+	return header, nil
+}
+
+// execStatusError converts the status sent on the error channel once the command finished.
+func execStatusError(data []byte, stderr string) error {
+	var status metav1.Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("failed to decode exec status: %v", err)
+	}
+
+	if status.Status == metav1.StatusSuccess {
+		return nil
+	}
+
+	message := status.Message
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		message = fmt.Sprintf("%s: %s", message, stderr)
+	}
+
+	return fmt.Errorf("command failed: %s", message)
+}
@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/build"
 	"github.com/actions/actions-runner-controller/hash"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // secret constants
@@ -54,6 +59,36 @@ func (b *resourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.A
 			Value: strconv.Itoa(autoscalingListener.Spec.RunnerScaleSetId),
 		},
 	}
+	if autoscalingListener.Spec.RunnerScaleSetName != "" {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_RUNNER_SCALE_SET_NAME",
+			Value: autoscalingListener.Spec.RunnerScaleSetName,
+		})
+	}
+	if autoscalingListener.Spec.SessionBackoffMax != nil {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_SESSION_BACKOFF_MAX",
+			Value: autoscalingListener.Spec.SessionBackoffMax.Duration.String(),
+		})
+	}
+	if autoscalingListener.Spec.StaleSessionTimeout != nil {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_STALE_SESSION_TIMEOUT",
+			Value: autoscalingListener.Spec.StaleSessionTimeout.Duration.String(),
+		})
+	}
+	if autoscalingListener.Spec.Cordoned {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_CORDONED",
+			Value: "true",
+		})
+	}
+	if len(autoscalingListener.Spec.PreferredLabels) > 0 {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_PREFERRED_LABELS",
+			Value: strings.Join(autoscalingListener.Spec.PreferredLabels, ","),
+		})
+	}
 	listenerEnv = append(listenerEnv, envs...)
 
 	if _, ok := secret.Data["github_token"]; ok {
@@ -166,17 +201,20 @@ func (b *resourceBuilder) newEphemeralRunnerSet(autoscalingRunnerSet *v1alpha1.A
 			Labels:       newLabels,
 		},
 		Spec: v1alpha1.EphemeralRunnerSetSpec{
-			Replicas: 0,
+			Replicas:            0,
+			ResourceLabels:      autoscalingRunnerSet.Spec.ResourceLabels,
+			ResourceAnnotations: autoscalingRunnerSet.Spec.ResourceAnnotations,
 			EphemeralRunnerSpec: v1alpha1.EphemeralRunnerSpec{
 				RunnerScaleSetId:   runnerScaleSetId,
 				GitHubConfigUrl:    autoscalingRunnerSet.Spec.GitHubConfigUrl,
 				GitHubConfigSecret: autoscalingRunnerSet.Spec.GitHubConfigSecret,
 				Proxy:              autoscalingRunnerSet.Spec.Proxy,
 				GitHubServerTLS:    autoscalingRunnerSet.Spec.GitHubServerTLS,
-				PodTemplateSpec:    autoscalingRunnerSet.Spec.Template,
+				PodTemplateSpec:    autoscalingRunnerSet.RunnerTemplate(),
 			},
 		},
 	}
+	mergeResourceMetadata(newEphemeralRunnerSet, autoscalingRunnerSet.Spec.ResourceLabels, autoscalingRunnerSet.Spec.ResourceAnnotations)
 
 	return newEphemeralRunnerSet, nil
 }
@@ -194,6 +232,20 @@ func (b *resourceBuilder) newScaleSetListenerServiceAccount(autoscalingListener
 	}
 }
 
+func (b *resourceBuilder) newRunnerServiceAccount(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      autoscalingRunnerSet.RunnerServiceAccountName(),
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels: map[string]string{
+				LabelKeyAutoScaleRunnerSetNamespace: autoscalingRunnerSet.Namespace,
+				LabelKeyAutoScaleRunnerSetName:      autoscalingRunnerSet.Name,
+			},
+			Annotations: autoscalingRunnerSet.Spec.ServiceAccountAnnotations,
+		},
+	}
+}
+
 func (b *resourceBuilder) newScaleSetListenerRole(autoscalingListener *v1alpha1.AutoscalingListener) *rbacv1.Role {
 	rules := rulesForListenerRole([]string{autoscalingListener.Spec.EphemeralRunnerSetName})
 	rulesHash := hash.ComputeTemplateHash(&rules)
@@ -299,6 +351,7 @@ func (b *resourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.
 			GitHubConfigUrl:               autoscalingRunnerSet.Spec.GitHubConfigUrl,
 			GitHubConfigSecret:            autoscalingRunnerSet.Spec.GitHubConfigSecret,
 			RunnerScaleSetId:              runnerScaleSetId,
+			RunnerScaleSetName:            autoscalingRunnerSet.Annotations[runnerScaleSetNameKey],
 			AutoscalingRunnerSetNamespace: autoscalingRunnerSet.Namespace,
 			AutoscalingRunnerSetName:      autoscalingRunnerSet.Name,
 			EphemeralRunnerSetName:        ephemeralRunnerSet.Name,
@@ -307,6 +360,10 @@ func (b *resourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.
 			Image:                         image,
 			ImagePullSecrets:              imagePullSecrets,
 			Proxy:                         autoscalingRunnerSet.Spec.Proxy,
+			SessionBackoffMax:             autoscalingRunnerSet.Spec.ListenerSessionBackoffMax,
+			StaleSessionTimeout:           autoscalingRunnerSet.Spec.ListenerStaleSessionTimeout,
+			Cordoned:                      autoscalingRunnerSet.Spec.Cordoned,
+			PreferredLabels:               autoscalingRunnerSet.Spec.ListenerPreferredLabels,
 		},
 	}
 
@@ -314,14 +371,238 @@ func (b *resourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.
 }
 
 func (b *resourceBuilder) newEphemeralRunner(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) *v1alpha1.EphemeralRunner {
-	return &v1alpha1.EphemeralRunner{
+	spec := *ephemeralRunnerSet.Spec.EphemeralRunnerSpec.DeepCopy()
+	if ephemeralRunnerSet.Spec.SpreadAcrossNodes {
+		spec.PodTemplateSpec.Spec.TopologySpreadConstraints = withNodeSpreadConstraint(
+			spec.PodTemplateSpec.Spec.TopologySpreadConstraints,
+			spec.RunnerScaleSetId,
+		)
+	}
+	spec.PodTemplateSpec.Spec.NodeSelector = withDefaultNodeSelector(spec.PodTemplateSpec.Spec.NodeSelector, ephemeralRunnerSet.Spec.NodeSelector)
+	spec.PodTemplateSpec.Spec.Tolerations = withTolerations(spec.PodTemplateSpec.Spec.Tolerations, ephemeralRunnerSet.Spec.Tolerations)
+	if spec.PodTemplateSpec.Spec.DNSPolicy == "" {
+		spec.PodTemplateSpec.Spec.DNSPolicy = ephemeralRunnerSet.Spec.DNSPolicy
+	}
+	spec.PodTemplateSpec.Spec.DNSConfig = withDefaultDNSConfig(spec.PodTemplateSpec.Spec.DNSConfig, ephemeralRunnerSet.Spec.DNSConfig)
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: ephemeralRunnerSet.Name + "-runner-",
 			Namespace:    ephemeralRunnerSet.Namespace,
+			Annotations: map[string]string{
+				AnnotationKeyRunnerSpecHash: ephemeralRunnerSet.EphemeralRunnerSpecHash(),
+			},
+		},
+		Spec: spec,
+	}
+	mergeResourceMetadata(ephemeralRunner, ephemeralRunnerSet.Spec.ResourceLabels, ephemeralRunnerSet.Spec.ResourceAnnotations)
+
+	return ephemeralRunner
+}
+
+// busyRunnersPodDisruptionBudgetName returns the name of the busy runners PodDisruptionBudget of the EphemeralRunnerSet.
+func busyRunnersPodDisruptionBudgetName(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) string {
+	return ephemeralRunnerSet.Name + "-busy-runners"
+}
+
+// newBusyRunnersPodDisruptionBudget returns the PodDisruptionBudget not allowing the eviction of the runner pods
+// of the EphemeralRunnerSet assigned a job.
+func (b *resourceBuilder) newBusyRunnersPodDisruptionBudget(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(0)
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      busyRunnersPodDisruptionBudgetName(ephemeralRunnerSet),
+			Namespace: ephemeralRunnerSet.Namespace,
 		},
-		Spec: ephemeralRunnerSet.Spec.EphemeralRunnerSpec,
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					LabelKeyEphemeralRunnerSetName: ephemeralRunnerSet.Name,
+					LabelKeyBusy:                   "true",
+				},
+			},
+		},
+	}
+}
+
+// runnerPodDisruptionLabels returns the labels of the runner pod of the ephemeral runner selected by
+// the busy runners PodDisruptionBudget of its EphemeralRunnerSet.
+func runnerPodDisruptionLabels(ephemeralRunner *v1alpha1.EphemeralRunner) map[string]string {
+	labels := map[string]string{
+		LabelKeyBusy: strconv.FormatBool(ephemeralRunner.Status.JobRequestId > 0),
+	}
+	if name := ephemeralRunnerSetName(ephemeralRunner); name != "" && len(validation.IsValidLabelValue(name)) == 0 {
+		labels[LabelKeyEphemeralRunnerSetName] = name
 	}
+	return labels
+}
+
+// withNodeSpreadConstraint adds a constraint spreading the runner pods of the runner scale set across nodes.
+//
+// Constraints defined in the pod template take precedence: if one of them already spreads on the hostname
+// topology key, the constraints are returned unchanged. Other user defined constraints are kept as is and
+// the node spread constraint is added next to them.
+func withNodeSpreadConstraint(constraints []corev1.TopologySpreadConstraint, runnerScaleSetId int) []corev1.TopologySpreadConstraint {
+	for _, c := range constraints {
+		if c.TopologyKey == corev1.LabelHostname {
+			return constraints
+		}
+	}
+
+	return append(constraints, corev1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       corev1.LabelHostname,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				runnerScaleSetIdKey: strconv.Itoa(runnerScaleSetId),
+			},
+		},
+	})
+}
+
+// withDefaultNodeSelector returns the node selector of a pod template with the defaults merged into it.
+// Keys set in the pod template take precedence.
+func withDefaultNodeSelector(nodeSelector, defaults map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return nodeSelector
+	}
+
+	result := make(map[string]string, len(nodeSelector)+len(defaults))
+	for k, v := range defaults {
+		result[k] = v
+	}
+	for k, v := range nodeSelector {
+		result[k] = v
+	}
+	return result
+}
+
+// withTolerations returns the tolerations of a pod template with the additional tolerations appended,
+// skipping the ones that are already in the pod template or added before.
+func withTolerations(tolerations, additional []corev1.Toleration) []corev1.Toleration {
+	if len(additional) == 0 {
+		return tolerations
+	}
+
+	result := make([]corev1.Toleration, 0, len(tolerations)+len(additional))
+	result = append(result, tolerations...)
+	for _, t := range additional {
+		if !containsToleration(result, t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// withDefaultDNSConfig returns the DNS config of a pod template with the defaults merged into it.
+// The nameservers and searches of the pod template replace the default ones, and its options take precedence
+// on conflicting names.
+func withDefaultDNSConfig(config, defaults *corev1.PodDNSConfig) *corev1.PodDNSConfig {
+	if defaults == nil {
+		return config
+	}
+
+	result := defaults.DeepCopy()
+	if config == nil {
+		return result
+	}
+	if len(config.Nameservers) > 0 {
+		result.Nameservers = config.Nameservers
+	}
+	if len(config.Searches) > 0 {
+		result.Searches = config.Searches
+	}
+	for _, option := range config.Options {
+		replaced := false
+		for i := range result.Options {
+			if result.Options[i].Name == option.Name {
+				result.Options[i] = option
+				replaced = true
+			}
+		}
+		if !replaced {
+			result.Options = append(result.Options, option)
+		}
+	}
+	return result
+}
+
+func containsToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	for _, t := range tolerations {
+		if equality.Semantic.DeepEqual(t, toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaultResources returns the resource requirements of a container with the defaults applied to the
+// requests and limits it leaves unset. Resources set on the container always take precedence.
+// A default request is not applied when the container sets a limit for the resource, so that Kubernetes
+// defaults the request to the limit, and a default limit is not applied when it is lower than the request.
+func withDefaultResources(resources, defaults corev1.ResourceRequirements) corev1.ResourceRequirements {
+	result := *resources.DeepCopy()
+
+	for name, quantity := range defaults.Requests {
+		if _, ok := result.Requests[name]; ok {
+			continue
+		}
+		if _, ok := result.Limits[name]; ok {
+			continue
+		}
+		if result.Requests == nil {
+			result.Requests = corev1.ResourceList{}
+		}
+		result.Requests[name] = quantity.DeepCopy()
+	}
+
+	for name, quantity := range defaults.Limits {
+		if _, ok := result.Limits[name]; ok {
+			continue
+		}
+		if request, ok := result.Requests[name]; ok && request.Cmp(quantity) > 0 {
+			continue
+		}
+		if result.Limits == nil {
+			result.Limits = corev1.ResourceList{}
+		}
+		result.Limits[name] = quantity.DeepCopy()
+	}
+
+	return result
+}
+
+// withDefaultSecurityContext returns the security context of a container with the defaults applied to the settings
+// it leaves unset. Settings of the container always take precedence, as well as the settings of the pod security context,
+// which apply to the container unless it overrides them.
+func withDefaultSecurityContext(securityContext *corev1.SecurityContext, podSecurityContext *corev1.PodSecurityContext, defaults *corev1.SecurityContext) *corev1.SecurityContext {
+	result := securityContext.DeepCopy()
+	if result == nil {
+		result = new(corev1.SecurityContext)
+	}
+	if podSecurityContext == nil {
+		podSecurityContext = new(corev1.PodSecurityContext)
+	}
+
+	if result.RunAsNonRoot == nil && podSecurityContext.RunAsNonRoot == nil && defaults.RunAsNonRoot != nil {
+		runAsNonRoot := *defaults.RunAsNonRoot
+		result.RunAsNonRoot = &runAsNonRoot
+	}
+	if result.ReadOnlyRootFilesystem == nil && defaults.ReadOnlyRootFilesystem != nil {
+		readOnlyRootFilesystem := *defaults.ReadOnlyRootFilesystem
+		result.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+	}
+	if result.SeccompProfile == nil && podSecurityContext.SeccompProfile == nil && defaults.SeccompProfile != nil {
+		result.SeccompProfile = defaults.SeccompProfile.DeepCopy()
+	}
+	if result.Capabilities == nil && defaults.Capabilities != nil {
+		result.Capabilities = defaults.Capabilities.DeepCopy()
+	}
+
+	return result
 }
 
 func (b *resourceBuilder) newEphemeralRunnerPod(ctx context.Context, runner *v1alpha1.EphemeralRunner, secret *corev1.Secret, envs ...corev1.EnvVar) *corev1.Pod {
@@ -352,6 +633,10 @@ func (b *resourceBuilder) newEphemeralRunnerPod(ctx context.Context, runner *v1a
 	)
 
 	labels["actions-ephemeral-runner"] = string(corev1.ConditionTrue)
+	labels[runnerScaleSetIdKey] = strconv.Itoa(runner.Spec.RunnerScaleSetId)
+	for k, v := range runnerPodDisruptionLabels(runner) {
+		labels[k] = v
+	}
 
 	objectMeta := metav1.ObjectMeta{
 		Name:        runner.ObjectMeta.Name,
@@ -362,10 +647,16 @@ func (b *resourceBuilder) newEphemeralRunnerPod(ctx context.Context, runner *v1a
 
 	newPod.ObjectMeta = objectMeta
 	newPod.Spec = runner.Spec.PodTemplateSpec.Spec
+	newPod.Spec.NodeSelector = withOSNodeSelector(newPod.Spec.NodeSelector, runner.Spec.OS)
+	if runner.Spec.TerminationGracePeriodSeconds != nil {
+		gracePeriod := *runner.Spec.TerminationGracePeriodSeconds
+		newPod.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	}
 	newPod.Spec.Containers = make([]corev1.Container, 0, len(runner.Spec.PodTemplateSpec.Spec.Containers))
 
+	containerName := runnerContainerName(runner)
 	for _, c := range runner.Spec.PodTemplateSpec.Spec.Containers {
-		if c.Name == EphemeralRunnerContainerName {
+		if c.Name == containerName {
 			c.Env = append(
 				c.Env,
 				corev1.EnvVar{
@@ -437,6 +728,24 @@ func scaleSetListenerSecretMirrorName(autoscalingListener *v1alpha1.AutoscalingL
 	return fmt.Sprintf("%v-%v-listener", autoscalingListener.Spec.AutoscalingRunnerSetName, namespaceHash)
 }
 
+// withOSNodeSelector returns the node selector with the kubernetes.io/os label of the runner OS added,
+// unless the OS is unset or the node selector already selects an OS.
+func withOSNodeSelector(nodeSelector map[string]string, os v1alpha1.EphemeralRunnerOS) map[string]string {
+	if os == "" {
+		return nodeSelector
+	}
+	if _, ok := nodeSelector[corev1.LabelOSStable]; ok {
+		return nodeSelector
+	}
+
+	merged := make(map[string]string, len(nodeSelector)+1)
+	for k, v := range nodeSelector {
+		merged[k] = v
+	}
+	merged[corev1.LabelOSStable] = string(os)
+	return merged
+}
+
 func proxyListenerSecretName(autoscalingListener *v1alpha1.AutoscalingListener) string {
 	namespaceHash := hash.FNVHashString(autoscalingListener.Spec.AutoscalingRunnerSetNamespace)
 	if len(namespaceHash) > 8 {
@@ -459,12 +768,17 @@ func rulesForListenerRole(resourceNames []string) []rbacv1.PolicyRule {
 			APIGroups:     []string{"actions.github.com"},
 			Resources:     []string{"ephemeralrunnersets"},
 			ResourceNames: resourceNames,
-			Verbs:         []string{"patch"},
+			Verbs:         []string{"get", "patch"},
 		},
 		{
 			APIGroups: []string{"actions.github.com"},
 			Resources: []string{"ephemeralrunners", "ephemeralrunners/status"},
 			Verbs:     []string{"patch"},
 		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create"},
+		},
 	}
 }
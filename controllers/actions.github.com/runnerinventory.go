@@ -0,0 +1,121 @@
+package actionsgithubcom
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RunnerInventoryPath is the path of the runner inventory endpoint on the metrics server.
+const RunnerInventoryPath = "/debug/runners"
+
+// RunnerInventoryHandler serves the EphemeralRunners of each EphemeralRunnerSet as JSON, read from the cache of
+// the controller, to debug incidents without querying the API server. Only names, phases and IDs are served:
+// the specs, messages and JIT configs of the runners are never exposed.
+type RunnerInventoryHandler struct {
+	// Client must read from the cache of the manager, with the EphemeralRunner owner index
+	// of the EphemeralRunnerSetReconciler.
+	Client client.Reader
+	Log    logr.Logger
+}
+
+type runnerInventory struct {
+	RunnerSets []runnerSetInventory `json:"runnerSets"`
+}
+
+type runnerSetInventory struct {
+	Namespace       string                 `json:"namespace"`
+	Name            string                 `json:"name"`
+	DesiredReplicas int                    `json:"desiredReplicas"`
+	Pending         int                    `json:"pending"`
+	Running         int                    `json:"running"`
+	Finished        int                    `json:"finished"`
+	Failed          int                    `json:"failed"`
+	Deleting        int                    `json:"deleting"`
+	Runners         []runnerInventoryEntry `json:"runners"`
+}
+
+type runnerInventoryEntry struct {
+	Name         string `json:"name"`
+	Phase        string `json:"phase"`
+	Deleting     bool   `json:"deleting,omitempty"`
+	RunnerId     int    `json:"runnerId,omitempty"`
+	JobRequestId int64  `json:"jobRequestId,omitempty"`
+}
+
+func (h *RunnerInventoryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	inventory, err := h.inventory(req)
+	if err != nil {
+		h.Log.Error(err, "Failed to list the runner inventory")
+		http.Error(w, "failed to list the runner inventory", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(inventory); err != nil {
+		h.Log.Error(err, "Failed to write the runner inventory")
+	}
+}
+
+func (h *RunnerInventoryHandler) inventory(req *http.Request) (*runnerInventory, error) {
+	ephemeralRunnerSets := new(v1alpha1.EphemeralRunnerSetList)
+	if err := h.Client.List(req.Context(), ephemeralRunnerSets); err != nil {
+		return nil, err
+	}
+
+	inventory := &runnerInventory{RunnerSets: []runnerSetInventory{}}
+	for i := range ephemeralRunnerSets.Items {
+		ephemeralRunnerSet := &ephemeralRunnerSets.Items[i]
+
+		ephemeralRunners := new(v1alpha1.EphemeralRunnerList)
+		if err := h.Client.List(
+			req.Context(),
+			ephemeralRunners,
+			client.InNamespace(ephemeralRunnerSet.Namespace),
+			client.MatchingFields{ephemeralRunnerSetReconcilerOwnerKey: ephemeralRunnerSet.Name},
+		); err != nil {
+			return nil, err
+		}
+
+		inventory.RunnerSets = append(inventory.RunnerSets, newRunnerSetInventory(ephemeralRunnerSet, ephemeralRunners))
+	}
+
+	return inventory, nil
+}
+
+func newRunnerSetInventory(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, ephemeralRunners *v1alpha1.EphemeralRunnerList) runnerSetInventory {
+	pending, running, finished, failed, deleting := categorizeEphemeralRunners(ephemeralRunners)
+
+	runners := make([]runnerInventoryEntry, 0, len(ephemeralRunners.Items))
+	for i := range ephemeralRunners.Items {
+		runner := &ephemeralRunners.Items[i]
+		runners = append(runners, runnerInventoryEntry{
+			Name:         runner.Name,
+			Phase:        string(runner.Status.Phase),
+			Deleting:     !runner.DeletionTimestamp.IsZero(),
+			RunnerId:     runner.Status.RunnerId,
+			JobRequestId: runner.Status.JobRequestId,
+		})
+	}
+
+	return runnerSetInventory{
+		Namespace:       ephemeralRunnerSet.Namespace,
+		Name:            ephemeralRunnerSet.Name,
+		DesiredReplicas: ephemeralRunnerSet.Spec.Replicas,
+		Pending:         len(pending),
+		Running:         len(running),
+		Finished:        len(finished),
+		Failed:          len(failed),
+		Deleting:        len(deleting),
+		Runners:         runners,
+	}
+}
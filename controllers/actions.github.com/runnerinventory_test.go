@@ -0,0 +1,58 @@
+package actionsgithubcom
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewRunnerSetInventory(t *testing.T) {
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "arc-runners", Name: "runners-abc"},
+		Spec:       v1alpha1.EphemeralRunnerSetSpec{Replicas: 2},
+	}
+
+	now := metav1.Now()
+	ephemeralRunners := &v1alpha1.EphemeralRunnerList{Items: []v1alpha1.EphemeralRunner{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "running"},
+			Status: v1alpha1.EphemeralRunnerStatus{
+				Phase:           corev1.PodRunning,
+				RunnerId:        7,
+				JobRequestId:    42,
+				RunnerJITConfig: "secret-jit-config",
+				Message:         "message",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "deleting", DeletionTimestamp: &now},
+			Status:     v1alpha1.EphemeralRunnerStatus{Phase: corev1.PodRunning, RunnerId: 8},
+		},
+	}}
+
+	inventory := newRunnerSetInventory(ephemeralRunnerSet, ephemeralRunners)
+	assert.Equal(t, "arc-runners", inventory.Namespace)
+	assert.Equal(t, "runners-abc", inventory.Name)
+	assert.Equal(t, 2, inventory.DesiredReplicas)
+	assert.Equal(t, 1, inventory.Pending)
+	assert.Equal(t, 1, inventory.Running)
+	assert.Equal(t, 1, inventory.Deleting)
+	assert.Equal(t, []runnerInventoryEntry{
+		{Name: "pending"},
+		{Name: "running", Phase: "Running", RunnerId: 7, JobRequestId: 42},
+		{Name: "deleting", Phase: "Running", Deleting: true, RunnerId: 8},
+	}, inventory.Runners)
+
+	data, err := json.Marshal(inventory)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-jit-config")
+	assert.NotContains(t, string(data), "message")
+}
@@ -1,7 +1,15 @@
 package actionsgithubcom
 
 import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/controllers/actions.github.com/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func FilterLabels(labels map[string]string, filter string) map[string]string {
@@ -25,3 +33,73 @@ func RandStringRunes(n int) string {
 	}
 	return string(b)
 }
+
+// reservedMetadataKeys are the labels and annotations set by the controllers on the resources of a runner scale set.
+var reservedMetadataKeys = map[string]bool{
+	LabelKeyRunnerTemplateHash:          true,
+	LabelKeyPodTemplateHash:             true,
+	LabelKeyRunnerSpecHash:              true,
+	LabelKeyAutoScaleRunnerSetName:      true,
+	LabelKeyAutoScaleRunnerSetNamespace: true,
+	runnerScaleSetIdKey:                 true,
+	runnerScaleSetNameKey:               true,
+	runnerScaleSetRunnerGroupNameKey:    true,
+	runnerScaleSetRunnerGroupIdKey:      true,
+	"actions-ephemeral-runner":          true,
+}
+
+// isReservedMetadataKey reports whether the label or annotation key is managed by the controllers.
+func isReservedMetadataKey(key string) bool {
+	return reservedMetadataKeys[key] || strings.Contains(key, "actions.github.com/")
+}
+
+// mergeResourceMetadata merges the resource labels and annotations onto the object, skipping reserved keys.
+// Labels and annotations of the object that are not part of the resource labels and annotations are kept.
+// It returns true if the labels or annotations of the object changed.
+func mergeResourceMetadata(obj metav1.Object, labels, annotations map[string]string) bool {
+	mergedLabels, labelsChanged := mergeMetadata(obj.GetLabels(), labels)
+	mergedAnnotations, annotationsChanged := mergeMetadata(obj.GetAnnotations(), annotations)
+	if labelsChanged {
+		obj.SetLabels(mergedLabels)
+	}
+	if annotationsChanged {
+		obj.SetAnnotations(mergedAnnotations)
+	}
+	return labelsChanged || annotationsChanged
+}
+
+func mergeMetadata(existing, additional map[string]string) (map[string]string, bool) {
+	changed := false
+	for k, v := range additional {
+		if isReservedMetadataKey(k) {
+			continue
+		}
+		if current, ok := existing[k]; ok && current == v {
+			continue
+		}
+		if existing == nil {
+			existing = make(map[string]string, len(additional))
+		}
+		existing[k] = v
+		changed = true
+	}
+	return existing, changed
+}
+
+// instrumentedReconciler records the duration and the errors of the reconciles of the wrapped reconciler
+// in the arc_reconcile_duration_seconds and arc_reconcile_errors_total metrics.
+type instrumentedReconciler struct {
+	controller string
+	reconcile.Reconciler
+}
+
+func instrumentReconciler(controller string, r reconcile.Reconciler) reconcile.Reconciler {
+	return &instrumentedReconciler{controller: controller, Reconciler: r}
+}
+
+func (r *instrumentedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+	result, err := r.Reconciler.Reconcile(ctx, req)
+	metrics.ObserveReconcile(r.controller, time.Since(start), err)
+	return result, err
+}
@@ -3,6 +3,9 @@ package actionsgithubcom
 import (
 	"reflect"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func Test_filterLabels(t *testing.T) {
@@ -32,3 +35,48 @@ func Test_filterLabels(t *testing.T) {
 		})
 	}
 }
+
+func Test_mergeResourceMetadata(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				runnerScaleSetIdKey: "1",
+				"team":              "platform",
+			},
+		},
+	}
+
+	labels := map[string]string{
+		runnerScaleSetIdKey:            "2",
+		"actions.github.com/scale-set": "override",
+		"cost-center":                  "ci",
+	}
+	annotations := map[string]string{
+		AnnotationKeyRunnerSpecHash: "override",
+		"example.com/owner":         "platform",
+	}
+
+	if changed := mergeResourceMetadata(pod, labels, annotations); !changed {
+		t.Fatalf("mergeResourceMetadata() = false, want true")
+	}
+
+	wantLabels := map[string]string{
+		runnerScaleSetIdKey: "1",
+		"team":              "platform",
+		"cost-center":       "ci",
+	}
+	if !reflect.DeepEqual(pod.Labels, wantLabels) {
+		t.Errorf("labels = %v, want %v", pod.Labels, wantLabels)
+	}
+
+	wantAnnotations := map[string]string{
+		"example.com/owner": "platform",
+	}
+	if !reflect.DeepEqual(pod.Annotations, wantAnnotations) {
+		t.Errorf("annotations = %v, want %v", pod.Annotations, wantAnnotations)
+	}
+
+	if changed := mergeResourceMetadata(pod, labels, annotations); changed {
+		t.Errorf("mergeResourceMetadata() = true on already merged metadata, want false")
+	}
+}
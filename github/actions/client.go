@@ -8,6 +8,7 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -66,18 +67,32 @@ type Client struct {
 	ActionsServiceAdminTokenExpiresAt time.Time
 	ActionsServiceURL                 string
 
+	// registrationToken is reused to refresh the ActionsServiceAdminToken until it is about to expire.
+	// It is protected by mu.
+	registrationToken *registrationToken
+
 	retryMax     int
 	retryWaitMax time.Duration
 
+	rateLimit *rateLimitTransport
+
 	creds     *ActionsAuth
 	config    *GitHubConfig
 	logger    logr.Logger
 	userAgent string
 
+	// pathPrefix is the path the GitHub Enterprise Server is served under, used to parse the config URL.
+	pathPrefix string
+
 	rootCAs               *x509.CertPool
 	tlsInsecureSkipVerify bool
 
-	proxyFunc ProxyFunc
+	proxyFunc          ProxyFunc
+	proxyCACertificate []byte
+
+	// lock for discovering the GitHub App installation when it is not part of the credentials
+	installationMu           sync.Mutex
+	discoveredInstallationID int64
 }
 
 type ProxyFunc func(req *http.Request) (*url.URL, error)
@@ -126,15 +141,26 @@ func WithProxy(proxyFunc ProxyFunc) ClientOption {
 	}
 }
 
-func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOption) (*Client, error) {
-	config, err := ParseGitHubConfigFromURL(githubConfigURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse githubConfigURL: %w", err)
+// WithProxyCACertificate adds the PEM encoded certificates to the pool used
+// to verify TLS connections made through the proxy. The certificates are
+// appended to the root CAs, or to the system pool when no root CAs are set.
+func WithProxyCACertificate(pem []byte) ClientOption {
+	return func(c *Client) {
+		c.proxyCACertificate = pem
 	}
+}
 
+// WithGitHubPathPrefix sets the path a GitHub Enterprise Server is served under, e.g. behind a reverse proxy.
+// The config URL must be under the prefix, and the API is requested under the prefix as well.
+func WithGitHubPathPrefix(pathPrefix string) ClientOption {
+	return func(c *Client) {
+		c.pathPrefix = pathPrefix
+	}
+}
+
+func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOption) (*Client, error) {
 	ac := &Client{
 		creds:  creds,
-		config: config,
 		logger: logr.Discard(),
 
 		// retryablehttp defaults
@@ -146,6 +172,12 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 		option(ac)
 	}
 
+	config, err := ParseGitHubConfigFromURLWithPathPrefix(githubConfigURL, ac.pathPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse githubConfigURL: %w", err)
+	}
+	ac.config = config
+
 	retryClient := retryablehttp.NewClient()
 	retryClient.Logger = log.New(io.Discard, "", log.LstdFlags)
 
@@ -166,18 +198,49 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 		transport.TLSClientConfig.RootCAs = ac.rootCAs
 	}
 
+	if len(ac.proxyCACertificate) > 0 {
+		rootCAs, err := ac.proxyRootCAs()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.RootCAs = rootCAs
+	}
+
 	if ac.tlsInsecureSkipVerify {
 		transport.TLSClientConfig.InsecureSkipVerify = true
 	}
 
 	transport.Proxy = ac.proxyFunc
 
-	retryClient.HTTPClient.Transport = transport
+	ac.rateLimit = newRateLimitTransport(
+		transport,
+		[]string{ac.config.ConfigURL.String(), ac.Identifier()},
+	)
+	retryClient.HTTPClient.Transport = ac.rateLimit
 	ac.Client = retryClient.StandardClient()
 
 	return ac, nil
 }
 
+func (c *Client) proxyRootCAs() (*x509.CertPool, error) {
+	var rootCAs *x509.CertPool
+	if c.rootCAs != nil {
+		rootCAs = c.rootCAs.Clone()
+	} else {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		rootCAs = pool
+	}
+
+	if ok := rootCAs.AppendCertsFromPEM(c.proxyCACertificate); !ok {
+		return nil, fmt.Errorf("no certificates successfully parsed from proxy CA certificate")
+	}
+
+	return rootCAs, nil
+}
+
 // Identifier returns a string to help identify a client uniquely.
 // This is used for caching client instances and understanding when a config
 // change warrants creating a new client. Any changes to Client that would
@@ -185,11 +248,15 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 func (c *Client) Identifier() string {
 	identifier := fmt.Sprintf("configURL:%q,", c.config.ConfigURL.String())
 
-	if c.creds.Token != "" {
+	if c.config.PathPrefix != "" {
+		identifier += fmt.Sprintf("pathPrefix:%q,", c.config.PathPrefix)
+	}
+
+	if c.creds != nil && c.creds.Token != "" {
 		identifier += fmt.Sprintf("token:%q", c.creds.Token)
 	}
 
-	if c.creds.AppCreds != nil {
+	if c.creds != nil && c.creds.AppCreds != nil {
 		identifier += fmt.Sprintf(
 			"appID:%q,installationID:%q,key:%q",
 			c.creds.AppCreds.AppID,
@@ -198,6 +265,10 @@ func (c *Client) Identifier() string {
 		)
 	}
 
+	if len(c.proxyCACertificate) > 0 {
+		identifier += fmt.Sprintf(",proxyCACertificate:%x", sha256.Sum256(c.proxyCACertificate))
+	}
+
 	return uuid.NewHash(sha256.New(), uuid.NameSpaceOID, []byte(identifier), 6).String()
 }
 
@@ -207,6 +278,11 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		return nil, err
 	}
 
+	// The admin token was rejected, refresh both tokens on the next request.
+	if adminToken, ok := req.Context().Value(adminTokenContextKey{}).(string); ok && resp.StatusCode == http.StatusUnauthorized {
+		c.invalidateTokens(adminToken)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -265,6 +341,7 @@ func (c *Client) NewActionsServiceRequest(ctx context.Context, method, path stri
 	}
 	u.RawQuery = q.Encode()
 
+	ctx = context.WithValue(ctx, adminTokenContextKey{}, c.ActionsServiceAdminToken)
 	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return nil, err
@@ -847,12 +924,17 @@ type accessToken struct {
 }
 
 func (c *Client) fetchAccessToken(ctx context.Context, gitHubConfigURL string, creds *GitHubAppAuth) (*accessToken, error) {
+	installationID, err := c.appInstallationID(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
 	accessTokenJWT, err := createJWTForGitHubApp(creds)
 	if err != nil {
 		return nil, err
 	}
 
-	path := fmt.Sprintf("/app/installations/%v/access_tokens", creds.AppInstallationID)
+	path := fmt.Sprintf("/app/installations/%v/access_tokens", installationID)
 	req, err := c.NewGitHubAPIRequest(ctx, http.MethodPost, path, nil)
 	if err != nil {
 		return nil, err
@@ -915,6 +997,17 @@ func (c *Client) getActionsServiceAdminConnection(ctx context.Context, rt *regis
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &HttpClientSideError{
+			msg:  fmt.Sprintf("unexpected response from GitHub API during runner registration call: %v - %v", resp.StatusCode, string(body)),
+			Code: resp.StatusCode,
+		}
+	}
+
 	var actionsServiceAdminConnection *ActionsServiceAdminConnection
 	if err := json.NewDecoder(resp.Body).Decode(&actionsServiceAdminConnection); err != nil {
 		return nil, err
@@ -998,13 +1091,27 @@ func (c *Client) updateTokenIfNeeded(ctx context.Context) error {
 	}
 
 	c.logger.Info("refreshing token", "githubConfigUrl", c.config.ConfigURL.String())
-	rt, err := c.getRunnerRegistrationToken(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get runner registration token on refresh: %w", err)
+	cached := c.registrationTokenValid(time.Now())
+	if !cached {
+		rt, err := c.getRunnerRegistrationToken(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get runner registration token on refresh: %w", err)
+		}
+		c.registrationToken = rt
 	}
 
-	adminConnInfo, err := c.getActionsServiceAdminConnection(ctx, rt)
+	adminConnInfo, err := c.getActionsServiceAdminConnection(ctx, c.registrationToken)
+	var clientSideError *HttpClientSideError
+	if cached && errors.As(err, &clientSideError) && clientSideError.Code == http.StatusUnauthorized {
+		c.logger.Info("cached runner registration token was rejected, getting a new one", "githubConfigUrl", c.config.ConfigURL.String())
+		c.registrationToken, err = c.getRunnerRegistrationToken(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get runner registration token on refresh: %w", err)
+		}
+		adminConnInfo, err = c.getActionsServiceAdminConnection(ctx, c.registrationToken)
+	}
 	if err != nil {
+		c.registrationToken = nil
 		return fmt.Errorf("failed to get actions service admin connection on refresh: %w", err)
 	}
 
@@ -1017,3 +1124,34 @@ func (c *Client) updateTokenIfNeeded(ctx context.Context) error {
 
 	return nil
 }
+
+// registrationTokenExpiryMargin is how long before its expiry a cached registration token is no longer reused.
+const registrationTokenExpiryMargin = 60 * time.Second
+
+// registrationTokenValid reports whether the cached registration token can be reused at the given time.
+// Tokens without an expiry are not reused. The caller must hold mu.
+func (c *Client) registrationTokenValid(now time.Time) bool {
+	rt := c.registrationToken
+	if rt == nil || rt.Token == nil || rt.ExpiresAt == nil {
+		return false
+	}
+	return now.Add(registrationTokenExpiryMargin).Before(*rt.ExpiresAt)
+}
+
+// adminTokenContextKey holds the admin token of an Actions service request in its context.
+type adminTokenContextKey struct{}
+
+// invalidateTokens forces a refresh of the admin token and of the registration token on the next Actions service request,
+// unless the rejected admin token was already refreshed.
+func (c *Client) invalidateTokens(rejectedAdminToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ActionsServiceAdminToken != rejectedAdminToken {
+		return
+	}
+
+	c.logger.Info("admin token was rejected, refreshing tokens on the next request", "githubConfigUrl", c.config.ConfigURL.String())
+	c.ActionsServiceAdminTokenExpiresAt = time.Time{}
+	c.registrationToken = nil
+}
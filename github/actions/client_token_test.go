@@ -0,0 +1,156 @@
+package actions_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenServer counts the registration token and admin connection requests.
+// Each registration token is valid until registrationTokenExpiresAt, and each admin token until adminTokenTTL.
+type tokenServer struct {
+	*httptest.Server
+
+	registrationTokenExpiresAt time.Time
+	adminTokenTTL              time.Duration
+
+	registrationTokenCalls atomic.Int32
+	adminConnectionCalls   atomic.Int32
+
+	// validRegistrationToken is the only registration token accepted, any when empty.
+	validRegistrationToken atomic.Value
+	// unauthorizedRequests is the number of Actions service requests answered with 401.
+	unauthorizedRequests atomic.Int32
+}
+
+func newTokenServer(t *testing.T, registrationTokenExpiresAt time.Time, adminTokenTTL time.Duration) *tokenServer {
+	s := &tokenServer{
+		registrationTokenExpiresAt: registrationTokenExpiresAt,
+		adminTokenTTL:              adminTokenTTL,
+	}
+	s.validRegistrationToken.Store("")
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/runners/registration-token"):
+			n := s.registrationTokenCalls.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"registration-token-%d","expires_at":%q}`, n, s.registrationTokenExpiresAt.Format(time.RFC3339))
+
+		case strings.HasSuffix(r.URL.Path, "/actions/runner-registration"):
+			s.adminConnectionCalls.Add(1)
+			if valid := s.validRegistrationToken.Load().(string); valid != "" && r.Header.Get("Authorization") != "RemoteAuth "+valid {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, `{"url":"%s/tenant/123/","token":"%s"}`, s.URL, adminToken(t, s.adminTokenTTL))
+
+		default:
+			if s.unauthorizedRequests.Load() > 0 {
+				s.unauthorizedRequests.Add(-1)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"id": 1, "name": "self-hosted-ubuntu"}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func adminToken(t *testing.T, ttl time.Duration) string {
+	claims := &jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(time.Now().Add(-10 * time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		Issuer:    "123",
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(samplePrivateKey))
+	require.NoError(t, err)
+	tokenString, err := token.SignedString(privateKey)
+	require.NoError(t, err)
+	return tokenString
+}
+
+func TestRegistrationTokenCache(t *testing.T) {
+	ctx := context.Background()
+	auth := &actions.ActionsAuth{
+		Token: "token",
+	}
+
+	t.Run("reuses the registration token until it is about to expire", func(t *testing.T) {
+		// Admin tokens expiring within a minute are refreshed on every request.
+		server := newTokenServer(t, time.Now().Add(time.Hour), 30*time.Second)
+
+		client, err := actions.NewClient(server.URL+"/my-org", auth)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := client.GetRunner(ctx, 1)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(3), server.adminConnectionCalls.Load())
+		assert.Equal(t, int32(1), server.registrationTokenCalls.Load())
+	})
+
+	t.Run("does not reuse a registration token about to expire", func(t *testing.T) {
+		server := newTokenServer(t, time.Now().Add(30*time.Second), 30*time.Second)
+
+		client, err := actions.NewClient(server.URL+"/my-org", auth)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.GetRunner(ctx, 1)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), server.registrationTokenCalls.Load())
+	})
+
+	t.Run("refreshes both tokens after a 401 response", func(t *testing.T) {
+		server := newTokenServer(t, time.Now().Add(time.Hour), time.Hour)
+
+		client, err := actions.NewClient(server.URL+"/my-org", auth)
+		require.NoError(t, err)
+
+		_, err = client.GetRunner(ctx, 1)
+		require.NoError(t, err)
+
+		server.unauthorizedRequests.Store(1)
+		_, err = client.GetRunner(ctx, 1)
+		require.Error(t, err)
+
+		_, err = client.GetRunner(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), server.adminConnectionCalls.Load())
+		assert.Equal(t, int32(2), server.registrationTokenCalls.Load())
+	})
+
+	t.Run("gets a new registration token when the cached one is rejected", func(t *testing.T) {
+		server := newTokenServer(t, time.Now().Add(time.Hour), 30*time.Second)
+
+		client, err := actions.NewClient(server.URL+"/my-org", auth)
+		require.NoError(t, err)
+
+		_, err = client.GetRunner(ctx, 1)
+		require.NoError(t, err)
+
+		// The cached registration token was revoked.
+		server.validRegistrationToken.Store("registration-token-2")
+		_, err = client.GetRunner(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), server.registrationTokenCalls.Load())
+		assert.Equal(t, int32(3), server.adminConnectionCalls.Load())
+	})
+}
@@ -26,14 +26,33 @@ type GitHubConfig struct {
 	Repository   string
 
 	IsHosted bool
+
+	// PathPrefix is the path a GitHub Enterprise Server is served under, e.g. behind a reverse proxy.
+	// It is empty when the server is served at the root of its host.
+	PathPrefix string
 }
 
 func ParseGitHubConfigFromURL(in string) (*GitHubConfig, error) {
+	return ParseGitHubConfigFromURLWithPathPrefix(in, "")
+}
+
+// ParseGitHubConfigFromURLWithPathPrefix parses the config URL of a GitHub Enterprise Server served under pathPrefix,
+// e.g. https://ghes.example.com/github/org with the path prefix /github.
+// The enterprise, organization and repository are parsed from the path of the config URL after the prefix,
+// and the API is expected under the prefix as well, e.g. https://ghes.example.com/github/api/v3.
+func ParseGitHubConfigFromURLWithPathPrefix(in, pathPrefix string) (*GitHubConfig, error) {
 	u, err := url.Parse(in)
 	if err != nil {
 		return nil, err
 	}
 
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%q: scheme must be http or https: %w", u.String(), ErrInvalidGitHubConfigURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q: host is missing: %w", u.String(), ErrInvalidGitHubConfigURL)
+	}
+
 	isHosted := u.Host == "github.com" ||
 		u.Host == "www.github.com" ||
 		u.Host == "github.localhost"
@@ -43,9 +62,22 @@ func ParseGitHubConfigFromURL(in string) (*GitHubConfig, error) {
 		IsHosted:  isHosted,
 	}
 
+	path := u.Path
+	if pathPrefix = strings.Trim(pathPrefix, "/"); pathPrefix != "" {
+		if isHosted {
+			return nil, fmt.Errorf("%q: a path prefix is only supported for GitHub Enterprise Server: %w", u.String(), ErrInvalidGitHubConfigURL)
+		}
+
+		configURL.PathPrefix = "/" + pathPrefix
+		if !strings.HasPrefix(path, configURL.PathPrefix+"/") {
+			return nil, fmt.Errorf("%q: path is not under the path prefix %q: %w", u.String(), configURL.PathPrefix, ErrInvalidGitHubConfigURL)
+		}
+		path = strings.TrimPrefix(path, configURL.PathPrefix)
+	}
+
 	invalidURLError := fmt.Errorf("%q: %w", u.String(), ErrInvalidGitHubConfigURL)
 
-	pathParts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	pathParts := strings.Split(strings.TrimPrefix(path, "/"), "/")
 
 	switch len(pathParts) {
 	case 1: // Organization
@@ -89,7 +121,7 @@ func (c *GitHubConfig) GitHubAPIURL(path string) *url.URL {
 	// Enterprise
 	default:
 		result.Host = c.ConfigURL.Host
-		result.Path = "/api/v3"
+		result.Path = c.PathPrefix + "/api/v3"
 	}
 
 	result.Path += path
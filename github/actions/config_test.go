@@ -96,6 +96,8 @@ func TestGitHubConfig(t *testing.T) {
 			"https://github.com/",
 			"https://github.com",
 			"https://github.com/some/random/path",
+			"github.com/org",
+			"ftp://my-ghes.com/org",
 		}
 
 		for _, u := range invalidURLs {
@@ -115,4 +117,42 @@ func TestGitHubConfig_GitHubAPIURL(t *testing.T) {
 		assert.Equal(t, "https://api.github.com/some/path", result.String())
 	})
 	t.Run("when not hosted", func(t *testing.T) {})
+	t.Run("when served under a path prefix", func(t *testing.T) {
+		config, err := actions.ParseGitHubConfigFromURLWithPathPrefix("https://my-ghes.com/github/org/repo", "/github/")
+		require.NoError(t, err)
+
+		result := config.GitHubAPIURL("/some/path")
+		assert.Equal(t, "https://my-ghes.com/github/api/v3/some/path", result.String())
+	})
+}
+
+func TestGitHubConfigWithPathPrefix(t *testing.T) {
+	t.Run("when given a valid URL", func(t *testing.T) {
+		config, err := actions.ParseGitHubConfigFromURLWithPathPrefix("https://my-ghes.com/github/org/repo", "github")
+		require.NoError(t, err)
+		assert.Equal(t, actions.GitHubScopeRepository, config.Scope)
+		assert.Equal(t, "org", config.Organization)
+		assert.Equal(t, "repo", config.Repository)
+		assert.Equal(t, "/github", config.PathPrefix)
+
+		config, err = actions.ParseGitHubConfigFromURLWithPathPrefix("https://my-ghes.com/proxy/github/enterprises/my-enterprise", "/proxy/github")
+		require.NoError(t, err)
+		assert.Equal(t, actions.GitHubScopeEnterprise, config.Scope)
+		assert.Equal(t, "my-enterprise", config.Enterprise)
+	})
+
+	t.Run("when given an invalid URL", func(t *testing.T) {
+		invalidURLs := []string{
+			"https://my-ghes.com/org",
+			"https://my-ghes.com/github",
+			"https://my-ghes.com/githubber/org",
+			"https://github.com/github/org",
+		}
+
+		for _, u := range invalidURLs {
+			_, err := actions.ParseGitHubConfigFromURLWithPathPrefix(u, "/github")
+			require.Error(t, err, u)
+			assert.True(t, errors.Is(err, actions.ErrInvalidGitHubConfigURL))
+		}
+	})
 }
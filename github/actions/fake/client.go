@@ -17,6 +17,13 @@ func WithGetRunnerScaleSetResult(scaleSet *actions.RunnerScaleSet, err error) Op
 	}
 }
 
+func WithGetRunnerScaleSetById(scaleSet *actions.RunnerScaleSet, err error) Option {
+	return func(f *FakeClient) {
+		f.getRunnerScaleSetByIdResult.RunnerScaleSet = scaleSet
+		f.getRunnerScaleSetByIdResult.err = err
+	}
+}
+
 func WithGetRunnerGroup(runnerGroup *actions.RunnerGroup, err error) Option {
 	return func(f *FakeClient) {
 		f.getRunnerGroupByNameResult.RunnerGroup = runnerGroup
@@ -45,6 +52,12 @@ func WithUpdateRunnerScaleSet(scaleSet *actions.RunnerScaleSet, err error) Optio
 	}
 }
 
+func WithRemoveRunner(err error) Option {
+	return func(f *FakeClient) {
+		f.removeRunnerResult.err = err
+	}
+}
+
 var defaultRunnerScaleSet = &actions.RunnerScaleSet{
 	Id:                 1,
 	Name:               "testset",
@@ -220,7 +233,7 @@ func (f *FakeClient) GetRunnerScaleSet(ctx context.Context, runnerScaleSetName s
 }
 
 func (f *FakeClient) GetRunnerScaleSetById(ctx context.Context, runnerScaleSetId int) (*actions.RunnerScaleSet, error) {
-	return f.getRunnerScaleSetByIdResult.RunnerScaleSet, f.getRunnerScaleSetResult.err
+	return f.getRunnerScaleSetByIdResult.RunnerScaleSet, f.getRunnerScaleSetByIdResult.err
 }
 
 func (f *FakeClient) GetRunnerGroupByName(ctx context.Context, runnerGroup string) (*actions.RunnerGroup, error) {
@@ -38,6 +38,6 @@ func (f *fakeMultiClient) GetClientFor(ctx context.Context, githubConfigURL stri
 	return f.defaultClient, f.defaultErr
 }
 
-func (f *fakeMultiClient) GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secretData actions.KubernetesSecretData, options ...actions.ClientOption) (actions.ActionsService, error) {
+func (f *fakeMultiClient) GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secret actions.KubernetesSecret, options ...actions.ClientOption) (actions.ActionsService, error) {
 	return f.defaultClient, f.defaultErr
 }
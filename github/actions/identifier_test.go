@@ -1,6 +1,8 @@
 package actions_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/actions/actions-runner-controller/github/actions"
@@ -108,4 +110,24 @@ func TestClient_Identifier(t *testing.T) {
 			})
 		}
 	})
+	t.Run("proxy CA certificate changes", func(t *testing.T) {
+		configURL := "https://github.com/org/repo"
+		creds := &actions.ActionsAuth{
+			Token: "token",
+		}
+
+		cert, err := os.ReadFile(filepath.Join("testdata", "rootCA.crt"))
+		require.NoError(t, err)
+
+		oldClient, err := actions.NewClient(configURL, creds)
+		require.NoError(t, err)
+
+		newClient, err := actions.NewClient(configURL, creds, actions.WithProxyCACertificate(cert))
+		require.NoError(t, err)
+		assert.NotEqual(t, oldClient.Identifier(), newClient.Identifier())
+
+		sameClient, err := actions.NewClient(configURL, creds, actions.WithProxyCACertificate(cert))
+		require.NoError(t, err)
+		assert.Equal(t, newClient.Identifier(), sameClient.Identifier())
+	})
 }
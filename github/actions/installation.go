@@ -0,0 +1,144 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const installationsPerPage = 100
+
+// Format: https://docs.github.com/en/rest/apps/apps#list-installations-for-the-authenticated-app
+type appInstallation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login string `json:"login"`
+		Slug  string `json:"slug"`
+	} `json:"account"`
+	TargetType string `json:"target_type"`
+}
+
+func (i *appInstallation) accountName() string {
+	if i.Account.Login != "" {
+		return i.Account.Login
+	}
+	return i.Account.Slug
+}
+
+// appInstallationID returns the installation ID of the GitHub App.
+// When the credentials do not carry one, it is looked up from the
+// installations of the app matching the configured GitHub config URL
+// and cached for the lifetime of the client.
+func (c *Client) appInstallationID(ctx context.Context, creds *GitHubAppAuth) (int64, error) {
+	if creds.AppInstallationID != 0 {
+		return creds.AppInstallationID, nil
+	}
+
+	c.installationMu.Lock()
+	defer c.installationMu.Unlock()
+
+	if c.discoveredInstallationID != 0 {
+		return c.discoveredInstallationID, nil
+	}
+
+	installations, err := c.listAppInstallations(ctx, creds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list installations of GitHub App %d: %v", creds.AppID, err)
+	}
+
+	var candidates []*appInstallation
+	for _, installation := range installations {
+		if c.config.matchesInstallation(installation) {
+			candidates = append(candidates, installation)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return 0, fmt.Errorf("GitHub App %d has no installation on %q, install the app there or set github_app_installation_id", creds.AppID, c.config.installationAccount())
+	case 1:
+		c.discoveredInstallationID = candidates[0].ID
+		c.logger.Info("discovered GitHub App installation", "appID", creds.AppID, "installationID", c.discoveredInstallationID)
+		return c.discoveredInstallationID, nil
+	default:
+		names := make([]string, 0, len(candidates))
+		for _, candidate := range candidates {
+			names = append(names, fmt.Sprintf("%d (%s %s)", candidate.ID, candidate.TargetType, candidate.accountName()))
+		}
+		return 0, fmt.Errorf("GitHub App %d has multiple installations matching %q, set github_app_installation_id to one of: %s", creds.AppID, c.config.installationAccount(), strings.Join(names, ", "))
+	}
+}
+
+func (c *Client) listAppInstallations(ctx context.Context, creds *GitHubAppAuth) ([]*appInstallation, error) {
+	jwt, err := createJWTForGitHubApp(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	var installations []*appInstallation
+	for page := 1; ; page++ {
+		req, err := c.NewGitHubAPIRequest(ctx, http.MethodGet, "/app/installations", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		q := req.URL.Query()
+		q.Set("per_page", strconv.Itoa(installationsPerPage))
+		q.Set("page", strconv.Itoa(page))
+		req.URL.RawQuery = q.Encode()
+
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwt))
+
+		resp, err := c.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("unexpected response from GitHub API: %v - %v", resp.StatusCode, string(body))
+		}
+
+		var pageInstallations []*appInstallation
+		err = json.NewDecoder(resp.Body).Decode(&pageInstallations)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		installations = append(installations, pageInstallations...)
+		if len(pageInstallations) < installationsPerPage {
+			return installations, nil
+		}
+	}
+}
+
+// installationAccount returns the account a GitHub App has to be installed on to manage runners for the config URL.
+func (c *GitHubConfig) installationAccount() string {
+	if c.Scope == GitHubScopeEnterprise {
+		return c.Enterprise
+	}
+	return c.Organization
+}
+
+func (c *GitHubConfig) matchesInstallation(installation *appInstallation) bool {
+	if c.Scope == GitHubScopeEnterprise {
+		return strings.EqualFold(installation.TargetType, "Enterprise") &&
+			(strings.EqualFold(installation.Account.Slug, c.Enterprise) || strings.EqualFold(installation.Account.Login, c.Enterprise))
+	}
+
+	if strings.EqualFold(installation.TargetType, "Enterprise") {
+		return false
+	}
+
+	return strings.EqualFold(installation.Account.Login, c.Organization)
+}
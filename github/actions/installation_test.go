@@ -0,0 +1,113 @@
+package actions_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubAppInstallationDiscovery(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+
+	newServer := func(t *testing.T, installations string, listCalls *int, accessTokenPath *string) *actionsServer {
+		return newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/app/installations"):
+				*listCalls++
+				w.Write([]byte(installations))
+			case strings.HasSuffix(r.URL.Path, "/access_tokens"):
+				*accessTokenPath = r.URL.Path
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"token":"token","expires_at":"2099-01-01T00:00:00Z"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	}
+
+	t.Run("uses the installation matching the config URL and caches it", func(t *testing.T) {
+		var listCalls int
+		var accessTokenPath string
+		server := newServer(t, `[
+			{"id":1,"target_type":"Organization","account":{"login":"other-org"}},
+			{"id":2,"target_type":"Organization","account":{"login":"My-Org"}}
+		]`, &listCalls, &accessTokenPath)
+
+		creds := &actions.ActionsAuth{AppCreds: &actions.GitHubAppAuth{AppID: 1, AppPrivateKey: privateKey}}
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), creds)
+		require.NoError(t, err)
+
+		_, err = client.NewActionsServiceRequest(ctx, http.MethodGet, "/my/path", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "/api/v3/app/installations/2/access_tokens", accessTokenPath)
+
+		client.ActionsServiceAdminToken = ""
+		_, err = client.NewActionsServiceRequest(ctx, http.MethodGet, "/my/path", nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, listCalls)
+	})
+
+	t.Run("does not list installations when the installation ID is set", func(t *testing.T) {
+		var listCalls int
+		var accessTokenPath string
+		server := newServer(t, `[]`, &listCalls, &accessTokenPath)
+
+		creds := &actions.ActionsAuth{AppCreds: &actions.GitHubAppAuth{AppID: 1, AppInstallationID: 5, AppPrivateKey: privateKey}}
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), creds)
+		require.NoError(t, err)
+
+		_, err = client.NewActionsServiceRequest(ctx, http.MethodGet, "/my/path", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "/api/v3/app/installations/5/access_tokens", accessTokenPath)
+		assert.Equal(t, 0, listCalls)
+	})
+
+	t.Run("fails when the app is not installed on the config URL", func(t *testing.T) {
+		var listCalls int
+		var accessTokenPath string
+		server := newServer(t, `[{"id":1,"target_type":"Organization","account":{"login":"other-org"}}]`, &listCalls, &accessTokenPath)
+
+		creds := &actions.ActionsAuth{AppCreds: &actions.GitHubAppAuth{AppID: 1, AppPrivateKey: privateKey}}
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), creds)
+		require.NoError(t, err)
+
+		_, err = client.NewActionsServiceRequest(ctx, http.MethodGet, "/my/path", nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `has no installation on "my-org"`)
+		assert.Empty(t, accessTokenPath)
+	})
+
+	t.Run("lists the candidates when multiple installations match", func(t *testing.T) {
+		var listCalls int
+		var accessTokenPath string
+		server := newServer(t, `[
+			{"id":1,"target_type":"Enterprise","account":{"slug":"my-enterprise"}},
+			{"id":2,"target_type":"Enterprise","account":{"login":"my-enterprise"}}
+		]`, &listCalls, &accessTokenPath)
+
+		creds := &actions.ActionsAuth{AppCreds: &actions.GitHubAppAuth{AppID: 1, AppPrivateKey: privateKey}}
+		client, err := actions.NewClient(server.URL+"/enterprises/my-enterprise", creds)
+		require.NoError(t, err)
+
+		_, err = client.NewActionsServiceRequest(ctx, http.MethodGet, "/my/path", nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 (Enterprise my-enterprise), 2 (Enterprise my-enterprise)")
+		assert.Empty(t, accessTokenPath)
+	})
+}
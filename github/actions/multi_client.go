@@ -12,7 +12,7 @@ import (
 
 type MultiClient interface {
 	GetClientFor(ctx context.Context, githubConfigURL string, creds ActionsAuth, namespace string, options ...ClientOption) (ActionsService, error)
-	GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secretData KubernetesSecretData, options ...ClientOption) (ActionsService, error)
+	GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secret KubernetesSecret, options ...ClientOption) (ActionsService, error)
 }
 
 type multiClient struct {
@@ -20,8 +20,15 @@ type multiClient struct {
 	mu      sync.Mutex
 	clients map[ActionsClientKey]*Client
 
+	// secretClients tracks the client built from each version of a GitHub config secret,
+	// so that the client is rebuilt once the secret is rotated.
+	secretClients map[secretClientKey]secretClient
+
 	logger    logr.Logger
 	userAgent string
+
+	// options are applied to every client before the options of the request for the client.
+	options []ClientOption
 }
 
 type GitHubAppAuth struct {
@@ -43,16 +50,44 @@ type ActionsClientKey struct {
 	Namespace  string
 }
 
-func NewMultiClient(userAgent string, logger logr.Logger) MultiClient {
+type secretClientKey struct {
+	githubConfigURL string
+	namespace       string
+	secretName      string
+}
+
+type secretClient struct {
+	resourceVersion string
+	key             ActionsClientKey
+}
+
+// NewMultiClient returns a MultiClient whose clients are built with the options, e.g. WithGitHubPathPrefix,
+// followed by the options of each request for a client.
+func NewMultiClient(userAgent string, logger logr.Logger, options ...ClientOption) MultiClient {
 	return &multiClient{
-		mu:        sync.Mutex{},
-		clients:   make(map[ActionsClientKey]*Client),
-		logger:    logger,
-		userAgent: userAgent,
+		mu:            sync.Mutex{},
+		clients:       make(map[ActionsClientKey]*Client),
+		secretClients: make(map[secretClientKey]secretClient),
+		logger:        logger,
+		userAgent:     userAgent,
+		options:       options,
 	}
 }
 
 func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string, creds ActionsAuth, namespace string, options ...ClientOption) (ActionsService, error) {
+	client, err := m.getClient(githubConfigURL, creds, namespace, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.cacheClient(client, namespace), nil
+}
+
+// getClient validates the credentials and builds a new client. The client is not cached.
+func (m *multiClient) getClient(githubConfigURL string, creds ActionsAuth, namespace string, options ...ClientOption) (*Client, error) {
 	m.logger.Info("retrieve actions client", "githubConfigURL", githubConfigURL, "namespace", namespace)
 
 	if creds.Token == "" && creds.AppCreds == nil {
@@ -66,18 +101,21 @@ func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string,
 	client, err := NewClient(
 		githubConfigURL,
 		&creds,
-		append([]ClientOption{
+		append(append([]ClientOption{
 			WithUserAgent(m.userAgent),
 			WithLogger(m.logger),
-		}, options...)...,
+		}, m.options...), options...)...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return client, nil
+}
 
+// cacheClient returns the cached client with the same identifier as client, or caches client if there is none.
+// The caller must hold m.mu.
+func (m *multiClient) cacheClient(client *Client, namespace string) *Client {
 	key := ActionsClientKey{
 		Identifier: client.Identifier(),
 		Namespace:  namespace,
@@ -85,24 +123,113 @@ func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string,
 
 	cachedClient, has := m.clients[key]
 	if has {
-		m.logger.Info("using cache client", "githubConfigURL", githubConfigURL, "namespace", namespace)
-		return cachedClient, nil
+		m.logger.Info("using cache client", "githubConfigURL", client.config.ConfigURL.String(), "namespace", namespace)
+		return cachedClient
 	}
 
-	m.logger.Info("creating new client", "githubConfigURL", githubConfigURL, "namespace", namespace)
+	m.logger.Info("creating new client", "githubConfigURL", client.config.ConfigURL.String(), "namespace", namespace)
 
 	m.clients[key] = client
 
-	m.logger.Info("successfully created new client", "githubConfigURL", githubConfigURL, "namespace", namespace)
+	m.logger.Info("successfully created new client", "githubConfigURL", client.config.ConfigURL.String(), "namespace", namespace)
 
-	return client, nil
+	return client
+}
+
+// evictClient removes the client cached under key, unless it is still used by another GitHub config secret.
+// The evicted client is not closed, so requests that are already using it complete normally.
+// It stops reporting its rate limit series, which is deleted unless a client cached for another namespace reports it too.
+// The caller must hold m.mu.
+func (m *multiClient) evictClient(key ActionsClientKey) {
+	for _, sc := range m.secretClients {
+		if sc.key == key {
+			return
+		}
+	}
+
+	client, ok := m.clients[key]
+	if !ok {
+		return
+	}
+	delete(m.clients, key)
+
+	if client.rateLimit == nil {
+		return
+	}
+	labels := client.rateLimit.stopMetric()
+	for k := range m.clients {
+		if k.Identifier == key.Identifier {
+			return
+		}
+	}
+	if labels != nil {
+		rateLimitRemaining.DeleteLabelValues(labels...)
+	}
 }
 
 type KubernetesSecretData map[string][]byte
 
-func (m *multiClient) GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secretData KubernetesSecretData, options ...ClientOption) (ActionsService, error) {
+// KubernetesSecret is the GitHub config secret the client is built from.
+type KubernetesSecret struct {
+	Name            string
+	ResourceVersion string
+	Data            KubernetesSecretData
+}
+
+// GetClientFromSecret returns the client for the GitHub config secret.
+//
+// Clients are cached per GitHub config URL, namespace and secret name. When the resource version
+// of the secret changes, e.g. because the credentials were rotated, the client built from the previous version
+// is dropped from the cache and a new client is built.
+func (m *multiClient) GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secret KubernetesSecret, options ...ClientOption) (ActionsService, error) {
+	creds, err := actionsAuthFromSecretData(secret.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := m.getClient(githubConfigURL, creds, namespace, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	secretKey := secretClientKey{
+		githubConfigURL: githubConfigURL,
+		namespace:       namespace,
+		secretName:      secret.Name,
+	}
+
+	previous, has := m.secretClients[secretKey]
+	delete(m.secretClients, secretKey)
+	if has && previous.resourceVersion != secret.ResourceVersion {
+		m.logger.Info("github config secret changed, rebuilding client", "githubConfigURL", githubConfigURL, "namespace", namespace, "secret", secret.Name)
+		m.evictClient(previous.key)
+	}
+
+	cachedClient := m.cacheClient(client, namespace)
+
+	key := ActionsClientKey{
+		Identifier: cachedClient.Identifier(),
+		Namespace:  namespace,
+	}
+	if has && previous.key != key {
+		// The client options changed, e.g. the proxy configuration.
+		m.evictClient(previous.key)
+	}
+
+	m.secretClients[secretKey] = secretClient{
+		resourceVersion: secret.ResourceVersion,
+		key:             key,
+	}
+
+	return cachedClient, nil
+}
+
+func actionsAuthFromSecretData(secretData KubernetesSecretData) (ActionsAuth, error) {
 	if len(secretData) == 0 {
-		return nil, fmt.Errorf("must provide secret data with either PAT or GitHub App Auth")
+		return ActionsAuth{}, fmt.Errorf("must provide secret data with either PAT or GitHub App Auth")
 	}
 
 	token := string(secretData["github_token"])
@@ -111,35 +238,39 @@ func (m *multiClient) GetClientFromSecret(ctx context.Context, githubConfigURL,
 	appID := string(secretData["github_app_id"])
 	appInstallationID := string(secretData["github_app_installation_id"])
 	appPrivateKey := string(secretData["github_app_private_key"])
-	hasGitHubAppAuth := len(appID) > 0 && len(appInstallationID) > 0 && len(appPrivateKey) > 0
+	// The installation ID is optional, it is discovered from the GitHub config URL when omitted.
+	hasGitHubAppAuth := len(appID) > 0 && len(appPrivateKey) > 0
 
 	if hasToken && hasGitHubAppAuth {
-		return nil, fmt.Errorf("must provide secret with only PAT or GitHub App Auth to avoid ambiguity in client behavior")
+		return ActionsAuth{}, fmt.Errorf("must provide secret with only PAT or GitHub App Auth to avoid ambiguity in client behavior")
 	}
 
 	if !hasToken && !hasGitHubAppAuth {
-		return nil, fmt.Errorf("neither PAT nor GitHub App Auth credentials provided in secret")
+		return ActionsAuth{}, fmt.Errorf("neither PAT nor GitHub App Auth credentials provided in secret")
 	}
 
 	auth := ActionsAuth{}
 
 	if hasToken {
 		auth.Token = token
-		return m.GetClientFor(ctx, githubConfigURL, auth, namespace, options...)
+		return auth, nil
 	}
 
 	parsedAppID, err := strconv.ParseInt(appID, 10, 64)
 	if err != nil {
-		return nil, err
+		return ActionsAuth{}, err
 	}
 
-	parsedAppInstallationID, err := strconv.ParseInt(appInstallationID, 10, 64)
-	if err != nil {
-		return nil, err
+	var parsedAppInstallationID int64
+	if len(appInstallationID) > 0 {
+		parsedAppInstallationID, err = strconv.ParseInt(appInstallationID, 10, 64)
+		if err != nil {
+			return ActionsAuth{}, err
+		}
 	}
 
 	auth.AppCreds = &GitHubAppAuth{AppID: parsedAppID, AppInstallationID: parsedAppInstallationID, AppPrivateKey: appPrivateKey}
-	return m.GetClientFor(ctx, githubConfigURL, auth, namespace, options...)
+	return auth, nil
 }
 
 func RootCAsFromConfigMap(configMapData map[string][]byte) (*x509.CertPool, error) {
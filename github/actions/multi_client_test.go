@@ -3,9 +3,11 @@ package actions
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -49,6 +51,125 @@ func TestMultiClientCaching(t *testing.T) {
 	assert.Len(t, multiClient.clients, 2)
 }
 
+func TestMultiClientSecretRotation(t *testing.T) {
+	logger := logr.Discard()
+	ctx := context.Background()
+	multiClient := NewMultiClient("test-user-agent", logger).(*multiClient)
+
+	defaultNamespace := "default"
+	defaultConfigURL := "https://github.com/org/repo"
+
+	secret := KubernetesSecret{
+		Name:            "github-config",
+		ResourceVersion: "1",
+		Data: map[string][]byte{
+			"github_token": []byte("token"),
+		},
+	}
+
+	client, err := multiClient.GetClientFromSecret(ctx, defaultConfigURL, defaultNamespace, secret)
+	require.NoError(t, err)
+
+	// The same version of the secret returns the cached client
+	cachedClient, err := multiClient.GetClientFromSecret(ctx, defaultConfigURL, defaultNamespace, secret)
+	require.NoError(t, err)
+	assert.Same(t, client, cachedClient)
+	assert.Len(t, multiClient.clients, 1)
+
+	// A new version of the secret rebuilds the client, even if the content is the same
+	secret.ResourceVersion = "2"
+	rebuiltClient, err := multiClient.GetClientFromSecret(ctx, defaultConfigURL, defaultNamespace, secret)
+	require.NoError(t, err)
+	assert.NotSame(t, client, rebuiltClient)
+	assert.Len(t, multiClient.clients, 1)
+
+	// Rotated credentials replace the client built from the previous version
+	secret.ResourceVersion = "3"
+	secret.Data = map[string][]byte{
+		"github_token": []byte("rotated-token"),
+	}
+	rotatedClient, err := multiClient.GetClientFromSecret(ctx, defaultConfigURL, defaultNamespace, secret)
+	require.NoError(t, err)
+	assert.NotSame(t, rebuiltClient, rotatedClient)
+	assert.Equal(t, "rotated-token", rotatedClient.(*Client).creds.Token)
+	assert.Len(t, multiClient.clients, 1)
+	assert.Len(t, multiClient.secretClients, 1)
+
+	// The replaced client is not closed and can still be used
+	assert.Equal(t, "token", rebuiltClient.(*Client).creds.Token)
+	_, err = rebuiltClient.(*Client).NewGitHubAPIRequest(ctx, "GET", "/test", nil)
+	require.NoError(t, err)
+
+	// A client shared with another secret is kept when one of the secrets is rotated
+	otherSecret := secret
+	otherSecret.Name = "other-github-config"
+	otherClient, err := multiClient.GetClientFromSecret(ctx, defaultConfigURL, defaultNamespace, otherSecret)
+	require.NoError(t, err)
+	assert.Same(t, rotatedClient, otherClient)
+
+	secret.ResourceVersion = "4"
+	secret.Data = map[string][]byte{
+		"github_token": []byte("token"),
+	}
+	_, err = multiClient.GetClientFromSecret(ctx, defaultConfigURL, defaultNamespace, secret)
+	require.NoError(t, err)
+	assert.Len(t, multiClient.clients, 2)
+
+	otherClient, err = multiClient.GetClientFromSecret(ctx, defaultConfigURL, defaultNamespace, otherSecret)
+	require.NoError(t, err)
+	assert.Same(t, rotatedClient, otherClient)
+}
+
+func TestMultiClientSecretRotationDeletesRateLimitMetric(t *testing.T) {
+	logger := logr.Discard()
+	ctx := context.Background()
+	multiClient := NewMultiClient("test-user-agent", logger).(*multiClient)
+
+	defaultConfigURL := "https://github.com/org/rate-limit"
+
+	secret := KubernetesSecret{
+		Name:            "github-config",
+		ResourceVersion: "1",
+		Data: map[string][]byte{
+			"github_token": []byte("token"),
+		},
+	}
+
+	client, err := multiClient.GetClientFromSecret(ctx, defaultConfigURL, "default", secret)
+	require.NoError(t, err)
+	sharedClient, err := multiClient.GetClientFromSecret(ctx, defaultConfigURL, "other", secret)
+	require.NoError(t, err)
+
+	staleLabels := []string{defaultConfigURL, client.(*Client).Identifier()}
+	client.(*Client).rateLimit.update(rateLimitResponse("10"))
+	sharedClient.(*Client).rateLimit.update(rateLimitResponse("10"))
+	assert.Equal(t, float64(10), testutil.ToFloat64(rateLimitRemaining.WithLabelValues(staleLabels...)))
+
+	// The series is kept while a client for another namespace still reports it
+	secret.ResourceVersion = "2"
+	secret.Data = map[string][]byte{
+		"github_token": []byte("rotated-token"),
+	}
+	_, err = multiClient.GetClientFromSecret(ctx, defaultConfigURL, "default", secret)
+	require.NoError(t, err)
+	client.(*Client).rateLimit.update(rateLimitResponse("5"))
+	assert.Equal(t, float64(10), testutil.ToFloat64(rateLimitRemaining.WithLabelValues(staleLabels...)))
+
+	// The series is deleted once the last client reporting it is evicted,
+	// and the evicted clients no longer report it
+	_, err = multiClient.GetClientFromSecret(ctx, defaultConfigURL, "other", secret)
+	require.NoError(t, err)
+	client.(*Client).rateLimit.update(rateLimitResponse("5"))
+	sharedClient.(*Client).rateLimit.update(rateLimitResponse("5"))
+	assert.False(t, rateLimitRemaining.DeleteLabelValues(staleLabels...))
+}
+
+func rateLimitResponse(remaining string) *http.Response {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	resp.Header.Set(headerRateLimitRemaining, remaining)
+	return resp
+}
+
 func TestMultiClientOptions(t *testing.T) {
 	logger := logr.Discard()
 	ctx := context.Background()
@@ -78,8 +199,12 @@ func TestMultiClientOptions(t *testing.T) {
 	})
 
 	t.Run("GetClientFromSecret", func(t *testing.T) {
-		secret := map[string][]byte{
-			"github_token": []byte("token"),
+		secret := KubernetesSecret{
+			Name:            "github-config",
+			ResourceVersion: "1",
+			Data: map[string][]byte{
+				"github_token": []byte("token"),
+			},
 		}
 
 		multiClient := NewMultiClient("test-user-agent", logger)
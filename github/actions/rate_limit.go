@@ -0,0 +1,113 @@
+package actions
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+)
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitRemaining)
+}
+
+var rateLimitRemaining = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "arc_github_api_rate_limit_remaining",
+		Help: "The number of GitHub API requests remaining in the current rate limit window of an actions client.",
+	},
+	[]string{"github_config_url", "client"},
+)
+
+// rateLimitTransport tracks the rate limit reported by the GitHub API and delays
+// requests until the rate limit is reset once the remaining budget is exhausted.
+type rateLimitTransport struct {
+	transport http.RoundTripper
+
+	mu sync.Mutex
+	// labels of the rate limit series of the client, nil once the series is deleted
+	labels    []string
+	remaining int
+	reset     time.Time
+
+	// now is used to mock time in tests
+	now func() time.Time
+}
+
+func newRateLimitTransport(transport http.RoundTripper, labels []string) *rateLimitTransport {
+	return &rateLimitTransport{
+		transport: transport,
+		labels:    labels,
+		remaining: -1,
+		now:       time.Now,
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if wait := t.wait(); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if resp != nil {
+		t.update(resp)
+	}
+	return resp, err
+}
+
+// wait returns how long the next request should be delayed.
+func (t *rateLimitTransport) wait() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.remaining != 0 {
+		return 0
+	}
+
+	return t.reset.Sub(t.now())
+}
+
+func (t *rateLimitTransport) update(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get(headerRateLimitRemaining))
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.remaining = remaining
+	if reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64); err == nil {
+		t.reset = time.Unix(reset, 0)
+	}
+
+	if t.labels != nil {
+		rateLimitRemaining.WithLabelValues(t.labels...).Set(float64(remaining))
+	}
+}
+
+// stopMetric stops reporting the rate limit series and returns its labels, so the
+// series of clients replaced when their credentials are rotated can be deleted.
+func (t *rateLimitTransport) stopMetric() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	labels := t.labels
+	t.labels = nil
+	return labels
+}
@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	var remaining string
+	reset := now.Add(time.Minute)
+	requests := 0
+	transport := newRateLimitTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		resp.Header.Set(headerRateLimitRemaining, remaining)
+		resp.Header.Set(headerRateLimitReset, strconv.FormatInt(reset.Unix(), 10))
+		return resp, nil
+	}), nil)
+	transport.now = func() time.Time { return now }
+
+	t.Run("does not wait without rate limit information", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), transport.wait())
+	})
+
+	t.Run("does not wait while budget remains", func(t *testing.T) {
+		remaining = "10"
+		req, err := http.NewRequest(http.MethodGet, "https://api.github.com", nil)
+		require.NoError(t, err)
+
+		_, err = transport.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(0), transport.wait())
+	})
+
+	t.Run("waits until reset once the budget is exhausted", func(t *testing.T) {
+		remaining = "0"
+		req, err := http.NewRequest(http.MethodGet, "https://api.github.com", nil)
+		require.NoError(t, err)
+
+		_, err = transport.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, transport.wait())
+	})
+
+	t.Run("stops waiting when the request is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com", nil)
+		require.NoError(t, err)
+
+		before := requests
+		_, err = transport.RoundTrip(req)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, before, requests)
+	})
+
+	t.Run("does not wait after reset", func(t *testing.T) {
+		now = reset.Add(time.Second)
+		assert.True(t, transport.wait() < 0)
+	})
+}
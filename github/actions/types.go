@@ -55,14 +55,15 @@ type JobMessageType struct {
 
 type JobMessageBase struct {
 	JobMessageType
-	RunnerRequestId int64    `json:"runnerRequestId"`
-	RepositoryName  string   `json:"repositoryName"`
-	OwnerName       string   `json:"ownerName"`
-	JobWorkflowRef  string   `json:"jobWorkflowRef"`
-	JobDisplayName  string   `json:"jobDisplayName"`
-	WorkflowRunId   int64    `json:"workflowRunId"`
-	EventName       string   `json:"eventName"`
-	RequestLabels   []string `json:"requestLabels"`
+	RunnerRequestId int64     `json:"runnerRequestId"`
+	RepositoryName  string    `json:"repositoryName"`
+	OwnerName       string    `json:"ownerName"`
+	JobWorkflowRef  string    `json:"jobWorkflowRef"`
+	JobDisplayName  string    `json:"jobDisplayName"`
+	WorkflowRunId   int64     `json:"workflowRunId"`
+	EventName       string    `json:"eventName"`
+	RequestLabels   []string  `json:"requestLabels"`
+	QueueTime       time.Time `json:"queueTime,omitempty"`
 }
 
 type Label struct {
@@ -145,8 +146,12 @@ type RunnerReference struct {
 	Id               int    `json:"id"`
 	Name             string `json:"name"`
 	RunnerScaleSetId int    `json:"runnerScaleSetId"`
+	Status           string `json:"status,omitempty"`
 }
 
+// RunnerStatusOnline is the status of a runner connected to the service.
+const RunnerStatusOnline = "online"
+
 type RunnerScaleSetJitRunnerConfig struct {
 	Runner           *RunnerReference `json:"runner"`
 	EncodedJITConfig string           `json:"encodedJITConfig"`
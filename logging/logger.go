@@ -20,6 +20,8 @@ const (
 	LogLevelError = "error"
 	LogFormatText = "text"
 	LogFormatJSON = "json"
+	// LogFormatConsole is an alias of LogFormatText.
+	LogFormatConsole = "console"
 )
 
 var (
@@ -45,7 +47,7 @@ func NewLogger(logLevel string, logFormat string) (logr.Logger, error) {
 	o.EncoderConfigOptions = make([]zap.EncoderConfigOption, len(LogOpts.EncoderConfigOptions))
 	copy(o.EncoderConfigOptions, LogOpts.EncoderConfigOptions)
 
-	if logFormat == "json" {
+	if logFormat == LogFormatJSON {
 		o.Development = false
 		o.TimeEncoder = nil
 	}
@@ -80,7 +82,7 @@ func NewLogger(logLevel string, logFormat string) (logr.Logger, error) {
 }
 
 func validLogFormat(logFormat string) bool {
-	validFormat := []string{"text", "json"}
+	validFormat := []string{LogFormatText, LogFormatJSON, LogFormatConsole}
 	for _, v := range validFormat {
 		if v == logFormat {
 			return true
@@ -18,9 +18,11 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -32,8 +34,12 @@ import (
 	"github.com/actions/actions-runner-controller/github"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/logging"
+	"github.com/actions/actions-runner-controller/pkg/certreloader"
+	"github.com/go-logr/logr"
 	"github.com/kelseyhightower/envconfig"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -84,6 +90,8 @@ func main() {
 
 		defaultScaleDownDelay time.Duration
 
+		webhookCertReloadInterval time.Duration
+
 		runnerImage            string
 		runnerImagePullSecrets stringSlice
 
@@ -94,6 +102,73 @@ func main() {
 		logFormat            string
 
 		autoScalerImagePullSecrets stringSlice
+		runnerFailureLogLines      int64
+
+		runnerSetRequeueInterval time.Duration
+		runnerSetRequeueJitter   float64
+
+		runnerRegistrationReadinessGate bool
+		runnerPodCreationBackoffMax     time.Duration
+		runnerRemovedCheckInterval      time.Duration
+		runnerScheduleMetricsNodeLabel  string
+		runnerUnschedulableThreshold    time.Duration
+		runnerUnschedulableRetry        bool
+		runnerJobEvents                 bool
+		runnerNodeAnnotation            bool
+		runnerSetFinalizerTimeout       time.Duration
+
+		runnerCompletionWebhookURL       string
+		runnerCompletionWebhookQueueSize int
+
+		runnerDefaultCPURequest    string
+		runnerDefaultMemoryRequest string
+		runnerDefaultCPULimit      string
+		runnerDefaultMemoryLimit   string
+
+		runnerDefaultRunAsNonRoot           bool
+		runnerDefaultReadOnlyRootFilesystem bool
+		runnerDefaultSeccompProfile         string
+		runnerDefaultDropCapabilities       string
+		runnerForbidPrivileged              bool
+
+		runnerImagePullPolicy             string
+		runnerImagePullPolicyKeepTemplate bool
+
+		runnerDefaultWorkVolume          bool
+		runnerDefaultWorkVolumeSizeLimit string
+		runnerWorkDir                    string
+		windowsRunnerWorkDir             string
+
+		runnerPreflightCheckImage   string
+		runnerPreflightCheckCommand string
+
+		windowsRunnerPreflightCheckImage   string
+		windowsRunnerPreflightCheckCommand string
+
+		runnerSetOrphanedProxySecretSweepInterval time.Duration
+		runnerSetSelector                         string
+		runnerSetStartupScaleDownDelay            time.Duration
+
+		autoscalingRunnerSetMaxConcurrentReconciles int
+		runnerSetMaxConcurrentReconciles            int
+		runnerMaxConcurrentReconciles               int
+
+		runnerInventoryEndpoint bool
+
+		runnerDeregistrationRateLimit float64
+		runnerDeregistrationBurst     int
+
+		maxRunnersPerNamespace int
+
+		githubPathPrefix string
+
+		runnerScaleSetCheckInterval time.Duration
+
+		listenerScaleSetRegistrationRetries int
+
+		skipRunnerDeregistration bool
+
+		dryRun bool
 
 		commonRunnerLabels commaSeparatedStringSlice
 	)
@@ -125,15 +200,119 @@ func main() {
 	flag.BoolVar(&runnerStatusUpdateHook, "runner-status-update-hook", false, "Use custom RBAC for runners (role, role binding and service account).")
 	flag.DurationVar(&defaultScaleDownDelay, "default-scale-down-delay", actionssummerwindnet.DefaultScaleDownDelay, "The approximate delay for a scale down followed by a scale up, used to prevent flapping (down->up->down->... loop)")
 	flag.IntVar(&port, "port", 9443, "The port to which the admission webhook endpoint should bind")
+	flag.DurationVar(&webhookCertReloadInterval, "webhook-cert-reload-interval", 0, "How often the serving certificate of the admission webhook is read again, to serve a rotated certificate without restarting the controller. The current certificate is kept until the new certificate and key form a valid pair. Set to 0 to disable the reload.")
 	flag.DurationVar(&syncPeriod, "sync-period", 1*time.Minute, "Determines the minimum frequency at which K8s resources managed by this controller are reconciled.")
 	flag.Var(&commonRunnerLabels, "common-runner-labels", "Runner labels in the K1=V1,K2=V2,... format that are inherited all the runners created by the controller. See https://github.com/actions/actions-runner-controller/issues/321 for more information")
 	flag.StringVar(&namespace, "watch-namespace", "", "The namespace to watch for custom resources. Set to empty for letting it watch for all namespaces.")
 	flag.StringVar(&logLevel, "log-level", logging.LogLevelDebug, `The verbosity of the logging. Valid values are "debug", "info", "warn", "error". Defaults to "debug".`)
-	flag.StringVar(&logFormat, "log-format", "text", `The log format. Valid options are "text" and "json". Defaults to "text"`)
+	flag.StringVar(&logFormat, "log-format", "text", `The log format of the controller and the listeners. Valid options are "text", "console" (an alias of "text") and "json". Defaults to "text"`)
 	flag.BoolVar(&autoScalingRunnerSetOnly, "auto-scaling-runner-set-only", false, "Make controller only reconcile AutoRunnerScaleSet object.")
 	flag.Var(&autoScalerImagePullSecrets, "auto-scaler-image-pull-secrets", "The default image-pull secret name for auto-scaler listener container.")
+	flag.Int64Var(&runnerFailureLogLines, "runner-failure-log-lines", 50, "The number of runner container log lines stored in the EphemeralRunner status when the runner pod fails. Set to 0 to disable.")
+	flag.DurationVar(&runnerSetRequeueInterval, "runner-set-requeue-interval", 0, "The base interval after which an EphemeralRunnerSet is reconciled again. Set to 0 to only reconcile on changes.")
+	flag.Float64Var(&runnerSetRequeueJitter, "runner-set-requeue-jitter", 0, "The maximum fraction of the EphemeralRunnerSet requeue delay added at random, to spread reconciles of many runner sets over time. Must be between 0 and 1.")
+	flag.BoolVar(&runnerRegistrationReadinessGate, "runner-registration-readiness-gate", false, "Add a readiness gate to EphemeralRunner pods, so they only become Ready once the runner is registered with GitHub.")
+	flag.DurationVar(&runnerPodCreationBackoffMax, "runner-pod-creation-backoff-max", actionsgithubcom.DefaultPodCreationBackoffMax, "The maximum backoff between the creation of successive pods of an EphemeralRunner after pod failures.")
+	flag.DurationVar(&runnerRemovedCheckInterval, "runner-removed-check-interval", 0, "How often an idle EphemeralRunner is checked to still exist in GitHub, once it has been idle for that long. EphemeralRunners removed from GitHub, e.g. from the GitHub UI, are deleted and re-created by their EphemeralRunnerSet. Set to 0 to disable the check.")
+	flag.StringVar(&runnerScheduleMetricsNodeLabel, "runner-schedule-metrics-node-label", "", "The node label, e.g. karpenter.sh/nodepool, whose value labels the arc_runner_schedule_seconds metric as node_pool. Node names are never used as label, to keep the cardinality of the metric bounded. Requires reading nodes.")
+	flag.DurationVar(&runnerUnschedulableThreshold, "runner-unschedulable-threshold", actionsgithubcom.DefaultUnschedulableThreshold, "How long an EphemeralRunner pod can be pending because it can't be scheduled before it is reported with an event and the RunnersUnschedulable condition of its EphemeralRunnerSet. Set to 0 to disable the detection.")
+	flag.BoolVar(&runnerJobEvents, "runner-job-events", false, "Record an event on each EphemeralRunner when a job is assigned to it and when it completes the job, including the job request ID.")
+	flag.StringVar(&runnerCompletionWebhookURL, "runner-completion-webhook-url", "", "The URL a JSON record is posted to when an EphemeralRunner completes a job or its pod fails while running one, with the runner set, job request ID, duration and result of the job. Records are delivered in the background with retries and dropped when delivery keeps failing. Empty disables the notifications.")
+	flag.IntVar(&runnerCompletionWebhookQueueSize, "runner-completion-webhook-queue-size", 1000, "The number of completion records queued for delivery to runner-completion-webhook-url. Records are dropped when the queue is full.")
+	flag.BoolVar(&runnerNodeAnnotation, "runner-node-annotation", false, "Annotate each EphemeralRunner with the node its pod was scheduled to, as actions.github.com/node. The annotation is updated when the pod is re-created on another node.")
+	flag.BoolVar(&runnerUnschedulableRetry, "runner-unschedulable-retry", false, "Delete EphemeralRunner pods that are unschedulable for longer than the runner-unschedulable-threshold, so they are re-created after the pod creation backoff. Each retry counts as a pod failure.")
+	flag.DurationVar(&runnerSetFinalizerTimeout, "runner-set-finalizer-timeout", 0, "How long a deleted EphemeralRunnerSet waits for its runners to be removed from GitHub before deleting them without removing them from GitHub, e.g. when GitHub can't be reached. Set to 0 to wait forever.")
+	flag.StringVar(&runnerDefaultCPURequest, "runner-default-cpu-request", "", "The CPU request of the runner container of EphemeralRunner pods whose template doesn't set one, e.g. 500m.")
+	flag.StringVar(&runnerDefaultMemoryRequest, "runner-default-memory-request", "", "The memory request of the runner container of EphemeralRunner pods whose template doesn't set one, e.g. 1Gi.")
+	flag.StringVar(&runnerDefaultCPULimit, "runner-default-cpu-limit", "", "The CPU limit of the runner container of EphemeralRunner pods whose template doesn't set one, e.g. 2.")
+	flag.StringVar(&runnerDefaultMemoryLimit, "runner-default-memory-limit", "", "The memory limit of the runner container of EphemeralRunner pods whose template doesn't set one, e.g. 4Gi.")
+	flag.BoolVar(&runnerDefaultRunAsNonRoot, "runner-default-run-as-non-root", false, "Set runAsNonRoot on the runner container of EphemeralRunner pods whose template doesn't set it on the container or the pod.")
+	flag.BoolVar(&runnerDefaultReadOnlyRootFilesystem, "runner-default-read-only-root-filesystem", false, "Set readOnlyRootFilesystem on the runner container of EphemeralRunner pods whose template doesn't set it.")
+	flag.StringVar(&runnerDefaultSeccompProfile, "runner-default-seccomp-profile", "", `The seccomp profile type of the runner container of EphemeralRunner pods whose template doesn't set one on the container or the pod. Valid values are "RuntimeDefault" and "Unconfined".`)
+	flag.StringVar(&runnerDefaultDropCapabilities, "runner-default-drop-capabilities", "", "A comma separated list of capabilities dropped from the runner container of EphemeralRunner pods whose template doesn't set capabilities, e.g. ALL.")
+	flag.BoolVar(&runnerForbidPrivileged, "runner-forbid-privileged", false, "Remove privileged mode from the containers of EphemeralRunner pods whose template requests it, with a warning event on the EphemeralRunnerSet.")
+	flag.StringVar(&runnerImagePullPolicy, "runner-image-pull-policy", "", `The image pull policy set on the runner container of EphemeralRunner pods, overriding the one of the pod template. Valid values are "Always", "IfNotPresent" and "Never". Set to empty to keep the pod template value.`)
+	flag.BoolVar(&runnerImagePullPolicyKeepTemplate, "runner-image-pull-policy-keep-template", false, "Only set the runner-image-pull-policy on runner containers whose pod template doesn't set an image pull policy.")
+	flag.BoolVar(&runnerDefaultWorkVolume, "runner-default-work-volume", false, "Add an emptyDir volume mounted at the runner work directory to the runner container of EphemeralRunner pods that have nothing mounted there.")
+	flag.StringVar(&runnerDefaultWorkVolumeSizeLimit, "runner-default-work-volume-size-limit", "", "The size limit of the emptyDir volume added by runner-default-work-volume, e.g. 20Gi. Unlimited when empty.")
+	flag.StringVar(&runnerWorkDir, "runner-work-dir", actionsgithubcom.DefaultRunnerWorkDir, "The work directory of the runner container of EphemeralRunner pods, used by runner-default-work-volume.")
+	flag.StringVar(&windowsRunnerWorkDir, "windows-runner-work-dir", actionsgithubcom.DefaultWindowsRunnerWorkDir, "The work directory of the runner container of Windows EphemeralRunner pods, used by runner-default-work-volume.")
+	flag.StringVar(&runnerPreflightCheckImage, "runner-preflight-check-image", actionsgithubcom.DefaultPreflightCheckImage, "The image of the preflight check init container added to EphemeralRunner pods with PreflightCheck.")
+	flag.StringVar(&runnerPreflightCheckCommand, "runner-preflight-check-command", "", "The shell command run by the preflight check init container, with the GitHub config URL in the GITHUB_CONFIG_URL environment variable. A non-zero exit code fails the EphemeralRunner. Defaults to a curl request to the GitHub config URL.")
+	flag.StringVar(&windowsRunnerPreflightCheckImage, "windows-runner-preflight-check-image", actionsgithubcom.DefaultWindowsPreflightCheckImage, "The image of the preflight check init container added to Windows EphemeralRunner pods with PreflightCheck.")
+	flag.StringVar(&windowsRunnerPreflightCheckCommand, "windows-runner-preflight-check-command", "", "The PowerShell command run by the preflight check init container of Windows EphemeralRunner pods. Defaults to a web request to the GitHub config URL.")
+	flag.DurationVar(&runnerSetOrphanedProxySecretSweepInterval, "runner-set-orphaned-proxy-secret-sweep-interval", 0, "How often the proxy secrets of EphemeralRunnerSets that no longer exist are deleted. Only secrets labeled by the controller are deleted. Set to 0 to disable the sweep.")
+	flag.StringVar(&runnerSetSelector, "runner-set-selector", "", "A label selector restricting the EphemeralRunnerSets reconciled by this controller, e.g. team=frontend, to shard runner sets across controller deployments. EphemeralRunnerSets that don't match are ignored. Reconciles all EphemeralRunnerSets when empty.")
+	flag.DurationVar(&runnerSetStartupScaleDownDelay, "runner-set-startup-scale-down-delay", 0, "How long EphemeralRunnerSets are not scaled down after the controller starts or acquires the leader election, so the runner counts don't go down and back up while the new leader catches up with the existing EphemeralRunners. Scaling up is not deferred. Set to 0 to disable the delay.")
+	flag.IntVar(&autoscalingRunnerSetMaxConcurrentReconciles, "autoscaling-runner-set-max-concurrent-reconciles", 1, "The number of AutoscalingRunnerSets reconciled in parallel.")
+	flag.IntVar(&runnerSetMaxConcurrentReconciles, "runner-set-max-concurrent-reconciles", 1, "The number of EphemeralRunnerSets reconciled in parallel.")
+	flag.IntVar(&runnerMaxConcurrentReconciles, "runner-max-concurrent-reconciles", 1, "The number of EphemeralRunners reconciled in parallel.")
+	flag.BoolVar(&runnerInventoryEndpoint, "runner-inventory-endpoint", false, "Serve the EphemeralRunners of each EphemeralRunnerSet as JSON at /debug/runners on the metrics server, read from the cache of the controller. Only names, phases, runner IDs and job request IDs are served.")
+	flag.Float64Var(&runnerDeregistrationRateLimit, "runner-deregistration-rate-limit", 0, "The number of runners per second each runner scale set may remove from GitHub, to pace mass scale-downs. Scale-down itself is paced by the limit: idle runners are only deleted once removed from GitHub, so runners without a token are kept until one is available. Set to 0 to disable the limit.")
+	flag.IntVar(&runnerDeregistrationBurst, "runner-deregistration-burst", 10, "The number of runners each runner scale set may remove from GitHub at once before runner-deregistration-rate-limit applies.")
+	flag.IntVar(&maxRunnersPerNamespace, "max-runners-per-namespace", 0, "The maximum number of EphemeralRunners of all runner sets in a namespace. Scale ups are limited to the runners left in the namespace, without deleting existing runners. Failed runners retained for inspection are not counted. The limit is enforced by each controller process on its own, so controller deployments sharded with --runner-set-selector can exceed it together. Set to 0 to disable the limit.")
+	flag.StringVar(&githubPathPrefix, "github-path-prefix", "", "The path GitHub Enterprise Server is served under, e.g. /github behind a reverse proxy. The GitHub config URLs must be under the prefix, e.g. https://ghes.example.com/github/org, and the API is requested under the prefix as well. Empty when GitHub Enterprise Server is served at the root of its host.")
+	flag.DurationVar(&runnerScaleSetCheckInterval, "runner-scale-set-check-interval", 10*time.Minute, "How often the runner scale set of each AutoscalingRunnerSet is checked to still exist in GitHub. A runner scale set deleted in GitHub is reported by the ScaleSetMissing condition of the AutoscalingRunnerSet. Set to 0 to disable the check.")
+	flag.IntVar(&listenerScaleSetRegistrationRetries, "listener-scale-set-registration-retries", 0, "How many times the runner scale set of an AutoscalingListener is checked to be registered with GitHub, 10 seconds apart, before its listener pod is created, so fresh listeners don't poll a runner scale set that is not registered yet. The wait is reported by the ScaleSetRegistered condition of the AutoscalingListener, and the listener pod is created anyway once the retries are exhausted. Set to 0 to disable the check.")
+	flag.BoolVar(&skipRunnerDeregistration, "skip-runner-deregistration", false, "Clean up deleted AutoscalingRunnerSets, EphemeralRunnerSets and EphemeralRunners in the cluster only, without removing their runners and runner scale sets from GitHub. Only meant for throwaway clusters torn down wholesale, the runners and runner scale sets remain registered with GitHub.")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only log the ephemeral runners the EphemeralRunnerSet controller would create and delete, without creating or deleting them. This is a debugging tool, do not enable it in production.")
 	flag.Parse()
 
+	if runnerSetRequeueJitter < 0 || runnerSetRequeueJitter > 1 {
+		fmt.Fprintf(os.Stderr, "Error: runner-set-requeue-jitter must be between 0 and 1, got %v\n", runnerSetRequeueJitter)
+		os.Exit(1)
+	}
+
+	for name, value := range map[string]int{
+		"autoscaling-runner-set-max-concurrent-reconciles": autoscalingRunnerSetMaxConcurrentReconciles,
+		"runner-set-max-concurrent-reconciles":             runnerSetMaxConcurrentReconciles,
+		"runner-max-concurrent-reconciles":                 runnerMaxConcurrentReconciles,
+		"runner-deregistration-burst":                      runnerDeregistrationBurst,
+	} {
+		if value < 1 {
+			fmt.Fprintf(os.Stderr, "Error: %s must be at least 1, got %d\n", name, value)
+			os.Exit(1)
+		}
+	}
+
+	runnerDefaultResources, err := defaultResourceRequirements(runnerDefaultCPURequest, runnerDefaultMemoryRequest, runnerDefaultCPULimit, runnerDefaultMemoryLimit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	runnerDefaultSecurityContext, err := defaultSecurityContext(runnerDefaultRunAsNonRoot, runnerDefaultReadOnlyRootFilesystem, runnerDefaultSeccompProfile, runnerDefaultDropCapabilities)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var runnerDefaultWorkVolumeSize *resource.Quantity
+	if runnerDefaultWorkVolumeSizeLimit != "" {
+		quantity, err := resource.ParseQuantity(runnerDefaultWorkVolumeSizeLimit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: runner-default-work-volume-size-limit is not a valid quantity: %v\n", err)
+			os.Exit(1)
+		}
+		runnerDefaultWorkVolumeSize = &quantity
+	}
+
+	var runnerSetLabelSelector labels.Selector
+	if runnerSetSelector != "" {
+		runnerSetLabelSelector, err = labels.Parse(runnerSetSelector)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: runner-set-selector is not a valid label selector: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch corev1.PullPolicy(runnerImagePullPolicy) {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: runner-image-pull-policy must be one of Always, IfNotPresent or Never, got %q\n", runnerImagePullPolicy)
+		os.Exit(1)
+	}
+
 	log, err := logging.NewLogger(logLevel, logFormat)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: creating logger: %v\n", err)
@@ -141,6 +320,14 @@ func main() {
 	}
 	c.Log = &log
 
+	if dryRun {
+		log.Info("WARNING: running in dry run mode, the EphemeralRunnerSet controller does not create or delete ephemeral runners")
+	}
+
+	if skipRunnerDeregistration {
+		log.Info("WARNING: runner deregistration is skipped, deleted runners and runner scale sets remain registered with GitHub")
+	}
+
 	if !autoScalingRunnerSetOnly {
 		ghClient, err = c.NewClient()
 		if err != nil {
@@ -151,11 +338,6 @@ func main() {
 
 	ctrl.SetLogger(log)
 
-	if autoScalingRunnerSetOnly {
-		// We don't support metrics for AutoRunnerScaleSet for now
-		metricsAddr = "0"
-	}
-
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -175,9 +357,15 @@ func main() {
 		ghClient,
 	)
 
+	var actionsClientOptions []actions.ClientOption
+	if githubPathPrefix != "" {
+		actionsClientOptions = append(actionsClientOptions, actions.WithGitHubPathPrefix(githubPathPrefix))
+	}
+
 	actionsMultiClient := actions.NewMultiClient(
 		"actions-runner-controller/"+build.Version,
 		log.WithName("actions-clients"),
+		actionsClientOptions...,
 	)
 
 	if !autoScalingRunnerSetOnly {
@@ -332,7 +520,7 @@ func main() {
 		var mgrPod corev1.Pod
 		err = mgr.GetAPIReader().Get(context.Background(), types.NamespacedName{Namespace: mgrPodNamespace, Name: mgrPodName}, &mgrPod)
 		if err != nil {
-			log.Error(err, fmt.Sprintf("unable to obtain manager pod: %s (%s)", mgrPodName, mgrPodNamespace))
+			log.Error(err, "unable to obtain manager pod", "namespace", mgrPodNamespace, "name", mgrPodName)
 			os.Exit(1)
 		}
 
@@ -358,34 +546,101 @@ func main() {
 			DefaultRunnerScaleSetListenerImage: mgrContainer.Image,
 			ActionsClient:                      actionsMultiClient,
 			DefaultRunnerScaleSetListenerImagePullSecrets: autoScalerImagePullSecrets,
+			MaxConcurrentReconciles:                       autoscalingRunnerSetMaxConcurrentReconciles,
+			ScaleSetCheckInterval:                         runnerScaleSetCheckInterval,
+			SkipDeregistration:                            skipRunnerDeregistration,
 		}).SetupWithManager(mgr); err != nil {
 			log.Error(err, "unable to create controller", "controller", "AutoscalingRunnerSet")
 			os.Exit(1)
 		}
 
+		deregistrationLimiter := actionsgithubcom.NewDeregistrationLimiter(runnerDeregistrationRateLimit, runnerDeregistrationBurst)
+
+		completionNotifier := actionsgithubcom.NewCompletionNotifier(runnerCompletionWebhookURL, runnerCompletionWebhookQueueSize, log.WithName("CompletionNotifier"))
+		if completionNotifier != nil {
+			if err = mgr.Add(completionNotifier); err != nil {
+				log.Error(err, "unable to add the completion notifier")
+				os.Exit(1)
+			}
+		}
+
 		if err = (&actionsgithubcom.EphemeralRunnerReconciler{
-			Client:        mgr.GetClient(),
-			Log:           log.WithName("EphemeralRunner"),
-			Scheme:        mgr.GetScheme(),
-			ActionsClient: actionsMultiClient,
+			Client:          mgr.GetClient(),
+			Log:             log.WithName("EphemeralRunner"),
+			Scheme:          mgr.GetScheme(),
+			ActionsClient:   actionsMultiClient,
+			FailureLogLines: runnerFailureLogLines,
+
+			RegistrationReadinessGate:  runnerRegistrationReadinessGate,
+			PodCreationBackoffMax:      runnerPodCreationBackoffMax,
+			RemovedRunnerCheckInterval: runnerRemovedCheckInterval,
+			ScheduleMetricsNodeLabel:   runnerScheduleMetricsNodeLabel,
+			UnschedulableThreshold:     runnerUnschedulableThreshold,
+			UnschedulableRetry:         runnerUnschedulableRetry,
+			JobEvents:                  runnerJobEvents,
+			NodeAnnotation:             runnerNodeAnnotation,
+			CompletionNotifier:         completionNotifier,
+			PreflightCheckImage:        runnerPreflightCheckImage,
+			PreflightCheckCommand:      preflightCheckCommand(runnerPreflightCheckCommand, "sh", "-c"),
+			MaxConcurrentReconciles:    runnerMaxConcurrentReconciles,
+			DeregistrationLimiter:      deregistrationLimiter,
+			SkipDeregistration:         skipRunnerDeregistration,
+
+			WindowsPreflightCheckImage:   windowsRunnerPreflightCheckImage,
+			WindowsPreflightCheckCommand: preflightCheckCommand(windowsRunnerPreflightCheckCommand, "pwsh", "-Command"),
 		}).SetupWithManager(mgr); err != nil {
 			log.Error(err, "unable to create controller", "controller", "EphemeralRunner")
 			os.Exit(1)
 		}
 
 		if err = (&actionsgithubcom.EphemeralRunnerSetReconciler{
-			Client:        mgr.GetClient(),
-			Log:           log.WithName("EphemeralRunnerSet"),
-			Scheme:        mgr.GetScheme(),
-			ActionsClient: actionsMultiClient,
+			Client:           mgr.GetClient(),
+			Log:              log.WithName("EphemeralRunnerSet"),
+			Scheme:           mgr.GetScheme(),
+			ActionsClient:    actionsMultiClient,
+			RequeueInterval:  runnerSetRequeueInterval,
+			RequeueJitter:    runnerSetRequeueJitter,
+			DryRun:           dryRun,
+			FinalizerTimeout: runnerSetFinalizerTimeout,
+
+			DefaultRunnerResources:            runnerDefaultResources,
+			DefaultRunnerSecurityContext:      runnerDefaultSecurityContext,
+			ForbidPrivilegedRunners:           runnerForbidPrivileged,
+			RunnerImagePullPolicy:             corev1.PullPolicy(runnerImagePullPolicy),
+			RunnerImagePullPolicyKeepTemplate: runnerImagePullPolicyKeepTemplate,
+			DefaultWorkVolume:                 runnerDefaultWorkVolume,
+			DefaultWorkVolumeSizeLimit:        runnerDefaultWorkVolumeSize,
+			RunnerWorkDir:                     runnerWorkDir,
+			WindowsRunnerWorkDir:              windowsRunnerWorkDir,
+			OrphanedProxySecretSweepInterval:  runnerSetOrphanedProxySecretSweepInterval,
+			MaxConcurrentReconciles:           runnerSetMaxConcurrentReconciles,
+			Selector:                          runnerSetLabelSelector,
+			DeregistrationLimiter:             deregistrationLimiter,
+			MaxRunnersPerNamespace:            maxRunnersPerNamespace,
+			SkipDeregistration:                skipRunnerDeregistration,
+			StartupScaleDownDelay:             runnerSetStartupScaleDownDelay,
 		}).SetupWithManager(mgr); err != nil {
 			log.Error(err, "unable to create controller", "controller", "EphemeralRunnerSet")
 			os.Exit(1)
 		}
+		if runnerInventoryEndpoint {
+			if err = mgr.AddMetricsExtraHandler(actionsgithubcom.RunnerInventoryPath, &actionsgithubcom.RunnerInventoryHandler{
+				Client: mgr.GetClient(),
+				Log:    log.WithName("RunnerInventory"),
+			}); err != nil {
+				log.Error(err, "unable to add the runner inventory endpoint")
+				os.Exit(1)
+			}
+		}
 		if err = (&actionsgithubcom.AutoscalingListenerReconciler{
-			Client: mgr.GetClient(),
-			Log:    log.WithName("AutoscalingListener"),
-			Scheme: mgr.GetScheme(),
+			Client:            mgr.GetClient(),
+			Log:               log.WithName("AutoscalingListener"),
+			Scheme:            mgr.GetScheme(),
+			ListenerLogFormat: logFormat,
+			GitHubPathPrefix:  githubPathPrefix,
+
+			ActionsClient:               actionsMultiClient,
+			ScaleSetRegistrationRetries: listenerScaleSetRegistrationRetries,
 		}).SetupWithManager(mgr); err != nil {
 			log.Error(err, "unable to create controller", "controller", "AutoscalingListener")
 			os.Exit(1)
@@ -403,6 +658,13 @@ func main() {
 			log.Error(err, "unable to create webhook server", "webhook", "PodRunnerTokenInjector")
 			os.Exit(1)
 		}
+
+		if webhookCertReloadInterval > 0 {
+			if err := setupWebhookCertReloader(mgr, webhookCertReloadInterval, log.WithName("webhook-cert-reloader")); err != nil {
+				log.Error(err, "unable to set up the webhook certificate reloader")
+				os.Exit(1)
+			}
+		}
 	}
 
 	log.Info("starting manager")
@@ -412,6 +674,35 @@ func main() {
 	}
 }
 
+// setupWebhookCertReloader makes the webhook server serve the certificate of its certificate directory,
+// reloaded every interval.
+func setupWebhookCertReloader(mgr ctrl.Manager, interval time.Duration, log logr.Logger) error {
+	server := mgr.GetWebhookServer()
+
+	certDir := server.CertDir
+	if certDir == "" {
+		certDir = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+	}
+	certName := server.CertName
+	if certName == "" {
+		certName = "tls.crt"
+	}
+	keyName := server.KeyName
+	if keyName == "" {
+		keyName = "tls.key"
+	}
+
+	reloader, err := certreloader.New(filepath.Join(certDir, certName), filepath.Join(certDir, keyName), interval, log)
+	if err != nil {
+		return err
+	}
+	server.TLSOpts = append(server.TLSOpts, func(config *tls.Config) {
+		config.GetCertificate = reloader.GetCertificate
+	})
+
+	return mgr.Add(reloader)
+}
+
 type commaSeparatedStringSlice []string
 
 func (s *commaSeparatedStringSlice) String() string {
@@ -428,3 +719,77 @@ func (s *commaSeparatedStringSlice) Set(value string) error {
 	}
 	return nil
 }
+
+// preflightCheckCommand returns the command running the preflight check command with the shell. Empty uses the default command.
+func preflightCheckCommand(command string, shell ...string) []string {
+	if command == "" {
+		return nil
+	}
+	return append(shell, command)
+}
+
+// defaultResourceRequirements parses the default resources of runner containers. Empty values are left unset.
+func defaultResourceRequirements(cpuRequest, memoryRequest, cpuLimit, memoryLimit string) (corev1.ResourceRequirements, error) {
+	var requirements corev1.ResourceRequirements
+
+	for _, r := range []struct {
+		list  *corev1.ResourceList
+		name  corev1.ResourceName
+		value string
+		flag  string
+	}{
+		{&requirements.Requests, corev1.ResourceCPU, cpuRequest, "runner-default-cpu-request"},
+		{&requirements.Requests, corev1.ResourceMemory, memoryRequest, "runner-default-memory-request"},
+		{&requirements.Limits, corev1.ResourceCPU, cpuLimit, "runner-default-cpu-limit"},
+		{&requirements.Limits, corev1.ResourceMemory, memoryLimit, "runner-default-memory-limit"},
+	} {
+		if r.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(r.value)
+		if err != nil {
+			return corev1.ResourceRequirements{}, fmt.Errorf("%s is not a valid quantity: %v", r.flag, err)
+		}
+		if *r.list == nil {
+			*r.list = corev1.ResourceList{}
+		}
+		(*r.list)[r.name] = quantity
+	}
+
+	return requirements, nil
+}
+
+// defaultSecurityContext returns the default security context of runner containers, or nil if no default is set.
+func defaultSecurityContext(runAsNonRoot, readOnlyRootFilesystem bool, seccompProfile, dropCapabilities string) (*corev1.SecurityContext, error) {
+	securityContext := new(corev1.SecurityContext)
+	if runAsNonRoot {
+		securityContext.RunAsNonRoot = &runAsNonRoot
+	}
+	if readOnlyRootFilesystem {
+		securityContext.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+	}
+
+	switch profileType := corev1.SeccompProfileType(seccompProfile); profileType {
+	case "":
+	case corev1.SeccompProfileTypeRuntimeDefault, corev1.SeccompProfileTypeUnconfined:
+		securityContext.SeccompProfile = &corev1.SeccompProfile{Type: profileType}
+	default:
+		return nil, fmt.Errorf("runner-default-seccomp-profile must be %q or %q, got %q", corev1.SeccompProfileTypeRuntimeDefault, corev1.SeccompProfileTypeUnconfined, seccompProfile)
+	}
+
+	for _, capability := range strings.Split(dropCapabilities, ",") {
+		if capability = strings.TrimSpace(capability); capability == "" {
+			continue
+		}
+		if securityContext.Capabilities == nil {
+			securityContext.Capabilities = new(corev1.Capabilities)
+		}
+		securityContext.Capabilities.Drop = append(securityContext.Capabilities.Drop, corev1.Capability(capability))
+	}
+
+	if securityContext.RunAsNonRoot == nil && securityContext.ReadOnlyRootFilesystem == nil &&
+		securityContext.SeccompProfile == nil && securityContext.Capabilities == nil {
+		return nil, nil
+	}
+	return securityContext, nil
+}
@@ -0,0 +1,105 @@
+// Package certreloader serves a TLS certificate that is reloaded in place when its files change,
+// e.g. when cert-manager rotates the certificate of a mounted secret.
+package certreloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Reloader holds the certificate loaded from a certificate and key file pair.
+// The files are read again every interval, and the certificate is replaced once both changed files form a valid pair.
+// The previous certificate keeps being served until then, so TLS handshakes never fail because of a rotation.
+type Reloader struct {
+	certPath string
+	keyPath  string
+	interval time.Duration
+	log      logr.Logger
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certData []byte
+	keyData  []byte
+}
+
+// New returns a Reloader of the certificate and key files, which must already hold a valid key pair.
+func New(certPath, keyPath string, interval time.Duration, log logr.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+		interval: interval,
+		log:      log,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. It is meant to be used as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload reads the certificate and key files and replaces the current certificate if they changed.
+// It reports whether the certificate was replaced. The current certificate is kept on errors.
+func (r *Reloader) Reload() (bool, error) {
+	certData, err := os.ReadFile(r.certPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	keyData, err := os.ReadFile(r.keyPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	r.mu.RLock()
+	unchanged := bytes.Equal(certData, r.certData) && bytes.Equal(keyData, r.keyData)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return false, fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.certData = certData
+	r.keyData = keyData
+	return true, nil
+}
+
+// Start reloads the certificate every interval until the context is done.
+// Reload errors are logged, and retried at the next interval.
+func (r *Reloader) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		reloaded, err := r.Reload()
+		if err != nil {
+			r.log.Error(err, "Failed to reload the certificate, keeping the current one", "cert", r.certPath, "key", r.keyPath)
+			return
+		}
+		if reloaded {
+			r.log.Info("Reloaded the certificate", "cert", r.certPath, "key", r.keyPath)
+		}
+	}, r.interval)
+	return nil
+}
+
+// NeedLeaderElection reports that the certificate is reloaded on every replica, which all serve webhooks.
+func (r *Reloader) NeedLeaderElection() bool {
+	return false
+}